@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	webhookagent "github.com/sekia-ai/sekia/internal/webhook"
+)
+
+var version = "dev"
+
+func main() {
+	var cfgFile string
+	var instanceName string
+
+	rootCmd := &cobra.Command{
+		Use:   "sekia-webhook",
+		Short: "sekia webhook agent — receives webhooks from any service and maps them to events",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := zerolog.New(
+				zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339},
+			).With().Timestamp().Logger()
+
+			cfg, err := webhookagent.LoadConfig(cfgFile, instanceName)
+			if err != nil {
+				return fmt.Errorf("load config: %w", err)
+			}
+
+			wa := webhookagent.NewAgent(cfg, cfgFile, instanceName, logger)
+			return wa.Run()
+		},
+	}
+
+	rootCmd.Version = version
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path")
+	rootCmd.PersistentFlags().StringVar(&instanceName, "name", "", "instance name for multi-tenant setups (e.g., webhook-ops); changes config file and NATS registration")
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
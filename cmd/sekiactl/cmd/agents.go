@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -11,7 +12,7 @@ import (
 )
 
 func newAgentsCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "agents",
 		Short: "List registered agents",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -28,9 +29,13 @@ func newAgentsCmd() *cobra.Command {
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 			fmt.Fprintln(w, "NAME\tVERSION\tSTATUS\tEVENTS\tERRORS\tLAST HEARTBEAT")
 			for _, a := range resp.Agents {
-				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+				errs := strconv.FormatInt(a.Errors, 10)
+				if a.CommandsDenied > 0 {
+					errs += fmt.Sprintf(" (%d denied)", a.CommandsDenied)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
 					a.Name, a.Version, a.Status,
-					a.EventsProcessed, a.Errors,
+					a.EventsProcessed, errs,
 					a.LastHeartbeat.Format("15:04:05"),
 				)
 			}
@@ -38,4 +43,6 @@ func newAgentsCmd() *cobra.Command {
 			return nil
 		},
 	}
+	cmd.AddCommand(newAgentsCredentialsCmd())
+	return cmd
 }
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
 )
 
 // apiClient returns an http.Client that connects over the Unix socket.
@@ -49,3 +52,47 @@ func apiPost(path string, dest any) error {
 	}
 	return nil
 }
+
+// apiPostJSON performs a POST with a JSON body and decodes the JSON response.
+// Error responses include the body sekiad returned.
+func apiPostJSON(path string, body, dest any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := apiClient().Post("http://sekiad"+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cannot connect to sekiad at %s: %w", socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("sekiad returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if dest != nil {
+		return json.NewDecoder(resp.Body).Decode(dest)
+	}
+	return nil
+}
+
+// apiDelete performs a DELETE and decodes the JSON response.
+func apiDelete(path string, dest any) error {
+	req, err := http.NewRequest(http.MethodDelete, "http://sekiad"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot connect to sekiad at %s: %w", socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sekiad returned HTTP %d", resp.StatusCode)
+	}
+	if dest != nil {
+		return json.NewDecoder(resp.Body).Decode(dest)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+)
+
+func newAgentsCredentialsCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "Manage per-agent NATS credentials",
+		Long: `Manages the nkeys agents authenticate to sekiad's NATS server with. An agent
+with credentials may publish only its own events, heartbeats, registration
+and command results, and subscribe only to its own commands and config
+reloads. Each agent holds its seed file, <name>.nk; sekiad loads the public
+keys from agents.toml (nats.agents_file).`,
+	}
+	cmd.PersistentFlags().StringVar(&dir, "dir", "", "credentials directory (default: ~/.config/sekia/nats)")
+	store := func() secrets.CredentialStore {
+		if dir != "" {
+			return secrets.CredentialStore{Dir: dir}
+		}
+		return secrets.DefaultCredentialStore()
+	}
+
+	cmd.AddCommand(newCredentialsCreateCmd(store))
+	cmd.AddCommand(newCredentialsListCmd(store))
+	cmd.AddCommand(newCredentialsRevokeCmd(store))
+
+	return cmd
+}
+
+func newCredentialsCreateCmd(store func() secrets.CredentialStore) *cobra.Command {
+	var sources []string
+	var commands bool
+
+	cmd := &cobra.Command{
+		Use:   "create <agent-name>",
+		Short: "Create NATS credentials for an agent",
+		Long: `Generates an nkey for the agent, writes its seed to <dir>/<agent-name>.nk and
+adds its public key to <dir>/agents.toml. The agent may publish events on
+sekia.events.<source> for each --source, which defaults to the agent name
+without its "-agent" suffix. With --commands it may also send commands to
+any agent, which the MCP server needs. Point the agent's nats.credentials at
+the seed file and sekiad's nats.agents_file at agents.toml, then reload
+sekiad (sekiactl config reload --target sekiad).`,
+		Example: `  sekiactl agents credentials create github-agent
+  sekiactl agents credentials create webhook-agent --source webhook --source stripe
+  sekiactl agents credentials create mcp --source '*' --commands`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if len(sources) == 0 {
+				sources = []string{strings.TrimSuffix(name, "-agent")}
+			}
+			cs := store()
+			a, err := cs.Create(name, sources, commands)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Credentials for %s (%s) written to: %s\n", a.Name, a.NKey, cs.SeedPath(name))
+			fmt.Printf("Event sources: %s\n", strings.Join(a.Sources, ", "))
+			if a.Commands {
+				fmt.Println("May send commands to any agent")
+			}
+			fmt.Printf("Agents file: %s (apply with: sekiactl config reload --target sekiad)\n", cs.AgentsPath())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sources, "source", nil, "event source the agent may publish, or '*' for any (repeatable)")
+	cmd.Flags().BoolVar(&commands, "commands", false, "allow sending commands to any agent (for the MCP server)")
+	return cmd
+}
+
+func newCredentialsListCmd(store func() secrets.CredentialStore) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List agents with NATS credentials",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			creds, err := store().List()
+			if err != nil {
+				return err
+			}
+			if len(creds.Agents) == 0 {
+				fmt.Println("No agent credentials.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSOURCES\tNKEY\tCREATED")
+			for _, a := range creds.Agents {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+					a.Name, strings.Join(a.Sources, ","), a.NKey,
+					a.Created.Format("2006-01-02"),
+				)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newCredentialsRevokeCmd(store func() secrets.CredentialStore) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <agent-name>",
+		Short: "Revoke an agent's NATS credentials",
+		Long: `Removes the agent from agents.toml and deletes its seed file. sekiad
+disconnects the agent on its next config reload.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cs := store()
+			if err := cs.Revoke(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Revoked %s. Disconnect it with: sekiactl config reload --target sekiad\n", args[0])
+			return nil
+		},
+	}
+}
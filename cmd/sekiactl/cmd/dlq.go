@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newDLQCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dlq",
+		Short: "Inspect and replay failed events and commands",
+	}
+
+	cmd.AddCommand(newDLQListCmd())
+	cmd.AddCommand(newDLQShowCmd())
+	cmd.AddCommand(newDLQReplayCmd())
+	cmd.AddCommand(newDLQPurgeCmd())
+
+	// Default to list when no subcommand given.
+	cmd.RunE = newDLQListCmd().RunE
+
+	return cmd
+}
+
+// dlqFilterFlags holds the --workflow/--agent flags shared by dlq subcommands.
+type dlqFilterFlags struct {
+	workflow string
+	agent    string
+}
+
+func (f *dlqFilterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.workflow, "workflow", "", "only dead letters from this workflow")
+	cmd.Flags().StringVar(&f.agent, "agent", "", "only dead letters from this agent")
+}
+
+func (f *dlqFilterFlags) query() url.Values {
+	q := url.Values{}
+	if f.workflow != "" {
+		q.Set("workflow", f.workflow)
+	}
+	if f.agent != "" {
+		q.Set("agent", f.agent)
+	}
+	return q
+}
+
+func (f *dlqFilterFlags) set() bool {
+	return f.workflow != "" || f.agent != ""
+}
+
+func newDLQListCmd() *cobra.Command {
+	var (
+		filter dlqFilterFlags
+		limit  int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List dead letters",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q := filter.query()
+			if limit > 0 {
+				q.Set("limit", strconv.Itoa(limit))
+			}
+			var resp protocol.DLQResponse
+			if err := apiGet(withQuery("/api/v1/dlq", q), &resp); err != nil {
+				return err
+			}
+
+			if len(resp.Entries) == 0 {
+				fmt.Println("Dead-letter queue is empty.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SEQ\tKIND\tFROM\tSUBJECT\tATTEMPTS\tFAILED AT\tERROR")
+			for _, dl := range resp.Entries {
+				from := dl.Workflow
+				if dl.Kind == protocol.DeadLetterCommand {
+					from = dl.Agent
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					dl.Seq, dl.Kind, from, dl.Subject, dl.Attempts,
+					dl.FailedAt.Local().Format("2006-01-02 15:04:05"),
+					truncate(firstLine(dl.Error), 60),
+				)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+
+	filter.register(cmd)
+	cmd.Flags().IntVar(&limit, "limit", 0, "maximum number of entries to show (0 = all)")
+	return cmd
+}
+
+func newDLQShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <seq>",
+		Short: "Show a dead letter, including the original message",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			seq, err := parseSeq(args[0])
+			if err != nil {
+				return err
+			}
+			var dl protocol.DeadLetter
+			if err := apiGet(fmt.Sprintf("/api/v1/dlq/%d", seq), &dl); err != nil {
+				return err
+			}
+
+			fmt.Printf("Seq:       %d\n", dl.Seq)
+			fmt.Printf("Kind:      %s\n", dl.Kind)
+			if dl.Workflow != "" {
+				fmt.Printf("Workflow:  %s\n", dl.Workflow)
+			}
+			if dl.Agent != "" {
+				fmt.Printf("Agent:     %s\n", dl.Agent)
+			}
+			fmt.Printf("Subject:   %s\n", dl.Subject)
+			if dl.Handler != "" {
+				fmt.Printf("Handler:   %s\n", dl.Handler)
+			}
+			fmt.Printf("Attempts:  %d\n", dl.Attempts)
+			fmt.Printf("Failed at: %s\n", dl.FailedAt.Local().Format("2006-01-02 15:04:05"))
+			fmt.Printf("Error:     %s\n", dl.Error)
+
+			var data any
+			if err := json.Unmarshal(dl.Data, &data); err == nil {
+				pretty, _ := json.MarshalIndent(data, "", "  ")
+				fmt.Printf("Data:\n%s\n", pretty)
+			}
+			return nil
+		},
+	}
+}
+
+func newDLQReplayCmd() *cobra.Command {
+	var (
+		filter dlqFilterFlags
+		all    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay [seq...]",
+		Short: "Re-inject dead letters and remove them from the queue",
+		Long: `Replays dead letters by sequence number, or every dead letter matching
+--workflow/--agent (or --all). Failed events are delivered again to the
+workflow that failed; failed commands are re-published to their agent.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !all && !filter.set() {
+				return errors.New("specify sequence numbers, --workflow, --agent, or --all")
+			}
+
+			var resp protocol.DLQReplayResponse
+			if len(args) > 0 {
+				for _, arg := range args {
+					seq, err := parseSeq(arg)
+					if err != nil {
+						return err
+					}
+					if err := apiPost(fmt.Sprintf("/api/v1/dlq/%d/replay", seq), nil); err != nil {
+						if resp.Failed == nil {
+							resp.Failed = make(map[uint64]string)
+						}
+						resp.Failed[seq] = err.Error()
+						continue
+					}
+					resp.Replayed = append(resp.Replayed, seq)
+				}
+			} else if err := apiPost(withQuery("/api/v1/dlq/replay", filter.query()), &resp); err != nil {
+				return err
+			}
+
+			fmt.Printf("Replayed %d dead letter(s).\n", len(resp.Replayed))
+			for seq, msg := range resp.Failed {
+				fmt.Printf("  %d: %s\n", seq, msg)
+			}
+			if len(resp.Failed) > 0 {
+				return fmt.Errorf("%d dead letter(s) could not be replayed", len(resp.Failed))
+			}
+			return nil
+		},
+	}
+
+	filter.register(cmd)
+	cmd.Flags().BoolVar(&all, "all", false, "replay every dead letter")
+	return cmd
+}
+
+func newDLQPurgeCmd() *cobra.Command {
+	var (
+		filter dlqFilterFlags
+		all    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "purge [seq...]",
+		Short: "Delete dead letters without replaying them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !all && !filter.set() {
+				return errors.New("specify sequence numbers, --workflow, --agent, or --all")
+			}
+
+			var purged uint64
+			if len(args) > 0 {
+				for _, arg := range args {
+					seq, err := parseSeq(arg)
+					if err != nil {
+						return err
+					}
+					if err := apiDelete(fmt.Sprintf("/api/v1/dlq/%d", seq), nil); err != nil {
+						return fmt.Errorf("purge %d: %w", seq, err)
+					}
+					purged++
+				}
+			} else {
+				var resp protocol.DLQPurgeResponse
+				if err := apiPost(withQuery("/api/v1/dlq/purge", filter.query()), &resp); err != nil {
+					return err
+				}
+				purged = resp.Purged
+			}
+
+			fmt.Printf("Purged %d dead letter(s).\n", purged)
+			return nil
+		},
+	}
+
+	filter.register(cmd)
+	cmd.Flags().BoolVar(&all, "all", false, "purge every dead letter")
+	return cmd
+}
+
+func parseSeq(s string) (uint64, error) {
+	seq, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || seq == 0 {
+		return 0, fmt.Errorf("invalid sequence number %q", s)
+	}
+	return seq, nil
+}
+
+func withQuery(path string, q url.Values) string {
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newEventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Work with the durable event log",
+	}
+
+	cmd.AddCommand(newEventsReplayCmd())
+	cmd.AddCommand(newEventsSchemaCmd())
+
+	return cmd
+}
+
+func newEventsReplayCmd() *cobra.Command {
+	var (
+		workflow string
+		since    string
+		subject  string
+		dryRun   bool
+		limit    int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Re-process stored events through a single workflow",
+		Long: `Feeds events from the durable event log to one workflow, without
+fanning out to the others. Only events matching the workflow's handlers are
+delivered.
+
+With --dry-run, a fresh copy of the workflow processes the events and every
+sekia.publish / sekia.command call is printed instead of executed.
+
+Examples:
+  sekiactl events replay --workflow github-labeler --since 2h --dry-run
+  sekiactl events replay --workflow github-labeler --since 2024-05-01T09:00:00Z --subject sekia.events.github`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if workflow == "" {
+				return errors.New("--workflow is required")
+			}
+			start, err := parseSince(since)
+			if err != nil {
+				return err
+			}
+
+			var resp protocol.ReplayResponse
+			err = apiPostJSON("/api/v1/workflows/"+url.PathEscape(workflow)+"/replay", protocol.ReplayRequest{
+				Since:   start,
+				Subject: subject,
+				DryRun:  dryRun,
+				Limit:   limit,
+			}, &resp)
+			if err != nil {
+				return err
+			}
+
+			if !resp.DryRun {
+				fmt.Printf("Replayed %d event(s) to workflow %s.\n", resp.Events, resp.Workflow)
+				return nil
+			}
+
+			fmt.Printf("Dry run: %d event(s) through workflow %s, %d handler error(s), %d intent(s).\n",
+				resp.Events, resp.Workflow, resp.Errors, len(resp.Intents))
+			if len(resp.Intents) == 0 {
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "EVENT\tKIND\tTARGET\tPAYLOAD")
+			for _, in := range resp.Intents {
+				target := in.Subject + " " + in.EventType
+				switch in.Kind {
+				case protocol.IntentCommand:
+					target = in.Agent + " " + in.Command
+				case protocol.IntentHTTP:
+					target = in.Method + " " + in.Subject
+				}
+				payload, _ := json.Marshal(in.Payload)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", in.EventID, in.Kind, target, payload)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&workflow, "workflow", "", "workflow to replay events through (required)")
+	cmd.Flags().StringVar(&since, "since", "1h", "how far back to replay: a duration (2h, 30m) or an RFC 3339 time")
+	cmd.Flags().StringVar(&subject, "subject", "", "only replay events on this subject (wildcards allowed)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "capture and print publishes/commands instead of sending them")
+	cmd.Flags().IntVar(&limit, "limit", 0, "maximum number of events to replay (0 = no limit)")
+	return cmd
+}
+
+func newEventsSchemaCmd() *cobra.Command {
+	var version int
+
+	cmd := &cobra.Command{
+		Use:   "schema [type]",
+		Short: "Print the payload schema of an event type",
+		Long: `Prints the JSON Schema describing the payload of an event type, so
+workflow authors know which fields exist. Without a type, lists every event
+type with a schema and its latest version.
+
+Examples:
+  sekiactl events schema
+  sekiactl events schema github.issue.opened`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "TYPE\tVERSION\tDESCRIPTION")
+				for _, t := range protocol.SchemaTypes() {
+					s, _ := protocol.EventSchema(t, 0)
+					fmt.Fprintf(w, "%s\tv%d\t%s\n", t, protocol.LatestSchemaVersion(t), s.Description)
+				}
+				w.Flush()
+				return nil
+			}
+
+			s, ok := protocol.EventSchema(args[0], version)
+			if !ok {
+				if protocol.LatestSchemaVersion(args[0]) == 0 {
+					return fmt.Errorf("no schema for event type %q (see sekiactl events schema)", args[0])
+				}
+				return fmt.Errorf("event type %q has no schema version %d", args[0], version)
+			}
+			_, err := os.Stdout.Write(s.JSON())
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&version, "version", 0, "schema version to print (0 = latest)")
+	return cmd
+}
+
+// parseSince accepts a duration relative to now or an absolute RFC 3339 time.
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("--since must be positive, got %s", s)
+		}
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: use a duration like 2h or an RFC 3339 time", s)
+	}
+	return t, nil
+}
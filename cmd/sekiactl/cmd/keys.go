@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+)
+
+func newKeysCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage Ed25519 command signing keys",
+		Long: `Manages the Ed25519 keys command senders sign commands with. Each sender
+(sekiad, the MCP server) holds its own private key, <name>.key, and may sign
+only commands from the sources it was generated for. Agents hold only the
+public keys, listed in trusted_keys.toml, and cannot forge commands.`,
+	}
+	cmd.PersistentFlags().StringVar(&dir, "dir", "", "keyring directory (default: ~/.config/sekia/keys)")
+	keyring := func() secrets.Keyring {
+		if dir != "" {
+			return secrets.Keyring{Dir: dir}
+		}
+		return secrets.DefaultKeyring()
+	}
+
+	cmd.AddCommand(newKeysGenerateCmd(keyring))
+	cmd.AddCommand(newKeysListCmd(keyring))
+	cmd.AddCommand(newKeysRotateCmd(keyring))
+	cmd.AddCommand(newKeysRevokeCmd(keyring))
+
+	return cmd
+}
+
+func newKeysGenerateCmd(keyring func() secrets.Keyring) *cobra.Command {
+	var sources []string
+
+	cmd := &cobra.Command{
+		Use:   "generate <name>",
+		Short: "Generate a signing key for a command sender",
+		Long: `Generates an Ed25519 signing key, writes the private key to <dir>/<name>.key
+and adds the public key to <dir>/trusted_keys.toml. Point the sender's
+security.signing_key at the key file, and each agent's security.trusted_keys
+at a copy of the trusted keys file.`,
+		Example: `  sekiactl keys generate sekiad --source 'workflow:*' --source 'skill:*'
+  sekiactl keys generate mcp --source mcp`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kr := keyring()
+			k, err := kr.Generate(args[0], sources)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Key %s written to: %s\n", k.ID, kr.KeyPath(args[0]))
+			fmt.Printf("Trusted keys: %s (copy it to every agent)\n", kr.TrustedKeysPath())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sources, "source", nil, "command source the key may sign for, e.g. 'workflow:*' or mcp (repeatable, required)")
+	_ = cmd.MarkFlagRequired("source")
+	return cmd
+}
+
+func newKeysListCmd(keyring func() secrets.Keyring) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List signing keys and their status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kr := keyring()
+			ks, err := kr.TrustedKeys()
+			if err != nil {
+				return err
+			}
+			if len(ks.Keys) == 0 {
+				fmt.Println("No signing keys.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSOURCES\tSTATUS\tCREATED\tKEY FILE")
+			for _, k := range ks.Keys {
+				keyFile := "-"
+				if current, err := secrets.LoadSigningKey(kr.KeyPath(k.Name)); err == nil && current.ID == k.ID {
+					keyFile = filepath.Base(kr.KeyPath(k.Name))
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					k.ID, strings.Join(k.Sources, ","), k.Status,
+					k.Created.Format("2006-01-02"), keyFile,
+				)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newKeysRotateCmd(keyring func() secrets.Keyring) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate <name>",
+		Short: "Replace a sender's signing key",
+		Long: `Generates a new key for the sender, replacing <dir>/<name>.key, and marks the
+old key retired. Copy the trusted keys file to every agent before reloading
+the sender, which then signs with the new key. Agents keep accepting the
+retired key, so commands already queued still verify; revoke it once they
+have expired (security.command_ttl).`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kr := keyring()
+			next, old, err := kr.Rotate(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Key %s written to: %s\n", next.ID, kr.KeyPath(args[0]))
+			fmt.Printf("Retired %s; once queued commands have expired: sekiactl keys revoke %s\n", old.ID, old.ID)
+			return nil
+		},
+	}
+}
+
+func newKeysRevokeCmd(keyring func() secrets.Keyring) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <key-id>",
+		Short: "Revoke a signing key",
+		Long: `Marks the key revoked in the trusted keys file, so that agents reject commands
+signed with it once they have the updated file, and deletes its private key
+file if it is still the sender's current key.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kr := keyring()
+			k, err := kr.Revoke(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Revoked %s. Copy %s to every agent.\n", k.ID, kr.TrustedKeysPath())
+			return nil
+		},
+	}
+}
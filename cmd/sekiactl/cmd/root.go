@@ -26,9 +26,15 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(newStatusCmd())
 	rootCmd.AddCommand(newAgentsCmd())
 	rootCmd.AddCommand(newWorkflowsCmd())
+	rootCmd.AddCommand(newEventsCmd())
+	rootCmd.AddCommand(newDLQCmd())
+	rootCmd.AddCommand(newStateCmd())
+	rootCmd.AddCommand(newTimersCmd())
 	rootCmd.AddCommand(newSkillsCmd())
 	rootCmd.AddCommand(newConfigCmd())
 	rootCmd.AddCommand(newSecretsCmd())
+	rootCmd.AddCommand(newKeysCmd())
+	rootCmd.AddCommand(newTLSCmd())
 	rootCmd.AddCommand(newServiceCmd())
 
 	return rootCmd
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newStateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Inspect persistent workflow state (sekia.state)",
+	}
+
+	cmd.AddCommand(newStateListCmd())
+	cmd.AddCommand(newStateGetCmd())
+	cmd.AddCommand(newStateDelCmd())
+
+	return cmd
+}
+
+func newStateListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list <workflow>",
+		Short: "List a workflow's state keys and values",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp protocol.StateResponse
+			if err := apiGet("/api/v1/state/"+url.PathEscape(args[0]), &resp); err != nil {
+				return err
+			}
+
+			if len(resp.Entries) == 0 {
+				fmt.Printf("No state stored for workflow %s.\n", resp.Workflow)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tVALUE\tREVISION\tUPDATED")
+			for _, e := range resp.Entries {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+					e.Key, truncate(string(e.Value), 60), e.Revision,
+					e.Updated.Local().Format("2006-01-02 15:04:05"),
+				)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+}
+
+func newStateGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <workflow> <key>",
+		Short: "Print the value of a state key",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var e protocol.StateEntry
+			if err := apiGet(statePath(args[0], args[1]), &e); err != nil {
+				return err
+			}
+			fmt.Println(string(e.Value))
+			return nil
+		},
+	}
+}
+
+func newStateDelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "del <workflow> <key>",
+		Short: "Delete a state key",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := apiDelete(statePath(args[0], args[1]), nil); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %s from workflow %s.\n", args[1], args[0])
+			return nil
+		},
+	}
+}
+
+func statePath(workflow, key string) string {
+	return "/api/v1/state/" + url.PathEscape(workflow) + "/" + url.PathEscape(key)
+}
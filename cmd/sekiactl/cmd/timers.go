@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newTimersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "timers",
+		Short: "Inspect delayed events (sekia.after, sekia.at)",
+	}
+
+	cmd.AddCommand(newTimersListCmd())
+	cmd.AddCommand(newTimersCancelCmd())
+
+	return cmd
+}
+
+func newTimersListCmd() *cobra.Command {
+	var workflow string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List pending delayed events, soonest first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "/api/v1/timers"
+			if workflow != "" {
+				path += "?workflow=" + url.QueryEscape(workflow)
+			}
+			var resp protocol.TimersResponse
+			if err := apiGet(path, &resp); err != nil {
+				return err
+			}
+
+			if len(resp.Timers) == 0 {
+				fmt.Println("No pending timers.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tWORKFLOW\tFIRES AT\tIN\tSUBJECT\tTYPE\tPAYLOAD")
+			for _, t := range resp.Timers {
+				payload, _ := json.Marshal(t.Payload)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					t.ID, t.Workflow,
+					t.FireAt.Local().Format("2006-01-02 15:04:05"),
+					max(time.Until(t.FireAt), 0).Round(time.Second),
+					t.Subject, t.EventType, truncate(string(payload), 60),
+				)
+			}
+			w.Flush()
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&workflow, "workflow", "", "only show timers scheduled by this workflow")
+	return cmd
+}
+
+func newTimersCancelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cancel <id>",
+		Short: "Cancel a pending delayed event",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := apiDelete("/api/v1/timers/"+url.PathEscape(args[0]), nil); err != nil {
+				return err
+			}
+			fmt.Printf("Cancelled timer %s.\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+)
+
+// caValidity is how long the local CA created by tls init is valid.
+const caValidity = 10 * 365 * 24 * time.Hour
+
+func newTLSCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tls",
+		Short: "Manage TLS certificates for the NATS listener",
+	}
+
+	cmd.AddCommand(newTLSInitCmd())
+
+	return cmd
+}
+
+func newTLSInitCmd() *cobra.Command {
+	var (
+		dir    string
+		hosts  []string
+		agents []string
+		days   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a local CA, the NATS server certificate and agent client certificates",
+		Long: `Creates a local certificate authority in <dir> (ca.pem, ca-key.pem), a server
+certificate for sekiad's NATS listener valid for each --host (server.pem,
+server-key.pem), and a client certificate for each --agent (<agent>.pem,
+<agent>-key.pem). Files that already exist are kept, so run it again with
+more --agent flags to issue certificates for new agents.
+
+Point sekiad's nats.tls_cert, nats.tls_key and nats.tls_ca at server.pem,
+server-key.pem and ca.pem, and set nats.verify_clients to require client
+certificates. Copy ca.pem and the agent's certificate and key to each agent
+and point its nats.tls_ca, nats.tls_cert and nats.tls_key at them.`,
+		Example: `  sekiactl tls init --host nats.example.com --agent github-agent --agent slack-agent
+  sekiactl tls init --agent linear-agent   # add an agent later`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ca := secrets.DefaultCertAuthority()
+			if dir != "" {
+				ca = secrets.CertAuthority{Dir: dir}
+			}
+			validity := time.Duration(days) * 24 * time.Hour
+			if validity <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+
+			created, err := ca.Init(caValidity)
+			if err != nil {
+				return err
+			}
+			if created {
+				fmt.Printf("CA written to: %s\n", ca.CAPath())
+			} else {
+				fmt.Printf("Using existing CA: %s\n", ca.CAPath())
+			}
+
+			if ca.Exists(secrets.ServerCertName) {
+				fmt.Printf("Keeping existing server certificate: %s\n", ca.CertPath(secrets.ServerCertName))
+			} else {
+				if err := ca.IssueServer(hosts, validity); err != nil {
+					return err
+				}
+				fmt.Printf("Server certificate written to: %s\n", ca.CertPath(secrets.ServerCertName))
+			}
+
+			for _, name := range agents {
+				if ca.Exists(name) {
+					fmt.Printf("Keeping existing certificate for %s: %s\n", name, ca.CertPath(name))
+					continue
+				}
+				if err := ca.IssueClient(name, validity); err != nil {
+					return err
+				}
+				fmt.Printf("Client certificate for %s written to: %s\n", name, ca.CertPath(name))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "", "certificate directory (default: ~/.config/sekia/tls)")
+	cmd.Flags().StringArrayVar(&hosts, "host", []string{"localhost", "127.0.0.1"}, "host name or IP address agents connect to (repeatable)")
+	cmd.Flags().StringArrayVar(&agents, "agent", nil, "agent to issue a client certificate for (repeatable)")
+	cmd.Flags().IntVar(&days, "days", 365, "validity of the server and client certificates, in days")
+	return cmd
+}
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 
 	"github.com/sekia-ai/sekia/internal/workflow"
@@ -22,6 +28,9 @@ func newWorkflowsCmd() *cobra.Command {
 	cmd.AddCommand(newWorkflowsListCmd())
 	cmd.AddCommand(newWorkflowsReloadCmd())
 	cmd.AddCommand(newWorkflowsSignCmd())
+	cmd.AddCommand(newWorkflowsShadowCmd())
+	cmd.AddCommand(newWorkflowsTestCmd())
+	cmd.AddCommand(newWorkflowsLintCmd())
 
 	// Default to list when no subcommand given.
 	cmd.RunE = newWorkflowsListCmd().RunE
@@ -45,21 +54,84 @@ func newWorkflowsListCmd() *cobra.Command {
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tHANDLERS\tPATTERNS\tEVENTS\tERRORS\tLOADED AT")
+			fmt.Fprintln(w, "NAME\tMODE\tHANDLERS\tPATTERNS\tEVENTS\tERRORS\tWORKERS\tQUEUED\tNEXT RUN\tLOADED AT")
+			var failed []protocol.WorkflowInfo
 			for _, wf := range resp.Workflows {
-				fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t%s\n",
-					wf.Name, wf.Handlers,
+				if wf.LoadError != nil {
+					failed = append(failed, wf)
+				}
+				mode := "live"
+				switch {
+				case wf.LoadedAt.IsZero():
+					mode = "failed"
+				case wf.Shadow:
+					mode = "shadow"
+				}
+				errs := strconv.FormatInt(wf.Errors, 10)
+				if wf.Denied > 0 {
+					errs += fmt.Sprintf(" (%d denied)", wf.Denied)
+				}
+				queued := strconv.Itoa(wf.Queued)
+				if wf.Dropped > 0 {
+					queued += fmt.Sprintf(" (%d dropped)", wf.Dropped)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\t%d/%d\t%s\t%s\t%s\n",
+					wf.Name, mode, wf.Handlers,
 					strings.Join(wf.Patterns, ", "),
-					wf.Events, wf.Errors,
-					wf.LoadedAt.Format("15:04:05"),
+					wf.Events, errs,
+					wf.Busy, wf.Concurrency, queued,
+					nextRun(wf.Schedules),
+					loadedAt(wf),
 				)
 			}
 			w.Flush()
+
+			for _, wf := range failed {
+				fmt.Printf("\n%s failed to load", wf.Name)
+				if !wf.LoadedAt.IsZero() {
+					fmt.Print(" (the previous version is still running)")
+				}
+				fmt.Printf(":\n  %s\n", formatLoadError(wf.FilePath, wf.LoadError))
+			}
 			return nil
 		},
 	}
 }
 
+// loadedAt formats when a workflow loaded and how long loading took.
+func loadedAt(wf protocol.WorkflowInfo) string {
+	if wf.LoadedAt.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%.1fms)", wf.LoadedAt.Format("15:04:05"), wf.LoadMs)
+}
+
+// formatLoadError formats a load error as file:line:column: message.
+func formatLoadError(file string, le *protocol.LoadError) string {
+	pos := file
+	if le.Line > 0 {
+		pos += ":" + strconv.Itoa(le.Line)
+		if le.Column > 0 {
+			pos += ":" + strconv.Itoa(le.Column)
+		}
+	}
+	return pos + ": " + le.Message
+}
+
+// nextRun formats the earliest upcoming run of a workflow's schedules.
+func nextRun(schedules []protocol.ScheduleInfo) string {
+	var next time.Time
+	for _, s := range schedules {
+		if !s.NextRun.IsZero() && (next.IsZero() || s.NextRun.Before(next)) {
+			next = s.NextRun
+		}
+	}
+	if next.IsZero() {
+		return "-"
+	}
+	return next.Local().Format("Jan 02 15:04:05")
+}
+
 func newWorkflowsReloadCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "reload",
@@ -75,15 +147,62 @@ func newWorkflowsReloadCmd() *cobra.Command {
 	}
 }
 
+func newWorkflowsShadowCmd() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "shadow <name>",
+		Short: "Show what a shadow-mode workflow would have sent",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var resp protocol.ShadowResponse
+			path := withQuery("/api/v1/workflows/"+url.PathEscape(args[0])+"/shadow",
+				url.Values{"limit": {strconv.Itoa(limit)}})
+			if err := apiGet(path, &resp); err != nil {
+				return err
+			}
+
+			if !resp.Shadow {
+				fmt.Printf("Workflow %s is live (not in shadow mode).\n", resp.Workflow)
+			}
+			if len(resp.Intents) == 0 {
+				fmt.Println("No intents recorded.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tEVENT\tKIND\tTARGET\tPAYLOAD")
+			for _, in := range resp.Intents {
+				target := in.Subject
+				switch in.Kind {
+				case protocol.IntentCommand:
+					target = in.Agent + " " + in.Command
+				case protocol.IntentHTTP:
+					target = in.Method + " " + in.Subject
+				}
+				payload, _ := json.Marshal(in.Payload)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					in.At.Local().Format("01-02 15:04:05"), in.EventID, in.Kind, target, payload)
+			}
+			w.Flush()
+			fmt.Printf("%d of %d recorded intent(s) shown.\n", len(resp.Intents), resp.Total)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 50, "number of most recent intents to show (0 = all kept)")
+	return cmd
+}
+
 func newWorkflowsSignCmd() *cobra.Command {
 	var dir string
 
 	cmd := &cobra.Command{
 		Use:   "sign",
 		Short: "Generate SHA256 manifest for workflow files",
-		Long: `Scans the workflow directory for .lua files, computes SHA256 hashes,
-and writes a workflows.sha256 manifest file. This manifest is checked by the
-daemon when workflows.verify_integrity is enabled.`,
+		Long: `Scans the workflow directory and its lib/ directory for .lua files,
+computes SHA256 hashes, and writes a workflows.sha256 manifest file. This
+manifest is checked by the daemon when workflows.verify_integrity is enabled.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if dir == "" {
 				homeDir, _ := os.UserHomeDir()
@@ -99,7 +218,7 @@ daemon when workflows.verify_integrity is enabled.`,
 				return fmt.Errorf("write manifest: %w", err)
 			}
 
-			fmt.Printf("Signed %d workflow(s) in %s\n", m.Count(), dir)
+			fmt.Printf("Signed %d file(s) in %s\n", m.Count(), dir)
 			m.WriteTo(os.Stdout)
 			return nil
 		},
@@ -108,3 +227,162 @@ daemon when workflows.verify_integrity is enabled.`,
 	cmd.Flags().StringVar(&dir, "dir", "", "workflow directory (default: ~/.config/sekia/workflows)")
 	return cmd
 }
+
+func newWorkflowsTestCmd() *cobra.Command {
+	var (
+		run     string
+		timeout time.Duration
+		verbose bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "test [path...]",
+		Short: "Run workflow tests",
+		Long: `Runs the *_test.lua files in the given files or directories (default:
+~/.config/sekia/workflows). foo_test.lua tests foo.lua: each test() loads a
+fresh copy of the workflow in the daemon's sandbox, with NATS, agents and the
+LLM mocked, feeds it events with emit() and asserts on the commands,
+publishes and logs it produced. No daemon is needed.
+
+Exits non-zero if any test fails, so it can run in CI.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				homeDir, _ := os.UserHomeDir()
+				args = []string{filepath.Join(homeDir, ".config", "sekia", "workflows")}
+			}
+			opts := workflow.TestOptions{HandlerTimeout: timeout, Logger: zerolog.Nop()}
+			if run != "" {
+				re, err := regexp.Compile(run)
+				if err != nil {
+					return fmt.Errorf("invalid --run pattern: %w", err)
+				}
+				opts.Run = re
+			}
+			if verbose {
+				opts.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.TimeOnly}).With().Timestamp().Logger()
+			}
+
+			files, err := workflow.FindTestFiles(args)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				fmt.Println("No workflow tests found.")
+				return nil
+			}
+			cmd.SilenceUsage = true
+
+			var passed, failed int
+			for _, file := range files {
+				res := workflow.RunTestFile(file, opts)
+				base := filepath.Base(file)
+				if res.Err != nil {
+					failed++
+					fmt.Printf("--- FAIL: %s\n    %v\n", base, res.Err)
+					continue
+				}
+				for _, tr := range res.Tests {
+					if tr.Failure == "" {
+						passed++
+						fmt.Printf("--- PASS: %s: %s (%.2fs)\n", base, tr.Name, tr.Duration.Seconds())
+						continue
+					}
+					failed++
+					fmt.Printf("--- FAIL: %s: %s (%.2fs)\n    %s\n", base, tr.Name, tr.Duration.Seconds(), tr.Failure)
+				}
+			}
+
+			if failed > 0 {
+				fmt.Printf("FAIL\t%d passed, %d failed\n", passed, failed)
+				return fmt.Errorf("%d workflow test(s) failed", failed)
+			}
+			fmt.Printf("ok\t%d passed\n", passed)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&run, "run", "", "run only tests whose name matches this regular expression")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Second, "limit for each handler call")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print workflow logs to stderr")
+	return cmd
+}
+
+func newWorkflowsLintCmd() *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "lint [path...]",
+		Short: "Check workflow files for mistakes",
+		Long: `Parses the given workflow files, or the workflows in the given directories
+(default: ~/.config/sekia/workflows), without running them. Reports calls to
+unknown sekia functions or with the wrong number of arguments, and handler
+subject patterns that can never match.
+
+Unless --offline is given, the agents registered with sekiad are fetched and
+sekia.command calls are checked against their commands and the commands'
+payload schemas. Exits non-zero if any problem is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				homeDir, _ := os.UserHomeDir()
+				args = []string{filepath.Join(homeDir, ".config", "sekia", "workflows")}
+			}
+			var files []string
+			for _, p := range args {
+				info, err := os.Stat(p)
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() {
+					files = append(files, p)
+					continue
+				}
+				entries, err := os.ReadDir(p)
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					if !e.IsDir() && strings.HasSuffix(e.Name(), ".lua") && !strings.HasSuffix(e.Name(), workflow.TestFileSuffix) {
+						files = append(files, filepath.Join(p, e.Name()))
+					}
+				}
+			}
+
+			var agents []protocol.AgentInfo
+			if !offline {
+				var resp protocol.AgentsResponse
+				if err := apiGet("/api/v1/agents", &resp); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v; agent commands are not checked\n", err)
+				}
+				agents = resp.Agents
+			}
+			cmd.SilenceUsage = true
+
+			problems := 0
+			for _, file := range files {
+				src, err := os.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				issues, err := workflow.Lint(file, src, agents)
+				if err != nil {
+					problems++
+					fmt.Println(strings.TrimSpace(err.Error())) // names the file
+					continue
+				}
+				for _, issue := range issues {
+					problems++
+					fmt.Printf("%s:%d: %s\n", file, issue.Line, issue.Message)
+				}
+			}
+
+			if problems > 0 {
+				return fmt.Errorf("%d problem(s) in %d file(s)", problems, len(files))
+			}
+			fmt.Printf("Checked %d file(s), no problems found.\n", len(files))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "do not fetch registered agents from sekiad")
+	return cmd
+}
@@ -3,18 +3,23 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
+	"github.com/sekia-ai/sekia/internal/dlq"
 	"github.com/sekia-ai/sekia/internal/registry"
 	"github.com/sekia-ai/sekia/internal/skills"
+	"github.com/sekia-ai/sekia/internal/state"
+	"github.com/sekia-ai/sekia/internal/timers"
 	"github.com/sekia-ai/sekia/internal/workflow"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -25,6 +30,10 @@ type Server struct {
 	registry   *registry.Registry
 	engine     *workflow.Engine
 	skills     *skills.Manager
+	dlq        *dlq.Store
+	state      *state.Store
+	timers     *timers.Scheduler
+	ingress    http.Handler
 	nc         *nats.Conn
 	startedAt  time.Time
 	httpServer *http.Server
@@ -48,8 +57,22 @@ func New(socketPath string, reg *registry.Registry, engine *workflow.Engine, nc
 	mux.HandleFunc("GET /api/v1/agents", s.handleAgents)
 	mux.HandleFunc("GET /api/v1/workflows", s.handleWorkflows)
 	mux.HandleFunc("POST /api/v1/workflows/reload", s.handleWorkflowReload)
+	mux.HandleFunc("POST /api/v1/workflows/{name}/replay", s.handleWorkflowReplay)
+	mux.HandleFunc("GET /api/v1/workflows/{name}/shadow", s.handleWorkflowShadow)
 	mux.HandleFunc("GET /api/v1/skills", s.handleSkills)
 	mux.HandleFunc("POST /api/v1/config/reload", s.handleConfigReload)
+	mux.HandleFunc("GET /api/v1/dlq", s.handleDLQList)
+	mux.HandleFunc("POST /api/v1/dlq/replay", s.handleDLQReplay)
+	mux.HandleFunc("POST /api/v1/dlq/purge", s.handleDLQPurge)
+	mux.HandleFunc("GET /api/v1/dlq/{seq}", s.handleDLQShow)
+	mux.HandleFunc("DELETE /api/v1/dlq/{seq}", s.handleDLQDelete)
+	mux.HandleFunc("POST /api/v1/dlq/{seq}/replay", s.handleDLQReplayOne)
+	mux.HandleFunc("GET /api/v1/state/{workflow}", s.handleStateList)
+	mux.HandleFunc("GET /api/v1/state/{workflow}/{key...}", s.handleStateGet)
+	mux.HandleFunc("DELETE /api/v1/state/{workflow}/{key...}", s.handleStateDelete)
+	mux.HandleFunc("GET /api/v1/timers", s.handleTimersList)
+	mux.HandleFunc("DELETE /api/v1/timers/{id}", s.handleTimerCancel)
+	mux.HandleFunc("POST /api/v1/events", s.handleEventIngest)
 
 	s.httpServer = &http.Server{
 		Handler:           mux,
@@ -138,13 +161,27 @@ func (s *Server) handleWorkflows(w http.ResponseWriter, r *http.Request) {
 	if s.engine != nil {
 		for _, wf := range s.engine.Workflows() {
 			workflows = append(workflows, protocol.WorkflowInfo{
-				Name:     wf.Name,
-				FilePath: wf.FilePath,
-				Handlers: wf.Handlers,
-				Patterns: wf.Patterns,
-				LoadedAt: wf.LoadedAt,
-				Events:   wf.Events,
-				Errors:   wf.Errors,
+				Name:      wf.Name,
+				FilePath:  wf.FilePath,
+				Handlers:  wf.Handlers,
+				Patterns:  wf.Patterns,
+				Filters:   wf.Filters,
+				LoadedAt:  wf.LoadedAt,
+				Events:    wf.Events,
+				Errors:    wf.Errors,
+				Shadow:    wf.Shadow,
+				Schedules: wf.Schedules,
+				LoadMs:    float64(wf.LoadTime) / float64(time.Millisecond),
+				LoadError: wf.LoadError,
+
+				Concurrency:   wf.Concurrency,
+				OrderKey:      wf.OrderKey,
+				Busy:          wf.Busy,
+				Queued:        wf.Queued,
+				QueueCapacity: wf.QueueCapacity,
+				Dropped:       wf.Dropped,
+
+				Denied: wf.Denied,
 			})
 		}
 	}
@@ -170,6 +207,82 @@ func (s *Server) handleWorkflowReload(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
 }
 
+func (s *Server) handleWorkflowReplay(w http.ResponseWriter, r *http.Request) {
+	if s.engine == nil {
+		http.Error(w, "workflow engine not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	var req protocol.ReplayRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Since.IsZero() {
+		http.Error(w, "since is required", http.StatusBadRequest)
+		return
+	}
+
+	name := r.PathValue("name")
+	res, err := s.engine.Replay(r.Context(), name, workflow.ReplayOptions{
+		Since:   req.Since,
+		Subject: req.Subject,
+		DryRun:  req.DryRun,
+		Limit:   req.Limit,
+	})
+	switch {
+	case errors.Is(err, workflow.ErrWorkflowNotLoaded):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, workflow.ErrEventLogDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.logger.Error().Err(err).Str("workflow", name).Msg("workflow replay failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.ReplayResponse{
+		Workflow: name,
+		DryRun:   req.DryRun,
+		Events:   res.Events,
+		Errors:   res.Errors,
+		Intents:  res.Intents,
+	})
+}
+
+func (s *Server) handleWorkflowShadow(w http.ResponseWriter, r *http.Request) {
+	if s.engine == nil {
+		http.Error(w, "workflow engine not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	name := r.PathValue("name")
+	info, err := s.engine.ShadowIntents(name, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.ShadowResponse{
+		Workflow: name,
+		Shadow:   info.Shadow,
+		Total:    info.Total,
+		Intents:  info.Intents,
+	})
+}
+
 func (s *Server) handleConfigReload(w http.ResponseWriter, r *http.Request) {
 	target := r.URL.Query().Get("target")
 	if target == "" {
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/sekia-ai/sekia/internal/dlq"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// SetDLQStore sets the dead-letter store backing the /api/v1/dlq endpoints.
+func (s *Server) SetDLQStore(store *dlq.Store) {
+	s.dlq = store
+}
+
+func (s *Server) handleDLQList(w http.ResponseWriter, r *http.Request) {
+	if s.dlq == nil {
+		http.Error(w, "dead-letter queue not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := s.dlq.List(r.Context(), dlqFilter(r), limit)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list dead letters failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.DLQResponse{Entries: entries})
+}
+
+func (s *Server) handleDLQShow(w http.ResponseWriter, r *http.Request) {
+	seq, ok := s.dlqSeq(w, r)
+	if !ok {
+		return
+	}
+	dl, err := s.dlq.Get(r.Context(), seq)
+	if err != nil {
+		dlqError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dl)
+}
+
+func (s *Server) handleDLQDelete(w http.ResponseWriter, r *http.Request) {
+	seq, ok := s.dlqSeq(w, r)
+	if !ok {
+		return
+	}
+	if err := s.dlq.Delete(r.Context(), seq); err != nil {
+		dlqError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.DLQPurgeResponse{Purged: 1})
+}
+
+func (s *Server) handleDLQReplayOne(w http.ResponseWriter, r *http.Request) {
+	seq, ok := s.dlqSeq(w, r)
+	if !ok {
+		return
+	}
+	dl, err := s.dlq.Get(r.Context(), seq)
+	if err != nil {
+		dlqError(w, err)
+		return
+	}
+	if err := s.replay(r.Context(), dl); err != nil {
+		s.logger.Error().Err(err).Uint64("seq", seq).Msg("dead letter replay failed")
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.DLQReplayResponse{Replayed: []uint64{seq}})
+}
+
+func (s *Server) handleDLQReplay(w http.ResponseWriter, r *http.Request) {
+	if s.dlq == nil {
+		http.Error(w, "dead-letter queue not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	entries, err := s.dlq.List(r.Context(), dlqFilter(r), 0)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list dead letters failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := protocol.DLQReplayResponse{Replayed: []uint64{}}
+	for _, dl := range entries {
+		if err := s.replay(r.Context(), dl); err != nil {
+			if resp.Failed == nil {
+				resp.Failed = make(map[uint64]string)
+			}
+			resp.Failed[dl.Seq] = err.Error()
+			continue
+		}
+		resp.Replayed = append(resp.Replayed, dl.Seq)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleDLQPurge(w http.ResponseWriter, r *http.Request) {
+	if s.dlq == nil {
+		http.Error(w, "dead-letter queue not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	n, err := s.dlq.Purge(r.Context(), dlqFilter(r))
+	if err != nil {
+		s.logger.Error().Err(err).Msg("purge dead letters failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info().Uint64("purged", n).Msg("purged dead letters")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(protocol.DLQPurgeResponse{Purged: n})
+}
+
+// replay re-injects a dead letter and removes it from the queue. Events are
+// delivered only to the workflow that failed; commands are re-published to
+// the agent's command subject.
+func (s *Server) replay(ctx context.Context, dl protocol.DeadLetter) error {
+	switch dl.Kind {
+	case protocol.DeadLetterEvent:
+		if s.engine == nil {
+			return errors.New("workflow engine not enabled")
+		}
+		if err := s.engine.Redeliver(dl.Workflow, dl.Subject, dl.Data); err != nil {
+			return err
+		}
+	case protocol.DeadLetterCommand:
+		var cmd protocol.Command
+		if err := json.Unmarshal(dl.Data, &cmd); err != nil {
+			return fmt.Errorf("decode command: %w", err)
+		}
+		data := dl.Data
+		if cmd.SigVersion >= protocol.SignatureV2 && s.engine != nil {
+			// The original has expired or its nonce was used: sign it afresh.
+			if err := s.engine.SignCommand(&cmd, dl.Agent); err != nil {
+				return fmt.Errorf("sign command: %w", err)
+			}
+			signed, err := json.Marshal(cmd)
+			if err != nil {
+				return fmt.Errorf("encode command: %w", err)
+			}
+			data = signed
+		}
+		msg := nats.NewMsg(dl.Subject)
+		// A fresh message ID so the work queue does not drop it as a duplicate.
+		msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s.replay.%d", cmd.ID, dl.Seq))
+		msg.Data = data
+		if err := s.nc.PublishMsg(msg); err != nil {
+			return fmt.Errorf("publish command: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown dead letter kind %q", dl.Kind)
+	}
+
+	s.logger.Info().
+		Uint64("seq", dl.Seq).
+		Str("kind", dl.Kind).
+		Str("workflow", dl.Workflow).
+		Str("agent", dl.Agent).
+		Msg("replayed dead letter")
+	return s.dlq.Delete(ctx, dl.Seq)
+}
+
+// dlqSeq parses the {seq} path value, writing an error response on failure.
+func (s *Server) dlqSeq(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	if s.dlq == nil {
+		http.Error(w, "dead-letter queue not enabled", http.StatusServiceUnavailable)
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(r.PathValue("seq"), 10, 64)
+	if err != nil || seq == 0 {
+		http.Error(w, "invalid sequence number", http.StatusBadRequest)
+		return 0, false
+	}
+	return seq, true
+}
+
+func dlqFilter(r *http.Request) dlq.Filter {
+	return dlq.Filter{
+		Workflow: r.URL.Query().Get("workflow"),
+		Agent:    r.URL.Query().Get("agent"),
+	}
+}
+
+func dlqError(w http.ResponseWriter, err error) {
+	if errors.Is(err, dlq.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
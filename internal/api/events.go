@@ -0,0 +1,17 @@
+package api
+
+import "net/http"
+
+// SetEventIngress sets the handler behind POST /api/v1/events, normally a
+// *cloudevents.Ingress.
+func (s *Server) SetEventIngress(h http.Handler) {
+	s.ingress = h
+}
+
+func (s *Server) handleEventIngest(w http.ResponseWriter, r *http.Request) {
+	if s.ingress == nil {
+		http.Error(w, "event ingress not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	s.ingress.ServeHTTP(w, r)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sekia-ai/sekia/internal/state"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// SetStateStore sets the workflow state store backing the /api/v1/state endpoints.
+func (s *Server) SetStateStore(store *state.Store) {
+	s.state = store
+}
+
+func (s *Server) handleStateList(w http.ResponseWriter, r *http.Request) {
+	if s.state == nil {
+		http.Error(w, "workflow state not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	wf := r.PathValue("workflow")
+	entries, err := s.state.List(r.Context(), wf)
+	if err != nil {
+		s.logger.Error().Err(err).Str("workflow", wf).Msg("list workflow state failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := protocol.StateResponse{Workflow: wf, Entries: make([]protocol.StateEntry, 0, len(entries))}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, stateEntry(e))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleStateGet(w http.ResponseWriter, r *http.Request) {
+	if s.state == nil {
+		http.Error(w, "workflow state not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	e, err := s.state.Get(r.Context(), r.PathValue("workflow"), r.PathValue("key"))
+	if errors.Is(err, state.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stateEntry(e))
+}
+
+func (s *Server) handleStateDelete(w http.ResponseWriter, r *http.Request) {
+	if s.state == nil {
+		http.Error(w, "workflow state not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	wf, key := r.PathValue("workflow"), r.PathValue("key")
+	if err := s.state.Delete(r.Context(), wf, key); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info().Str("workflow", wf).Str("key", key).Msg("deleted workflow state key")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+func stateEntry(e state.Entry) protocol.StateEntry {
+	return protocol.StateEntry{
+		Key:      e.Key,
+		Value:    json.RawMessage(e.Value),
+		Revision: e.Revision,
+		Updated:  e.Updated,
+	}
+}
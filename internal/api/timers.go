@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sekia-ai/sekia/internal/timers"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// SetTimerScheduler sets the scheduler backing the /api/v1/timers endpoints.
+func (s *Server) SetTimerScheduler(sched *timers.Scheduler) {
+	s.timers = sched
+}
+
+func (s *Server) handleTimersList(w http.ResponseWriter, r *http.Request) {
+	if s.timers == nil {
+		http.Error(w, "delayed events not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	all, err := s.timers.List(r.Context())
+	if err != nil {
+		s.logger.Error().Err(err).Msg("list timers failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := protocol.TimersResponse{Timers: all}
+	if wf := r.URL.Query().Get("workflow"); wf != "" {
+		resp.Timers = []protocol.Timer{}
+		for _, t := range all {
+			if t.Workflow == wf {
+				resp.Timers = append(resp.Timers, t)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleTimerCancel(w http.ResponseWriter, r *http.Request) {
+	if s.timers == nil {
+		http.Error(w, "delayed events not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	id := r.PathValue("id")
+	err := s.timers.Cancel(r.Context(), id)
+	if errors.Is(err, timers.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.logger.Info().Str("id", id).Msg("cancelled timer")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+}
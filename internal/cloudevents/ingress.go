@@ -0,0 +1,145 @@
+// Package cloudevents connects sekia to other event systems over HTTP
+// using CloudEvents 1.0: an ingress that publishes incoming CloudEvents
+// as sekia events, and sinks that forward sekia events to HTTP endpoints.
+package cloudevents
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// maxEventSize bounds the body of an incoming CloudEvent.
+const maxEventSize = 1 << 20
+
+// Ingress accepts CloudEvents in structured or binary HTTP mode and
+// publishes them on sekia.events.<source>. It is served on the daemon's
+// Unix socket and, optionally, on its own TCP listener.
+type Ingress struct {
+	publish    func(*nats.Msg) error
+	subjects   string
+	httpServer *http.Server
+	logger     zerolog.Logger
+}
+
+// NewIngress creates an ingress publishing to nc. subjects is the event
+// subject scheme (protocol.Subjects*).
+func NewIngress(nc *nats.Conn, subjects string, logger zerolog.Logger) *Ingress {
+	return &Ingress{
+		publish:  nc.PublishMsg,
+		subjects: subjects,
+		logger:   logger.With().Str("component", "cloudevents").Logger(),
+	}
+}
+
+// ServeHTTP handles POST /api/v1/events. The event is published with the
+// CloudEvent id as its NATS message ID, so JetStream drops redeliveries of
+// the same event, and with a Sekia-Origin header so sinks do not echo it
+// back out.
+func (in *Ingress) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	ce, err := protocol.ReadCloudEventHTTP(r.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ev, err := protocol.FromCloudEvent(ce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subject := protocol.SubjectEventsFor(in.subjects, protocol.SourceToken(ev.Source), ev.Type)
+	msg := nats.NewMsg(subject)
+	msg.Header.Set(nats.MsgIdHdr, ev.ID)
+	msg.Header.Set(protocol.HeaderOrigin, protocol.OriginCloudEvents)
+	msg.Data = data
+	if err := in.publish(msg); err != nil {
+		in.logger.Error().Err(err).Str("id", ev.ID).Msg("publish CloudEvent failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	in.logger.Debug().
+		Str("id", ev.ID).
+		Str("type", ev.Type).
+		Str("source", ev.Source).
+		Str("subject", subject).
+		Msg("accepted CloudEvent")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(protocol.EventIngestResponse{
+		Status:  "accepted",
+		ID:      ev.ID,
+		Subject: subject,
+	})
+}
+
+// Listen creates a TCP listener for the ingress route alone. If token is
+// non-empty, requests must carry it as a bearer token. Call Serve after to
+// begin accepting connections.
+func (in *Ingress) Listen(addr, token string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/events", in)
+	in.httpServer = &http.Server{
+		Handler:           requireToken(token, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	in.logger.Info().Str("listen", addr).Msg("CloudEvents ingress listening")
+	return ln, nil
+}
+
+// Serve accepts connections on a listener from Listen. Blocks until
+// Shutdown.
+func (in *Ingress) Serve(ln net.Listener) error {
+	return in.httpServer.Serve(ln)
+}
+
+// Shutdown gracefully stops the TCP listener, if any.
+func (in *Ingress) Shutdown(ctx context.Context) error {
+	if in.httpServer == nil {
+		return nil
+	}
+	return in.httpServer.Shutdown(ctx)
+}
+
+// requireToken wraps next with bearer token authentication. An empty token
+// disables authentication.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sekia"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
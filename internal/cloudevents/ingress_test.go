@@ -0,0 +1,145 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newTestIngress(subjects string) (*Ingress, *[]*nats.Msg) {
+	var published []*nats.Msg
+	in := &Ingress{
+		publish: func(msg *nats.Msg) error {
+			published = append(published, msg)
+			return nil
+		},
+		subjects: subjects,
+		logger:   zerolog.Nop(),
+	}
+	return in, &published
+}
+
+func TestIngress_Structured(t *testing.T) {
+	in, published := newTestIngress(protocol.SubjectsFlat)
+
+	body := `{"specversion":"1.0","id":"evt-1","source":"https://ci.example.com","type":"build.finished","data":{"status":"ok"}}`
+	req := httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", protocol.ContentTypeCloudEvents)
+	rec := httptest.NewRecorder()
+	in.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp protocol.EventIngestResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.ID != "evt-1" || resp.Subject != "sekia.events.ci_example_com" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	if len(*published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(*published))
+	}
+	msg := (*published)[0]
+	if msg.Subject != "sekia.events.ci_example_com" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	if msg.Header.Get(nats.MsgIdHdr) != "evt-1" {
+		t.Errorf("Nats-Msg-Id = %q", msg.Header.Get(nats.MsgIdHdr))
+	}
+	if msg.Header.Get(protocol.HeaderOrigin) != protocol.OriginCloudEvents {
+		t.Error("missing origin header")
+	}
+	var ev protocol.Event
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "build.finished" || ev.Payload["status"] != "ok" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestIngress_BinaryHierarchical(t *testing.T) {
+	in, published := newTestIngress(protocol.SubjectsHierarchical)
+
+	req := httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(`{"reading":21.5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Id", "r-9")
+	req.Header.Set("Ce-Source", "sensors")
+	req.Header.Set("Ce-Type", "sensor.reading")
+	rec := httptest.NewRecorder()
+	in.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := (*published)[0].Subject; got != "sekia.events.sensors.sensor.reading" {
+		t.Errorf("subject = %q", got)
+	}
+}
+
+func TestIngress_Errors(t *testing.T) {
+	in, published := newTestIngress(protocol.SubjectsFlat)
+
+	req := httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(`{"type":"x"}`))
+	req.Header.Set("Content-Type", protocol.ContentTypeCloudEvents)
+	rec := httptest.NewRecorder()
+	in.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid event: status = %d, want 400", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(strings.Repeat("x", maxEventSize+1)))
+	req.Header.Set("Content-Type", protocol.ContentTypeCloudEvents)
+	rec = httptest.NewRecorder()
+	in.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized event: status = %d, want 413", rec.Code)
+	}
+	if len(*published) != 0 {
+		t.Errorf("published %d messages, want 0", len(*published))
+	}
+
+	in.publish = func(*nats.Msg) error { return errors.New("no responders") }
+	req = httptest.NewRequest("POST", "/api/v1/events", strings.NewReader(`{"specversion":"1.0","id":"1","source":"s","type":"t"}`))
+	req.Header.Set("Content-Type", protocol.ContentTypeCloudEvents)
+	rec = httptest.NewRecorder()
+	in.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("publish failure: status = %d, want 500", rec.Code)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := requireToken("s3cret", ok)
+
+	for _, tt := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic s3cret", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/events", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Authorization %q: status = %d, want %d", tt.auth, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// Sink content modes.
+const (
+	ModeStructured = "structured"
+	ModeBinary     = "binary"
+)
+
+const (
+	// sinkQueueSize is the number of events a sink buffers while its
+	// endpoint is slow or down. Further events are dropped.
+	sinkQueueSize = 1024
+	// sinkAttempts is the number of times a delivery is tried.
+	sinkAttempts = 3
+	// defaultSinkTimeout bounds one delivery attempt.
+	defaultSinkTimeout = 10 * time.Second
+)
+
+// SinkConfig is one [[cloudevents.sinks]] entry: an HTTP endpoint that
+// receives matching sekia events as CloudEvents.
+type SinkConfig struct {
+	URL      string            `mapstructure:"url"`
+	Subjects []string          `mapstructure:"subjects"` // NATS subjects to forward (default sekia.events.>)
+	Types    []string          `mapstructure:"types"`    // event type globs; empty forwards every type
+	Mode     string            `mapstructure:"mode"`     // structured (default) or binary
+	Headers  map[string]string `mapstructure:"headers"`  // extra request headers, e.g. Authorization
+	Timeout  time.Duration     `mapstructure:"timeout"`  // per attempt (default 10s)
+}
+
+// Validate checks the sink settings.
+func (c SinkConfig) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL, got %q", c.URL)
+	}
+	switch c.Mode {
+	case "", ModeStructured, ModeBinary:
+	default:
+		return fmt.Errorf("mode must be %q or %q, got %q", ModeStructured, ModeBinary, c.Mode)
+	}
+	for _, g := range c.Types {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("invalid type pattern %q", g)
+		}
+	}
+	return nil
+}
+
+// Sink forwards sekia events to an HTTP endpoint as CloudEvents. Events
+// that entered through the ingress are not forwarded, so two sekia
+// instances (or a sink pointing back at its own ingress) cannot loop.
+// Delivery is at most once: events are read from core NATS, buffered in
+// memory and retried on network errors, 429 and 5xx responses.
+type Sink struct {
+	cfg     SinkConfig
+	client  *http.Client
+	queue   chan protocol.Event
+	subs    []*nats.Subscription
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	backoff time.Duration // before the second attempt; doubles after
+	logger  zerolog.Logger
+}
+
+// NewSink creates a sink from its config. Call Start to begin forwarding.
+func NewSink(cfg SinkConfig, logger zerolog.Logger) (*Sink, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if len(cfg.Subjects) == 0 {
+		cfg.Subjects = []string{"sekia.events.>"}
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = ModeStructured
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultSinkTimeout
+	}
+	return &Sink{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		queue:   make(chan protocol.Event, sinkQueueSize),
+		stop:    make(chan struct{}),
+		backoff: time.Second,
+		logger:  logger.With().Str("component", "cloudevents").Str("sink", cfg.URL).Logger(),
+	}, nil
+}
+
+// Start subscribes to the sink's subjects and starts delivering.
+func (s *Sink) Start(nc *nats.Conn) error {
+	for _, subject := range s.cfg.Subjects {
+		sub, err := nc.Subscribe(subject, s.handleMsg)
+		if err != nil {
+			s.Stop()
+			return fmt.Errorf("subscribe %s: %w", subject, err)
+		}
+		s.subs = append(s.subs, sub)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case ev := <-s.queue:
+				s.send(ev)
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	s.logger.Info().Strs("subjects", s.cfg.Subjects).Str("mode", s.cfg.Mode).Msg("CloudEvents sink started")
+	return nil
+}
+
+// Stop unsubscribes and waits for the delivery in progress, if any.
+// Buffered events are discarded.
+func (s *Sink) Stop() {
+	for _, sub := range s.subs {
+		sub.Unsubscribe()
+	}
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// handleMsg queues a NATS message for delivery if it is a sekia event the
+// sink forwards.
+func (s *Sink) handleMsg(msg *nats.Msg) {
+	if msg.Header.Get(protocol.HeaderOrigin) == protocol.OriginCloudEvents {
+		return
+	}
+	var ev protocol.Event
+	if err := json.Unmarshal(msg.Data, &ev); err != nil || ev.ID == "" || ev.Type == "" {
+		return
+	}
+	if !s.matches(ev.Type) {
+		return
+	}
+	select {
+	case s.queue <- ev:
+	default:
+		s.logger.Warn().Str("id", ev.ID).Str("type", ev.Type).Msg("sink queue full, dropping event")
+	}
+}
+
+// matches reports whether events of this type are forwarded.
+func (s *Sink) matches(eventType string) bool {
+	if len(s.cfg.Types) == 0 {
+		return true
+	}
+	for _, g := range s.cfg.Types {
+		if ok, _ := path.Match(g, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// send delivers one event, retrying transient failures with backoff.
+func (s *Sink) send(ev protocol.Event) {
+	wait := s.backoff
+	for attempt := 1; ; attempt++ {
+		err := s.deliver(ev)
+		if err == nil {
+			s.logger.Debug().Str("id", ev.ID).Str("type", ev.Type).Msg("forwarded event")
+			return
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) || attempt == sinkAttempts {
+			s.logger.Warn().Err(err).Str("id", ev.ID).Str("type", ev.Type).Int("attempts", attempt).Msg("CloudEvent delivery failed")
+			return
+		}
+		select {
+		case <-time.After(wait):
+		case <-s.stop:
+			return
+		}
+		wait *= 2
+	}
+}
+
+// permanentError is a delivery failure that retrying will not fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// deliver makes one delivery attempt.
+func (s *Sink) deliver(ev protocol.Event) error {
+	ce, err := protocol.ToCloudEvent(ev)
+	if err != nil {
+		return &permanentError{err}
+	}
+	header, body, err := protocol.EncodeCloudEventHTTP(ce, s.cfg.Mode == ModeBinary)
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header = header
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	default:
+		return &permanentError{fmt.Errorf("endpoint returned %s", resp.Status)}
+	}
+}
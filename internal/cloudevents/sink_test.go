@@ -0,0 +1,141 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func TestSinkConfig_Validate(t *testing.T) {
+	valid := []SinkConfig{
+		{URL: "https://events.example.com/ingest"},
+		{URL: "http://localhost:8080", Mode: ModeBinary, Types: []string{"github.*"}},
+	}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%+v: %v", c, err)
+		}
+	}
+	invalid := []SinkConfig{
+		{},
+		{URL: "ftp://example.com"},
+		{URL: "https://example.com", Mode: "batched"},
+		{URL: "https://example.com", Types: []string{"[x"}},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v: expected error", c)
+		}
+	}
+}
+
+func TestSink_DeliverStructured(t *testing.T) {
+	var got protocol.CloudEvent
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != protocol.ContentTypeCloudEvents {
+			t.Errorf("content-type = %q", ct)
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	sink, err := NewSink(SinkConfig{URL: srv.URL, Headers: map[string]string{"authorization": "Bearer t"}}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := protocol.NewEvent("github.push", "github", map[string]any{"repo": "acme/app"})
+	if err := sink.deliver(ev); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != ev.ID || got.Type != "github.push" || got.Source != "github" {
+		t.Errorf("unexpected CloudEvent: %+v", got)
+	}
+	if string(got.Data) != `{"repo":"acme/app"}` {
+		t.Errorf("data = %s", got.Data)
+	}
+	if auth != "Bearer t" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func TestSink_DeliverBinary(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	sink, _ := NewSink(SinkConfig{URL: srv.URL, Mode: ModeBinary}, zerolog.Nop())
+	ev := protocol.NewEvent("slack.mention", "slack", map[string]any{"text": "hi"})
+	if err := sink.deliver(ev); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Ce-Id") != ev.ID || header.Get("Ce-Type") != "slack.mention" || header.Get("Ce-Specversion") != "1.0" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if header.Get("Content-Type") != "application/json" || string(body) != `{"text":"hi"}` {
+		t.Errorf("content-type %q, body %s", header.Get("Content-Type"), body)
+	}
+}
+
+func TestSink_Retry(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(status)
+		}
+	}))
+	defer srv.Close()
+
+	sink, _ := NewSink(SinkConfig{URL: srv.URL}, zerolog.Nop())
+	sink.backoff = time.Millisecond
+	sink.send(protocol.NewEvent("x.y", "test", nil))
+	if n := calls.Load(); n != 3 {
+		t.Errorf("5xx: %d attempts, want 3", n)
+	}
+
+	calls.Store(0)
+	status = http.StatusBadRequest
+	sink.send(protocol.NewEvent("x.y", "test", nil))
+	if n := calls.Load(); n != 1 {
+		t.Errorf("4xx: %d attempts, want 1", n)
+	}
+}
+
+func TestSink_HandleMsg(t *testing.T) {
+	sink, _ := NewSink(SinkConfig{URL: "http://localhost", Types: []string{"github.issue.*"}}, zerolog.Nop())
+
+	msg := func(eventType string, origin string) *nats.Msg {
+		m := nats.NewMsg("sekia.events.github")
+		m.Data, _ = json.Marshal(protocol.NewEvent(eventType, "github", nil))
+		if origin != "" {
+			m.Header.Set(protocol.HeaderOrigin, origin)
+		}
+		return m
+	}
+	sink.handleMsg(msg("github.issue.opened", ""))
+	sink.handleMsg(msg("github.push", ""))
+	sink.handleMsg(msg("github.issue.closed", protocol.OriginCloudEvents))
+	sink.handleMsg(&nats.Msg{Subject: "sekia.events.github", Data: []byte("not json")})
+
+	if len(sink.queue) != 1 {
+		t.Fatalf("queued %d events, want 1", len(sink.queue))
+	}
+	if ev := <-sink.queue; ev.Type != "github.issue.opened" {
+		t.Errorf("queued %s", ev.Type)
+	}
+}
@@ -0,0 +1,129 @@
+// Package dlq reads and manages dead letters stored in the SEKIA_DLQ stream.
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// ErrNotFound is returned when a dead letter does not exist.
+var ErrNotFound = errors.New("dead letter not found")
+
+// Filter selects dead letters by workflow or agent. The zero Filter matches all.
+type Filter struct {
+	Workflow string
+	Agent    string
+}
+
+// subject returns the stream subject filter for f.
+func (f Filter) subject() string {
+	switch {
+	case f.Workflow != "":
+		return protocol.SubjectDLQWorkflow(f.Workflow)
+	case f.Agent != "":
+		return protocol.SubjectDLQAgent(f.Agent)
+	default:
+		return "sekia.dlq.>"
+	}
+}
+
+// Store provides access to the dead-letter stream.
+type Store struct {
+	js jetstream.JetStream
+}
+
+// New creates a Store backed by the given JetStream context.
+func New(js jetstream.JetStream) *Store {
+	return &Store{js: js}
+}
+
+// List returns up to limit dead letters matching f, oldest first (limit <= 0 = no limit).
+func (s *Store) List(ctx context.Context, f Filter, limit int) ([]protocol.DeadLetter, error) {
+	stream, err := s.js.Stream(ctx, protocol.StreamDLQ)
+	if err != nil {
+		return nil, fmt.Errorf("lookup stream %s: %w", protocol.StreamDLQ, err)
+	}
+
+	entries := []protocol.DeadLetter{}
+	subject := f.subject()
+	seq := max(stream.CachedInfo().State.FirstSeq, 1)
+	for limit <= 0 || len(entries) < limit {
+		msg, err := stream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(subject))
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read dead letter: %w", err)
+		}
+		dl, err := decode(msg)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, dl)
+		seq = msg.Sequence + 1
+	}
+	return entries, nil
+}
+
+// Get returns the dead letter stored at seq.
+func (s *Store) Get(ctx context.Context, seq uint64) (protocol.DeadLetter, error) {
+	stream, err := s.js.Stream(ctx, protocol.StreamDLQ)
+	if err != nil {
+		return protocol.DeadLetter{}, fmt.Errorf("lookup stream %s: %w", protocol.StreamDLQ, err)
+	}
+	msg, err := stream.GetMsg(ctx, seq)
+	if errors.Is(err, jetstream.ErrMsgNotFound) {
+		return protocol.DeadLetter{}, ErrNotFound
+	}
+	if err != nil {
+		return protocol.DeadLetter{}, fmt.Errorf("read dead letter: %w", err)
+	}
+	return decode(msg)
+}
+
+// Delete removes the dead letter stored at seq.
+func (s *Store) Delete(ctx context.Context, seq uint64) error {
+	stream, err := s.js.Stream(ctx, protocol.StreamDLQ)
+	if err != nil {
+		return fmt.Errorf("lookup stream %s: %w", protocol.StreamDLQ, err)
+	}
+	if err := stream.DeleteMsg(ctx, seq); err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("delete dead letter: %w", err)
+	}
+	return nil
+}
+
+// Purge removes every dead letter matching f and returns how many were removed.
+func (s *Store) Purge(ctx context.Context, f Filter) (uint64, error) {
+	stream, err := s.js.Stream(ctx, protocol.StreamDLQ)
+	if err != nil {
+		return 0, fmt.Errorf("lookup stream %s: %w", protocol.StreamDLQ, err)
+	}
+	before := stream.CachedInfo().State.Msgs
+	if err := stream.Purge(ctx, jetstream.WithPurgeSubject(f.subject())); err != nil {
+		return 0, fmt.Errorf("purge dead letters: %w", err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("stream info: %w", err)
+	}
+	return before - info.State.Msgs, nil
+}
+
+func decode(msg *jetstream.RawStreamMsg) (protocol.DeadLetter, error) {
+	var dl protocol.DeadLetter
+	if err := json.Unmarshal(msg.Data, &dl); err != nil {
+		return protocol.DeadLetter{}, fmt.Errorf("decode dead letter %d: %w", msg.Sequence, err)
+	}
+	dl.Seq = msg.Sequence
+	return dl, nil
+}
@@ -0,0 +1,134 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		NoLog:      true,
+		NoSigs:     true,
+		DontListen: true,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	nc, err := nats.Connect("", nats.InProcessServer(ns))
+	if err != nil {
+		ns.Shutdown()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+	})
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     protocol.StreamDLQ,
+		Subjects: []string{"sekia.dlq.>"},
+		Storage:  jetstream.MemoryStorage,
+	}); err != nil {
+		t.Fatalf("create stream: %v", err)
+	}
+	return New(js)
+}
+
+func publish(t *testing.T, store *Store, subject string, dl protocol.DeadLetter) {
+	t.Helper()
+	data, _ := json.Marshal(dl)
+	if _, err := store.js.Publish(context.Background(), subject, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	publish(t, store, protocol.SubjectDLQWorkflow("triage"), protocol.DeadLetter{
+		Kind: protocol.DeadLetterEvent, Workflow: "triage", Subject: "sekia.events.github",
+		Error: "boom", Attempts: 1, Data: json.RawMessage(`{"id":"ev1"}`),
+	})
+	publish(t, store, protocol.SubjectDLQAgent("slack-agent"), protocol.DeadLetter{
+		Kind: protocol.DeadLetterCommand, Agent: "slack-agent", Subject: "sekia.commands.slack-agent",
+		Error: "channel_not_found", Attempts: 5, Data: json.RawMessage(`{"id":"cmd_1"}`),
+	})
+	publish(t, store, protocol.SubjectDLQWorkflow("triage"), protocol.DeadLetter{
+		Kind: protocol.DeadLetterEvent, Workflow: "triage", Subject: "sekia.events.github",
+		Error: "boom again", Attempts: 1, Data: json.RawMessage(`{"id":"ev2"}`),
+	})
+
+	all, err := store.List(ctx, Filter{}, 0)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("list all = %d entries, want 3", len(all))
+	}
+	if all[0].Seq != 1 || all[1].Seq != 2 || all[2].Seq != 3 {
+		t.Errorf("unexpected sequence numbers: %d %d %d", all[0].Seq, all[1].Seq, all[2].Seq)
+	}
+
+	triage, err := store.List(ctx, Filter{Workflow: "triage"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triage) != 2 || triage[1].Error != "boom again" {
+		t.Errorf("workflow filter returned %+v", triage)
+	}
+
+	limited, _ := store.List(ctx, Filter{}, 1)
+	if len(limited) != 1 {
+		t.Errorf("limit 1 returned %d entries", len(limited))
+	}
+
+	dl, err := store.Get(ctx, 2)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if dl.Agent != "slack-agent" || string(dl.Data) != `{"id":"cmd_1"}` {
+		t.Errorf("get returned %+v", dl)
+	}
+
+	if err := store.Delete(ctx, 2); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete: err = %v, want ErrNotFound", err)
+	}
+
+	n, err := store.Purge(ctx, Filter{Workflow: "triage"})
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("purged = %d, want 2", n)
+	}
+	if rest, _ := store.List(ctx, Filter{}, 0); len(rest) != 0 {
+		t.Errorf("entries after purge = %d, want 0", len(rest))
+	}
+}
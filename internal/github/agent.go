@@ -2,7 +2,6 @@ package github
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -15,6 +14,7 @@ import (
 	"github.com/rs/zerolog"
 	"golang.org/x/oauth2"
 
+	"github.com/sekia-ai/sekia/internal/secrets"
 	"github.com/sekia-ai/sekia/pkg/agent"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -37,10 +37,6 @@ type GitHubAgent struct {
 	logger       zerolog.Logger
 	stopCh       chan struct{}
 
-	// Async command processing — NATS callback enqueues, worker goroutine executes.
-	cmdCh   chan *nats.Msg
-	cmdDone chan struct{}
-
 	// Overridable for testing.
 	natsOpts []nats.Option
 	readyCh  chan struct{}
@@ -64,8 +60,6 @@ func NewAgent(cfg Config, instanceName string, logger zerolog.Logger) *GitHubAge
 		ghClient:     &realGitHubClient{client: ghc, httpClient: httpClient, token: cfg.GitHub.Token},
 		logger:       logger.With().Str("component", instanceName).Logger(),
 		stopCh:       make(chan struct{}),
-		cmdCh:        make(chan *nats.Msg, 256),
-		cmdDone:      make(chan struct{}),
 		readyCh:      make(chan struct{}),
 	}
 }
@@ -82,13 +76,28 @@ func (ga *GitHubAgent) Run() error {
 		capabilities = append(capabilities, "github-polling")
 	}
 
-	natsOpts := ga.natsOpts
-	if ga.cfg.NATS.Token != "" {
-		natsOpts = append(natsOpts, nats.Token(ga.cfg.NATS.Token))
+	natsOpts, err := secrets.NATSOptions(ga.cfg.NATS.NATSClientConfig)
+	if err != nil {
+		return err
+	}
+	natsOpts = append(ga.natsOpts, natsOpts...)
+	policy, err := protocol.LoadCommandPolicy(ga.cfg.Security.PolicyFile)
+	if err != nil {
+		return err
+	}
+	trustedKeys, err := protocol.LoadKeySet(ga.cfg.Security.TrustedKeys)
+	if err != nil {
+		return err
 	}
 	agentCfg := agent.Config{
-		NATSUrl:  ga.cfg.NATS.URL,
-		NATSOpts: natsOpts,
+		NATSUrl:         ga.cfg.NATS.URL,
+		NATSOpts:        natsOpts,
+		EventValidation: ga.cfg.Events.Validation,
+		CommandSchemas:  protocol.CommandSchemas("github"),
+		CommandPolicy:   policy,
+
+		MinSignatureVersion: ga.cfg.Security.MinSignatureVersion,
+		TrustedKeys:         trustedKeys,
 	}
 	a, err := agent.New(
 		agentCfg, ga.instanceName, agentVersion,
@@ -106,13 +115,11 @@ func (ga *GitHubAgent) Run() error {
 		ga.logger.Warn().Err(err).Msg("failed to register config reload handler")
 	}
 
-	// 2. Subscribe to commands (non-blocking enqueue; worker goroutine executes).
-	_, err = a.Conn().Subscribe(protocol.SubjectCommands(ga.instanceName), ga.enqueueCommand)
-	if err != nil {
+	// 2. Consume commands from the durable work queue (executed one at a time).
+	if err := a.ServeCommands(agent.CommandOptions{}, ga.executeCommand); err != nil {
 		a.Close()
-		return fmt.Errorf("subscribe commands: %w", err)
+		return fmt.Errorf("serve commands: %w", err)
 	}
-	go ga.commandLoop()
 
 	// 3. Start webhook server (if configured).
 	var webhookErrCh chan error
@@ -205,8 +212,6 @@ func NewTestAgent(natsURL string, natsOpts []nats.Option, ghBaseURL, webhookList
 		natsOpts:     natsOpts,
 		logger:       logger.With().Str("component", defaultAgentName).Logger(),
 		stopCh:       make(chan struct{}),
-		cmdCh:        make(chan *nats.Msg, 256),
-		cmdDone:      make(chan struct{}),
 		readyCh:      make(chan struct{}),
 	}
 }
@@ -265,8 +270,6 @@ func NewTestAgentWithPolling(natsURL string, natsOpts []nats.Option, ghClient Gi
 		natsOpts:     natsOpts,
 		logger:       logger.With().Str("component", defaultAgentName).Logger(),
 		stopCh:       make(chan struct{}),
-		cmdCh:        make(chan *nats.Msg, 256),
-		cmdDone:      make(chan struct{}),
 		readyCh:      make(chan struct{}),
 	}
 }
@@ -283,12 +286,6 @@ func (ga *GitHubAgent) shutdown() error {
 		ga.webhook.Shutdown(ctx)
 	}
 
-	// Drain command channel before closing agent connection.
-	if ga.cmdCh != nil {
-		close(ga.cmdCh)
-		<-ga.cmdDone
-	}
-
 	if ga.agent != nil {
 		ga.agent.Close()
 	}
@@ -315,93 +312,72 @@ func (ga *GitHubAgent) reloadConfig() {
 	ga.cfg.Poll.PerTick = newCfg.Poll.PerTick
 	ga.cfg.Poll.State = newCfg.Poll.State
 
+	if policy, err := protocol.LoadCommandPolicy(newCfg.Security.PolicyFile); err != nil {
+		ga.logger.Error().Err(err).Msg("failed to reload command policy")
+	} else {
+		ga.agent.SetCommandPolicy(policy)
+		ga.cfg.Security.PolicyFile = newCfg.Security.PolicyFile
+	}
+	ga.agent.SetMinSignatureVersion(newCfg.Security.MinSignatureVersion)
+	ga.cfg.Security.MinSignatureVersion = newCfg.Security.MinSignatureVersion
+	if ks, err := protocol.LoadKeySet(newCfg.Security.TrustedKeys); err != nil {
+		ga.logger.Error().Err(err).Msg("failed to reload trusted keys")
+	} else {
+		ga.agent.SetTrustedKeys(ks)
+		ga.cfg.Security.TrustedKeys = newCfg.Security.TrustedKeys
+	}
+
 	ga.logger.Info().Msg("github agent configuration reloaded")
 }
 
 // publishEvent sends a mapped GitHub event onto the NATS bus.
 func (ga *GitHubAgent) publishEvent(ev protocol.Event) {
-	data, err := json.Marshal(ev)
-	if err != nil {
-		ga.logger.Error().Err(err).Msg("marshal event")
-		return
-	}
-	if err := ga.agent.Conn().Publish(protocol.SubjectEvents("github"), data); err != nil {
-		ga.logger.Error().Err(err).Msg("publish event")
+	subject := protocol.SubjectEventsFor(ga.cfg.Events.Subjects, "github", ev.Type)
+	if err := ga.agent.PublishEvent(subject, ev); err != nil {
+		ga.logger.Error().Err(err).Str("type", ev.Type).Msg("publish event")
 		return
 	}
-	ga.agent.RecordEvent()
-}
-
-// enqueueCommand is the NATS subscription callback. It does a non-blocking
-// send to the command channel so the NATS delivery goroutine is never blocked
-// by slow GitHub API calls.
-func (ga *GitHubAgent) enqueueCommand(msg *nats.Msg) {
-	select {
-	case ga.cmdCh <- msg:
-	default:
-		ga.agent.RecordError()
-		ga.logger.Warn().Msg("command channel full, dropping command")
-	}
 }
 
-// commandLoop drains the command channel and executes commands sequentially.
-func (ga *GitHubAgent) commandLoop() {
-	defer close(ga.cmdDone)
-	for msg := range ga.cmdCh {
-		ga.executeCommand(msg)
-	}
-}
-
-// executeCommand processes a single command message from workflows.
-func (ga *GitHubAgent) executeCommand(msg *nats.Msg) {
-	var cmd protocol.Command
-	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
-		ga.agent.RecordError()
-		ga.logger.Error().Err(err).Msg("unmarshal command")
-		return
+// executeCommand processes a single command from workflows. It is called by
+// the agent SDK, which acknowledges the command, retries transient failures,
+// and publishes the outcome on sekia.results.<agent> (and replies to
+// sekia.command_sync requests).
+func (ga *GitHubAgent) executeCommand(ctx context.Context, cmd *protocol.Command) (map[string]any, error) {
+	if err := ga.agent.VerifyCommand(ctx, cmd, ga.cfg.Security.CommandSecret); err != nil {
+		return nil, err
 	}
-
-	if !protocol.VerifyCommand(&cmd, ga.cfg.Security.CommandSecret) {
-		ga.agent.RecordError()
-		ga.logger.Warn().
-			Str("command", cmd.Command).
-			Str("source", cmd.Source).
-			Msg("rejected command: invalid or missing signature")
-		return
+	if err := ga.agent.AuthorizeCommand(cmd); err != nil {
+		return nil, err
 	}
 
 	ga.logger.Info().
 		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
 		Str("source", cmd.Source).
 		Msg("received command")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	var err error
+	var (
+		result map[string]any
+		err    error
+	)
 	switch cmd.Command {
 	case "add_label":
-		err = cmdAddLabel(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdAddLabel(ctx, ga.ghClient, cmd.Payload)
 	case "remove_label":
-		err = cmdRemoveLabel(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdRemoveLabel(ctx, ga.ghClient, cmd.Payload)
 	case "create_comment":
-		err = cmdCreateComment(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdCreateComment(ctx, ga.ghClient, cmd.Payload)
 	case "close_issue":
-		err = cmdCloseIssue(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdCloseIssue(ctx, ga.ghClient, cmd.Payload)
 	case "reopen_issue":
-		err = cmdReopenIssue(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdReopenIssue(ctx, ga.ghClient, cmd.Payload)
 	case "approve_pr":
-		err = cmdApprovePR(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdApprovePR(ctx, ga.ghClient, cmd.Payload)
 	case "add_to_project":
-		err = cmdAddToProject(ctx, ga.ghClient, cmd.Payload)
+		result, err = cmdAddToProject(ctx, ga.ghClient, cmd.Payload)
 	default:
 		err = fmt.Errorf("unknown command: %s", cmd.Command)
 	}
-
-	if err != nil {
-		ga.agent.RecordError()
-		ga.logger.Error().Err(err).Str("command", cmd.Command).Msg("command failed")
-	} else {
-		ga.agent.RecordEvent()
-	}
+	return result, classifyError(err)
 }
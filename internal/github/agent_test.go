@@ -422,37 +422,37 @@ func (m *e2ePollMockClient) ListCommentsPage(_ context.Context, _, _ string, _ t
 	return nil, 0, nil
 }
 
-func (m *e2ePollMockClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
+func (m *e2ePollMockClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) ([]*gh.Label, error) {
 	// Proxy to mock HTTP server so the test can assert on the call.
 	url := fmt.Sprintf("%s/repos/%s/%s/issues/%d/labels", m.mockGHURL, owner, repo, number)
 	body, _ := json.Marshal(labels)
 	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resp.Body.Close()
-	return nil
+	return nil, nil
 }
 
-func (m *e2ePollMockClient) RemoveLabel(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *e2ePollMockClient) RemoveLabel(_ context.Context, _, _ string, _ int, _ string) ([]*gh.Label, error) {
+	return nil, nil
 }
 
-func (m *e2ePollMockClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *e2ePollMockClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) (*gh.IssueComment, error) {
+	return nil, nil
 }
 
-func (m *e2ePollMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *e2ePollMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) (*gh.Issue, error) {
+	return nil, nil
 }
 
 func (m *e2ePollMockClient) ListIssuesByLabelPage(_ context.Context, _, _ string, _ []string, _ string, _, _ int) ([]*gh.Issue, int, error) {
 	return nil, 0, nil
 }
 
-func (m *e2ePollMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *e2ePollMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) (*gh.PullRequestReview, error) {
+	return nil, nil
 }
 
 func (m *e2ePollMockClient) AddToProject(_ context.Context, _, _ string, _ int, _ string, _ []ghagent.ProjectField) (string, error) {
@@ -2,21 +2,24 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	gh "github.com/google/go-github/v68/github"
+
+	"github.com/sekia-ai/sekia/pkg/agent"
 )
 
 // GitHubClient abstracts the GitHub API methods used by commands and polling.
 type GitHubClient interface {
-	// Command methods.
-	AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error
-	RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error
-	CreateComment(ctx context.Context, owner, repo string, number int, body string) error
-	EditIssueState(ctx context.Context, owner, repo string, number int, state string) error
-	ApprovePR(ctx context.Context, owner, repo string, number int, body string) error
+	// Command methods. Each returns the resource it created or changed.
+	AddLabels(ctx context.Context, owner, repo string, number int, labels []string) ([]*gh.Label, error)
+	RemoveLabel(ctx context.Context, owner, repo string, number int, label string) ([]*gh.Label, error)
+	CreateComment(ctx context.Context, owner, repo string, number int, body string) (*gh.IssueComment, error)
+	EditIssueState(ctx context.Context, owner, repo string, number int, state string) (*gh.Issue, error)
+	ApprovePR(ctx context.Context, owner, repo string, number int, body string) (*gh.PullRequestReview, error)
 	AddToProject(ctx context.Context, owner, repo string, number int, projectID string, fields []ProjectField) (string, error)
 
 	// Polling methods — fetch a single page of results.
@@ -39,53 +42,56 @@ type realGitHubClient struct {
 	token      string       // GitHub PAT for GraphQL Authorization header
 }
 
-func (c *realGitHubClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) error {
-	_, _, err := c.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels)
-	return err
+func (c *realGitHubClient) AddLabels(ctx context.Context, owner, repo string, number int, labels []string) ([]*gh.Label, error) {
+	all, _, err := c.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels)
+	return all, err
 }
 
-func (c *realGitHubClient) RemoveLabel(ctx context.Context, owner, repo string, number int, label string) error {
-	_, err := c.client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label)
-	return err
+func (c *realGitHubClient) RemoveLabel(ctx context.Context, owner, repo string, number int, label string) ([]*gh.Label, error) {
+	if _, err := c.client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label); err != nil {
+		return nil, err
+	}
+	remaining, _, err := c.client.Issues.ListLabelsByIssue(ctx, owner, repo, number, &gh.ListOptions{PerPage: 100})
+	return remaining, err
 }
 
-func (c *realGitHubClient) CreateComment(ctx context.Context, owner, repo string, number int, body string) error {
-	_, _, err := c.client.Issues.CreateComment(ctx, owner, repo, number, &gh.IssueComment{
+func (c *realGitHubClient) CreateComment(ctx context.Context, owner, repo string, number int, body string) (*gh.IssueComment, error) {
+	comment, _, err := c.client.Issues.CreateComment(ctx, owner, repo, number, &gh.IssueComment{
 		Body: &body,
 	})
-	return err
+	return comment, err
 }
 
-func (c *realGitHubClient) EditIssueState(ctx context.Context, owner, repo string, number int, state string) error {
-	_, _, err := c.client.Issues.Edit(ctx, owner, repo, number, &gh.IssueRequest{
+func (c *realGitHubClient) EditIssueState(ctx context.Context, owner, repo string, number int, state string) (*gh.Issue, error) {
+	issue, _, err := c.client.Issues.Edit(ctx, owner, repo, number, &gh.IssueRequest{
 		State: &state,
 	})
-	return err
+	return issue, err
 }
 
-func (c *realGitHubClient) ApprovePR(ctx context.Context, owner, repo string, number int, body string) error {
+func (c *realGitHubClient) ApprovePR(ctx context.Context, owner, repo string, number int, body string) (*gh.PullRequestReview, error) {
 	// Check if we already approved this PR to avoid duplicate reviews.
 	reviews, _, err := c.client.PullRequests.ListReviews(ctx, owner, repo, number, &gh.ListOptions{PerPage: 100})
 	if err != nil {
-		return fmt.Errorf("list reviews: %w", err)
+		return nil, fmt.Errorf("list reviews: %w", err)
 	}
 	// Resolve authenticated user to compare against existing reviews.
 	me, _, err := c.client.Users.Get(ctx, "")
 	if err != nil {
-		return fmt.Errorf("get authenticated user: %w", err)
+		return nil, fmt.Errorf("get authenticated user: %w", err)
 	}
 	for _, r := range reviews {
 		if r.GetUser().GetLogin() == me.GetLogin() && r.GetState() == "APPROVED" {
-			return nil // already approved
+			return r, nil // already approved
 		}
 	}
 
 	event := "APPROVE"
-	_, _, err = c.client.PullRequests.CreateReview(ctx, owner, repo, number, &gh.PullRequestReviewRequest{
+	review, _, err := c.client.PullRequests.CreateReview(ctx, owner, repo, number, &gh.PullRequestReviewRequest{
 		Event: &event,
 		Body:  &body,
 	})
-	return err
+	return review, err
 }
 
 func (c *realGitHubClient) ListIssuesPage(ctx context.Context, owner, repo string, since time.Time, page, perPage int) ([]*gh.Issue, int, error) {
@@ -246,92 +252,165 @@ func extractString(payload map[string]any, key string) (string, error) {
 	return s, nil
 }
 
-func cmdAddLabel(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+// classifyError marks GitHub API failures worth retrying (rate limits and
+// server errors) as transient so the agent SDK redelivers the command.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var rateErr *gh.RateLimitError
+	var abuseErr *gh.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return agent.Transient(err)
+	}
+	var respErr *gh.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil && agent.TransientStatus(respErr.Response.StatusCode) {
+		return agent.Transient(err)
+	}
+	return err
+}
+
+// issueRef is the result reported for commands that act on an issue or PR.
+func issueRef(owner, repo string, number int) map[string]any {
+	return map[string]any{"owner": owner, "repo": repo, "number": number}
+}
+
+// labelsResult is the result of the label commands: the issue's labels
+// after the change.
+func labelsResult(owner, repo string, number int, labels []*gh.Label) map[string]any {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.GetName())
+	}
+	res := issueRef(owner, repo, number)
+	res["labels"] = names
+	return res
+}
+
+// issueStateResult is the result of the close and reopen commands.
+func issueStateResult(owner, repo string, number int, issue *gh.Issue) map[string]any {
+	res := issueRef(owner, repo, number)
+	res["state"] = issue.GetState()
+	res["html_url"] = issue.GetHTMLURL()
+	return res
+}
+
+func cmdAddLabel(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	label, err := extractString(payload, "label")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ghc.AddLabels(ctx, owner, repo, number, []string{label})
+	labels, err := ghc.AddLabels(ctx, owner, repo, number, []string{label})
+	if err != nil {
+		return nil, err
+	}
+	return labelsResult(owner, repo, number, labels), nil
 }
 
-func cmdRemoveLabel(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+func cmdRemoveLabel(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	label, err := extractString(payload, "label")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ghc.RemoveLabel(ctx, owner, repo, number, label)
+	labels, err := ghc.RemoveLabel(ctx, owner, repo, number, label)
+	if err != nil {
+		return nil, err
+	}
+	return labelsResult(owner, repo, number, labels), nil
 }
 
-func cmdCreateComment(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+func cmdCreateComment(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	body, err := extractString(payload, "body")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	comment, err := ghc.CreateComment(ctx, owner, repo, number, body)
+	if err != nil {
+		return nil, err
 	}
-	return ghc.CreateComment(ctx, owner, repo, number, body)
+	res := issueRef(owner, repo, number)
+	res["comment_id"] = comment.GetID()
+	res["html_url"] = comment.GetHTMLURL()
+	return res, nil
 }
 
-func cmdCloseIssue(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+func cmdCloseIssue(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ghc.EditIssueState(ctx, owner, repo, number, "closed")
+	issue, err := ghc.EditIssueState(ctx, owner, repo, number, "closed")
+	if err != nil {
+		return nil, err
+	}
+	return issueStateResult(owner, repo, number, issue), nil
 }
 
-func cmdReopenIssue(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+func cmdReopenIssue(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	issue, err := ghc.EditIssueState(ctx, owner, repo, number, "open")
+	if err != nil {
+		return nil, err
 	}
-	return ghc.EditIssueState(ctx, owner, repo, number, "open")
+	return issueStateResult(owner, repo, number, issue), nil
 }
 
-func cmdApprovePR(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+func cmdApprovePR(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	body, _ := payload["body"].(string) // optional
-	return ghc.ApprovePR(ctx, owner, repo, number, body)
+	review, err := ghc.ApprovePR(ctx, owner, repo, number, body)
+	if err != nil {
+		return nil, err
+	}
+	res := issueRef(owner, repo, number)
+	res["review_id"] = review.GetID()
+	res["html_url"] = review.GetHTMLURL()
+	return res, nil
 }
 
-func cmdAddToProject(ctx context.Context, ghc GitHubClient, payload map[string]any) error {
+func cmdAddToProject(ctx context.Context, ghc GitHubClient, payload map[string]any) (map[string]any, error) {
 	owner, repo, number, err := extractRepoRef(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	projectID, err := extractString(payload, "project_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var fields []ProjectField
 	if raw, ok := payload["fields"]; ok {
 		arr, ok := raw.([]any)
 		if !ok {
-			return fmt.Errorf("fields must be an array")
+			return nil, fmt.Errorf("fields must be an array")
 		}
 		for _, item := range arr {
 			m, ok := item.(map[string]any)
 			if !ok {
-				return fmt.Errorf("each field must be an object")
+				return nil, fmt.Errorf("each field must be an object")
 			}
 			f := ProjectField{}
 			fid, err := extractString(m, "field_id")
 			if err != nil {
-				return err
+				return nil, err
 			}
 			f.FieldID = fid
 			if v, ok := m["text"].(string); ok {
@@ -353,6 +432,11 @@ func cmdAddToProject(ctx context.Context, ghc GitHubClient, payload map[string]a
 		}
 	}
 
-	_, err = ghc.AddToProject(ctx, owner, repo, number, projectID, fields)
-	return err
+	itemID, err := ghc.AddToProject(ctx, owner, repo, number, projectID, fields)
+	if err != nil {
+		return nil, err
+	}
+	res := issueRef(owner, repo, number)
+	res["item_id"] = itemID
+	return res, nil
 }
@@ -3,6 +3,7 @@ package github
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -22,29 +23,42 @@ type mockCall struct {
 	Args   []string
 }
 
-func (m *mockGitHubClient) AddLabels(_ context.Context, owner, repo string, number int, labels []string) error {
+func (m *mockGitHubClient) AddLabels(_ context.Context, owner, repo string, number int, labels []string) ([]*gh.Label, error) {
 	m.calls = append(m.calls, mockCall{"AddLabels", owner, repo, number, labels})
-	return nil
+	all := []*gh.Label{{Name: gh.Ptr("triage")}}
+	for _, l := range labels {
+		all = append(all, &gh.Label{Name: gh.Ptr(l)})
+	}
+	return all, nil
 }
 
-func (m *mockGitHubClient) RemoveLabel(_ context.Context, owner, repo string, number int, label string) error {
+func (m *mockGitHubClient) RemoveLabel(_ context.Context, owner, repo string, number int, label string) ([]*gh.Label, error) {
 	m.calls = append(m.calls, mockCall{"RemoveLabel", owner, repo, number, []string{label}})
-	return nil
+	return []*gh.Label{{Name: gh.Ptr("triage")}}, nil
 }
 
-func (m *mockGitHubClient) CreateComment(_ context.Context, owner, repo string, number int, body string) error {
+func (m *mockGitHubClient) CreateComment(_ context.Context, owner, repo string, number int, body string) (*gh.IssueComment, error) {
 	m.calls = append(m.calls, mockCall{"CreateComment", owner, repo, number, []string{body}})
-	return nil
+	return &gh.IssueComment{
+		ID:      gh.Ptr(int64(1001)),
+		HTMLURL: gh.Ptr(fmt.Sprintf("https://github.com/%s/%s/issues/%d#issuecomment-1001", owner, repo, number)),
+	}, nil
 }
 
-func (m *mockGitHubClient) EditIssueState(_ context.Context, owner, repo string, number int, state string) error {
+func (m *mockGitHubClient) EditIssueState(_ context.Context, owner, repo string, number int, state string) (*gh.Issue, error) {
 	m.calls = append(m.calls, mockCall{"EditIssueState", owner, repo, number, []string{state}})
-	return nil
+	return &gh.Issue{
+		State:   gh.Ptr(state),
+		HTMLURL: gh.Ptr(fmt.Sprintf("https://github.com/%s/%s/issues/%d", owner, repo, number)),
+	}, nil
 }
 
-func (m *mockGitHubClient) ApprovePR(_ context.Context, owner, repo string, number int, body string) error {
+func (m *mockGitHubClient) ApprovePR(_ context.Context, owner, repo string, number int, body string) (*gh.PullRequestReview, error) {
 	m.calls = append(m.calls, mockCall{"ApprovePR", owner, repo, number, []string{body}})
-	return nil
+	return &gh.PullRequestReview{
+		ID:      gh.Ptr(int64(2002)),
+		HTMLURL: gh.Ptr(fmt.Sprintf("https://github.com/%s/%s/pull/%d#pullrequestreview-2002", owner, repo, number)),
+	}, nil
 }
 
 func (m *mockGitHubClient) ListPRsByStatePage(_ context.Context, _, _ string, _ string, _ []string, _, _ int) ([]*gh.PullRequest, int, error) {
@@ -74,7 +88,7 @@ func (m *mockGitHubClient) ListIssuesByLabelPage(_ context.Context, _, _ string,
 
 func TestCmdAddLabel(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdAddLabel(context.Background(), mock, map[string]any{
+	res, err := cmdAddLabel(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(42),
@@ -93,11 +107,15 @@ func TestCmdAddLabel(t *testing.T) {
 	if len(c.Args) != 1 || c.Args[0] != "bug" {
 		t.Errorf("unexpected labels: %v", c.Args)
 	}
+	want := map[string]any{"owner": "myorg", "repo": "myrepo", "number": 42, "labels": []string{"triage", "bug"}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("result = %v, want %v", res, want)
+	}
 }
 
 func TestCmdRemoveLabel(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdRemoveLabel(context.Background(), mock, map[string]any{
+	res, err := cmdRemoveLabel(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(1),
@@ -109,11 +127,14 @@ func TestCmdRemoveLabel(t *testing.T) {
 	if mock.calls[0].Method != "RemoveLabel" || mock.calls[0].Args[0] != "wontfix" {
 		t.Errorf("unexpected call: %+v", mock.calls[0])
 	}
+	if labels, _ := res["labels"].([]string); !reflect.DeepEqual(labels, []string{"triage"}) {
+		t.Errorf("labels = %v, want [triage]", res["labels"])
+	}
 }
 
 func TestCmdCreateComment(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdCreateComment(context.Background(), mock, map[string]any{
+	res, err := cmdCreateComment(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(5),
@@ -125,11 +146,18 @@ func TestCmdCreateComment(t *testing.T) {
 	if mock.calls[0].Method != "CreateComment" || mock.calls[0].Args[0] != "Hello, world!" {
 		t.Errorf("unexpected call: %+v", mock.calls[0])
 	}
+	want := map[string]any{
+		"owner": "myorg", "repo": "myrepo", "number": 5,
+		"comment_id": int64(1001), "html_url": "https://github.com/myorg/myrepo/issues/5#issuecomment-1001",
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("result = %v, want %v", res, want)
+	}
 }
 
 func TestCmdCloseIssue(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdCloseIssue(context.Background(), mock, map[string]any{
+	res, err := cmdCloseIssue(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(10),
@@ -140,11 +168,14 @@ func TestCmdCloseIssue(t *testing.T) {
 	if mock.calls[0].Method != "EditIssueState" || mock.calls[0].Args[0] != "closed" {
 		t.Errorf("unexpected call: %+v", mock.calls[0])
 	}
+	if res["state"] != "closed" || res["html_url"] != "https://github.com/myorg/myrepo/issues/10" {
+		t.Errorf("result = %v", res)
+	}
 }
 
 func TestCmdReopenIssue(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdReopenIssue(context.Background(), mock, map[string]any{
+	res, err := cmdReopenIssue(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(10),
@@ -155,11 +186,14 @@ func TestCmdReopenIssue(t *testing.T) {
 	if mock.calls[0].Method != "EditIssueState" || mock.calls[0].Args[0] != "open" {
 		t.Errorf("unexpected call: %+v", mock.calls[0])
 	}
+	if res["state"] != "open" {
+		t.Errorf("state = %v, want open", res["state"])
+	}
 }
 
 func TestCmdApprovePR(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdApprovePR(context.Background(), mock, map[string]any{
+	res, err := cmdApprovePR(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(7),
@@ -171,11 +205,14 @@ func TestCmdApprovePR(t *testing.T) {
 	if mock.calls[0].Method != "ApprovePR" || mock.calls[0].Args[0] != "LGTM" {
 		t.Errorf("unexpected call: %+v", mock.calls[0])
 	}
+	if res["review_id"] != int64(2002) || res["html_url"] != "https://github.com/myorg/myrepo/pull/7#pullrequestreview-2002" {
+		t.Errorf("result = %v", res)
+	}
 }
 
 func TestCmdApprovePRNoBody(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdApprovePR(context.Background(), mock, map[string]any{
+	_, err := cmdApprovePR(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(7),
@@ -190,7 +227,7 @@ func TestCmdApprovePRNoBody(t *testing.T) {
 
 func TestCmdAddToProject(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdAddToProject(context.Background(), mock, map[string]any{
+	_, err := cmdAddToProject(context.Background(), mock, map[string]any{
 		"owner":      "myorg",
 		"repo":       "myrepo",
 		"number":     float64(10),
@@ -213,7 +250,7 @@ func TestCmdAddToProject(t *testing.T) {
 
 func TestCmdAddToProjectWithFields(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdAddToProject(context.Background(), mock, map[string]any{
+	_, err := cmdAddToProject(context.Background(), mock, map[string]any{
 		"owner":      "myorg",
 		"repo":       "myrepo",
 		"number":     float64(10),
@@ -233,7 +270,7 @@ func TestCmdAddToProjectWithFields(t *testing.T) {
 
 func TestCmdAddToProjectMissingProjectID(t *testing.T) {
 	mock := &mockGitHubClient{}
-	err := cmdAddToProject(context.Background(), mock, map[string]any{
+	_, err := cmdAddToProject(context.Background(), mock, map[string]any{
 		"owner":  "myorg",
 		"repo":   "myrepo",
 		"number": float64(10),
@@ -258,7 +295,7 @@ func TestCmdMissingFields(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := cmdAddLabel(context.Background(), mock, tt.payload)
+			_, err := cmdAddLabel(context.Background(), mock, tt.payload)
 			if err == nil {
 				t.Error("expected error for missing field")
 			}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
 )
 
 func TestMapWebhookEvent_IssueOpened(t *testing.T) {
@@ -136,6 +138,32 @@ func TestMapWebhookEvent_UnsupportedAction(t *testing.T) {
 	}
 }
 
+func TestMapWebhookEvent_MatchesSchemas(t *testing.T) {
+	deliveries := []struct {
+		event   string
+		payload []byte
+	}{
+		{"issues", issueWebhookJSON("opened", "myorg", "myrepo", 1, "Bug", "alice")},
+		{"issues", issueWebhookJSON("closed", "myorg", "myrepo", 1, "Bug", "alice")},
+		{"pull_request", prWebhookJSON("opened", "myorg", "myrepo", 2, "Feature", "bob", false)},
+		{"pull_request", prWebhookJSON("closed", "myorg", "myrepo", 2, "Feature", "bob", true)},
+		{"push", pushWebhookJSON("myorg", "myrepo", "refs/heads/main", "abc123", "def456", "alice", "fix: typo")},
+		{"issue_comment", commentWebhookJSON("created", "myorg", "myrepo", 1, 999, "Looks good!", "carol")},
+	}
+	for _, d := range deliveries {
+		ev, ok := MapWebhookEvent(d.event, d.payload)
+		if !ok {
+			t.Fatalf("%s delivery not mapped", d.event)
+		}
+		if ev.SchemaVersion == 0 {
+			t.Errorf("%s: no schema registered", ev.Type)
+		}
+		if err := protocol.ValidateEvent(ev); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 // --- Test helpers: build minimal GitHub webhook JSON ---
 
 func issueWebhookJSON(action, owner, repo string, number int, title, author string) []byte {
@@ -24,29 +24,29 @@ type pollMockClient struct {
 	calls    []mockCall
 }
 
-func (m *pollMockClient) AddLabels(_ context.Context, owner, repo string, number int, labels []string) error {
+func (m *pollMockClient) AddLabels(_ context.Context, owner, repo string, number int, labels []string) ([]*gh.Label, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.calls = append(m.calls, mockCall{"AddLabels", owner, repo, number, labels})
-	return nil
+	return nil, nil
 }
 
-func (m *pollMockClient) RemoveLabel(_ context.Context, owner, repo string, number int, label string) error {
-	return nil
+func (m *pollMockClient) RemoveLabel(_ context.Context, owner, repo string, number int, label string) ([]*gh.Label, error) {
+	return nil, nil
 }
 
-func (m *pollMockClient) CreateComment(_ context.Context, owner, repo string, number int, body string) error {
+func (m *pollMockClient) CreateComment(_ context.Context, owner, repo string, number int, body string) (*gh.IssueComment, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.calls = append(m.calls, mockCall{"CreateComment", owner, repo, number, []string{body}})
-	return nil
+	return nil, nil
 }
 
-func (m *pollMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *pollMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) (*gh.Issue, error) {
+	return nil, nil
 }
-func (m *pollMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *pollMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) (*gh.PullRequestReview, error) {
+	return nil, nil
 }
 func (m *pollMockClient) AddToProject(_ context.Context, _, _ string, _ int, _ string, _ []ProjectField) (string, error) {
 	return "", nil
@@ -309,20 +309,20 @@ type paginatingMockClient struct {
 	issueErr error
 }
 
-func (m *paginatingMockClient) AddLabels(_ context.Context, _, _ string, _ int, _ []string) error {
-	return nil
+func (m *paginatingMockClient) AddLabels(_ context.Context, _, _ string, _ int, _ []string) ([]*gh.Label, error) {
+	return nil, nil
 }
-func (m *paginatingMockClient) RemoveLabel(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *paginatingMockClient) RemoveLabel(_ context.Context, _, _ string, _ int, _ string) ([]*gh.Label, error) {
+	return nil, nil
 }
-func (m *paginatingMockClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *paginatingMockClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) (*gh.IssueComment, error) {
+	return nil, nil
 }
-func (m *paginatingMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *paginatingMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) (*gh.Issue, error) {
+	return nil, nil
 }
-func (m *paginatingMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *paginatingMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) (*gh.PullRequestReview, error) {
+	return nil, nil
 }
 func (m *paginatingMockClient) AddToProject(_ context.Context, _, _ string, _ int, _ string, _ []ProjectField) (string, error) {
 	return "", nil
@@ -651,20 +651,20 @@ type labelMockClient struct {
 	labelErr error
 }
 
-func (m *labelMockClient) AddLabels(_ context.Context, _, _ string, _ int, _ []string) error {
-	return nil
+func (m *labelMockClient) AddLabels(_ context.Context, _, _ string, _ int, _ []string) ([]*gh.Label, error) {
+	return nil, nil
 }
-func (m *labelMockClient) RemoveLabel(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *labelMockClient) RemoveLabel(_ context.Context, _, _ string, _ int, _ string) ([]*gh.Label, error) {
+	return nil, nil
 }
-func (m *labelMockClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *labelMockClient) CreateComment(_ context.Context, _, _ string, _ int, _ string) (*gh.IssueComment, error) {
+	return nil, nil
 }
-func (m *labelMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *labelMockClient) EditIssueState(_ context.Context, _, _ string, _ int, _ string) (*gh.Issue, error) {
+	return nil, nil
 }
-func (m *labelMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) error {
-	return nil
+func (m *labelMockClient) ApprovePR(_ context.Context, _, _ string, _ int, _ string) (*gh.PullRequestReview, error) {
+	return nil, nil
 }
 func (m *labelMockClient) AddToProject(_ context.Context, _, _ string, _ int, _ string, _ []ProjectField) (string, error) {
 	return "", nil
@@ -2,7 +2,6 @@ package google
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -13,6 +12,7 @@ import (
 	"github.com/rs/zerolog"
 	"golang.org/x/oauth2"
 
+	"github.com/sekia-ai/sekia/internal/secrets"
 	"github.com/sekia-ai/sekia/pkg/agent"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -133,13 +133,28 @@ func (ga *GoogleAgent) Run() error {
 	}
 
 	// 2. Connect to NATS via the agent SDK.
-	natsOpts := ga.natsOpts
-	if ga.cfg.NATS.Token != "" {
-		natsOpts = append(natsOpts, nats.Token(ga.cfg.NATS.Token))
+	natsOpts, err := secrets.NATSOptions(ga.cfg.NATS.NATSClientConfig)
+	if err != nil {
+		return err
+	}
+	natsOpts = append(ga.natsOpts, natsOpts...)
+	policy, err := protocol.LoadCommandPolicy(ga.cfg.Security.PolicyFile)
+	if err != nil {
+		return err
+	}
+	trustedKeys, err := protocol.LoadKeySet(ga.cfg.Security.TrustedKeys)
+	if err != nil {
+		return err
 	}
 	agentCfg := agent.Config{
-		NATSUrl:  ga.cfg.NATS.URL,
-		NATSOpts: natsOpts,
+		NATSUrl:         ga.cfg.NATS.URL,
+		NATSOpts:        natsOpts,
+		EventValidation: ga.cfg.Events.Validation,
+		CommandSchemas:  protocol.CommandSchemas("google"),
+		CommandPolicy:   policy,
+
+		MinSignatureVersion: ga.cfg.Security.MinSignatureVersion,
+		TrustedKeys:         trustedKeys,
 	}
 	a, err := agent.New(
 		agentCfg, ga.instanceName, agentVersion,
@@ -156,11 +171,10 @@ func (ga *GoogleAgent) Run() error {
 		ga.logger.Warn().Err(err).Msg("failed to register config reload handler")
 	}
 
-	// 3. Subscribe to commands.
-	_, err = a.Conn().Subscribe(protocol.SubjectCommands(ga.instanceName), ga.handleCommand)
-	if err != nil {
+	// 3. Consume commands from the durable work queue.
+	if err := a.ServeCommands(agent.CommandOptions{}, ga.handleCommand); err != nil {
 		a.Close()
-		return fmt.Errorf("subscribe commands: %w", err)
+		return fmt.Errorf("serve commands: %w", err)
 	}
 
 	// 4. Start pollers.
@@ -266,83 +280,83 @@ func (ga *GoogleAgent) reloadConfig() {
 
 	ga.cfg.Security.CommandSecret = newCfg.Security.CommandSecret
 
+	if policy, err := protocol.LoadCommandPolicy(newCfg.Security.PolicyFile); err != nil {
+		ga.logger.Error().Err(err).Msg("failed to reload command policy")
+	} else {
+		ga.agent.SetCommandPolicy(policy)
+		ga.cfg.Security.PolicyFile = newCfg.Security.PolicyFile
+	}
+	ga.agent.SetMinSignatureVersion(newCfg.Security.MinSignatureVersion)
+	ga.cfg.Security.MinSignatureVersion = newCfg.Security.MinSignatureVersion
+	if ks, err := protocol.LoadKeySet(newCfg.Security.TrustedKeys); err != nil {
+		ga.logger.Error().Err(err).Msg("failed to reload trusted keys")
+	} else {
+		ga.agent.SetTrustedKeys(ks)
+		ga.cfg.Security.TrustedKeys = newCfg.Security.TrustedKeys
+	}
+
 	ga.logger.Info().Msg("google agent configuration reloaded")
 }
 
 func (ga *GoogleAgent) publishEvent(ev protocol.Event) {
-	data, err := json.Marshal(ev)
-	if err != nil {
-		ga.logger.Error().Err(err).Msg("marshal event")
-		return
-	}
-	if err := ga.agent.Conn().Publish(protocol.SubjectEvents("google"), data); err != nil {
-		ga.logger.Error().Err(err).Msg("publish event")
+	subject := protocol.SubjectEventsFor(ga.cfg.Events.Subjects, "google", ev.Type)
+	if err := ga.agent.PublishEvent(subject, ev); err != nil {
+		ga.logger.Error().Err(err).Str("type", ev.Type).Msg("publish event")
 		return
 	}
-	ga.agent.RecordEvent()
 }
 
-func (ga *GoogleAgent) handleCommand(msg *nats.Msg) {
-	var cmd protocol.Command
-	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
-		ga.agent.RecordError()
-		ga.logger.Error().Err(err).Msg("unmarshal command")
-		return
+// handleCommand executes a single command from workflows. It is called by
+// the agent SDK, which acknowledges the command, retries transient failures,
+// and publishes the outcome on sekia.results.<agent> (and replies to
+// sekia.command_sync requests). create_event reports the new event_id.
+func (ga *GoogleAgent) handleCommand(ctx context.Context, cmd *protocol.Command) (map[string]any, error) {
+	if err := ga.agent.VerifyCommand(ctx, cmd, ga.cfg.Security.CommandSecret); err != nil {
+		return nil, err
 	}
-
-	if !protocol.VerifyCommand(&cmd, ga.cfg.Security.CommandSecret) {
-		ga.agent.RecordError()
-		ga.logger.Warn().
-			Str("command", cmd.Command).
-			Str("source", cmd.Source).
-			Msg("rejected command: invalid or missing signature")
-		return
+	if err := ga.agent.AuthorizeCommand(cmd); err != nil {
+		return nil, err
 	}
 
 	ga.logger.Info().
 		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
 		Str("source", cmd.Source).
 		Msg("received command")
 
-	ctx, ctxCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer ctxCancel()
-
-	var err error
+	var (
+		result map[string]any
+		err    error
+	)
 	switch cmd.Command {
 	// Gmail commands
 	case "send_email":
-		err = cmdGmailSendEmail(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailSendEmail(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "reply_email":
-		err = cmdGmailReplyEmail(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailReplyEmail(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "add_label":
-		err = cmdGmailAddLabel(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailAddLabel(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "remove_label":
-		err = cmdGmailRemoveLabel(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailRemoveLabel(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "archive":
-		err = cmdGmailArchive(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailArchive(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "trash":
-		err = cmdGmailTrash(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailTrash(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "untrash":
-		err = cmdGmailUntrash(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailUntrash(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 	case "delete":
-		err = cmdGmailDelete(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
+		result, err = cmdGmailDelete(ctx, ga.gmailClient, ga.cfg.Gmail.UserID, cmd.Payload)
 
 	// Calendar commands
 	case "create_event":
-		err = cmdCalendarCreateEvent(ctx, ga.calendarClient, ga.cfg.Calendar.CalendarID, cmd.Payload)
+		result, err = cmdCalendarCreateEvent(ctx, ga.calendarClient, ga.cfg.Calendar.CalendarID, cmd.Payload)
 	case "update_event":
-		err = cmdCalendarUpdateEvent(ctx, ga.calendarClient, ga.cfg.Calendar.CalendarID, cmd.Payload)
+		result, err = cmdCalendarUpdateEvent(ctx, ga.calendarClient, ga.cfg.Calendar.CalendarID, cmd.Payload)
 	case "delete_event":
-		err = cmdCalendarDeleteEvent(ctx, ga.calendarClient, ga.cfg.Calendar.CalendarID, cmd.Payload)
+		result, err = cmdCalendarDeleteEvent(ctx, ga.calendarClient, ga.cfg.Calendar.CalendarID, cmd.Payload)
 
 	default:
 		err = fmt.Errorf("unknown command: %s", cmd.Command)
 	}
-
-	if err != nil {
-		ga.agent.RecordError()
-		ga.logger.Error().Err(err).Str("command", cmd.Command).Msg("command failed")
-	} else {
-		ga.agent.RecordEvent()
-	}
+	return result, classifyError(err)
 }
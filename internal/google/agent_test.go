@@ -288,24 +288,24 @@ func (m *mockGmailClient) ListMessages(_ context.Context, _ string, _ string, _
 	return msgs, nil
 }
 
-func (m *mockGmailClient) SendEmail(_ context.Context, _, to, subject, body string) error {
+func (m *mockGmailClient) SendEmail(_ context.Context, _, to, subject, body string) (string, string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.gmailCalls = append(m.gmailCalls, mockCommandCall{
 		Method: "SendEmail",
 		Args:   map[string]string{"to": to, "subject": subject, "body": body},
 	})
-	return nil
+	return "sent-001", "thread-sent-001", nil
 }
 
-func (m *mockGmailClient) ReplyEmail(_ context.Context, _, threadID, inReplyTo, to, subject, body string) error {
+func (m *mockGmailClient) ReplyEmail(_ context.Context, _, threadID, inReplyTo, to, subject, body string) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.gmailCalls = append(m.gmailCalls, mockCommandCall{
 		Method: "ReplyEmail",
 		Args:   map[string]string{"thread_id": threadID, "to": to, "subject": subject, "body": body, "in_reply_to": inReplyTo},
 	})
-	return nil
+	return "reply-001", nil
 }
 
 func (m *mockGmailClient) AddLabel(_ context.Context, _, messageID, label string) error {
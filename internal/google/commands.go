@@ -2,10 +2,25 @@ package google
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/sekia-ai/sekia/pkg/agent"
 )
 
+// classifyError marks Google API failures worth retrying (rate limits and
+// server errors) as transient so the agent SDK redelivers the command.
+func classifyError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && agent.TransientStatus(apiErr.Code) {
+		return agent.Transient(err)
+	}
+	return err
+}
+
 // extractString extracts a required string field from the payload.
 func extractString(payload map[string]any, key string) (string, error) {
 	val, ok := payload[key]
@@ -53,122 +68,148 @@ func extractStringSlice(payload map[string]any, key string) []string {
 
 // --- Gmail commands ---
 
-func cmdGmailSendEmail(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailSendEmail(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	to, err := extractString(payload, "to")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	subject, err := extractString(payload, "subject")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	body, err := extractString(payload, "body")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return gc.SendEmail(ctx, userID, to, subject, body)
+	messageID, threadID, err := gc.SendEmail(ctx, userID, to, subject, body)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"message_id": messageID, "thread_id": threadID}, nil
 }
 
-func cmdGmailReplyEmail(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailReplyEmail(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	threadID, err := extractString(payload, "thread_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	to, err := extractString(payload, "to")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	subject, err := extractString(payload, "subject")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	body, err := extractString(payload, "body")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	inReplyTo := extractOptionalString(payload, "in_reply_to")
-	return gc.ReplyEmail(ctx, userID, threadID, inReplyTo, to, subject, body)
+	messageID, err := gc.ReplyEmail(ctx, userID, threadID, inReplyTo, to, subject, body)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"message_id": messageID, "thread_id": threadID}, nil
 }
 
-func cmdGmailAddLabel(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailAddLabel(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	messageID, err := extractString(payload, "message_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	label, err := extractString(payload, "label")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := gc.AddLabel(ctx, userID, messageID, label); err != nil {
+		return nil, err
 	}
-	return gc.AddLabel(ctx, userID, messageID, label)
+	return map[string]any{"message_id": messageID, "label": label}, nil
 }
 
-func cmdGmailRemoveLabel(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailRemoveLabel(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	messageID, err := extractString(payload, "message_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	label, err := extractString(payload, "label")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return gc.RemoveLabel(ctx, userID, messageID, label)
+	if err := gc.RemoveLabel(ctx, userID, messageID, label); err != nil {
+		return nil, err
+	}
+	return map[string]any{"message_id": messageID, "label": label}, nil
 }
 
-func cmdGmailArchive(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailArchive(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	messageID, err := extractString(payload, "message_id")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := gc.Archive(ctx, userID, messageID); err != nil {
+		return nil, err
 	}
-	return gc.Archive(ctx, userID, messageID)
+	return map[string]any{"message_id": messageID}, nil
 }
 
-func cmdGmailTrash(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailTrash(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	messageID, err := extractString(payload, "message_id")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := gc.Trash(ctx, userID, messageID); err != nil {
+		return nil, err
 	}
-	return gc.Trash(ctx, userID, messageID)
+	return map[string]any{"message_id": messageID}, nil
 }
 
-func cmdGmailUntrash(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailUntrash(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	messageID, err := extractString(payload, "message_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return gc.Untrash(ctx, userID, messageID)
+	if err := gc.Untrash(ctx, userID, messageID); err != nil {
+		return nil, err
+	}
+	return map[string]any{"message_id": messageID}, nil
 }
 
-func cmdGmailDelete(ctx context.Context, gc GmailClient, userID string, payload map[string]any) error {
+func cmdGmailDelete(ctx context.Context, gc GmailClient, userID string, payload map[string]any) (map[string]any, error) {
 	messageID, err := extractString(payload, "message_id")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := gc.Delete(ctx, userID, messageID); err != nil {
+		return nil, err
 	}
-	return gc.Delete(ctx, userID, messageID)
+	return map[string]any{"message_id": messageID}, nil
 }
 
 // --- Calendar commands ---
 
-func cmdCalendarCreateEvent(ctx context.Context, cc CalendarClient, calendarID string, payload map[string]any) error {
+func cmdCalendarCreateEvent(ctx context.Context, cc CalendarClient, calendarID string, payload map[string]any) (map[string]any, error) {
 	summary, err := extractString(payload, "summary")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	startStr, err := extractString(payload, "start")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	endStr, err := extractString(payload, "end")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	start, err := parseTime(startStr)
 	if err != nil {
-		return fmt.Errorf("parse start: %w", err)
+		return nil, fmt.Errorf("parse start: %w", err)
 	}
 	end, err := parseTime(endStr)
 	if err != nil {
-		return fmt.Errorf("parse end: %w", err)
+		return nil, fmt.Errorf("parse end: %w", err)
 	}
 
 	ev := CalendarEvent{
@@ -180,14 +221,17 @@ func cmdCalendarCreateEvent(ctx context.Context, cc CalendarClient, calendarID s
 		Attendees:   extractStringSlice(payload, "attendees"),
 	}
 
-	_, err = cc.CreateEvent(ctx, calendarID, ev)
-	return err
+	eventID, err := cc.CreateEvent(ctx, calendarID, ev)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"event_id": eventID}, nil
 }
 
-func cmdCalendarUpdateEvent(ctx context.Context, cc CalendarClient, calendarID string, payload map[string]any) error {
+func cmdCalendarUpdateEvent(ctx context.Context, cc CalendarClient, calendarID string, payload map[string]any) (map[string]any, error) {
 	eventID, err := extractString(payload, "event_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	updates := make(map[string]any)
@@ -197,15 +241,21 @@ func cmdCalendarUpdateEvent(ctx context.Context, cc CalendarClient, calendarID s
 		}
 	}
 
-	return cc.UpdateEvent(ctx, calendarID, eventID, updates)
+	if err := cc.UpdateEvent(ctx, calendarID, eventID, updates); err != nil {
+		return nil, err
+	}
+	return map[string]any{"event_id": eventID}, nil
 }
 
-func cmdCalendarDeleteEvent(ctx context.Context, cc CalendarClient, calendarID string, payload map[string]any) error {
+func cmdCalendarDeleteEvent(ctx context.Context, cc CalendarClient, calendarID string, payload map[string]any) (map[string]any, error) {
 	eventID, err := extractString(payload, "event_id")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := cc.DeleteEvent(ctx, calendarID, eventID); err != nil {
+		return nil, err
 	}
-	return cc.DeleteEvent(ctx, calendarID, eventID)
+	return map[string]any{"event_id": eventID}, nil
 }
 
 func parseTime(s string) (time.Time, error) {
@@ -0,0 +1,60 @@
+package google
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// sendMockGmailClient implements the send methods of GmailClient; the
+// others are not called by these tests.
+type sendMockGmailClient struct {
+	GmailClient
+	threadID string // thread the last send went to
+}
+
+func (m *sendMockGmailClient) SendEmail(_ context.Context, _, _, _, _ string) (string, string, error) {
+	m.threadID = "thread-new"
+	return "msg-sent", m.threadID, nil
+}
+
+func (m *sendMockGmailClient) ReplyEmail(_ context.Context, _, threadID, _, _, _, _ string) (string, error) {
+	m.threadID = threadID
+	return "msg-reply", nil
+}
+
+func TestCmdGmailSendEmail(t *testing.T) {
+	res, err := cmdGmailSendEmail(context.Background(), &sendMockGmailClient{}, "me", map[string]any{
+		"to":      "bob@example.com",
+		"subject": "Hello",
+		"body":    "Hi Bob",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{"message_id": "msg-sent", "thread_id": "thread-new"}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("result = %v, want %v", res, want)
+	}
+}
+
+func TestCmdGmailReplyEmail(t *testing.T) {
+	mock := &sendMockGmailClient{}
+	res, err := cmdGmailReplyEmail(context.Background(), mock, "me", map[string]any{
+		"thread_id":   "thread-001",
+		"in_reply_to": "<msg-1@example.com>",
+		"to":          "alice@example.com",
+		"subject":     "Re: Hello",
+		"body":        "Thanks",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.threadID != "thread-001" {
+		t.Errorf("replied in thread %q, want thread-001", mock.threadID)
+	}
+	want := map[string]any{"message_id": "msg-reply", "thread_id": "thread-001"}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("result = %v, want %v", res, want)
+	}
+}
@@ -35,8 +35,8 @@ type GmailClient interface {
 	ListMessages(ctx context.Context, userID string, query string, maxResults int64) ([]EmailMessage, error)
 
 	// Commands
-	SendEmail(ctx context.Context, userID, to, subject, body string) error
-	ReplyEmail(ctx context.Context, userID, threadID, inReplyTo, to, subject, body string) error
+	SendEmail(ctx context.Context, userID, to, subject, body string) (messageID, threadID string, err error)
+	ReplyEmail(ctx context.Context, userID, threadID, inReplyTo, to, subject, body string) (messageID string, err error)
 	AddLabel(ctx context.Context, userID, messageID, labelName string) error
 	RemoveLabel(ctx context.Context, userID, messageID, labelName string) error
 	Archive(ctx context.Context, userID, messageID string) error
@@ -219,27 +219,27 @@ func extractTextFromHTML(rawHTML string) string {
 	return strings.Join(fields, " ")
 }
 
-func (c *realGmailClient) SendEmail(ctx context.Context, userID, to, subject, body string) error {
+func (c *realGmailClient) SendEmail(ctx context.Context, userID, to, subject, body string) (string, string, error) {
 	raw := buildRFC2822("", to, subject, body, "", "")
 	msg := &gmail.Message{Raw: base64.URLEncoding.EncodeToString([]byte(raw))}
-	_, err := c.svc.Users.Messages.Send(userID, msg).Context(ctx).Do()
+	sent, err := c.svc.Users.Messages.Send(userID, msg).Context(ctx).Do()
 	if err != nil {
-		return fmt.Errorf("send email: %w", err)
+		return "", "", fmt.Errorf("send email: %w", err)
 	}
-	return nil
+	return sent.Id, sent.ThreadId, nil
 }
 
-func (c *realGmailClient) ReplyEmail(ctx context.Context, userID, threadID, inReplyTo, to, subject, body string) error {
+func (c *realGmailClient) ReplyEmail(ctx context.Context, userID, threadID, inReplyTo, to, subject, body string) (string, error) {
 	raw := buildRFC2822("", to, subject, body, inReplyTo, inReplyTo)
 	msg := &gmail.Message{
 		Raw:      base64.URLEncoding.EncodeToString([]byte(raw)),
 		ThreadId: threadID,
 	}
-	_, err := c.svc.Users.Messages.Send(userID, msg).Context(ctx).Do()
+	sent, err := c.svc.Users.Messages.Send(userID, msg).Context(ctx).Do()
 	if err != nil {
-		return fmt.Errorf("reply email: %w", err)
+		return "", fmt.Errorf("reply email: %w", err)
 	}
-	return nil
+	return sent.Id, nil
 }
 
 func (c *realGmailClient) AddLabel(ctx context.Context, userID, messageID, labelName string) error {
@@ -2,7 +2,6 @@ package linear
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -12,6 +11,7 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
 
+	"github.com/sekia-ai/sekia/internal/secrets"
 	"github.com/sekia-ai/sekia/pkg/agent"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -58,13 +58,28 @@ func NewAgent(cfg Config, instanceName string, logger zerolog.Logger) *LinearAge
 // starts the poller, and blocks until signal or Stop().
 func (la *LinearAgent) Run() error {
 	// 1. Connect to NATS via the agent SDK.
-	natsOpts := la.natsOpts
-	if la.cfg.NATS.Token != "" {
-		natsOpts = append(natsOpts, nats.Token(la.cfg.NATS.Token))
+	natsOpts, err := secrets.NATSOptions(la.cfg.NATS.NATSClientConfig)
+	if err != nil {
+		return err
+	}
+	natsOpts = append(la.natsOpts, natsOpts...)
+	policy, err := protocol.LoadCommandPolicy(la.cfg.Security.PolicyFile)
+	if err != nil {
+		return err
+	}
+	trustedKeys, err := protocol.LoadKeySet(la.cfg.Security.TrustedKeys)
+	if err != nil {
+		return err
 	}
 	agentCfg := agent.Config{
-		NATSUrl:  la.cfg.NATS.URL,
-		NATSOpts: natsOpts,
+		NATSUrl:         la.cfg.NATS.URL,
+		NATSOpts:        natsOpts,
+		EventValidation: la.cfg.Events.Validation,
+		CommandSchemas:  protocol.CommandSchemas("linear"),
+		CommandPolicy:   policy,
+
+		MinSignatureVersion: la.cfg.Security.MinSignatureVersion,
+		TrustedKeys:         trustedKeys,
 	}
 	a, err := agent.New(
 		agentCfg, la.instanceName, agentVersion,
@@ -82,11 +97,10 @@ func (la *LinearAgent) Run() error {
 		la.logger.Warn().Err(err).Msg("failed to register config reload handler")
 	}
 
-	// 2. Subscribe to commands.
-	_, err = a.Conn().Subscribe(protocol.SubjectCommands(la.instanceName), la.handleCommand)
-	if err != nil {
+	// 2. Consume commands from the durable work queue.
+	if err := a.ServeCommands(agent.CommandOptions{}, la.handleCommand); err != nil {
 		a.Close()
-		return fmt.Errorf("subscribe commands: %w", err)
+		return fmt.Errorf("serve commands: %w", err)
 	}
 
 	// 3. Start poller.
@@ -172,66 +186,61 @@ func (la *LinearAgent) reloadConfig() {
 	la.cfg.Poll.TeamFilter = newCfg.Poll.TeamFilter
 	la.cfg.Security.CommandSecret = newCfg.Security.CommandSecret
 
+	if policy, err := protocol.LoadCommandPolicy(newCfg.Security.PolicyFile); err != nil {
+		la.logger.Error().Err(err).Msg("failed to reload command policy")
+	} else {
+		la.agent.SetCommandPolicy(policy)
+		la.cfg.Security.PolicyFile = newCfg.Security.PolicyFile
+	}
+	la.agent.SetMinSignatureVersion(newCfg.Security.MinSignatureVersion)
+	la.cfg.Security.MinSignatureVersion = newCfg.Security.MinSignatureVersion
+	if ks, err := protocol.LoadKeySet(newCfg.Security.TrustedKeys); err != nil {
+		la.logger.Error().Err(err).Msg("failed to reload trusted keys")
+	} else {
+		la.agent.SetTrustedKeys(ks)
+		la.cfg.Security.TrustedKeys = newCfg.Security.TrustedKeys
+	}
+
 	la.logger.Info().Msg("linear agent configuration reloaded")
 }
 
 func (la *LinearAgent) publishEvent(ev protocol.Event) {
-	data, err := json.Marshal(ev)
-	if err != nil {
-		la.logger.Error().Err(err).Msg("marshal event")
-		return
-	}
-	if err := la.agent.Conn().Publish(protocol.SubjectEvents("linear"), data); err != nil {
-		la.logger.Error().Err(err).Msg("publish event")
+	subject := protocol.SubjectEventsFor(la.cfg.Events.Subjects, "linear", ev.Type)
+	if err := la.agent.PublishEvent(subject, ev); err != nil {
+		la.logger.Error().Err(err).Str("type", ev.Type).Msg("publish event")
 		return
 	}
 	la.agent.Conn().Flush()
-	la.agent.RecordEvent()
 }
 
-func (la *LinearAgent) handleCommand(msg *nats.Msg) {
-	var cmd protocol.Command
-	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
-		la.agent.RecordError()
-		la.logger.Error().Err(err).Msg("unmarshal command")
-		return
+// handleCommand executes a single command from workflows. It is called by
+// the agent SDK, which acknowledges the command, retries transient failures,
+// and publishes the outcome on sekia.results.<agent> (and replies to
+// sekia.command_sync requests). create_issue reports the new issue_id.
+func (la *LinearAgent) handleCommand(ctx context.Context, cmd *protocol.Command) (map[string]any, error) {
+	if err := la.agent.VerifyCommand(ctx, cmd, la.cfg.Security.CommandSecret); err != nil {
+		return nil, err
 	}
-
-	if !protocol.VerifyCommand(&cmd, la.cfg.Security.CommandSecret) {
-		la.agent.RecordError()
-		la.logger.Warn().
-			Str("command", cmd.Command).
-			Str("source", cmd.Source).
-			Msg("rejected command: invalid or missing signature")
-		return
+	if err := la.agent.AuthorizeCommand(cmd); err != nil {
+		return nil, err
 	}
 
 	la.logger.Info().
 		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
 		Str("source", cmd.Source).
 		Msg("received command")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	var err error
 	switch cmd.Command {
 	case "create_issue":
-		err = cmdCreateIssue(ctx, la.lnClient, cmd.Payload)
+		return cmdCreateIssue(ctx, la.lnClient, cmd.Payload)
 	case "update_issue":
-		err = cmdUpdateIssue(ctx, la.lnClient, cmd.Payload)
+		return cmdUpdateIssue(ctx, la.lnClient, cmd.Payload)
 	case "create_comment":
-		err = cmdCreateComment(ctx, la.lnClient, cmd.Payload)
+		return cmdCreateComment(ctx, la.lnClient, cmd.Payload)
 	case "add_label":
-		err = cmdAddLabel(ctx, la.lnClient, cmd.Payload)
+		return cmdAddLabel(ctx, la.lnClient, cmd.Payload)
 	default:
-		err = fmt.Errorf("unknown command: %s", cmd.Command)
-	}
-
-	if err != nil {
-		la.agent.RecordError()
-		la.logger.Error().Err(err).Str("command", cmd.Command).Msg("command failed")
-	} else {
-		la.agent.RecordEvent()
+		return nil, fmt.Errorf("unknown command: %s", cmd.Command)
 	}
 }
@@ -8,6 +8,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/sekia-ai/sekia/pkg/agent"
 )
 
 const linearAPIURL = "https://api.linear.app/graphql"
@@ -105,7 +107,11 @@ func (c *realLinearClient) graphql(ctx context.Context, query string, variables
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("linear API error (status %d): %s", resp.StatusCode, string(respBody))
+		err := fmt.Errorf("linear API error (status %d): %s", resp.StatusCode, string(respBody))
+		if agent.TransientStatus(resp.StatusCode) {
+			return nil, agent.Transient(err)
+		}
+		return nil, err
 	}
 
 	var gqlResp struct {
@@ -18,24 +18,27 @@ func extractString(payload map[string]any, key string) (string, error) {
 	return s, nil
 }
 
-func cmdCreateIssue(ctx context.Context, lc LinearClient, payload map[string]any) error {
+func cmdCreateIssue(ctx context.Context, lc LinearClient, payload map[string]any) (map[string]any, error) {
 	teamID, err := extractString(payload, "team_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	title, err := extractString(payload, "title")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	description, _ := extractString(payload, "description") // optional
-	_, err = lc.CreateIssue(ctx, teamID, title, description)
-	return err
+	issueID, err := lc.CreateIssue(ctx, teamID, title, description)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"issue_id": issueID}, nil
 }
 
-func cmdUpdateIssue(ctx context.Context, lc LinearClient, payload map[string]any) error {
+func cmdUpdateIssue(ctx context.Context, lc LinearClient, payload map[string]any) (map[string]any, error) {
 	issueID, err := extractString(payload, "issue_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	input := make(map[string]any)
 	if v, ok := payload["state_id"]; ok {
@@ -48,31 +51,40 @@ func cmdUpdateIssue(ctx context.Context, lc LinearClient, payload map[string]any
 		input["priority"] = v
 	}
 	if len(input) == 0 {
-		return fmt.Errorf("update_issue requires at least one of: state_id, assignee_id, priority")
+		return nil, fmt.Errorf("update_issue requires at least one of: state_id, assignee_id, priority")
+	}
+	if err := lc.UpdateIssue(ctx, issueID, input); err != nil {
+		return nil, err
 	}
-	return lc.UpdateIssue(ctx, issueID, input)
+	return map[string]any{"issue_id": issueID}, nil
 }
 
-func cmdCreateComment(ctx context.Context, lc LinearClient, payload map[string]any) error {
+func cmdCreateComment(ctx context.Context, lc LinearClient, payload map[string]any) (map[string]any, error) {
 	issueID, err := extractString(payload, "issue_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	body, err := extractString(payload, "body")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return lc.CreateComment(ctx, issueID, body)
+	if err := lc.CreateComment(ctx, issueID, body); err != nil {
+		return nil, err
+	}
+	return map[string]any{"issue_id": issueID}, nil
 }
 
-func cmdAddLabel(ctx context.Context, lc LinearClient, payload map[string]any) error {
+func cmdAddLabel(ctx context.Context, lc LinearClient, payload map[string]any) (map[string]any, error) {
 	issueID, err := extractString(payload, "issue_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	labelID, err := extractString(payload, "label_id")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := lc.AddLabel(ctx, issueID, labelID); err != nil {
+		return nil, err
 	}
-	return lc.AddLabel(ctx, issueID, labelID)
+	return map[string]any{"issue_id": issueID, "label_id": labelID}, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/sekia-ai/sekia/internal/secrets"
+	"github.com/sekia-ai/sekia/pkg/protocol"
 )
 
 // Config holds all configuration for the Linear agent.
@@ -15,17 +16,35 @@ type Config struct {
 	Linear   LinearConfig   `mapstructure:"linear"`
 	Poll     PollConfig     `mapstructure:"poll"`
 	Security SecurityConfig `mapstructure:"security"`
+	Events   EventsConfig   `mapstructure:"events"`
 }
 
 // SecurityConfig holds application-level security settings.
 type SecurityConfig struct {
 	CommandSecret string `mapstructure:"command_secret"`
+	PolicyFile    string `mapstructure:"policy_file"` // command policy (protocol.CommandPolicy); empty = allow all
+
+	// MinSignatureVersion is the oldest command signature version accepted:
+	// 1 (default) during a rollout, 2 once every sender signs with version 2,
+	// 3 to accept only commands signed with a trusted key.
+	MinSignatureVersion int `mapstructure:"min_signature_version"`
+
+	// TrustedKeys is the trusted keys file (sekiactl keys) holding the
+	// public keys whose Ed25519 command signatures the agent accepts.
+	TrustedKeys string `mapstructure:"trusted_keys"`
+}
+
+// EventsConfig holds event publishing settings.
+type EventsConfig struct {
+	Subjects   string `mapstructure:"subjects"`   // flat, hierarchical or compat (protocol.Subjects*)
+	Validation string `mapstructure:"validation"` // off, warn or reject (protocol.Validation*)
 }
 
 // NATSConfig holds NATS connection settings.
 type NATSConfig struct {
-	URL   string `mapstructure:"url"`
-	Token string `mapstructure:"token"`
+	URL string `mapstructure:"url"`
+
+	secrets.NATSClientConfig `mapstructure:",squash"`
 }
 
 // LinearConfig holds Linear API credentials.
@@ -46,6 +65,8 @@ func LoadConfig(cfgFile, instanceName string) (Config, error) {
 	v := viper.New()
 
 	v.SetDefault("nats.url", "nats://127.0.0.1:4222")
+	v.SetDefault("events.subjects", protocol.SubjectsFlat)
+	v.SetDefault("events.validation", protocol.ValidationWarn)
 	v.SetDefault("poll.interval", "30s")
 	v.SetDefault("poll.team_filter", "")
 
@@ -67,7 +88,16 @@ func LoadConfig(cfgFile, instanceName string) (Config, error) {
 	v.BindEnv("linear.api_key", "LINEAR_API_KEY")
 	v.BindEnv("nats.url", "SEKIA_NATS_URL")
 	v.BindEnv("nats.token", "SEKIA_NATS_TOKEN")
+	v.BindEnv("nats.credentials", "SEKIA_NATS_CREDENTIALS")
+	v.BindEnv("nats.tls_cert", "SEKIA_NATS_TLS_CERT")
+	v.BindEnv("nats.tls_key", "SEKIA_NATS_TLS_KEY")
+	v.BindEnv("nats.tls_ca", "SEKIA_NATS_TLS_CA")
 	v.BindEnv("security.command_secret", "SEKIA_COMMAND_SECRET")
+	v.BindEnv("security.policy_file", "SEKIA_POLICY_FILE")
+	v.BindEnv("security.min_signature_version", "SEKIA_MIN_SIGNATURE_VERSION")
+	v.BindEnv("security.trusted_keys", "SEKIA_TRUSTED_KEYS")
+	v.BindEnv("events.subjects", "SEKIA_EVENT_SUBJECTS")
+	v.BindEnv("events.validation", "SEKIA_EVENT_VALIDATION")
 
 	_ = v.ReadInConfig() // config file is optional
 
@@ -80,6 +110,15 @@ func LoadConfig(cfgFile, instanceName string) (Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return cfg, err
 	}
+	if err := protocol.ValidateSubjectScheme(cfg.Events.Subjects); err != nil {
+		return cfg, err
+	}
+	if err := protocol.ValidateValidationMode(cfg.Events.Validation); err != nil {
+		return cfg, err
+	}
+	if err := protocol.ValidateMinSignatureVersion(cfg.Security.MinSignatureVersion); err != nil {
+		return cfg, err
+	}
 
 	if cfg.Linear.APIKey == "" {
 		return cfg, fmt.Errorf("linear.api_key is required (set via config file or LINEAR_API_KEY env var)")
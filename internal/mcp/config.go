@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/sekia-ai/sekia/internal/secrets"
+	"github.com/sekia-ai/sekia/pkg/protocol"
 	"github.com/sekia-ai/sekia/pkg/sockpath"
 )
 
@@ -14,17 +15,25 @@ type Config struct {
 	NATS     NATSConfig     `mapstructure:"nats"`
 	Daemon   DaemonConfig   `mapstructure:"daemon"`
 	Security SecurityConfig `mapstructure:"security"`
+	Events   EventsConfig   `mapstructure:"events"`
 }
 
 // SecurityConfig holds application-level security settings.
 type SecurityConfig struct {
 	CommandSecret string `mapstructure:"command_secret"`
+	SigningKey    string `mapstructure:"signing_key"` // Ed25519 key file for agents that trust it
+}
+
+// EventsConfig holds event publishing settings.
+type EventsConfig struct {
+	Subjects string `mapstructure:"subjects"` // flat, hierarchical or compat (protocol.Subjects*)
 }
 
 // NATSConfig holds NATS connection settings.
 type NATSConfig struct {
-	URL   string `mapstructure:"url"`
-	Token string `mapstructure:"token"`
+	URL string `mapstructure:"url"`
+
+	secrets.NATSClientConfig `mapstructure:",squash"`
 }
 
 // DaemonConfig holds settings for connecting to the sekiad daemon API.
@@ -37,6 +46,7 @@ func LoadConfig(cfgFile string) (Config, error) {
 	v := viper.New()
 
 	v.SetDefault("nats.url", "nats://127.0.0.1:4222")
+	v.SetDefault("events.subjects", protocol.SubjectsFlat)
 	v.SetDefault("daemon.socket", sockpath.DefaultSocketPath())
 
 	v.SetConfigType("toml")
@@ -52,8 +62,14 @@ func LoadConfig(cfgFile string) (Config, error) {
 
 	v.BindEnv("nats.url", "SEKIA_NATS_URL")
 	v.BindEnv("nats.token", "SEKIA_NATS_TOKEN")
+	v.BindEnv("nats.credentials", "SEKIA_NATS_CREDENTIALS")
+	v.BindEnv("nats.tls_cert", "SEKIA_NATS_TLS_CERT")
+	v.BindEnv("nats.tls_key", "SEKIA_NATS_TLS_KEY")
+	v.BindEnv("nats.tls_ca", "SEKIA_NATS_TLS_CA")
 	v.BindEnv("daemon.socket", "SEKIA_DAEMON_SOCKET")
 	v.BindEnv("security.command_secret", "SEKIA_COMMAND_SECRET")
+	v.BindEnv("security.signing_key", "SEKIA_MCP_SIGNING_KEY")
+	v.BindEnv("events.subjects", "SEKIA_EVENT_SUBJECTS")
 
 	_ = v.ReadInConfig() // config file is optional
 
@@ -66,5 +82,8 @@ func LoadConfig(cfgFile string) (Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return cfg, err
 	}
+	if err := protocol.ValidateSubjectScheme(cfg.Events.Subjects); err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
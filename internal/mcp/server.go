@@ -9,6 +9,9 @@ import (
 	mcpserver "github.com/mark3labs/mcp-go/server"
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+	"github.com/sekia-ai/sekia/pkg/protocol"
 )
 
 // MCPServer exposes sekia capabilities to AI assistants via MCP.
@@ -17,6 +20,10 @@ type MCPServer struct {
 	nc            *nats.Conn
 	logger        zerolog.Logger
 	commandSecret string
+	keyPath       string               // security.signing_key
+	signingKey    *protocol.SigningKey // loaded from keyPath by Run
+	subjects      string               // event subject scheme (protocol.Subjects*)
+	natsCfg       secrets.NATSClientConfig
 
 	// Overridable for testing.
 	natsOpts []nats.Option
@@ -24,15 +31,14 @@ type MCPServer struct {
 
 // New creates an MCPServer. Call Run() to start serving on stdio.
 func New(cfg Config, logger zerolog.Logger) *MCPServer {
-	s := &MCPServer{
+	return &MCPServer{
 		api:           NewAPIClient(cfg.Daemon.Socket),
 		logger:        logger.With().Str("component", "mcp").Logger(),
 		commandSecret: cfg.Security.CommandSecret,
+		keyPath:       cfg.Security.SigningKey,
+		subjects:      cfg.Events.Subjects,
+		natsCfg:       cfg.NATS.NATSClientConfig,
 	}
-	if cfg.NATS.Token != "" {
-		s.natsOpts = append(s.natsOpts, nats.Token(cfg.NATS.Token))
-	}
-	return s
 }
 
 // SetDaemonAPI overrides the daemon API client. Intended for testing with a mock.
@@ -48,8 +54,21 @@ func (s *MCPServer) SetNATSOpts(opts []nats.Option) {
 // Run connects to NATS, registers MCP tools, and serves on stdio.
 // It blocks until stdin is closed or the context is cancelled.
 func (s *MCPServer) Run(ctx context.Context, natsURL string) error {
+	if s.keyPath != "" {
+		key, err := secrets.LoadSigningKey(s.keyPath)
+		if err != nil {
+			return err
+		}
+		s.signingKey = key
+	}
+
 	// Connect to NATS for mutation tools.
-	nc, err := nats.Connect(natsURL, s.natsOpts...)
+	natsOpts, err := secrets.NATSOptions(s.natsCfg)
+	if err != nil {
+		return err
+	}
+	natsOpts = append(s.natsOpts, natsOpts...)
+	nc, err := nats.Connect(natsURL, natsOpts...)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -331,6 +332,10 @@ func TestSendCommand(t *testing.T) {
 		if cmd["source"] != "mcp" {
 			t.Errorf("source = %v, want mcp", cmd["source"])
 		}
+		text := result.Content[0].(mcplib.TextContent).Text
+		if id, _ := cmd["id"].(string); id == "" || !strings.Contains(text, id) {
+			t.Errorf("tool result %s does not report command id %v", text, cmd["id"])
+		}
 		payload := cmd["payload"].(map[string]any)
 		if payload["label"] != "bug" {
 			t.Errorf("payload.label = %v, want bug", payload["label"])
@@ -358,6 +363,17 @@ func TestSendCommandMissingPayload(t *testing.T) {
 	}
 }
 
+func TestRunMissingCredentials(t *testing.T) {
+	var cfg Config
+	cfg.NATS.Credentials = filepath.Join(t.TempDir(), "mcp.nk")
+	s := New(cfg, zerolog.Nop())
+
+	err := s.Run(context.Background(), "nats://127.0.0.1:4222")
+	if err == nil || !strings.Contains(err.Error(), "load NATS credentials") {
+		t.Fatalf("Run = %v, want a credentials error", err)
+	}
+}
+
 func TestMCPEndToEnd(t *testing.T) {
 	wfDir := t.TempDir()
 
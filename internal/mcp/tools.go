@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	mcplib "github.com/mark3labs/mcp-go/mcp"
+	"github.com/nats-io/nats.go"
 
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -68,7 +69,7 @@ func (s *MCPServer) handlePublishEvent(ctx context.Context, req mcplib.CallToolR
 		return textError("failed to marshal event: " + err.Error()), nil
 	}
 
-	subject := protocol.SubjectEvents(source)
+	subject := protocol.SubjectEventsFor(s.subjects, source, eventType)
 	if err := s.nc.Publish(subject, data); err != nil {
 		return textError("failed to publish event: " + err.Error()), nil
 	}
@@ -98,12 +99,8 @@ func (s *MCPServer) handleSendCommand(ctx context.Context, req mcplib.CallToolRe
 		return textError("missing required parameter: payload"), nil
 	}
 
-	cmd := &protocol.Command{
-		Command: command,
-		Payload: payload,
-		Source:  "mcp",
-	}
-	if err := protocol.SignCommand(cmd, s.commandSecret); err != nil {
+	cmd := protocol.NewCommand(command, payload, "mcp")
+	if err := protocol.SignCommandFor(&cmd, s.signingKey, s.commandSecret, s.signatureVersion(ctx, agentName), 0); err != nil {
 		return textError("failed to sign command: " + err.Error()), nil
 	}
 	data, err := json.Marshal(cmd)
@@ -111,13 +108,29 @@ func (s *MCPServer) handleSendCommand(ctx context.Context, req mcplib.CallToolRe
 		return textError("failed to marshal command: " + err.Error()), nil
 	}
 
-	subject := protocol.SubjectCommands(agentName)
-	if err := s.nc.Publish(subject, data); err != nil {
+	msg := nats.NewMsg(protocol.SubjectCommands(agentName))
+	msg.Header.Set(nats.MsgIdHdr, cmd.ID)
+	msg.Data = data
+	if err := s.nc.PublishMsg(msg); err != nil {
 		return textError("failed to send command: " + err.Error()), nil
 	}
 	s.nc.Flush()
 
-	return textResult(fmt.Sprintf(`{"status":"sent","agent":"%s","command":"%s"}`, agentName, command)), nil
+	return textResult(fmt.Sprintf(`{"status":"sent","agent":"%s","command":"%s","command_id":"%s"}`, agentName, command, cmd.ID)), nil
+}
+
+// signatureVersion returns the newest command signature version the agent
+// supports, as registered with the daemon (version 1 if unknown).
+func (s *MCPServer) signatureVersion(ctx context.Context, agentName string) int {
+	resp, err := s.api.GetAgents(ctx)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("agent", agentName).Msg("could not look up agent; signing command with version 1")
+		return protocol.SignatureV1
+	}
+	if resp == nil {
+		return protocol.SignatureV1
+	}
+	return protocol.NegotiateSignatureVersion(resp.Agents, agentName)
 }
 
 // textResult returns a successful text result.
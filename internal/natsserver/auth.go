@@ -0,0 +1,80 @@
+package natsserver
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// AgentUser is an agent that authenticates with its own nkey (sekiactl
+// agents credentials create) instead of the shared token.
+type AgentUser struct {
+	Name     string   // agent name: its command, heartbeat and result subjects
+	NKey     string   // public user nkey
+	Sources  []string // event sources it may publish, sekia.events.<source>
+	Commands bool     // may send commands to any agent (the MCP server)
+}
+
+// AgentPermissions returns the subjects an agent may use: it may publish its
+// events, heartbeats, registration, command results and dead letters, and
+// pull commands from its own consumer on the command work queue; it may
+// subscribe only to its commands, config reloads and its reply inboxes
+// (protocol.SubjectInbox). It may also answer sekia.command_sync requests.
+// With Commands set it may send commands to every agent too.
+func AgentPermissions(u AgentUser) *server.Permissions {
+	pub := []string{
+		protocol.SubjectRegistry,
+		protocol.SubjectHeartbeat(u.Name),
+		protocol.SubjectResults(u.Name),
+		protocol.SubjectDLQAgent(u.Name),
+		"$JS.API.STREAM.INFO." + protocol.StreamCommands,
+		"$JS.API.CONSUMER.CREATE." + protocol.StreamCommands + "." + u.Name + "." + protocol.SubjectCommands(u.Name),
+		"$JS.API.CONSUMER.INFO." + protocol.StreamCommands + "." + u.Name,
+		"$JS.API.CONSUMER.MSG.NEXT." + protocol.StreamCommands + "." + u.Name,
+		"$JS.ACK." + protocol.StreamCommands + "." + u.Name + ".>",
+	}
+	for _, source := range u.Sources {
+		pub = append(pub, protocol.SubjectEvents(source), protocol.SubjectEvents(source)+".>")
+	}
+	if u.Commands {
+		pub = append(pub, protocol.SubjectCommands("*"))
+	}
+	return &server.Permissions{
+		Publish: &server.SubjectPermission{Allow: pub},
+		Subscribe: &server.SubjectPermission{Allow: []string{
+			protocol.SubjectCommands(u.Name),
+			protocol.SubjectCommandsSync(u.Name),
+			protocol.SubjectConfigReload,
+			protocol.SubjectConfigReloadAgent(u.Name),
+			protocol.SubjectInbox(u.Name) + ".>",
+		}},
+		Response: &server.ResponsePermission{MaxMsgs: 1},
+	}
+}
+
+// nkeyUsers returns the server's nkey users: the agents, with their
+// permissions, and sekiad's own connection (internalKey), unrestricted.
+func nkeyUsers(internalKey string, agents []AgentUser) []*server.NkeyUser {
+	users := []*server.NkeyUser{{Nkey: internalKey}}
+	for _, a := range agents {
+		users = append(users, &server.NkeyUser{Nkey: a.NKey, Permissions: AgentPermissions(a)})
+	}
+	return users
+}
+
+// newInternalKey generates the nkey sekiad's own connections use while
+// agent credentials are enabled. It lives only as long as the process.
+func newInternalKey() (nkeys.KeyPair, string, error) {
+	kp, err := nkeys.CreateUser()
+	if err != nil {
+		return nil, "", fmt.Errorf("generate internal nkey: %w", err)
+	}
+	pub, err := kp.PublicKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("generate internal nkey: %w", err)
+	}
+	return kp, pub, nil
+}
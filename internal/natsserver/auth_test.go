@@ -0,0 +1,137 @@
+package natsserver
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func TestAgentCredentials(t *testing.T) {
+	kp, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := kp.PublicKey()
+	agents := []AgentUser{{Name: "github-agent", NKey: pub, Sources: []string{"github"}}}
+
+	srv, err := New(Config{
+		StoreDir: t.TempDir(),
+		Host:     "127.0.0.1",
+		Port:     -1,
+		Token:    "test-secret-token",
+		Agents:   agents,
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	// The token keeps working alongside agent credentials.
+	nc, err := nats.Connect(srv.ClientURL(), nats.Token("test-secret-token"))
+	if err != nil {
+		t.Fatalf("connect with token: %v", err)
+	}
+	nc.Close()
+
+	other, _ := nkeys.CreateUser()
+	otherPub, _ := other.PublicKey()
+	if nc, err := nats.Connect(srv.ClientURL(), nats.Nkey(otherPub, other.Sign)); err == nil {
+		nc.Close()
+		t.Fatal("expected connection with an unknown nkey to fail")
+	}
+
+	violations := make(chan error, 10)
+	nc, err = nats.Connect(srv.ClientURL(),
+		nats.Nkey(pub, kp.Sign),
+		nats.CustomInboxPrefix(protocol.SubjectInbox("github-agent")),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) { violations <- err }),
+	)
+	if err != nil {
+		t.Fatalf("connect with nkey: %v", err)
+	}
+	defer nc.Close()
+
+	// The agent's own subjects are allowed.
+	if _, err := nc.SubscribeSync(protocol.SubjectCommands("github-agent")); err != nil {
+		t.Fatal(err)
+	}
+	for _, subject := range []string{
+		protocol.SubjectHeartbeat("github-agent"),
+		protocol.SubjectEvents("github"),
+		protocol.SubjectEventsTyped("github", "issue.opened"),
+	} {
+		nc.Publish(subject, []byte("{}"))
+	}
+	nc.Flush()
+	select {
+	case err := <-violations:
+		t.Fatalf("unexpected violation: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Other agents' subjects are not.
+	for _, op := range []func(){
+		func() { nc.Publish(protocol.SubjectHeartbeat("slack-agent"), []byte("{}")) },
+		func() { nc.Publish(protocol.SubjectEvents("slack"), []byte("{}")) },
+		func() { nc.Publish(protocol.SubjectCommands("slack-agent"), []byte("{}")) },
+		func() { nc.SubscribeSync(protocol.SubjectCommands("slack-agent")) },
+		func() { nc.SubscribeSync("sekia.events.>") },
+	} {
+		op()
+		nc.Flush()
+		select {
+		case err := <-violations:
+			if !errors.Is(err, nats.ErrPermissionViolation) {
+				t.Errorf("error = %v, want a permission violation", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected a permission violation")
+		}
+	}
+
+	// Removing the agent disconnects it.
+	if err := srv.SetAgents([]AgentUser{}); err != nil {
+		t.Fatalf("SetAgents: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for nc.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if nc.IsConnected() {
+		t.Error("expected revoked agent to be disconnected")
+	}
+}
+
+func TestAgentPermissions_Commands(t *testing.T) {
+	cmds := protocol.SubjectCommands("*")
+	perms := AgentPermissions(AgentUser{Name: "github-agent", Sources: []string{"github"}})
+	if slices.Contains(perms.Publish.Allow, cmds) {
+		t.Errorf("agent may send commands: %v", perms.Publish.Allow)
+	}
+	perms = AgentPermissions(AgentUser{Name: "mcp", Sources: []string{"*"}, Commands: true})
+	if !slices.Contains(perms.Publish.Allow, cmds) {
+		t.Errorf("publish allow = %v, want %s", perms.Publish.Allow, cmds)
+	}
+}
+
+func TestSetAgents_NotEnabled(t *testing.T) {
+	srv, err := New(Config{StoreDir: t.TempDir()}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	if err := srv.SetAgents(nil); err != nil {
+		t.Errorf("SetAgents(nil): %v", err)
+	}
+	if err := srv.SetAgents([]AgentUser{}); err == nil {
+		t.Error("SetAgents without agent credentials enabled: expected an error")
+	}
+}
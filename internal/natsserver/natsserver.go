@@ -2,11 +2,13 @@ package natsserver
 
 import (
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nkeys"
 	"github.com/rs/zerolog"
 )
 
@@ -16,6 +18,19 @@ type Config struct {
 	Host     string
 	Port     int
 	Token    string // If non-empty, requires token auth for NATS connections.
+
+	// TLS for the TCP listener: the server certificate and key, the CA that
+	// client certificates must chain to, and whether clients must present
+	// one (mutual TLS). In-process connections are not affected.
+	TLSCert       string
+	TLSKey        string
+	TLSCA         string
+	VerifyClients bool
+
+	// Agents, if non-nil, enables per-agent credentials: each agent
+	// authenticates with its nkey and is restricted to its own subjects
+	// (see AgentPermissions). Clients presenting the token keep full access.
+	Agents []AgentUser
 }
 
 // Server wraps an embedded NATS server with JetStream.
@@ -24,6 +39,11 @@ type Server struct {
 	nc     *nats.Conn
 	js     jetstream.JetStream
 	logger zerolog.Logger
+
+	opts        *server.Options
+	token       string
+	internalKey nkeys.KeyPair // sekiad's own nkey, nil without agent credentials
+	agents      []AgentUser
 }
 
 // New creates and starts the embedded NATS server.
@@ -40,6 +60,34 @@ func New(cfg Config, logger zerolog.Logger) (*Server, error) {
 	if cfg.Token != "" {
 		opts.Authorization = cfg.Token
 	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		if cfg.VerifyClients && cfg.TLSCA == "" {
+			return nil, fmt.Errorf("nats tls: verifying clients requires a CA")
+		}
+		tc, err := server.GenTLSConfig(&server.TLSConfigOpts{
+			CertFile: cfg.TLSCert,
+			KeyFile:  cfg.TLSKey,
+			CaFile:   cfg.TLSCA,
+			Verify:   cfg.VerifyClients,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("nats tls: %w", err)
+		}
+		opts.TLS = true
+		opts.TLSConfig = tc
+		opts.TLSVerify = cfg.VerifyClients
+	} else if cfg.VerifyClients {
+		return nil, fmt.Errorf("nats tls: verifying clients requires a server certificate")
+	}
+	var internalKey nkeys.KeyPair
+	if cfg.Agents != nil {
+		kp, pub, err := newInternalKey()
+		if err != nil {
+			return nil, err
+		}
+		internalKey = kp
+		opts.Nkeys = nkeyUsers(pub, cfg.Agents)
+	}
 
 	ns, err := server.NewServer(opts)
 	if err != nil {
@@ -54,13 +102,15 @@ func New(cfg Config, logger zerolog.Logger) (*Server, error) {
 		return nil, fmt.Errorf("nats server failed to become ready")
 	}
 
+	s := &Server{ns: ns, logger: logger, opts: opts, token: cfg.Token, internalKey: internalKey, agents: cfg.Agents}
+
+	// sekiad's own connection stays in-process under TLS, so it needs no
+	// client certificate.
 	var connectOpts []nats.Option
-	if opts.DontListen {
+	if opts.DontListen || opts.TLSConfig != nil {
 		connectOpts = append(connectOpts, nats.InProcessServer(ns))
 	}
-	if cfg.Token != "" {
-		connectOpts = append(connectOpts, nats.Token(cfg.Token))
-	}
+	connectOpts = append(connectOpts, s.AuthOpts()...)
 	nc, err := nats.Connect(ns.ClientURL(), connectOpts...)
 	if err != nil {
 		ns.Shutdown()
@@ -74,9 +124,11 @@ func New(cfg Config, logger zerolog.Logger) (*Server, error) {
 		return nil, fmt.Errorf("jetstream init: %w", err)
 	}
 
-	logger.Info().Str("client_url", ns.ClientURL()).Msg("embedded NATS started")
+	s.nc, s.js = nc, js
 
-	return &Server{ns: ns, nc: nc, js: js, logger: logger}, nil
+	logger.Info().Str("client_url", ns.ClientURL()).Int("agent_credentials", len(cfg.Agents)).Msg("embedded NATS started")
+
+	return s, nil
 }
 
 // Conn returns the internal NATS client connection.
@@ -88,6 +140,43 @@ func (s *Server) JetStream() jetstream.JetStream { return s.js }
 // NATSServer returns the raw server for InProcessServer connections.
 func (s *Server) NATSServer() *server.Server { return s.ns }
 
+// AuthOpts returns the NATS options sekiad's own connections authenticate
+// with: its internal nkey when agent credentials are enabled, or the token.
+func (s *Server) AuthOpts() []nats.Option {
+	if s.internalKey != nil {
+		pub, _ := s.internalKey.PublicKey()
+		return []nats.Option{nats.Nkey(pub, s.internalKey.Sign)}
+	}
+	if s.token != "" {
+		return []nats.Option{nats.Token(s.token)}
+	}
+	return nil
+}
+
+// SetAgents replaces the agent credentials, disconnecting agents whose
+// nkey was removed. Agent credentials must have been enabled when the
+// server started.
+func (s *Server) SetAgents(agents []AgentUser) error {
+	if s.internalKey == nil {
+		if agents == nil {
+			return nil
+		}
+		return fmt.Errorf("agent credentials were not enabled at startup; restart to enable them")
+	}
+	if reflect.DeepEqual(agents, s.agents) {
+		return nil
+	}
+	pub, _ := s.internalKey.PublicKey()
+	opts := s.opts.Clone()
+	opts.Nkeys = nkeyUsers(pub, agents)
+	if err := s.ns.ReloadOptions(opts); err != nil {
+		return fmt.Errorf("reload agent credentials: %w", err)
+	}
+	s.opts, s.agents = opts, agents
+	s.logger.Info().Int("agents", len(agents)).Msg("updated agent credentials")
+	return nil
+}
+
 // ClientURL returns the NATS client connection URL.
 func (s *Server) ClientURL() string { return s.ns.ClientURL() }
 
@@ -1,10 +1,15 @@
 package natsserver
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+	"github.com/sekia-ai/sekia/pkg/protocol"
 )
 
 func TestTokenAuth(t *testing.T) {
@@ -70,3 +75,122 @@ func TestNoToken_AllowsAnonymous(t *testing.T) {
 	}
 	nc.Close()
 }
+
+func TestMutualTLS(t *testing.T) {
+	ca := secrets.CertAuthority{Dir: t.TempDir()}
+	if _, err := ca.Init(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.IssueServer([]string{"127.0.0.1"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.IssueClient("github-agent", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Start a NATS server requiring client certificates on a random TCP port.
+	srv, err := New(Config{
+		StoreDir:      t.TempDir(),
+		Host:          "127.0.0.1",
+		Port:          -1,
+		TLSCert:       ca.CertPath(secrets.ServerCertName),
+		TLSKey:        ca.KeyPath(secrets.ServerCertName),
+		TLSCA:         ca.CAPath(),
+		VerifyClients: true,
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	// The daemon's own connection is in-process and needs no certificate.
+	if !srv.Conn().IsConnected() {
+		t.Fatal("expected internal connection")
+	}
+
+	url := srv.ClientURL()
+
+	// Connection without TLS should fail.
+	if nc, err := nats.Connect(url); err == nil {
+		nc.Close()
+		t.Fatal("expected connection without TLS to fail")
+	}
+
+	// Connection without a client certificate should fail.
+	if nc, err := nats.Connect(url, secrets.TLSOptions("", "", ca.CAPath())...); err == nil {
+		nc.Close()
+		t.Fatal("expected connection without a client certificate to fail")
+	}
+
+	// Connection with the agent's certificate should succeed.
+	nc, err := nats.Connect(url, secrets.TLSOptions(ca.CertPath("github-agent"), ca.KeyPath("github-agent"), ca.CAPath())...)
+	if err != nil {
+		t.Fatalf("expected connection with client certificate to succeed: %v", err)
+	}
+	nc.Close()
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"verify without certificate": {VerifyClients: true},
+		"verify without CA":          {TLSCert: "server.pem", TLSKey: "server-key.pem", VerifyClients: true},
+		"missing files":              {TLSCert: "missing.pem", TLSKey: "missing-key.pem"},
+	} {
+		cfg.StoreDir = t.TempDir()
+		if srv, err := New(cfg, zerolog.Nop()); err == nil {
+			srv.Shutdown()
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEnsureEventStream(t *testing.T) {
+	srv, err := New(Config{StoreDir: t.TempDir()}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer srv.Shutdown()
+
+	if err := srv.EnsureEventStream(EventStreamConfig{MaxAge: time.Hour}); err != nil {
+		t.Fatalf("ensure stream: %v", err)
+	}
+
+	// Core publishes on sekia.events.> must be captured by the stream.
+	if err := srv.Conn().Publish("sekia.events.test", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	srv.Conn().Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := srv.JetStream().Stream(ctx, protocol.StreamEvents)
+	if err != nil {
+		t.Fatalf("lookup stream: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for stream.CachedInfo().State.Msgs != 1 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := stream.Info(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stream.CachedInfo().State.Msgs; got != 1 {
+		t.Fatalf("stream msgs = %d, want 1", got)
+	}
+
+	// Calling again with new limits updates the existing stream.
+	if err := srv.EnsureEventStream(EventStreamConfig{MaxAge: 2 * time.Hour, MaxMsgs: 100}); err != nil {
+		t.Fatalf("update stream: %v", err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Config.MaxAge != 2*time.Hour {
+		t.Errorf("max_age = %v, want 2h", info.Config.MaxAge)
+	}
+	if info.Config.MaxMsgs != 100 {
+		t.Errorf("max_msgs = %d, want 100", info.Config.MaxMsgs)
+	}
+}
@@ -0,0 +1,169 @@
+package natsserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// EventStreamConfig controls retention of the durable event log.
+// Zero values leave the corresponding limit unbounded.
+type EventStreamConfig struct {
+	MaxAge   time.Duration
+	MaxBytes int64
+	MaxMsgs  int64
+}
+
+// EnsureEventStream creates the SEKIA_EVENTS stream covering sekia.events.>
+// and command results on sekia.results.>, or updates its retention limits if
+// it already exists.
+func (s *Server) EnsureEventStream(cfg EventStreamConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        protocol.StreamEvents,
+		Description: "sekia durable event log",
+		Subjects:    []string{"sekia.events.>", "sekia.results.>"},
+		Retention:   jetstream.LimitsPolicy,
+		Discard:     jetstream.DiscardOld,
+		Storage:     jetstream.FileStorage,
+		MaxAge:      cfg.MaxAge,
+		MaxBytes:    limitOrUnbounded(cfg.MaxBytes),
+		MaxMsgs:     limitOrUnbounded(cfg.MaxMsgs),
+	})
+	if err != nil {
+		return fmt.Errorf("ensure stream %s: %w", protocol.StreamEvents, err)
+	}
+
+	s.logger.Info().
+		Str("stream", protocol.StreamEvents).
+		Dur("max_age", cfg.MaxAge).
+		Int64("max_bytes", cfg.MaxBytes).
+		Int64("max_msgs", cfg.MaxMsgs).
+		Msg("event stream ready")
+	return nil
+}
+
+// CommandStreamConfig controls retention of the command work queue.
+type CommandStreamConfig struct {
+	// MaxAge bounds how long an undelivered command waits for its agent.
+	MaxAge time.Duration
+}
+
+// EnsureCommandStream creates the SEKIA_COMMANDS work queue covering
+// sekia.commands.<agent>, or updates it if it already exists. Each agent
+// consumes its own subject, and a command is removed once acknowledged.
+func (s *Server) EnsureCommandStream(cfg CommandStreamConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        protocol.StreamCommands,
+		Description: "sekia command work queue",
+		Subjects:    []string{"sekia.commands.*"},
+		Retention:   jetstream.WorkQueuePolicy,
+		Storage:     jetstream.FileStorage,
+		MaxAge:      cfg.MaxAge,
+		Duplicates:  2 * time.Minute, // dedupe on Nats-Msg-Id (the command ID)
+	})
+	if err != nil {
+		return fmt.Errorf("ensure stream %s: %w", protocol.StreamCommands, err)
+	}
+
+	s.logger.Info().
+		Str("stream", protocol.StreamCommands).
+		Dur("max_age", cfg.MaxAge).
+		Msg("command stream ready")
+	return nil
+}
+
+// DLQStreamConfig controls retention of the dead-letter queue.
+type DLQStreamConfig struct {
+	MaxAge time.Duration
+}
+
+// EnsureDLQStream creates the SEKIA_DLQ stream covering sekia.dlq.>, or
+// updates its retention if it already exists. Failed workflow events land on
+// sekia.dlq.workflow.<name> and failed commands on sekia.dlq.agent.<name>.
+func (s *Server) EnsureDLQStream(cfg DLQStreamConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        protocol.StreamDLQ,
+		Description: "sekia dead-letter queue",
+		Subjects:    []string{"sekia.dlq.>"},
+		Retention:   jetstream.LimitsPolicy,
+		Discard:     jetstream.DiscardOld,
+		Storage:     jetstream.FileStorage,
+		MaxAge:      cfg.MaxAge,
+	})
+	if err != nil {
+		return fmt.Errorf("ensure stream %s: %w", protocol.StreamDLQ, err)
+	}
+
+	s.logger.Info().
+		Str("stream", protocol.StreamDLQ).
+		Dur("max_age", cfg.MaxAge).
+		Msg("dead-letter stream ready")
+	return nil
+}
+
+// stateMarkerTTL is how long the state bucket keeps the marker left behind
+// when a key expires. Setting it enables per-key TTLs on the bucket.
+const stateMarkerTTL = time.Minute
+
+// EnsureStateBucket creates the SEKIA_STATE KeyValue bucket holding
+// workflow state, or updates it if it already exists. Only the latest value
+// of each key is kept.
+func (s *Server) EnsureStateBucket() (jetstream.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	kv, err := s.js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:         protocol.BucketState,
+		Description:    "sekia workflow state",
+		History:        1,
+		Storage:        jetstream.FileStorage,
+		LimitMarkerTTL: stateMarkerTTL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ensure bucket %s: %w", protocol.BucketState, err)
+	}
+
+	s.logger.Info().Str("bucket", protocol.BucketState).Msg("state bucket ready")
+	return kv, nil
+}
+
+// EnsureTimersBucket creates the SEKIA_TIMERS KeyValue bucket holding
+// pending delayed events, or updates it if it already exists.
+func (s *Server) EnsureTimersBucket() (jetstream.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	kv, err := s.js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      protocol.BucketTimers,
+		Description: "sekia delayed events",
+		History:     1,
+		Storage:     jetstream.FileStorage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ensure bucket %s: %w", protocol.BucketTimers, err)
+	}
+
+	s.logger.Info().Str("bucket", protocol.BucketTimers).Msg("timers bucket ready")
+	return kv, nil
+}
+
+// limitOrUnbounded maps a zero or negative limit to JetStream's "unlimited" (-1).
+func limitOrUnbounded(v int64) int64 {
+	if v <= 0 {
+		return -1
+	}
+	return v
+}
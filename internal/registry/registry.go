@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"cmp"
 	"encoding/json"
 	"sync"
 	"time"
@@ -108,10 +109,15 @@ func (r *Registry) Agents() []protocol.AgentInfo {
 			Status:          status,
 			Capabilities:    s.Registration.Capabilities,
 			Commands:        s.Registration.Commands,
+			CommandSchemas:  s.Registration.CommandSchemas,
 			RegisteredAt:    s.RegisteredAt,
 			LastHeartbeat:   s.LastSeen,
 			EventsProcessed: s.LastHeartbeat.EventsProcessed,
 			Errors:          s.LastHeartbeat.Errors,
+			CommandsDenied:  s.LastHeartbeat.CommandsDenied,
+
+			// The heartbeat's is current: it drops when an agent loses its trusted keys.
+			SignatureVersion: cmp.Or(s.LastHeartbeat.SignatureVersion, s.Registration.SignatureVersion),
 		})
 	}
 	return result
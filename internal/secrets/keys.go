@@ -0,0 +1,229 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+const (
+	// DefaultKeysDir is the default command signing keyring directory,
+	// relative to the user's home directory.
+	DefaultKeysDir = ".config/sekia/keys"
+
+	// TrustedKeysFilename is the keyring's trusted keys file, which holds
+	// only public keys and is copied to every agent.
+	TrustedKeysFilename = "trusted_keys.toml"
+
+	// signingKeyPrefix starts the key line of a signing key file.
+	signingKeyPrefix = "SEKIA-SIGNING-KEY-1"
+)
+
+// Keyring manages Ed25519 command signing keys in a directory: one private
+// key file per sender, <name>.key, and the trusted keys file listing the
+// public keys of every key generated, with their status.
+type Keyring struct {
+	Dir string
+}
+
+// DefaultKeyring returns the keyring in ~/.config/sekia/keys.
+func DefaultKeyring() Keyring {
+	homeDir, _ := os.UserHomeDir()
+	return Keyring{Dir: filepath.Join(homeDir, DefaultKeysDir)}
+}
+
+// KeyPath returns the path of the private key file for name.
+func (kr Keyring) KeyPath(name string) string {
+	return filepath.Join(kr.Dir, name+".key")
+}
+
+// TrustedKeysPath returns the path of the trusted keys file.
+func (kr Keyring) TrustedKeysPath() string {
+	return filepath.Join(kr.Dir, TrustedKeysFilename)
+}
+
+// TrustedKeys loads the trusted keys file, which is empty if it does not
+// exist yet.
+func (kr Keyring) TrustedKeys() (*protocol.KeySet, error) {
+	ks, err := protocol.LoadKeySet(kr.TrustedKeysPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &protocol.KeySet{}, nil
+	}
+	return ks, err
+}
+
+// Generate creates a signing key for name that may sign commands from the
+// given sources, writes it to KeyPath(name) and adds its public key to the
+// trusted keys file.
+func (kr Keyring) Generate(name string, sources []string) (*protocol.TrustedKey, error) {
+	if err := checkKeyName(name); err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("at least one source is required")
+	}
+	if _, err := os.Stat(kr.KeyPath(name)); err == nil {
+		return nil, fmt.Errorf("key file already exists: %s (use rotate to replace it)", kr.KeyPath(name))
+	}
+	ks, err := kr.TrustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	return kr.add(ks, name, sources)
+}
+
+// Rotate replaces name's signing key with a new one for the same sources.
+// The old key is marked retired, so agents keep accepting commands it
+// signed until they are revoked.
+func (kr Keyring) Rotate(name string) (newKey, oldKey *protocol.TrustedKey, err error) {
+	old, err := LoadSigningKey(kr.KeyPath(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	ks, err := kr.TrustedKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	oldKey = ks.Key(old.ID)
+	if oldKey == nil {
+		return nil, nil, fmt.Errorf("key %s is not in %s", old.ID, kr.TrustedKeysPath())
+	}
+	if oldKey.Status == protocol.KeyActive {
+		oldKey.Status = protocol.KeyRetired
+	}
+	newKey, err = kr.add(ks, name, oldKey.Sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newKey, ks.Key(old.ID), nil
+}
+
+// Revoke marks the key with the given ID revoked, so agents reject commands
+// signed with it, and deletes its private key file if it is still the
+// current key for its name.
+func (kr Keyring) Revoke(id string) (*protocol.TrustedKey, error) {
+	ks, err := kr.TrustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	k := ks.Key(id)
+	if k == nil {
+		return nil, fmt.Errorf("no key %s in %s", id, kr.TrustedKeysPath())
+	}
+	k.Status = protocol.KeyRevoked
+	if err := kr.writeTrustedKeys(ks); err != nil {
+		return nil, err
+	}
+	if current, err := LoadSigningKey(kr.KeyPath(k.Name)); err == nil && current.ID == id {
+		if err := os.Remove(kr.KeyPath(k.Name)); err != nil {
+			return nil, fmt.Errorf("remove key file: %w", err)
+		}
+	}
+	return k, nil
+}
+
+// add generates a key, writes its private key file and the trusted keys
+// file with its public key added to ks.
+func (kr Keyring) add(ks *protocol.KeySet, name string, sources []string) (*protocol.TrustedKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	key := &protocol.SigningKey{ID: protocol.KeyID(name, pub), PrivateKey: priv}
+	ks.Keys = append(ks.Keys, protocol.TrustedKey{
+		ID:        key.ID,
+		Name:      name,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		Sources:   sources,
+		Created:   time.Now().UTC().Truncate(time.Second),
+		Status:    protocol.KeyActive,
+	})
+
+	if err := os.MkdirAll(kr.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	// Trust the new key before it can sign anything.
+	if err := kr.writeTrustedKeys(ks); err != nil {
+		return nil, err
+	}
+	if err := WriteSigningKey(kr.KeyPath(name), key); err != nil {
+		return nil, err
+	}
+	return &ks.Keys[len(ks.Keys)-1], nil
+}
+
+func (kr Keyring) writeTrustedKeys(ks *protocol.KeySet) error {
+	data, err := ks.Marshal()
+	if err != nil {
+		return fmt.Errorf("encode trusted keys: %w", err)
+	}
+	header := "# Public keys agents trust to sign commands, maintained by sekiactl keys.\n" +
+		"# Copy this file to each agent and point security.trusted_keys at it.\n\n"
+	if err := os.WriteFile(kr.TrustedKeysPath(), append([]byte(header), data...), 0644); err != nil { // #nosec G306 -- public keys only
+		return fmt.Errorf("write trusted keys: %w", err)
+	}
+	return nil
+}
+
+// WriteSigningKey writes a signing key file, replacing any existing one.
+func WriteSigningKey(path string, key *protocol.SigningKey) error {
+	content := fmt.Sprintf("# created: %s\n# key id: %s\n# public key: %s\n%s %s %s\n",
+		time.Now().Format(time.RFC3339),
+		key.ID,
+		base64.StdEncoding.EncodeToString(key.PrivateKey.Public().(ed25519.PublicKey)),
+		signingKeyPrefix,
+		key.ID,
+		base64.StdEncoding.EncodeToString(key.PrivateKey.Seed()),
+	)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("write key file: %w", err)
+	}
+	return nil
+}
+
+// LoadSigningKey reads a signing key file written by WriteSigningKey.
+func LoadSigningKey(path string) (*protocol.SigningKey, error) {
+	f, err := os.Open(filepath.Clean(ExpandHome(path))) // #nosec G304 -- path comes from user config or flags, not untrusted input
+	if err != nil {
+		return nil, fmt.Errorf("open signing key: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != signingKeyPrefix {
+			break
+		}
+		seed, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil || len(seed) != ed25519.SeedSize {
+			break
+		}
+		return &protocol.SigningKey{ID: fields[1], PrivateKey: ed25519.NewKeyFromSeed(seed)}, nil
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	return nil, fmt.Errorf("parse signing key %s: no %s line", path, signingKeyPrefix)
+}
+
+// checkKeyName rejects names that cannot be used as a file name.
+func checkKeyName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\ `) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid key name %q", name)
+	}
+	return nil
+}
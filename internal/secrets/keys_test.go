@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"os"
+	"testing"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func TestKeyring(t *testing.T) {
+	kr := Keyring{Dir: t.TempDir()}
+
+	first, err := kr.Generate("sekiad", []string{"workflow:*", "skill:*"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if _, err := kr.Generate("sekiad", []string{"*"}); err == nil {
+		t.Error("Generate over an existing key: expected an error")
+	}
+	if _, err := kr.Generate("mcp", nil); err == nil {
+		t.Error("Generate without sources: expected an error")
+	}
+	if _, err := kr.Generate("../evil", []string{"*"}); err == nil {
+		t.Error("Generate with a path as name: expected an error")
+	}
+	if info, err := os.Stat(kr.KeyPath("sekiad")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file: %v, %v", info, err)
+	}
+
+	sign := func() protocol.Command {
+		t.Helper()
+		key, err := LoadSigningKey(kr.KeyPath("sekiad"))
+		if err != nil {
+			t.Fatalf("LoadSigningKey: %v", err)
+		}
+		cmd := protocol.NewCommand("noop", map[string]any{}, "workflow:x")
+		if err := protocol.SignCommandEd25519(&cmd, key, 0); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+	verify := func(cmd protocol.Command) error {
+		t.Helper()
+		ks, err := protocol.LoadKeySet(kr.TrustedKeysPath())
+		if err != nil {
+			t.Fatalf("LoadKeySet: %v", err)
+		}
+		return ks.Verify(&cmd)
+	}
+
+	old := sign()
+	if old.KeyID != first.ID {
+		t.Errorf("signed with %s, want %s", old.KeyID, first.ID)
+	}
+	if err := verify(old); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	next, retired, err := kr.Rotate("sekiad")
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if next.ID == first.ID || retired.ID != first.ID || retired.Status != protocol.KeyRetired || len(next.Sources) != 2 {
+		t.Errorf("Rotate = %+v, %+v", next, retired)
+	}
+	current := sign()
+	if current.KeyID != next.ID {
+		t.Errorf("signed with %s after rotation, want %s", current.KeyID, next.ID)
+	}
+	if err := verify(old); err != nil {
+		t.Errorf("retired key: %v", err)
+	}
+
+	if _, err := kr.Revoke(first.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := verify(old); err == nil {
+		t.Error("revoked key: expected an error")
+	}
+	if err := verify(current); err != nil {
+		t.Errorf("current key after revoking the old one: %v", err)
+	}
+	if _, err := os.Stat(kr.KeyPath("sekiad")); err != nil {
+		t.Error("revoking a retired key removed the current key file")
+	}
+
+	if _, err := kr.Revoke(next.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := os.Stat(kr.KeyPath("sekiad")); !os.IsNotExist(err) {
+		t.Errorf("revoking the current key should remove its file: %v", err)
+	}
+	if _, err := kr.Revoke("nope-00000000"); err == nil {
+		t.Error("Revoke of an unknown key: expected an error")
+	}
+}
+
+func TestLoadSigningKey_Invalid(t *testing.T) {
+	path := t.TempDir() + "/bad.key"
+	if err := os.WriteFile(path, []byte("# comment\nAGE-SECRET-KEY-1XYZ\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSigningKey(path); err == nil {
+		t.Error("expected an error for a file without a signing key")
+	}
+}
@@ -0,0 +1,303 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// DefaultCredentialsDir is the default agent NATS credentials directory,
+	// relative to the user's home directory.
+	DefaultCredentialsDir = ".config/sekia/nats"
+
+	// AgentsFilename is the credentials directory's list of agents and
+	// their public nkeys, which sekiad loads (nats.agents_file).
+	AgentsFilename = "agents.toml"
+)
+
+// AgentCredentials is the list of agents allowed to connect to sekiad's
+// NATS server with their own nkey, loaded from a TOML file that sekiactl
+// agents credentials maintains:
+//
+//	[[agents]]
+//	name = "github-agent"
+//	nkey = "UABC..."
+//	sources = ["github"]
+//	created = 2026-03-01T12:00:00Z
+//
+// Each agent may publish events only for its sources; the source "*"
+// allows any. An entry with commands = true may also send commands to any
+// agent, as the MCP server does.
+type AgentCredentials struct {
+	Agents []AgentCredential `toml:"agents"`
+}
+
+// AgentCredential is an agent in AgentCredentials.
+type AgentCredential struct {
+	Name     string    `toml:"name"`
+	NKey     string    `toml:"nkey"` // public user nkey
+	Sources  []string  `toml:"sources"`
+	Commands bool      `toml:"commands,omitempty"` // may send commands to any agent
+	Created  time.Time `toml:"created"`
+}
+
+// LoadAgentCredentials reads an agents file. An empty path returns nil.
+func LoadAgentCredentials(path string) (*AgentCredentials, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Clean(ExpandHome(path))) // #nosec G304 -- path comes from the operator's config
+	if err != nil {
+		return nil, fmt.Errorf("read agent credentials: %w", err)
+	}
+	creds, err := parseAgentCredentials(data)
+	if err != nil {
+		return nil, fmt.Errorf("agent credentials %s: %w", path, err)
+	}
+	return creds, nil
+}
+
+func parseAgentCredentials(data []byte) (*AgentCredentials, error) {
+	var creds AgentCredentials
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&creds); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			return nil, errors.New(strings.TrimSpace(strict.String()))
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(creds.Agents))
+	for i, a := range creds.Agents {
+		where := fmt.Sprintf("agents[%d]", i)
+		if err := checkSubjectToken(a.Name); err != nil {
+			return nil, fmt.Errorf("%s: name: %w", where, err)
+		}
+		if seen[a.Name] {
+			return nil, fmt.Errorf("%s: duplicate agent %s", where, a.Name)
+		}
+		seen[a.Name] = true
+		if !nkeys.IsValidPublicUserKey(a.NKey) {
+			return nil, fmt.Errorf("%s: nkey must be a public user nkey", where)
+		}
+		if len(a.Sources) == 0 {
+			return nil, fmt.Errorf("%s: at least one source is required", where)
+		}
+		for _, src := range a.Sources {
+			if err := checkSource(src); err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+		}
+	}
+	return &creds, nil
+}
+
+// Agent returns the named agent, or nil.
+func (c *AgentCredentials) Agent(name string) *AgentCredential {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Agents {
+		if c.Agents[i].Name == name {
+			return &c.Agents[i]
+		}
+	}
+	return nil
+}
+
+// NkeyOption returns the NATS option that authenticates with the nkey seed
+// file at path (an agent's nats.credentials), as written by
+// CredentialStore.Create.
+func NkeyOption(path string) (nats.Option, error) {
+	return nats.NkeyOptionFromSeed(filepath.Clean(ExpandHome(path)))
+}
+
+// NATSClientConfig is an agent's NATS authentication and TLS settings,
+// embedded in each agent's [nats] config section.
+type NATSClientConfig struct {
+	Token string `mapstructure:"token"`
+
+	// Credentials is the agent's nkey seed file (sekiactl agents
+	// credentials create), used instead of the token.
+	Credentials string `mapstructure:"credentials"`
+
+	// TLS: the CA to verify sekiad with, and the client certificate to
+	// present when sekiad verifies clients (sekiactl tls init).
+	TLSCert string `mapstructure:"tls_cert"`
+	TLSKey  string `mapstructure:"tls_key"`
+	TLSCA   string `mapstructure:"tls_ca"`
+}
+
+// NATSOptions returns the NATS options for cfg: the token or nkey to
+// authenticate with, and the TLS options.
+func NATSOptions(cfg NATSClientConfig) ([]nats.Option, error) {
+	var opts []nats.Option
+	if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+	if cfg.Credentials != "" {
+		opt, err := NkeyOption(cfg.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("load NATS credentials: %w", err)
+		}
+		opts = append(opts, opt)
+	}
+	return append(opts, TLSOptions(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA)...), nil
+}
+
+// CredentialStore manages agent NATS credentials in a directory: one nkey
+// seed file per agent, <name>.nk, and the agents file listing their public
+// nkeys and sources.
+type CredentialStore struct {
+	Dir string
+}
+
+// DefaultCredentialStore returns the credential store in ~/.config/sekia/nats.
+func DefaultCredentialStore() CredentialStore {
+	homeDir, _ := os.UserHomeDir()
+	return CredentialStore{Dir: filepath.Join(homeDir, DefaultCredentialsDir)}
+}
+
+// SeedPath returns the path of the named agent's nkey seed file, which its
+// nats.credentials setting points at.
+func (cs CredentialStore) SeedPath(name string) string {
+	return filepath.Join(cs.Dir, name+".nk")
+}
+
+// AgentsPath returns the path of the agents file.
+func (cs CredentialStore) AgentsPath() string {
+	return filepath.Join(cs.Dir, AgentsFilename)
+}
+
+// List loads the agents file, which is empty if it does not exist yet.
+func (cs CredentialStore) List() (*AgentCredentials, error) {
+	creds, err := LoadAgentCredentials(cs.AgentsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &AgentCredentials{}, nil
+	}
+	return creds, err
+}
+
+// Create generates an nkey for the named agent, allowed to publish events
+// for the given sources and, if commands is set, to send commands, writes
+// its seed to SeedPath(name) and adds it to the agents file.
+func (cs CredentialStore) Create(name string, sources []string, commands bool) (*AgentCredential, error) {
+	if err := checkSubjectToken(name); err != nil {
+		return nil, fmt.Errorf("agent name: %w", err)
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("at least one source is required")
+	}
+	for _, src := range sources {
+		if err := checkSource(src); err != nil {
+			return nil, err
+		}
+	}
+	creds, err := cs.List()
+	if err != nil {
+		return nil, err
+	}
+	if creds.Agent(name) != nil {
+		return nil, fmt.Errorf("agent %s already has credentials (revoke them first)", name)
+	}
+
+	kp, err := nkeys.CreateUser()
+	if err != nil {
+		return nil, fmt.Errorf("generate nkey: %w", err)
+	}
+	pub, err := kp.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate nkey: %w", err)
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("generate nkey: %w", err)
+	}
+	creds.Agents = append(creds.Agents, AgentCredential{
+		Name:     name,
+		NKey:     pub,
+		Sources:  sources,
+		Commands: commands,
+		Created:  time.Now().UTC().Truncate(time.Second),
+	})
+
+	if err := os.MkdirAll(cs.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	content := fmt.Sprintf("# NATS credentials for agent %s, created %s.\n# Point the agent's nats.credentials at this file.\n%s\n",
+		name, time.Now().Format(time.RFC3339), seed)
+	if err := os.WriteFile(cs.SeedPath(name), []byte(content), 0600); err != nil {
+		return nil, fmt.Errorf("write credentials file: %w", err)
+	}
+	if err := cs.writeAgents(creds); err != nil {
+		return nil, err
+	}
+	return creds.Agent(name), nil
+}
+
+// Revoke removes the named agent from the agents file and deletes its seed
+// file. sekiad disconnects it on its next config reload.
+func (cs CredentialStore) Revoke(name string) error {
+	creds, err := cs.List()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(creds.Agents, func(a AgentCredential) bool { return a.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no credentials for agent %s in %s", name, cs.AgentsPath())
+	}
+	creds.Agents = slices.Delete(creds.Agents, i, i+1)
+	if err := cs.writeAgents(creds); err != nil {
+		return err
+	}
+	if err := os.Remove(cs.SeedPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove credentials file: %w", err)
+	}
+	return nil
+}
+
+func (cs CredentialStore) writeAgents(creds *AgentCredentials) error {
+	data, err := toml.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("encode agent credentials: %w", err)
+	}
+	header := "# Agents allowed to connect to sekiad's NATS server, maintained by\n" +
+		"# sekiactl agents credentials. Point sekiad's nats.agents_file at this file.\n\n"
+	if err := os.WriteFile(cs.AgentsPath(), append([]byte(header), data...), 0644); err != nil { // #nosec G306 -- public keys only
+		return fmt.Errorf("write agent credentials: %w", err)
+	}
+	return nil
+}
+
+// checkSubjectToken rejects names that cannot be used as a single NATS
+// subject token (or a file name).
+func checkSubjectToken(s string) error {
+	if s == "" || strings.ContainsAny(s, ".*>/\\ \t") {
+		return fmt.Errorf("%q must be a single NATS subject token", s)
+	}
+	return nil
+}
+
+// checkSource rejects event sources that are neither a subject token nor
+// the "*" wildcard.
+func checkSource(src string) error {
+	if src == "*" {
+		return nil
+	}
+	if err := checkSubjectToken(src); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCredentialStore(t *testing.T) {
+	cs := CredentialStore{Dir: t.TempDir()}
+
+	gh, err := cs.Create("github-agent", []string{"github"}, false)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := cs.Create("webhook-agent", []string{"webhook", "*"}, false); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := cs.Create("mcp", []string{"*"}, true); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := cs.Create("github-agent", []string{"github"}, false); err == nil {
+		t.Error("Create for an agent with credentials: expected an error")
+	}
+	if _, err := cs.Create("slack-agent", nil, false); err == nil {
+		t.Error("Create without sources: expected an error")
+	}
+	for _, name := range []string{"", "a.b", "a*", "../evil"} {
+		if _, err := cs.Create(name, []string{"x"}, false); err == nil {
+			t.Errorf("Create(%q): expected an error", name)
+		}
+	}
+	if _, err := cs.Create("linear-agent", []string{"linear.>"}, false); err == nil {
+		t.Error("Create with a subject as source: expected an error")
+	}
+	if info, err := os.Stat(cs.SeedPath("github-agent")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("seed file: %v, %v", info, err)
+	}
+
+	// The seed file is what the agent's nats.credentials points at.
+	if _, err := NkeyOption(cs.SeedPath("github-agent")); err != nil {
+		t.Errorf("NkeyOption: %v", err)
+	}
+	if opts, err := NATSOptions(NATSClientConfig{Credentials: cs.SeedPath("github-agent"), TLSCA: "ca.pem"}); err != nil || len(opts) != 2 {
+		t.Errorf("NATSOptions = %d options, %v; want 2", len(opts), err)
+	}
+	if _, err := NATSOptions(NATSClientConfig{Credentials: cs.SeedPath("missing")}); err == nil {
+		t.Error("NATSOptions with a missing seed file: expected an error")
+	}
+
+	creds, err := LoadAgentCredentials(cs.AgentsPath())
+	if err != nil {
+		t.Fatalf("LoadAgentCredentials: %v", err)
+	}
+	if len(creds.Agents) != 3 {
+		t.Fatalf("agents = %d, want 3", len(creds.Agents))
+	}
+	if a := creds.Agent("github-agent"); a == nil || a.NKey != gh.NKey || !strings.HasPrefix(a.NKey, "U") || a.Commands {
+		t.Errorf("github-agent = %+v, want nkey %s", a, gh.NKey)
+	}
+	if a := creds.Agent("mcp"); a == nil || !a.Commands {
+		t.Errorf("mcp = %+v, want commands allowed", a)
+	}
+
+	if err := cs.Revoke("github-agent"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := cs.Revoke("github-agent"); err == nil {
+		t.Error("Revoke twice: expected an error")
+	}
+	if _, err := os.Stat(cs.SeedPath("github-agent")); !os.IsNotExist(err) {
+		t.Errorf("seed file after revoke: %v", err)
+	}
+	creds, err = cs.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Agent("github-agent") != nil || creds.Agent("webhook-agent") == nil {
+		t.Errorf("agents after revoke = %+v", creds.Agents)
+	}
+}
+
+func TestLoadAgentCredentials_Errors(t *testing.T) {
+	if creds, err := LoadAgentCredentials(""); creds != nil || err != nil {
+		t.Errorf("empty path = %v, %v; want nil, nil", creds, err)
+	}
+	if _, err := (CredentialStore{Dir: t.TempDir()}).List(); err != nil {
+		t.Errorf("List without an agents file: %v", err)
+	}
+
+	const nkey = "UDXU4RCSJNZOIQHZNWXHXORDPRTGNJAHAHFRGZNEEJCPQTT2M7NLCNF4"
+	tests := map[string]string{
+		"unknown field": "[[agents]]\nname = \"a\"\nnkey = \"" + nkey + "\"\nsources = [\"a\"]\ntoken = \"x\"\n",
+		"bad nkey":      "[[agents]]\nname = \"a\"\nnkey = \"SUAB\"\nsources = [\"a\"]\n",
+		"no sources":    "[[agents]]\nname = \"a\"\nnkey = \"" + nkey + "\"\n",
+		"bad name":      "[[agents]]\nname = \"a.b\"\nnkey = \"" + nkey + "\"\nsources = [\"a\"]\n",
+		"duplicate": "[[agents]]\nname = \"a\"\nnkey = \"" + nkey + "\"\nsources = [\"a\"]\n" +
+			"[[agents]]\nname = \"a\"\nnkey = \"" + nkey + "\"\nsources = [\"a\"]\n",
+	}
+	for name, data := range tests {
+		if _, err := parseAgentCredentials([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -122,7 +122,7 @@ func ResolveIdentity(v *viper.Viper) ([]age.Identity, error) {
 
 	// 3. secrets.identity config key.
 	if path := v.GetString("secrets.identity"); path != "" {
-		return LoadIdentity(expandHome(path))
+		return LoadIdentity(ExpandHome(path))
 	}
 
 	// 4. Default location: ~/.config/sekia/age.key.
@@ -165,8 +165,8 @@ func HasEncryptedValues(v *viper.Viper) bool {
 	return false
 }
 
-// expandHome replaces a leading ~ with the user's home directory.
-func expandHome(path string) string {
+// ExpandHome replaces a leading ~ with the user's home directory.
+func ExpandHome(path string) string {
 	if !strings.HasPrefix(path, "~") {
 		return path
 	}
@@ -398,9 +398,9 @@ func TestExpandHome(t *testing.T) {
 		{"~", home},
 	}
 	for _, tt := range tests {
-		got := expandHome(tt.input)
+		got := ExpandHome(tt.input)
 		if got != tt.want {
-			t.Errorf("expandHome(%q) = %q, want %q", tt.input, got, tt.want)
+			t.Errorf("ExpandHome(%q) = %q, want %q", tt.input, got, tt.want)
 		}
 	}
 }
@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// DefaultTLSDir is the default local certificate authority directory,
+	// relative to the user's home directory.
+	DefaultTLSDir = ".config/sekia/tls"
+
+	// ServerCertName is the name of the NATS server certificate issued by
+	// CertAuthority.IssueServer.
+	ServerCertName = "server"
+
+	caName = "ca"
+)
+
+// CertAuthority is a local certificate authority for the NATS TCP
+// listener, kept in a directory: the CA certificate and key (ca.pem,
+// ca-key.pem), the server certificate (server.pem, server-key.pem) and one
+// client certificate per agent (<name>.pem, <name>-key.pem).
+type CertAuthority struct {
+	Dir string
+}
+
+// DefaultCertAuthority returns the certificate authority in ~/.config/sekia/tls.
+func DefaultCertAuthority() CertAuthority {
+	homeDir, _ := os.UserHomeDir()
+	return CertAuthority{Dir: filepath.Join(homeDir, DefaultTLSDir)}
+}
+
+// CAPath returns the path of the CA certificate, which sekiad's nats.tls_ca
+// and each agent's nats.tls_ca point at.
+func (ca CertAuthority) CAPath() string { return ca.CertPath(caName) }
+
+// CertPath returns the path of the named certificate.
+func (ca CertAuthority) CertPath(name string) string {
+	return filepath.Join(ca.Dir, name+".pem")
+}
+
+// KeyPath returns the path of the named certificate's private key.
+func (ca CertAuthority) KeyPath(name string) string {
+	return filepath.Join(ca.Dir, name+"-key.pem")
+}
+
+// Exists reports whether the named certificate has been issued.
+func (ca CertAuthority) Exists(name string) bool {
+	_, err := os.Stat(ca.CertPath(name))
+	return err == nil
+}
+
+// Init creates the CA certificate and key, valid for validity, unless they
+// already exist. It reports whether it created them.
+func (ca CertAuthority) Init(validity time.Duration) (bool, error) {
+	if ca.Exists(caName) {
+		return false, nil
+	}
+	if err := os.MkdirAll(ca.Dir, 0700); err != nil {
+		return false, fmt.Errorf("create directory: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "sekia local CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	if err := ca.issue(caName, tmpl, validity, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IssueServer issues the NATS server certificate for the given host names
+// and IP addresses.
+func (ca CertAuthority) IssueServer(hosts []string, validity time.Duration) error {
+	if len(hosts) == 0 {
+		return errors.New("at least one host is required")
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0]},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	return ca.issueSigned(ServerCertName, tmpl, validity)
+}
+
+// IssueClient issues a client certificate for the named agent, which it
+// presents when sekiad's nats.verify_clients is set.
+func (ca CertAuthority) IssueClient(name string, validity time.Duration) error {
+	if err := checkSubjectToken(name); err != nil {
+		return fmt.Errorf("agent name: %w", err)
+	}
+	if name == caName || name == ServerCertName {
+		return fmt.Errorf("agent name %q is reserved", name)
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	return ca.issueSigned(name, tmpl, validity)
+}
+
+// issueSigned issues a certificate signed by the CA.
+func (ca CertAuthority) issueSigned(name string, tmpl *x509.Certificate, validity time.Duration) error {
+	if ca.Exists(name) {
+		return fmt.Errorf("certificate already exists: %s", ca.CertPath(name))
+	}
+	parent, err := tls.LoadX509KeyPair(ca.CAPath(), ca.KeyPath(caName))
+	if err != nil {
+		return fmt.Errorf("load CA: %w", err)
+	}
+	return ca.issue(name, tmpl, validity, &parent)
+}
+
+// issue generates a key for tmpl, signs it with parent (self-signed if
+// nil) and writes the certificate and key.
+func (ca CertAuthority) issue(name string, tmpl *x509.Certificate, validity time.Duration, parent *tls.Certificate) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("generate serial number: %w", err)
+	}
+	now := time.Now()
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = now.Add(-time.Hour)
+	tmpl.NotAfter = now.Add(validity)
+
+	issuer, signer := tmpl, any(key)
+	if parent != nil {
+		if issuer, err = x509.ParseCertificate(parent.Certificate[0]); err != nil {
+			return fmt.Errorf("parse CA certificate: %w", err)
+		}
+		signer = parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		return fmt.Errorf("create certificate %s: %w", name, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encode key: %w", err)
+	}
+
+	// Write the key first, so a certificate never exists without one.
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(ca.KeyPath(name), keyPEM, 0600); err != nil {
+		return fmt.Errorf("write key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(ca.CertPath(name), certPEM, 0644); err != nil { // #nosec G306 -- certificates are public
+		return fmt.Errorf("write certificate: %w", err)
+	}
+	return nil
+}
+
+// TLSOptions returns the NATS options for a client's nats.tls_cert,
+// nats.tls_key and nats.tls_ca settings: the CA to verify the server with
+// and the client certificate to present. The files are read when
+// connecting.
+func TLSOptions(certFile, keyFile, caFile string) []nats.Option {
+	var opts []nats.Option
+	if caFile != "" {
+		opts = append(opts, nats.RootCAs(ExpandHome(caFile)))
+	}
+	if certFile != "" || keyFile != "" {
+		opts = append(opts, nats.ClientCert(ExpandHome(certFile), ExpandHome(keyFile)))
+	}
+	return opts
+}
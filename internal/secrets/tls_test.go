@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCertAuthority(t *testing.T) {
+	ca := CertAuthority{Dir: t.TempDir()}
+
+	if err := ca.IssueServer([]string{"localhost"}, time.Hour); err == nil {
+		t.Error("IssueServer before Init: expected an error")
+	}
+	created, err := ca.Init(24 * time.Hour)
+	if err != nil || !created {
+		t.Fatalf("Init = %v, %v", created, err)
+	}
+	if created, err := ca.Init(24 * time.Hour); err != nil || created {
+		t.Errorf("second Init = %v, %v; want existing CA kept", created, err)
+	}
+	if err := ca.IssueServer([]string{"localhost", "127.0.0.1"}, time.Hour); err != nil {
+		t.Fatalf("IssueServer: %v", err)
+	}
+	if err := ca.IssueClient("github-agent", time.Hour); err != nil {
+		t.Fatalf("IssueClient: %v", err)
+	}
+	if err := ca.IssueClient("github-agent", time.Hour); err == nil {
+		t.Error("IssueClient twice: expected an error")
+	}
+	for _, name := range []string{"server", "ca", "../evil", ""} {
+		if err := ca.IssueClient(name, time.Hour); err == nil {
+			t.Errorf("IssueClient(%q): expected an error", name)
+		}
+	}
+	for _, name := range []string{"ca", "server", "github-agent"} {
+		if info, err := os.Stat(ca.KeyPath(name)); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("%s key file: %v, %v", name, info, err)
+		}
+	}
+
+	// Mutual TLS between the server and agent certificates.
+	caPEM, err := os.ReadFile(ca.CAPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatal("parse CA certificate")
+	}
+	serverCert, err := tls.LoadX509KeyPair(ca.CertPath(ServerCertName), ca.KeyPath(ServerCertName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := tls.LoadX509KeyPair(ca.CertPath("github-agent"), ca.KeyPath("github-agent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// handshake returns the server's verdict on the client's certificates.
+	handshake := func(clientCerts []tls.Certificate) error {
+		t.Helper()
+		sc, cc := net.Pipe()
+		defer sc.Close()
+		defer cc.Close()
+		srv := tls.Server(sc, &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})
+		cli := tls.Client(cc, &tls.Config{
+			RootCAs:      pool,
+			ServerName:   "127.0.0.1",
+			Certificates: clientCerts,
+		})
+		done := make(chan error, 1)
+		go func() {
+			err := srv.Handshake()
+			sc.Close()
+			done <- err
+		}()
+		if err := cli.Handshake(); err == nil {
+			// Under TLS 1.3 the server's alert arrives after the client's
+			// handshake; read it so the server is not left blocked.
+			_, _ = cli.Read(make([]byte, 1))
+		}
+		return <-done
+	}
+
+	if err := handshake([]tls.Certificate{clientCert}); err != nil {
+		t.Errorf("handshake with client certificate: %v", err)
+	}
+	if err := handshake(nil); err == nil {
+		t.Error("handshake without client certificate: expected the server to reject it")
+	}
+}
@@ -35,6 +35,7 @@ type Sentinel struct {
 	registry *registry.Registry
 	engine   *workflow.Engine
 	logger   zerolog.Logger
+	subjects string // event subject scheme (protocol.Subjects*)
 
 	mu     sync.Mutex
 	cancel context.CancelFunc
@@ -52,6 +53,12 @@ func New(cfg Config, llm ai.LLMClient, nc *nats.Conn, reg *registry.Registry, en
 	}
 }
 
+// SetSubjectScheme sets the event subject scheme for published actions.
+// Call before Start.
+func (s *Sentinel) SetSubjectScheme(scheme string) {
+	s.subjects = scheme
+}
+
 // Start begins the sentinel check loop in a background goroutine.
 func (s *Sentinel) Start() {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -194,7 +201,7 @@ func (s *Sentinel) processResult(result string) {
 			s.logger.Error().Err(err).Msg("failed to marshal sentinel event")
 			continue
 		}
-		if err := s.nc.Publish("sekia.events.sentinel", data); err != nil {
+		if err := s.nc.Publish(protocol.SubjectEventsFor(s.subjects, "sentinel", eventType), data); err != nil {
 			s.logger.Error().Err(err).Msg("failed to publish sentinel event")
 			continue
 		}
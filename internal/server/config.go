@@ -9,8 +9,11 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/sekia-ai/sekia/internal/ai"
+	"github.com/sekia-ai/sekia/internal/cloudevents"
 	"github.com/sekia-ai/sekia/internal/secrets"
 	"github.com/sekia-ai/sekia/internal/sentinel"
+	"github.com/sekia-ai/sekia/internal/workflow"
+	"github.com/sekia-ai/sekia/pkg/protocol"
 	"github.com/sekia-ai/sekia/pkg/sockpath"
 )
 
@@ -24,6 +27,9 @@ type ConversationConfig struct {
 type Config struct {
 	Server       ServerConfig       `mapstructure:"server"`
 	NATS         NATSConfig         `mapstructure:"nats"`
+	Events       EventsConfig       `mapstructure:"events"`
+	Commands     CommandsConfig     `mapstructure:"commands"`
+	DLQ          DLQConfig          `mapstructure:"dlq"`
 	Workflows    WorkflowConfig     `mapstructure:"workflows"`
 	Web          WebConfig          `mapstructure:"web"`
 	AI           ai.Config          `mapstructure:"ai"`
@@ -31,11 +37,30 @@ type Config struct {
 	Skills       SkillsConfig       `mapstructure:"skills"`
 	Conversation ConversationConfig `mapstructure:"conversation"`
 	Security     SecurityConfig     `mapstructure:"security"`
+	CloudEvents  CloudEventsConfig  `mapstructure:"cloudevents"`
+}
+
+// CloudEventsConfig holds CloudEvents ingress and egress settings.
+// POST /api/v1/events is always served on the Unix socket; Listen adds a
+// TCP listener serving only that route.
+type CloudEventsConfig struct {
+	Listen string                   `mapstructure:"listen"`
+	Token  string                   `mapstructure:"token"` // #nosec G117 -- bearer token required on Listen (empty = no auth)
+	Sinks  []cloudevents.SinkConfig `mapstructure:"sinks"`
 }
 
 // SecurityConfig holds application-level security settings.
 type SecurityConfig struct {
 	CommandSecret string `mapstructure:"command_secret"`
+	PolicyFile    string `mapstructure:"policy_file"` // command policy (protocol.CommandPolicy); empty = allow all
+
+	// CommandTTL is how long commands signed with the version 2 envelope
+	// stay valid, including time spent queued for an offline agent.
+	CommandTTL time.Duration `mapstructure:"command_ttl"`
+
+	// SigningKey is the Ed25519 key file (sekiactl keys generate) commands
+	// are signed with for agents that trust it; others get HMAC signatures.
+	SigningKey string `mapstructure:"signing_key"`
 }
 
 // WebConfig holds web dashboard settings.
@@ -58,6 +83,49 @@ type NATSConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	Token    string `mapstructure:"token"`
+
+	// AgentsFile lists the agents that authenticate with their own nkey and
+	// are restricted to their own subjects (sekiactl agents credentials).
+	AgentsFile string `mapstructure:"agents_file"`
+
+	// TLS for the TCP listener (sekiactl tls init). With VerifyClients,
+	// agents must present a client certificate signed by TLSCA.
+	TLSCert       string `mapstructure:"tls_cert"`
+	TLSKey        string `mapstructure:"tls_key"`
+	TLSCA         string `mapstructure:"tls_ca"`
+	VerifyClients bool   `mapstructure:"verify_clients"`
+}
+
+// Validate checks the TLS settings.
+func (c NATSConfig) Validate() error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	if c.VerifyClients && (c.TLSCert == "" || c.TLSCA == "") {
+		return fmt.Errorf("verify_clients requires tls_cert, tls_key and tls_ca")
+	}
+	return nil
+}
+
+// EventsConfig holds event settings: the durable event log (SEKIA_EVENTS
+// stream), the subject scheme and payload validation.
+type EventsConfig struct {
+	MaxAge     time.Duration `mapstructure:"max_age"`
+	MaxBytes   int64         `mapstructure:"max_bytes"`
+	MaxMsgs    int64         `mapstructure:"max_msgs"`
+	AckWait    time.Duration `mapstructure:"ack_wait"`
+	Subjects   string        `mapstructure:"subjects"`   // flat, hierarchical or compat (protocol.Subjects*)
+	Validation string        `mapstructure:"validation"` // off, warn or reject (protocol.Validation*)
+}
+
+// CommandsConfig holds command work queue (SEKIA_COMMANDS stream) settings.
+type CommandsConfig struct {
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// DLQConfig holds dead-letter queue (SEKIA_DLQ stream) settings.
+type DLQConfig struct {
+	MaxAge time.Duration `mapstructure:"max_age"`
 }
 
 // SkillsConfig holds skill system settings.
@@ -68,10 +136,12 @@ type SkillsConfig struct {
 
 // WorkflowConfig holds Lua workflow engine settings.
 type WorkflowConfig struct {
-	Dir             string        `mapstructure:"dir"`
-	HotReload       bool          `mapstructure:"hot_reload"`
-	HandlerTimeout  time.Duration `mapstructure:"handler_timeout"`
-	VerifyIntegrity bool          `mapstructure:"verify_integrity"`
+	Dir             string              `mapstructure:"dir"`
+	HotReload       bool                `mapstructure:"hot_reload"`
+	HandlerTimeout  time.Duration       `mapstructure:"handler_timeout"`
+	VerifyIntegrity bool                `mapstructure:"verify_integrity"`
+	Shadow          []string            `mapstructure:"shadow"` // workflows whose publishes/commands are recorded, not sent
+	HTTP            workflow.HTTPConfig `mapstructure:"http"`   // sekia.http host allowlist
 }
 
 // LoadConfig reads configuration from file, env, and flags.
@@ -87,6 +157,16 @@ func LoadConfig(cfgFile string) (Config, error) {
 
 	v.SetDefault("nats.data_dir", filepath.Join(homeDir, ".local", "share", "sekia", "nats"))
 
+	v.SetDefault("events.max_age", 7*24*time.Hour)
+	v.SetDefault("events.max_bytes", int64(1<<30))
+	v.SetDefault("events.max_msgs", int64(-1))
+	v.SetDefault("events.ack_wait", 5*time.Minute)
+	v.SetDefault("events.subjects", protocol.SubjectsFlat)
+	v.SetDefault("events.validation", protocol.ValidationWarn)
+
+	v.SetDefault("commands.max_age", 24*time.Hour)
+	v.SetDefault("dlq.max_age", 30*24*time.Hour)
+
 	v.SetDefault("workflows.dir", filepath.Join(configDir, "workflows"))
 	v.SetDefault("workflows.hot_reload", true)
 	v.SetDefault("workflows.handler_timeout", 30*time.Second)
@@ -104,6 +184,8 @@ func LoadConfig(cfgFile string) (Config, error) {
 	v.SetDefault("conversation.max_history", 50)
 	v.SetDefault("conversation.ttl", 1*time.Hour)
 
+	v.SetDefault("security.command_ttl", protocol.DefaultCommandTTL)
+
 	v.SetDefault("sentinel.enabled", false)
 	v.SetDefault("sentinel.interval", 5*time.Minute)
 	v.SetDefault("sentinel.checklist_path", filepath.Join(configDir, "sentinel.md"))
@@ -123,9 +205,20 @@ func LoadConfig(cfgFile string) (Config, error) {
 	v.AutomaticEnv()
 
 	v.BindEnv("nats.token", "SEKIA_NATS_TOKEN")
+	v.BindEnv("nats.agents_file", "SEKIA_NATS_AGENTS_FILE")
+	v.BindEnv("nats.tls_cert", "SEKIA_NATS_TLS_CERT")
+	v.BindEnv("nats.tls_key", "SEKIA_NATS_TLS_KEY")
+	v.BindEnv("nats.tls_ca", "SEKIA_NATS_TLS_CA")
+	v.BindEnv("nats.verify_clients", "SEKIA_NATS_VERIFY_CLIENTS")
 	v.BindEnv("web.username", "SEKIA_WEB_USERNAME")
 	v.BindEnv("web.password", "SEKIA_WEB_PASSWORD")
 	v.BindEnv("security.command_secret", "SEKIA_COMMAND_SECRET")
+	v.BindEnv("security.policy_file", "SEKIA_POLICY_FILE")
+	v.BindEnv("security.command_ttl", "SEKIA_COMMAND_TTL")
+	v.BindEnv("security.signing_key", "SEKIA_SIGNING_KEY")
+	v.BindEnv("events.subjects", "SEKIA_EVENT_SUBJECTS")
+	v.BindEnv("events.validation", "SEKIA_EVENT_VALIDATION")
+	v.BindEnv("cloudevents.token", "SEKIA_CLOUDEVENTS_TOKEN")
 
 	// Config file is optional.
 	_ = v.ReadInConfig()
@@ -139,5 +232,22 @@ func LoadConfig(cfgFile string) (Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return cfg, err
 	}
+	if err := cfg.NATS.Validate(); err != nil {
+		return cfg, fmt.Errorf("nats: %w", err)
+	}
+	if err := protocol.ValidateSubjectScheme(cfg.Events.Subjects); err != nil {
+		return cfg, err
+	}
+	if err := protocol.ValidateValidationMode(cfg.Events.Validation); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Workflows.HTTP.Validate(); err != nil {
+		return cfg, fmt.Errorf("workflows.http: %w", err)
+	}
+	for i, sink := range cfg.CloudEvents.Sinks {
+		if err := sink.Validate(); err != nil {
+			return cfg, fmt.Errorf("cloudevents.sinks[%d]: %w", i, err)
+		}
+	}
 	return cfg, nil
 }
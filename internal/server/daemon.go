@@ -2,9 +2,14 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
 	"syscall"
 	"time"
 
@@ -12,11 +17,16 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/sekia-ai/sekia/internal/ai"
 	"github.com/sekia-ai/sekia/internal/api"
+	"github.com/sekia-ai/sekia/internal/cloudevents"
 	"github.com/sekia-ai/sekia/internal/conversation"
+	"github.com/sekia-ai/sekia/internal/dlq"
 	"github.com/sekia-ai/sekia/internal/natsserver"
 	"github.com/sekia-ai/sekia/internal/registry"
+	"github.com/sekia-ai/sekia/internal/secrets"
 	"github.com/sekia-ai/sekia/internal/sentinel"
 	"github.com/sekia-ai/sekia/internal/skills"
+	"github.com/sekia-ai/sekia/internal/state"
+	"github.com/sekia-ai/sekia/internal/timers"
 	"github.com/sekia-ai/sekia/internal/web"
 	"github.com/sekia-ai/sekia/internal/workflow"
 	"github.com/sekia-ai/sekia/pkg/protocol"
@@ -31,8 +41,14 @@ type Daemon struct {
 	engine      *workflow.Engine
 	sentinel    *sentinel.Sentinel
 	skills      *skills.Manager
+	state       *state.Store
+	timers      *timers.Scheduler
 	apiServer   *api.Server
 	webServer   *web.Server
+	ingress     *cloudevents.Ingress
+	sinks       []*cloudevents.Sink
+	policy      *protocol.CommandPolicy // loaded from security.policy_file
+	signingKey  *protocol.SigningKey    // loaded from security.signing_key
 	startedAt   time.Time
 	stopCh      chan struct{}
 	readyCh     chan struct{}
@@ -66,20 +82,68 @@ func (d *Daemon) Run() error {
 	d.startedAt = time.Now()
 
 	// 1. Start embedded NATS.
-	if d.cfg.NATS.Host != "" && d.cfg.NATS.Token == "" {
+	agentUsers, err := loadAgentUsers(d.cfg.NATS.AgentsFile)
+	if err != nil {
+		return err
+	}
+	if d.cfg.NATS.Host != "" && d.cfg.NATS.Token == "" && agentUsers == nil && !d.cfg.NATS.VerifyClients {
 		d.logger.Warn().Msg("NATS is listening on TCP without authentication; set nats.token or SEKIA_NATS_TOKEN")
 	}
+	if d.cfg.NATS.Host != "" && d.cfg.NATS.TLSCert == "" && !isLoopback(d.cfg.NATS.Host) {
+		d.logger.Warn().Str("host", d.cfg.NATS.Host).Msg("NATS is listening on a non-loopback address without TLS; set nats.tls_cert and nats.tls_key")
+	}
 	ns, err := natsserver.New(natsserver.Config{
-		StoreDir: d.cfg.NATS.DataDir,
-		Host:     d.cfg.NATS.Host,
-		Port:     d.cfg.NATS.Port,
-		Token:    d.cfg.NATS.Token,
+		StoreDir:      d.cfg.NATS.DataDir,
+		Host:          d.cfg.NATS.Host,
+		Port:          d.cfg.NATS.Port,
+		Token:         d.cfg.NATS.Token,
+		TLSCert:       secrets.ExpandHome(d.cfg.NATS.TLSCert),
+		TLSKey:        secrets.ExpandHome(d.cfg.NATS.TLSKey),
+		TLSCA:         secrets.ExpandHome(d.cfg.NATS.TLSCA),
+		VerifyClients: d.cfg.NATS.VerifyClients,
+		Agents:        agentUsers,
 	}, d.logger)
 	if err != nil {
 		return fmt.Errorf("start nats: %w", err)
 	}
 	d.nats = ns
 
+	// 1a. Create the durable event log, the command work queue, the
+	// dead-letter queue, the workflow state bucket, and the delayed
+	// event timers bucket.
+	if err := ns.EnsureEventStream(natsserver.EventStreamConfig{
+		MaxAge:   d.cfg.Events.MaxAge,
+		MaxBytes: d.cfg.Events.MaxBytes,
+		MaxMsgs:  d.cfg.Events.MaxMsgs,
+	}); err != nil {
+		ns.Shutdown()
+		return fmt.Errorf("create event stream: %w", err)
+	}
+	if err := ns.EnsureCommandStream(natsserver.CommandStreamConfig{
+		MaxAge: d.cfg.Commands.MaxAge,
+	}); err != nil {
+		ns.Shutdown()
+		return fmt.Errorf("create command stream: %w", err)
+	}
+	if err := ns.EnsureDLQStream(natsserver.DLQStreamConfig{
+		MaxAge: d.cfg.DLQ.MaxAge,
+	}); err != nil {
+		ns.Shutdown()
+		return fmt.Errorf("create dead-letter stream: %w", err)
+	}
+	stateKV, err := ns.EnsureStateBucket()
+	if err != nil {
+		ns.Shutdown()
+		return fmt.Errorf("create state bucket: %w", err)
+	}
+	d.state = state.New(ns.JetStream(), stateKV)
+	timersKV, err := ns.EnsureTimersBucket()
+	if err != nil {
+		ns.Shutdown()
+		return fmt.Errorf("create timers bucket: %w", err)
+	}
+	d.timers = timers.New(ns.Conn(), timersKV, d.logger)
+
 	// 2. Start agent registry.
 	reg, err := registry.New(ns.Conn(), d.logger)
 	if err != nil {
@@ -91,7 +155,7 @@ func (d *Daemon) Run() error {
 	// 3. Create LLM client (if configured).
 	llm := d.createLLMClient()
 
-	// 4. Start workflow engine.
+	// 4. Create workflow engine and load workflows.
 	if err := d.startWorkflowEngine(llm); err != nil {
 		reg.Close()
 		ns.Shutdown()
@@ -108,13 +172,38 @@ func (d *Daemon) Run() error {
 	// 4b. Load skills (if configured).
 	d.loadSkills()
 
-	// 4c. Start sentinel (if configured).
+	// 4c. Start routing events only once all workflows (including skill
+	// handlers) are loaded, so events replayed from the durable log find
+	// their handlers after a restart.
+	if d.engine != nil {
+		if err := d.engine.Start(); err != nil {
+			d.engine.Stop()
+			reg.Close()
+			ns.Shutdown()
+			return fmt.Errorf("start workflow engine: %w", err)
+		}
+	}
+
+	// Arm delayed events persisted by a previous run once workflows are
+	// routing, so overdue ones firing right away reach their handlers.
+	startCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err = d.timers.Start(startCtx)
+	cancel()
+	if err != nil {
+		d.logger.Warn().Err(err).Msg("failed to load pending timers")
+	}
+
+	// 4d. Start sentinel (if configured).
 	if d.cfg.Sentinel.Enabled && llm != nil {
 		d.sentinel = sentinel.New(d.cfg.Sentinel, llm, ns.Conn(), reg, d.engine, d.logger)
+		d.sentinel.SetSubjectScheme(d.cfg.Events.Subjects)
 		d.sentinel.Start()
 	}
 
-	// 4d. Subscribe to config reload for the daemon.
+	// 4e. Start CloudEvents sinks (if configured).
+	d.startSinks(ns.Conn())
+
+	// 4f. Subscribe to config reload for the daemon.
 	ns.Conn().Subscribe(protocol.SubjectConfigReload, func(_ *nats.Msg) {
 		d.reloadConfig()
 	})
@@ -127,13 +216,19 @@ func (d *Daemon) Run() error {
 	if d.skills != nil {
 		d.apiServer.SetSkillsManager(d.skills)
 	}
+	d.apiServer.SetDLQStore(dlq.New(ns.JetStream()))
+	d.apiServer.SetStateStore(d.state)
+	d.apiServer.SetTimerScheduler(d.timers)
+	d.ingress = cloudevents.NewIngress(ns.Conn(), d.cfg.Events.Subjects, d.logger)
+	d.apiServer.SetEventIngress(d.ingress)
 	apiErrCh, err := d.startAPIServer()
 	if err != nil {
 		return err
 	}
 
-	// 6. Start web UI (if configured).
+	// 6. Start web UI and CloudEvents TCP ingress (if configured).
 	webErrCh := d.startWebServer(reg, ns)
+	d.startIngressListener()
 
 	d.logger.Info().
 		Str("socket", d.cfg.Server.Socket).
@@ -149,19 +244,37 @@ func (d *Daemon) Run() error {
 }
 
 func (d *Daemon) startWorkflowEngine(llm ai.LLMClient) error {
-	if d.cfg.Security.CommandSecret == "" {
+	if d.cfg.Security.CommandSecret == "" && d.cfg.Security.SigningKey == "" {
 		d.logger.Warn().Msg("no command signing secret configured; commands will not be authenticated. Set security.command_secret or SEKIA_COMMAND_SECRET")
 	}
 	if d.cfg.Workflows.Dir == "" {
 		return nil
 	}
+	policy, err := protocol.LoadCommandPolicy(d.cfg.Security.PolicyFile)
+	if err != nil {
+		return err
+	}
+	signingKey, err := loadSigningKey(d.cfg.Security.SigningKey)
+	if err != nil {
+		return err
+	}
 	eng := workflow.New(d.nats.Conn(), d.cfg.Workflows.Dir, llm, d.cfg.Workflows.HandlerTimeout, d.cfg.Security.CommandSecret, d.logger)
 	if d.cfg.Workflows.VerifyIntegrity {
 		eng.SetVerifyIntegrity(true)
 	}
-	if err := eng.Start(); err != nil {
-		return fmt.Errorf("start workflow engine: %w", err)
-	}
+	eng.SetShadowWorkflows(d.cfg.Workflows.Shadow)
+	eng.SetHTTPConfig(d.cfg.Workflows.HTTP)
+	eng.SetAgentSource(d.registry.Agents)
+	eng.SetCommandPolicy(policy)
+	eng.SetCommandTTL(d.cfg.Security.CommandTTL)
+	eng.SetSigningKey(signingKey)
+	d.policy = policy
+	d.signingKey = signingKey
+	eng.SetSubjectScheme(d.cfg.Events.Subjects)
+	eng.SetEventValidation(d.cfg.Events.Validation)
+	eng.SetStateStore(state.NewWorkflowAdapter(d.state))
+	eng.SetTimerStore(timers.NewWorkflowAdapter(d.timers))
+	eng.SetEventLog(d.nats.JetStream(), d.cfg.Events.AckWait)
 	if err := eng.LoadDir(); err != nil {
 		d.logger.Warn().Err(err).Msg("failed to load workflows")
 	}
@@ -174,6 +287,45 @@ func (d *Daemon) startWorkflowEngine(llm ai.LLMClient) error {
 	return nil
 }
 
+// loadSigningKey reads the command signing key at path (nil if path is empty).
+func loadSigningKey(path string) (*protocol.SigningKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return secrets.LoadSigningKey(path)
+}
+
+// loadAgentUsers reads the agent credentials file at path (none if path is
+// empty) as the embedded NATS server's agent users.
+func loadAgentUsers(path string) ([]natsserver.AgentUser, error) {
+	creds, err := secrets.LoadAgentCredentials(path)
+	if err != nil || creds == nil {
+		return nil, err
+	}
+	users := make([]natsserver.AgentUser, 0, len(creds.Agents))
+	for _, a := range creds.Agents {
+		users = append(users, natsserver.AgentUser{Name: a.Name, NKey: a.NKey, Sources: a.Sources, Commands: a.Commands})
+	}
+	return users, nil
+}
+
+// isLoopback reports whether host is localhost or a loopback address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// keyID returns key's ID, or "" for no key.
+func keyID(key *protocol.SigningKey) string {
+	if key == nil {
+		return ""
+	}
+	return key.ID
+}
+
 func (d *Daemon) startAPIServer() (chan error, error) {
 	apiLn, err := d.apiServer.Listen()
 	if err != nil {
@@ -210,6 +362,39 @@ func (d *Daemon) startWebServer(reg *registry.Registry, ns *natsserver.Server) c
 	return errCh
 }
 
+func (d *Daemon) startSinks(nc *nats.Conn) {
+	for _, cfg := range d.cfg.CloudEvents.Sinks {
+		sink, err := cloudevents.NewSink(cfg, d.logger)
+		if err == nil {
+			err = sink.Start(nc)
+		}
+		if err != nil {
+			d.logger.Error().Err(err).Str("url", cfg.URL).Msg("failed to start CloudEvents sink")
+			continue
+		}
+		d.sinks = append(d.sinks, sink)
+	}
+}
+
+func (d *Daemon) startIngressListener() {
+	if d.cfg.CloudEvents.Listen == "" {
+		return
+	}
+	if d.cfg.CloudEvents.Token == "" {
+		d.logger.Warn().Msg("CloudEvents ingress has no authentication; set cloudevents.token or SEKIA_CLOUDEVENTS_TOKEN")
+	}
+	ln, err := d.ingress.Listen(d.cfg.CloudEvents.Listen, d.cfg.CloudEvents.Token)
+	if err != nil {
+		d.logger.Error().Err(err).Msg("CloudEvents ingress listen failed")
+		return
+	}
+	go func() {
+		if err := d.ingress.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Error().Err(err).Msg("CloudEvents ingress error")
+		}
+	}()
+}
+
 func (d *Daemon) waitForShutdown(apiErrCh, webErrCh chan error) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -248,11 +433,7 @@ func (d *Daemon) NATSConnectOpts() []nats.Option {
 	if d.nats == nil {
 		return nil
 	}
-	opts := []nats.Option{nats.InProcessServer(d.nats.NATSServer())}
-	if d.cfg.NATS.Token != "" {
-		opts = append(opts, nats.Token(d.cfg.NATS.Token))
-	}
-	return opts
+	return append([]nats.Option{nats.InProcessServer(d.nats.NATSServer())}, d.nats.AuthOpts()...)
 }
 
 func (d *Daemon) loadSkills() {
@@ -325,6 +506,14 @@ func (d *Daemon) reloadConfig() {
 	}
 
 	// Apply reloadable settings.
+	if d.nats != nil {
+		if users, err := loadAgentUsers(newCfg.NATS.AgentsFile); err != nil {
+			d.logger.Error().Err(err).Msg("failed to reload agent credentials")
+		} else if err := d.nats.SetAgents(users); err != nil {
+			d.logger.Error().Err(err).Msg("failed to apply agent credentials")
+		}
+	}
+
 	if d.engine != nil {
 		if newCfg.Workflows.HandlerTimeout != d.cfg.Workflows.HandlerTimeout {
 			d.engine.SetHandlerTimeout(newCfg.Workflows.HandlerTimeout)
@@ -336,6 +525,46 @@ func (d *Daemon) reloadConfig() {
 			d.logger.Info().Bool("verify_integrity", newCfg.Workflows.VerifyIntegrity).Msg("updated integrity verification")
 		}
 
+		// Settings that workflows capture when loaded take effect through a
+		// single reload once they are all applied, so no workflow keeps
+		// running with the old ones.
+		reload := false
+		if !slices.Equal(newCfg.Workflows.Shadow, d.cfg.Workflows.Shadow) {
+			d.engine.SetShadowWorkflows(newCfg.Workflows.Shadow)
+			reload = true
+			d.logger.Info().Strs("shadow", newCfg.Workflows.Shadow).Msg("updated shadow workflows")
+		}
+
+		if !reflect.DeepEqual(newCfg.Workflows.HTTP, d.cfg.Workflows.HTTP) {
+			d.engine.SetHTTPConfig(newCfg.Workflows.HTTP)
+			reload = true
+			d.logger.Info().Msg("updated sekia.http allowlist")
+		}
+
+		if newCfg.Security.CommandTTL != d.cfg.Security.CommandTTL {
+			d.engine.SetCommandTTL(newCfg.Security.CommandTTL)
+			reload = true
+			d.logger.Info().Dur("command_ttl", newCfg.Security.CommandTTL).Msg("updated command TTL")
+		}
+
+		if policy, err := protocol.LoadCommandPolicy(newCfg.Security.PolicyFile); err != nil {
+			d.logger.Error().Err(err).Msg("failed to reload command policy")
+		} else if !reflect.DeepEqual(policy, d.policy) {
+			d.policy = policy
+			d.engine.SetCommandPolicy(policy)
+			reload = true
+			d.logger.Info().Msg("updated command policy")
+		}
+
+		if key, err := loadSigningKey(newCfg.Security.SigningKey); err != nil {
+			d.logger.Error().Err(err).Msg("failed to reload signing key")
+		} else if keyID(key) != keyID(d.signingKey) {
+			d.signingKey = key
+			d.engine.SetSigningKey(key)
+			reload = true
+			d.logger.Info().Str("key_id", keyID(key)).Msg("updated command signing key")
+		}
+
 		if d.llmOverride == nil && newCfg.AI.APIKey != "" &&
 			(newCfg.AI.APIKey != d.cfg.AI.APIKey || newCfg.AI.Model != d.cfg.AI.Model ||
 				newCfg.AI.PersonaPath != d.cfg.AI.PersonaPath) {
@@ -344,6 +573,12 @@ func (d *Daemon) reloadConfig() {
 			d.engine.SetLLMClient(newLLM)
 			d.logger.Info().Str("model", newCfg.AI.Model).Msg("updated AI client")
 		}
+
+		if reload {
+			if err := d.engine.ReloadAll(); err != nil {
+				d.logger.Error().Err(err).Msg("failed to reload workflows")
+			}
+		}
 	}
 
 	d.cfg = newCfg
@@ -360,12 +595,21 @@ func (d *Daemon) shutdown() error {
 	if d.apiServer != nil {
 		d.apiServer.Shutdown(ctx)
 	}
+	if d.ingress != nil {
+		d.ingress.Shutdown(ctx)
+	}
+	for _, sink := range d.sinks {
+		sink.Stop()
+	}
 	if d.sentinel != nil {
 		d.sentinel.Stop()
 	}
 	if d.engine != nil {
 		d.engine.Stop()
 	}
+	if d.timers != nil {
+		d.timers.Stop()
+	}
 	if d.registry != nil {
 		d.registry.Close()
 	}
@@ -3,10 +3,14 @@ package server_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -398,3 +402,270 @@ end)
 		t.Fatal("timed out waiting for AI workflow command")
 	}
 }
+
+func TestDurableCommandDelivery(t *testing.T) {
+	wfDir := t.TempDir()
+
+	// The workflow sends a command per event and reports every result it sees.
+	workflowCode := `
+sekia.on("sekia.events.test", function(event)
+	sekia.command("durable-agent", "flaky", { n = event.payload.n })
+end)
+
+sekia.on("sekia.results.durable-agent", function(event)
+	sekia.publish("sekia.events.observed", "observed", {
+		command_id = event.payload.command_id,
+		status     = event.payload.status,
+		attempts   = event.payload.attempts,
+	})
+end)
+`
+	os.WriteFile(filepath.Join(wfDir, "durable.lua"), []byte(workflowCode), 0644)
+
+	d, _ := newTestDaemon(t, wfDir)
+
+	nc, err := nats.Connect(d.NATSClientURL(), d.NATSConnectOpts()...)
+	if err != nil {
+		t.Fatalf("nats connect: %v", err)
+	}
+	defer nc.Close()
+
+	observed := make(chan protocol.Event, 1)
+	sub, err := nc.Subscribe("sekia.events.observed", func(msg *nats.Msg) {
+		var ev protocol.Event
+		json.Unmarshal(msg.Data, &ev)
+		observed <- ev
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// The event (and so the command) is published before the agent exists:
+	// the command must wait in the work queue instead of being lost.
+	ev := protocol.NewEvent("test.event", "test-source", map[string]any{"n": float64(7)})
+	evData, _ := json.Marshal(ev)
+	nc.Publish("sekia.events.test", evData)
+	nc.Flush()
+	time.Sleep(200 * time.Millisecond)
+
+	testAgent, err := agent.New(agent.Config{
+		NATSUrl:  d.NATSClientURL(),
+		NATSOpts: d.NATSConnectOpts(),
+	}, "durable-agent", "0.2.3", []string{"testing"}, []string{"flaky"}, zerolog.New(os.Stderr))
+	if err != nil {
+		t.Fatalf("create test agent: %v", err)
+	}
+	defer testAgent.Close()
+
+	// Fail transiently on the first attempt, succeed on the second.
+	var calls atomic.Int32
+	var gotID atomic.Value
+	err = testAgent.ServeCommands(agent.CommandOptions{
+		Backoff: []time.Duration{50 * time.Millisecond},
+	}, func(_ context.Context, cmd *protocol.Command) (map[string]any, error) {
+		gotID.Store(cmd.ID)
+		if calls.Add(1) == 1 {
+			return nil, agent.Transient(errors.New("upstream unavailable"))
+		}
+		return map[string]any{"n": cmd.Payload["n"]}, nil
+	})
+	if err != nil {
+		t.Fatalf("serve commands: %v", err)
+	}
+
+	select {
+	case res := <-observed:
+		if res.Payload["status"] != protocol.ResultOK {
+			t.Errorf("status = %v, want ok", res.Payload["status"])
+		}
+		if res.Payload["attempts"] != float64(2) {
+			t.Errorf("attempts = %v, want 2", res.Payload["attempts"])
+		}
+		id, _ := gotID.Load().(string)
+		if id == "" || res.Payload["command_id"] != id {
+			t.Errorf("command_id = %v, want %q", res.Payload["command_id"], id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for command result")
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler calls = %d, want 2", got)
+	}
+}
+
+func TestCommandSyncEndToEnd(t *testing.T) {
+	wfDir := t.TempDir()
+
+	// The workflow uses the command's result in the same handler.
+	workflowCode := `
+sekia.on("sekia.events.test", function(event)
+	local res, err = sekia.command_sync("sync-agent", "create_issue", { title = event.payload.title }, 5)
+	if err then
+		sekia.publish("sekia.events.observed", "sync.failed", { error = err })
+		return
+	end
+	sekia.publish("sekia.events.observed", "sync.done", { issue_id = res.issue_id })
+end)
+`
+	os.WriteFile(filepath.Join(wfDir, "sync.lua"), []byte(workflowCode), 0644)
+
+	d, _ := newTestDaemon(t, wfDir)
+
+	testAgent, err := agent.New(agent.Config{
+		NATSUrl:  d.NATSClientURL(),
+		NATSOpts: d.NATSConnectOpts(),
+	}, "sync-agent", "0.2.3", []string{"testing"}, []string{"create_issue"}, zerolog.New(os.Stderr))
+	if err != nil {
+		t.Fatalf("create test agent: %v", err)
+	}
+	defer testAgent.Close()
+
+	err = testAgent.ServeCommands(agent.CommandOptions{}, func(_ context.Context, cmd *protocol.Command) (map[string]any, error) {
+		return map[string]any{"issue_id": "ISS-" + cmd.Payload["title"].(string)}, nil
+	})
+	if err != nil {
+		t.Fatalf("serve commands: %v", err)
+	}
+
+	nc, err := nats.Connect(d.NATSClientURL(), d.NATSConnectOpts()...)
+	if err != nil {
+		t.Fatalf("nats connect: %v", err)
+	}
+	defer nc.Close()
+
+	observed := make(chan protocol.Event, 1)
+	sub, err := nc.Subscribe("sekia.events.observed", func(msg *nats.Msg) {
+		var ev protocol.Event
+		json.Unmarshal(msg.Data, &ev)
+		observed <- ev
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	ev := protocol.NewEvent("test.event", "test-source", map[string]any{"title": "42"})
+	evData, _ := json.Marshal(ev)
+	nc.Publish("sekia.events.test", evData)
+	nc.Flush()
+
+	select {
+	case got := <-observed:
+		if got.Type != "sync.done" {
+			t.Fatalf("type = %s, payload = %v", got.Type, got.Payload)
+		}
+		if got.Payload["issue_id"] != "ISS-42" {
+			t.Errorf("issue_id = %v, want ISS-42", got.Payload["issue_id"])
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for workflow to observe the command result")
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	wfDir := t.TempDir()
+	wfPath := filepath.Join(wfDir, "faulty.lua")
+
+	// The first version of the workflow always fails.
+	os.WriteFile(wfPath, []byte(`
+sekia.on("sekia.events.test", function(event)
+	error("boom")
+end)
+`), 0644)
+
+	d, client := newTestDaemon(t, wfDir)
+
+	nc, err := nats.Connect(d.NATSClientURL(), d.NATSConnectOpts()...)
+	if err != nil {
+		t.Fatalf("nats connect: %v", err)
+	}
+	defer nc.Close()
+
+	observed := make(chan protocol.Event, 1)
+	sub, err := nc.Subscribe("sekia.events.observed", func(msg *nats.Msg) {
+		var ev protocol.Event
+		json.Unmarshal(msg.Data, &ev)
+		observed <- ev
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	ev := protocol.NewEvent("test.event", "test-source", map[string]any{"title": "retry me"})
+	evData, _ := json.Marshal(ev)
+	nc.Publish("sekia.events.test", evData)
+	nc.Flush()
+
+	listDLQ := func() protocol.DLQResponse {
+		t.Helper()
+		resp, err := client.Get("http://sekiad/api/v1/dlq?workflow=faulty")
+		if err != nil {
+			t.Fatalf("GET /api/v1/dlq: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /api/v1/dlq: HTTP %d", resp.StatusCode)
+		}
+		var out protocol.DLQResponse
+		json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	// The failure is recorded in the dead-letter queue.
+	var entries []protocol.DeadLetter
+	deadline := time.Now().Add(10 * time.Second)
+	for len(entries) == 0 && time.Now().Before(deadline) {
+		entries = listDLQ().Entries
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(entries))
+	}
+	dl := entries[0]
+	if dl.Kind != protocol.DeadLetterEvent || dl.Workflow != "faulty" || dl.Subject != "sekia.events.test" {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+	if !strings.Contains(dl.Error, "boom") {
+		t.Errorf("error = %q, want it to mention boom", dl.Error)
+	}
+	if dl.Handler != "sekia.events.test" || dl.Attempts != 1 {
+		t.Errorf("handler = %q attempts = %d", dl.Handler, dl.Attempts)
+	}
+
+	// Fix the workflow and reload it.
+	os.WriteFile(wfPath, []byte(`
+sekia.on("sekia.events.test", function(event)
+	sekia.publish("sekia.events.observed", "fixed", { title = event.payload.title })
+end)
+`), 0644)
+	resp, err := client.Post("http://sekiad/api/v1/workflows/reload", "application/json", nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = client.Post(fmt.Sprintf("http://sekiad/api/v1/dlq/%d/replay", dl.Seq), "application/json", nil)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("replay: HTTP %d", resp.StatusCode)
+	}
+
+	select {
+	case got := <-observed:
+		if got.Payload["title"] != "retry me" {
+			t.Errorf("title = %v, want retry me", got.Payload["title"])
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for replayed event")
+	}
+
+	if n := len(listDLQ().Entries); n != 0 {
+		t.Errorf("dead letters after replay = %d, want 0", n)
+	}
+}
@@ -2,17 +2,16 @@ package slack
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/rs/zerolog"
 	slackapi "github.com/slack-go/slack"
 
+	"github.com/sekia-ai/sekia/internal/secrets"
 	"github.com/sekia-ai/sekia/pkg/agent"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -59,13 +58,28 @@ func NewAgent(cfg Config, instanceName string, logger zerolog.Logger) *SlackAgen
 // starts the Socket Mode listener, and blocks until signal or Stop().
 func (sa *SlackAgent) Run() error {
 	// 1. Connect to NATS via the agent SDK.
-	natsOpts := sa.natsOpts
-	if sa.cfg.NATS.Token != "" {
-		natsOpts = append(natsOpts, nats.Token(sa.cfg.NATS.Token))
+	natsOpts, err := secrets.NATSOptions(sa.cfg.NATS.NATSClientConfig)
+	if err != nil {
+		return err
+	}
+	natsOpts = append(sa.natsOpts, natsOpts...)
+	policy, err := protocol.LoadCommandPolicy(sa.cfg.Security.PolicyFile)
+	if err != nil {
+		return err
+	}
+	trustedKeys, err := protocol.LoadKeySet(sa.cfg.Security.TrustedKeys)
+	if err != nil {
+		return err
 	}
 	agentCfg := agent.Config{
-		NATSUrl:  sa.cfg.NATS.URL,
-		NATSOpts: natsOpts,
+		NATSUrl:         sa.cfg.NATS.URL,
+		NATSOpts:        natsOpts,
+		EventValidation: sa.cfg.Events.Validation,
+		CommandSchemas:  protocol.CommandSchemas("slack"),
+		CommandPolicy:   policy,
+
+		MinSignatureVersion: sa.cfg.Security.MinSignatureVersion,
+		TrustedKeys:         trustedKeys,
 	}
 	a, err := agent.New(
 		agentCfg, sa.instanceName, agentVersion,
@@ -83,11 +97,10 @@ func (sa *SlackAgent) Run() error {
 		sa.logger.Warn().Err(err).Msg("failed to register config reload handler")
 	}
 
-	// 2. Subscribe to commands.
-	_, err = a.Conn().Subscribe(protocol.SubjectCommands(sa.instanceName), sa.handleCommand)
-	if err != nil {
+	// 2. Consume commands from the durable work queue.
+	if err := a.ServeCommands(agent.CommandOptions{}, sa.handleCommand); err != nil {
 		a.Close()
-		return fmt.Errorf("subscribe commands: %w", err)
+		return fmt.Errorf("serve commands: %w", err)
 	}
 
 	// 3. Start Socket Mode listener.
@@ -173,65 +186,60 @@ func (sa *SlackAgent) reloadConfig() {
 	// connection is long-lived and cannot be swapped without reconnecting.
 	sa.cfg.Security.CommandSecret = newCfg.Security.CommandSecret
 
+	if policy, err := protocol.LoadCommandPolicy(newCfg.Security.PolicyFile); err != nil {
+		sa.logger.Error().Err(err).Msg("failed to reload command policy")
+	} else {
+		sa.agent.SetCommandPolicy(policy)
+		sa.cfg.Security.PolicyFile = newCfg.Security.PolicyFile
+	}
+	sa.agent.SetMinSignatureVersion(newCfg.Security.MinSignatureVersion)
+	sa.cfg.Security.MinSignatureVersion = newCfg.Security.MinSignatureVersion
+	if ks, err := protocol.LoadKeySet(newCfg.Security.TrustedKeys); err != nil {
+		sa.logger.Error().Err(err).Msg("failed to reload trusted keys")
+	} else {
+		sa.agent.SetTrustedKeys(ks)
+		sa.cfg.Security.TrustedKeys = newCfg.Security.TrustedKeys
+	}
+
 	sa.logger.Info().Msg("slack agent configuration reloaded")
 }
 
 func (sa *SlackAgent) publishEvent(ev protocol.Event) {
-	data, err := json.Marshal(ev)
-	if err != nil {
-		sa.logger.Error().Err(err).Msg("marshal event")
-		return
-	}
-	if err := sa.agent.Conn().Publish(protocol.SubjectEvents("slack"), data); err != nil {
-		sa.logger.Error().Err(err).Msg("publish event")
+	subject := protocol.SubjectEventsFor(sa.cfg.Events.Subjects, "slack", ev.Type)
+	if err := sa.agent.PublishEvent(subject, ev); err != nil {
+		sa.logger.Error().Err(err).Str("type", ev.Type).Msg("publish event")
 		return
 	}
-	sa.agent.RecordEvent()
 }
 
-func (sa *SlackAgent) handleCommand(msg *nats.Msg) {
-	var cmd protocol.Command
-	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
-		sa.agent.RecordError()
-		sa.logger.Error().Err(err).Msg("unmarshal command")
-		return
+// handleCommand executes a single command from workflows. It is called by
+// the agent SDK, which acknowledges the command, retries transient failures,
+// and publishes the outcome on sekia.results.<agent> (and replies to
+// sekia.command_sync requests). Posted messages report their ts.
+func (sa *SlackAgent) handleCommand(ctx context.Context, cmd *protocol.Command) (map[string]any, error) {
+	if err := sa.agent.VerifyCommand(ctx, cmd, sa.cfg.Security.CommandSecret); err != nil {
+		return nil, err
 	}
-
-	if !protocol.VerifyCommand(&cmd, sa.cfg.Security.CommandSecret) {
-		sa.agent.RecordError()
-		sa.logger.Warn().
-			Str("command", cmd.Command).
-			Str("source", cmd.Source).
-			Msg("rejected command: invalid or missing signature")
-		return
+	if err := sa.agent.AuthorizeCommand(cmd); err != nil {
+		return nil, err
 	}
 
 	sa.logger.Info().
 		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
 		Str("source", cmd.Source).
 		Msg("received command")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	var err error
 	switch cmd.Command {
 	case "send_message":
-		err = cmdSendMessage(ctx, sa.slClient, cmd.Payload)
+		return cmdSendMessage(ctx, sa.slClient, cmd.Payload)
 	case "add_reaction":
-		err = cmdAddReaction(ctx, sa.slClient, cmd.Payload)
+		return cmdAddReaction(ctx, sa.slClient, cmd.Payload)
 	case "update_message":
-		err = cmdUpdateMessage(ctx, sa.slClient, cmd.Payload)
+		return cmdUpdateMessage(ctx, sa.slClient, cmd.Payload)
 	case "send_reply":
-		err = cmdSendReply(ctx, sa.slClient, cmd.Payload)
+		return cmdSendReply(ctx, sa.slClient, cmd.Payload)
 	default:
-		err = fmt.Errorf("unknown command: %s", cmd.Command)
-	}
-
-	if err != nil {
-		sa.agent.RecordError()
-		sa.logger.Error().Err(err).Str("command", cmd.Command).Msg("command failed")
-	} else {
-		sa.agent.RecordEvent()
+		return nil, fmt.Errorf("unknown command: %s", cmd.Command)
 	}
 }
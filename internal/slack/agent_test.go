@@ -420,25 +420,25 @@ type mockSlackClient struct {
 	calls []mockCall
 }
 
-func (m *mockSlackClient) PostMessage(_ context.Context, channel, text string) error {
+func (m *mockSlackClient) PostMessage(_ context.Context, channel, text string) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.calls = append(m.calls, mockCall{"PostMessage", map[string]string{"channel": channel, "text": text}})
-	return nil
+	return "1700000000.000100", nil
 }
 
-func (m *mockSlackClient) PostMessageWithBlocks(_ context.Context, channel, text string, blocksJSON []byte) error {
+func (m *mockSlackClient) PostMessageWithBlocks(_ context.Context, channel, text string, blocksJSON []byte) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.calls = append(m.calls, mockCall{"PostMessageWithBlocks", map[string]string{"channel": channel, "text": text, "blocks": string(blocksJSON)}})
-	return nil
+	return "1700000000.000200", nil
 }
 
-func (m *mockSlackClient) PostReply(_ context.Context, channel, threadTS, text string) error {
+func (m *mockSlackClient) PostReply(_ context.Context, channel, threadTS, text string) (string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.calls = append(m.calls, mockCall{"PostReply", map[string]string{"channel": channel, "thread_ts": threadTS, "text": text}})
-	return nil
+	return "1700000000.000300", nil
 }
 
 func (m *mockSlackClient) AddReaction(_ context.Context, channel, timestamp, emoji string) error {
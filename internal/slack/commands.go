@@ -9,10 +9,11 @@ import (
 )
 
 // SlackClient abstracts the Slack API methods used by commands.
+// Methods that post a message return its timestamp (ts).
 type SlackClient interface {
-	PostMessage(ctx context.Context, channel, text string) error
-	PostMessageWithBlocks(ctx context.Context, channel, text string, blocksJSON []byte) error
-	PostReply(ctx context.Context, channel, threadTS, text string) error
+	PostMessage(ctx context.Context, channel, text string) (string, error)
+	PostMessageWithBlocks(ctx context.Context, channel, text string, blocksJSON []byte) (string, error)
+	PostReply(ctx context.Context, channel, threadTS, text string) (string, error)
 	AddReaction(ctx context.Context, channel, timestamp, emoji string) error
 	UpdateMessage(ctx context.Context, channel, timestamp, text string) error
 	UpdateMessageWithBlocks(ctx context.Context, channel, timestamp, text string, blocksJSON []byte) error
@@ -23,28 +24,28 @@ type realSlackClient struct {
 	client *slackapi.Client
 }
 
-func (c *realSlackClient) PostMessage(ctx context.Context, channel, text string) error {
-	_, _, err := c.client.PostMessageContext(ctx, channel,
+func (c *realSlackClient) PostMessage(ctx context.Context, channel, text string) (string, error) {
+	_, ts, err := c.client.PostMessageContext(ctx, channel,
 		slackapi.MsgOptionText(text, false))
-	return err
+	return ts, err
 }
 
-func (c *realSlackClient) PostMessageWithBlocks(ctx context.Context, channel, text string, blocksJSON []byte) error {
+func (c *realSlackClient) PostMessageWithBlocks(ctx context.Context, channel, text string, blocksJSON []byte) (string, error) {
 	var blocks slackapi.Blocks
 	if err := json.Unmarshal(blocksJSON, &blocks); err != nil {
-		return fmt.Errorf("parse blocks JSON: %w", err)
+		return "", fmt.Errorf("parse blocks JSON: %w", err)
 	}
-	_, _, err := c.client.PostMessageContext(ctx, channel,
+	_, ts, err := c.client.PostMessageContext(ctx, channel,
 		slackapi.MsgOptionText(text, false),
 		slackapi.MsgOptionBlocks(blocks.BlockSet...))
-	return err
+	return ts, err
 }
 
-func (c *realSlackClient) PostReply(ctx context.Context, channel, threadTS, text string) error {
-	_, _, err := c.client.PostMessageContext(ctx, channel,
+func (c *realSlackClient) PostReply(ctx context.Context, channel, threadTS, text string) (string, error) {
+	_, ts, err := c.client.PostMessageContext(ctx, channel,
 		slackapi.MsgOptionText(text, false),
 		slackapi.MsgOptionTS(threadTS))
-	return err
+	return ts, err
 }
 
 func (c *realSlackClient) AddReaction(ctx context.Context, channel, timestamp, emoji string) error {
@@ -81,76 +82,94 @@ func extractString(payload map[string]any, key string) (string, error) {
 	return s, nil
 }
 
-func cmdSendMessage(ctx context.Context, sc SlackClient, payload map[string]any) error {
+func cmdSendMessage(ctx context.Context, sc SlackClient, payload map[string]any) (map[string]any, error) {
 	channel, err := extractString(payload, "channel")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	text, err := extractString(payload, "text")
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var ts string
 	if blocksRaw, ok := payload["blocks"]; ok {
 		blocksJSON, err := json.Marshal(blocksRaw)
 		if err != nil {
-			return fmt.Errorf("marshal blocks: %w", err)
+			return nil, fmt.Errorf("marshal blocks: %w", err)
 		}
-		return sc.PostMessageWithBlocks(ctx, channel, text, blocksJSON)
+		ts, err = sc.PostMessageWithBlocks(ctx, channel, text, blocksJSON)
+	} else {
+		ts, err = sc.PostMessage(ctx, channel, text)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return sc.PostMessage(ctx, channel, text)
+	return map[string]any{"channel": channel, "ts": ts}, nil
 }
 
-func cmdUpdateMessage(ctx context.Context, sc SlackClient, payload map[string]any) error {
+func cmdUpdateMessage(ctx context.Context, sc SlackClient, payload map[string]any) (map[string]any, error) {
 	channel, err := extractString(payload, "channel")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	timestamp, err := extractString(payload, "timestamp")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	text, err := extractString(payload, "text")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if blocksRaw, ok := payload["blocks"]; ok {
 		blocksJSON, err := json.Marshal(blocksRaw)
 		if err != nil {
-			return fmt.Errorf("marshal blocks: %w", err)
+			return nil, fmt.Errorf("marshal blocks: %w", err)
 		}
-		return sc.UpdateMessageWithBlocks(ctx, channel, timestamp, text, blocksJSON)
+		err = sc.UpdateMessageWithBlocks(ctx, channel, timestamp, text, blocksJSON)
+	} else {
+		err = sc.UpdateMessage(ctx, channel, timestamp, text)
 	}
-	return sc.UpdateMessage(ctx, channel, timestamp, text)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"channel": channel, "ts": timestamp}, nil
 }
 
-func cmdAddReaction(ctx context.Context, sc SlackClient, payload map[string]any) error {
+func cmdAddReaction(ctx context.Context, sc SlackClient, payload map[string]any) (map[string]any, error) {
 	channel, err := extractString(payload, "channel")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	timestamp, err := extractString(payload, "timestamp")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	emoji, err := extractString(payload, "emoji")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := sc.AddReaction(ctx, channel, timestamp, emoji); err != nil {
+		return nil, err
 	}
-	return sc.AddReaction(ctx, channel, timestamp, emoji)
+	return map[string]any{"channel": channel, "ts": timestamp}, nil
 }
 
-func cmdSendReply(ctx context.Context, sc SlackClient, payload map[string]any) error {
+func cmdSendReply(ctx context.Context, sc SlackClient, payload map[string]any) (map[string]any, error) {
 	channel, err := extractString(payload, "channel")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	threadTS, err := extractString(payload, "thread_ts")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	text, err := extractString(payload, "text")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	ts, err := sc.PostReply(ctx, channel, threadTS, text)
+	if err != nil {
+		return nil, err
 	}
-	return sc.PostReply(ctx, channel, threadTS, text)
+	return map[string]any{"channel": channel, "ts": ts, "thread_ts": threadTS}, nil
 }
@@ -0,0 +1,54 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WorkflowAdapter wraps a Store to implement the workflow.StateStore interface.
+type WorkflowAdapter struct {
+	store *Store
+}
+
+// NewWorkflowAdapter creates an adapter for the workflow engine.
+func NewWorkflowAdapter(store *Store) *WorkflowAdapter {
+	return &WorkflowAdapter{store: store}
+}
+
+// Get returns the value and revision of key, or revision 0 if it does not exist.
+func (a *WorkflowAdapter) Get(ctx context.Context, workflow, key string) ([]byte, uint64, error) {
+	e, err := a.store.Get(ctx, workflow, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return e.Value, e.Revision, nil
+}
+
+// Set stores value under key.
+func (a *WorkflowAdapter) Set(ctx context.Context, workflow, key string, value []byte, ttl time.Duration) error {
+	_, err := a.store.Put(ctx, workflow, key, value, ttl)
+	return err
+}
+
+// CompareAndSet stores value only if key is still at revision.
+func (a *WorkflowAdapter) CompareAndSet(ctx context.Context, workflow, key string, value []byte, revision uint64, ttl time.Duration) (bool, error) {
+	_, err := a.store.Update(ctx, workflow, key, value, revision, ttl)
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes key.
+func (a *WorkflowAdapter) Delete(ctx context.Context, workflow, key string) error {
+	return a.store.Delete(ctx, workflow, key)
+}
+
+// Incr atomically adds delta to the integer under key.
+func (a *WorkflowAdapter) Incr(ctx context.Context, workflow, key string, delta int64, ttl time.Duration) (int64, error) {
+	return a.store.Incr(ctx, workflow, key, delta, ttl)
+}
@@ -0,0 +1,237 @@
+// Package state stores persistent per-workflow key-value state in the
+// SEKIA_STATE JetStream KeyValue bucket.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+var (
+	// ErrNotFound is returned when a key does not exist or has expired.
+	ErrNotFound = errors.New("key not found")
+	// ErrConflict is returned by Update when the key changed since it was read.
+	ErrConflict = errors.New("key was modified concurrently")
+)
+
+// incrAttempts bounds the compare-and-set retries of Incr under contention.
+const incrAttempts = 20
+
+// Entry is one stored value.
+type Entry struct {
+	Key      string
+	Value    []byte // JSON
+	Revision uint64
+	Updated  time.Time
+}
+
+// Store reads and writes workflow state. Keys are namespaced per workflow:
+// the bucket key is "<workflow>.<key>", with each part escaped so any
+// workflow name or key string is allowed.
+type Store struct {
+	js jetstream.JetStream
+	kv jetstream.KeyValue
+}
+
+// New creates a Store backed by the given bucket. js is used for writes
+// carrying a per-key TTL, which the KeyValue API only supports on create.
+func New(js jetstream.JetStream, kv jetstream.KeyValue) *Store {
+	return &Store{js: js, kv: kv}
+}
+
+// Get returns the current entry for key.
+func (s *Store) Get(ctx context.Context, workflow, key string) (Entry, error) {
+	e, err := s.kv.Get(ctx, bucketKey(workflow, key))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return Entry{}, ErrNotFound
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("get %s: %w", key, err)
+	}
+	return Entry{Key: key, Value: e.Value(), Revision: e.Revision(), Updated: e.Created()}, nil
+}
+
+// Put stores value under key, replacing any previous value. A positive ttl
+// makes the key expire after that long.
+func (s *Store) Put(ctx context.Context, workflow, key string, value []byte, ttl time.Duration) (uint64, error) {
+	bk := bucketKey(workflow, key)
+	var (
+		rev uint64
+		err error
+	)
+	if ttl > 0 {
+		rev, err = s.publish(ctx, bk, value, ttl)
+	} else {
+		rev, err = s.kv.Put(ctx, bk, value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("put %s: %w", key, err)
+	}
+	return rev, nil
+}
+
+// Update stores value only if key is still at revision (0 = key must not
+// exist), returning ErrConflict otherwise.
+func (s *Store) Update(ctx context.Context, workflow, key string, value []byte, revision uint64, ttl time.Duration) (uint64, error) {
+	bk := bucketKey(workflow, key)
+	var (
+		rev uint64
+		err error
+	)
+	switch {
+	case revision == 0 && ttl > 0:
+		rev, err = s.kv.Create(ctx, bk, value, jetstream.KeyTTL(ttl))
+	case revision == 0:
+		rev, err = s.kv.Create(ctx, bk, value)
+	case ttl > 0:
+		rev, err = s.publish(ctx, bk, value, ttl, jetstream.WithExpectLastSequencePerSubject(revision))
+	default:
+		rev, err = s.kv.Update(ctx, bk, value, revision)
+	}
+	if errors.Is(err, jetstream.ErrKeyExists) {
+		return 0, ErrConflict
+	}
+	if err != nil {
+		return 0, fmt.Errorf("update %s: %w", key, err)
+	}
+	return rev, nil
+}
+
+// Incr atomically adds delta to the integer stored under key (a missing key
+// counts as 0) and returns the new value. A positive ttl is applied on every
+// write, so the counter expires ttl after its last increment.
+func (s *Store) Incr(ctx context.Context, workflow, key string, delta int64, ttl time.Duration) (int64, error) {
+	for range incrAttempts {
+		var (
+			cur int64
+			rev uint64
+		)
+		e, err := s.Get(ctx, workflow, key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return 0, err
+		default:
+			if err := json.Unmarshal(e.Value, &cur); err != nil {
+				return 0, fmt.Errorf("incr %s: value is not an integer", key)
+			}
+			rev = e.Revision
+		}
+
+		next := cur + delta
+		_, err = s.Update(ctx, workflow, key, []byte(strconv.FormatInt(next, 10)), rev, ttl)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return next, nil
+	}
+	return 0, fmt.Errorf("incr %s: %w", key, ErrConflict)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, workflow, key string) error {
+	if err := s.kv.Delete(ctx, bucketKey(workflow, key)); err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns every live entry of a workflow, sorted by key.
+func (s *Store) List(ctx context.Context, workflow string) ([]Entry, error) {
+	prefix := escape(workflow) + "."
+	lister, err := s.kv.ListKeysFiltered(ctx, prefix+">")
+	if err != nil {
+		return nil, fmt.Errorf("list keys: %w", err)
+	}
+	defer lister.Stop()
+
+	entries := []Entry{}
+	for bk := range lister.Keys() {
+		key, err := unescape(strings.TrimPrefix(bk, prefix))
+		if err != nil {
+			continue
+		}
+		e, err := s.Get(ctx, workflow, key)
+		if errors.Is(err, ErrNotFound) {
+			continue // deleted or expired since listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// publish writes a value with a per-message TTL directly to the bucket's
+// subject.
+func (s *Store) publish(ctx context.Context, bk string, value []byte, ttl time.Duration, opts ...jetstream.PublishOpt) (uint64, error) {
+	msg := &nats.Msg{Subject: "$KV." + protocol.BucketState + "." + bk, Data: value}
+	ack, err := s.js.PublishMsg(ctx, msg, append(opts, jetstream.WithMsgTTL(ttl))...)
+	if err != nil {
+		return 0, err
+	}
+	return ack.Sequence, nil
+}
+
+// bucketKey returns the bucket key holding key for workflow.
+func bucketKey(workflow, key string) string {
+	return escape(workflow) + "." + escape(key)
+}
+
+// escape encodes s as a single KeyValue key token. Letters, digits, '-' and
+// '_' are kept; every other byte becomes "=XX".
+func escape(s string) string {
+	if s == "" {
+		return "="
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "=%02X", c)
+	}
+	return b.String()
+}
+
+// unescape reverses escape.
+func unescape(s string) (string, error) {
+	if s == "=" {
+		return "", nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '=' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		b.WriteByte(byte(v))
+		i += 2
+	}
+	return b.String(), nil
+}
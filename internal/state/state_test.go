@@ -0,0 +1,188 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		NoLog:      true,
+		NoSigs:     true,
+		DontListen: true,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	nc, err := nats.Connect("", nats.InProcessServer(ns))
+	if err != nil {
+		ns.Shutdown()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+	})
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	kv, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:         protocol.BucketState,
+		Storage:        jetstream.MemoryStorage,
+		LimitMarkerTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("create bucket: %v", err)
+	}
+	return New(js, kv)
+}
+
+func TestStore(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "wf", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing: err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := store.Put(ctx, "wf", "greeting", []byte(`"hello"`), 0); err != nil {
+		t.Fatal(err)
+	}
+	// Same key in another workflow's namespace.
+	if _, err := store.Put(ctx, "skill:other", "greeting", []byte(`"hi"`), 0); err != nil {
+		t.Fatal(err)
+	}
+	e, err := store.Get(ctx, "wf", "greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(e.Value) != `"hello"` || e.Revision == 0 {
+		t.Errorf("entry = %+v", e)
+	}
+
+	// Compare-and-set.
+	if _, err := store.Update(ctx, "wf", "greeting", []byte(`"x"`), e.Revision+100, 0); !errors.Is(err, ErrConflict) {
+		t.Errorf("update with stale revision: err = %v, want ErrConflict", err)
+	}
+	if _, err := store.Update(ctx, "wf", "greeting", []byte(`"x"`), 0, 0); !errors.Is(err, ErrConflict) {
+		t.Errorf("create existing key: err = %v, want ErrConflict", err)
+	}
+	if _, err := store.Update(ctx, "wf", "greeting", []byte(`"bye"`), e.Revision, 0); err != nil {
+		t.Errorf("update with current revision: %v", err)
+	}
+
+	entries, err := store.List(ctx, "wf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Key != "greeting" || string(entries[0].Value) != `"bye"` {
+		t.Errorf("list = %+v", entries)
+	}
+
+	if err := store.Delete(ctx, "wf", "greeting"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "wf", "greeting"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Update(ctx, "wf", "greeting", []byte(`"again"`), 0, 0); err != nil {
+		t.Errorf("create after delete: %v", err)
+	}
+	if e, _ := store.Get(ctx, "skill:other", "greeting"); string(e.Value) != `"hi"` {
+		t.Errorf("other namespace value = %s", e.Value)
+	}
+}
+
+func TestStoreIncrConcurrent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				if _, err := store.Incr(ctx, "wf", "count", 1, 0); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := store.Incr(ctx, "wf", "count", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 50 {
+		t.Errorf("count = %d, want 50", n)
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := store.Put(ctx, "wf", "short", []byte(`true`), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "wf", "short"); err != nil {
+		t.Fatalf("get before expiry: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := store.Get(ctx, "wf", "short")
+		if errors.Is(err, ErrNotFound) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("key did not expire: err = %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, s := range []string{"", "plain", "skill:triage", "a.b/c d", "=", "ünï"} {
+		esc := escape(s)
+		if !jetstreamKeyToken(esc) {
+			t.Errorf("escape(%q) = %q is not a valid key token", s, esc)
+		}
+		got, err := unescape(esc)
+		if err != nil || got != s {
+			t.Errorf("unescape(escape(%q)) = %q, %v", s, got, err)
+		}
+	}
+}
+
+func jetstreamKeyToken(s string) bool {
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '=') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,45 @@
+package timers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WorkflowAdapter wraps a Scheduler to implement the workflow.TimerStore interface.
+type WorkflowAdapter struct {
+	s *Scheduler
+}
+
+// NewWorkflowAdapter creates an adapter for the workflow engine.
+func NewWorkflowAdapter(s *Scheduler) *WorkflowAdapter {
+	return &WorkflowAdapter{s: s}
+}
+
+// Schedule persists a delayed event and returns the timer ID.
+func (a *WorkflowAdapter) Schedule(ctx context.Context, workflow string, fireAt time.Time, subject, eventType string, payload map[string]any) (string, error) {
+	t, err := a.s.Schedule(ctx, workflow, fireAt, subject, eventType, payload)
+	if err != nil {
+		return "", err
+	}
+	return t.ID, nil
+}
+
+// Cancel removes a pending timer if it belongs to workflow.
+func (a *WorkflowAdapter) Cancel(ctx context.Context, workflow, id string) (bool, error) {
+	t, err := a.s.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if t.Workflow != workflow {
+		return false, nil
+	}
+	err = a.s.Cancel(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
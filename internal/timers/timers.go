@@ -0,0 +1,239 @@
+// Package timers schedules one-shot delayed events (sekia.after and
+// sekia.at) and publishes them when due. Pending timers live in the
+// SEKIA_TIMERS JetStream KeyValue bucket, so they survive daemon restarts.
+package timers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// ErrNotFound is returned when a timer does not exist (or already fired).
+var ErrNotFound = errors.New("timer not found")
+
+const (
+	// opTimeout bounds a single bucket operation made while firing a timer.
+	opTimeout = 10 * time.Second
+	// retryDelay is how long a timer whose event failed to publish waits
+	// before the next attempt.
+	retryDelay = 30 * time.Second
+)
+
+// Scheduler persists timers and publishes each one's event when it is due.
+// Timers already overdue when the scheduler starts fire right away.
+type Scheduler struct {
+	nc     *nats.Conn
+	kv     jetstream.KeyValue
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	stopped bool
+}
+
+// New creates a Scheduler backed by the given bucket. Call Start to arm
+// the timers persisted by a previous run.
+func New(nc *nats.Conn, kv jetstream.KeyValue, logger zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		nc:      nc,
+		kv:      kv,
+		logger:  logger.With().Str("component", "timers").Logger(),
+		pending: make(map[string]*time.Timer),
+	}
+}
+
+// Start arms every persisted timer.
+func (s *Scheduler) Start(ctx context.Context) error {
+	timers, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range timers {
+		s.arm(t.ID, time.Until(t.FireAt))
+	}
+	s.logger.Info().Int("pending", len(timers)).Msg("timers started")
+	return nil
+}
+
+// Stop disarms all timers. Persisted timers fire on the next Start.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for id, t := range s.pending {
+		t.Stop()
+		delete(s.pending, id)
+	}
+}
+
+// Schedule persists a timer publishing eventType with payload on subject at
+// fireAt, and returns it with its ID assigned.
+func (s *Scheduler) Schedule(ctx context.Context, workflow string, fireAt time.Time, subject, eventType string, payload map[string]any) (protocol.Timer, error) {
+	t := protocol.Timer{
+		ID:        "tmr_" + uuid.NewString(),
+		Workflow:  workflow,
+		Subject:   subject,
+		EventType: eventType,
+		Payload:   payload,
+		FireAt:    fireAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return protocol.Timer{}, fmt.Errorf("encode timer: %w", err)
+	}
+	if _, err := s.kv.Create(ctx, t.ID, data); err != nil {
+		return protocol.Timer{}, fmt.Errorf("store timer: %w", err)
+	}
+	s.arm(t.ID, time.Until(t.FireAt))
+
+	s.logger.Debug().
+		Str("id", t.ID).
+		Str("workflow", workflow).
+		Str("subject", subject).
+		Time("fire_at", t.FireAt).
+		Msg("timer scheduled")
+	return t, nil
+}
+
+// Get returns the pending timer with the given ID.
+func (s *Scheduler) Get(ctx context.Context, id string) (protocol.Timer, error) {
+	t, _, err := s.get(ctx, id)
+	return t, err
+}
+
+// List returns every pending timer, soonest first.
+func (s *Scheduler) List(ctx context.Context) ([]protocol.Timer, error) {
+	lister, err := s.kv.ListKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list timers: %w", err)
+	}
+	defer lister.Stop()
+
+	timers := []protocol.Timer{}
+	for id := range lister.Keys() {
+		t, _, err := s.get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue // fired or cancelled since listing
+		}
+		if err != nil {
+			return nil, err
+		}
+		timers = append(timers, t)
+	}
+	sort.Slice(timers, func(i, j int) bool { return timers[i].FireAt.Before(timers[j].FireAt) })
+	return timers, nil
+}
+
+// Cancel removes a pending timer so it never fires.
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	if _, _, err := s.get(ctx, id); err != nil {
+		return err
+	}
+	s.disarm(id)
+	if err := s.kv.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete timer %s: %w", id, err)
+	}
+	s.logger.Debug().Str("id", id).Msg("timer cancelled")
+	return nil
+}
+
+func (s *Scheduler) get(ctx context.Context, id string) (protocol.Timer, uint64, error) {
+	e, err := s.kv.Get(ctx, id)
+	if errors.Is(err, jetstream.ErrKeyNotFound) || errors.Is(err, jetstream.ErrInvalidKey) {
+		return protocol.Timer{}, 0, ErrNotFound
+	}
+	if err != nil {
+		return protocol.Timer{}, 0, fmt.Errorf("get timer %s: %w", id, err)
+	}
+	var t protocol.Timer
+	if err := json.Unmarshal(e.Value(), &t); err != nil {
+		return protocol.Timer{}, 0, fmt.Errorf("decode timer %s: %w", id, err)
+	}
+	return t, e.Revision(), nil
+}
+
+// arm fires the timer id after d.
+func (s *Scheduler) arm(id string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	if old, ok := s.pending[id]; ok {
+		old.Stop()
+	}
+	s.pending[id] = time.AfterFunc(d, func() { s.fire(id) })
+}
+
+func (s *Scheduler) disarm(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.pending[id]; ok {
+		t.Stop()
+		delete(s.pending, id)
+	}
+}
+
+// fire publishes a due timer's event and removes the timer. The timer is
+// re-read first so a concurrent Cancel wins. It is deleted only after the
+// publish succeeds: a crash in between fires it again on the next start
+// rather than losing it.
+func (s *Scheduler) fire(id string) {
+	s.mu.Lock()
+	delete(s.pending, id)
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+
+	t, rev, err := s.get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("id", id).Msg("load due timer")
+		s.arm(id, retryDelay)
+		return
+	}
+
+	// Timer events have their own source, so the self-event guard does not
+	// hide them from the workflow that scheduled them.
+	ev := protocol.NewEvent(t.EventType, "timer:"+t.Workflow, t.Payload)
+	data, err := json.Marshal(ev)
+	if err != nil {
+		s.logger.Error().Err(err).Str("id", id).Msg("marshal timer event")
+		return
+	}
+	if err := s.nc.Publish(t.Subject, data); err != nil {
+		s.logger.Error().Err(err).Str("id", id).Msg("publish timer event")
+		s.arm(id, retryDelay)
+		return
+	}
+	if err := s.kv.Delete(ctx, id, jetstream.LastRevision(rev)); err != nil {
+		s.logger.Warn().Err(err).Str("id", id).Msg("delete fired timer")
+	}
+
+	s.logger.Info().
+		Str("id", id).
+		Str("workflow", t.Workflow).
+		Str("subject", t.Subject).
+		Str("event_id", ev.ID).
+		Msg("timer fired")
+}
@@ -0,0 +1,238 @@
+package timers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/internal/workflow"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *nats.Conn, jetstream.KeyValue) {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		NoLog:      true,
+		NoSigs:     true,
+		DontListen: true,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	nc, err := nats.Connect("", nats.InProcessServer(ns))
+	if err != nil {
+		ns.Shutdown()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+	})
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	kv, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:  protocol.BucketTimers,
+		Storage: jetstream.MemoryStorage,
+	})
+	if err != nil {
+		t.Fatalf("create bucket: %v", err)
+	}
+	s := New(nc, kv, zerolog.Nop())
+	t.Cleanup(s.Stop)
+	return s, nc, kv
+}
+
+func TestScheduler_FireAndCancel(t *testing.T) {
+	s, nc, _ := newTestScheduler(t)
+	ctx := context.Background()
+
+	sub, err := nc.SubscribeSync("sekia.events.reminders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	soon, err := s.Schedule(ctx, "wf", time.Now().Add(100*time.Millisecond), "sekia.events.reminders", "pr.nudge", map[string]any{"pr": float64(42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, err := s.Schedule(ctx, "wf", time.Now().Add(200*time.Millisecond), "sekia.events.reminders", "pr.nudge", map[string]any{"pr": float64(43)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != soon.ID || list[1].ID != later.ID {
+		t.Fatalf("List = %+v, want [%s %s]", list, soon.ID, later.ID)
+	}
+
+	if err := s.Cancel(ctx, later.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Cancel(ctx, later.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second cancel: err = %v, want ErrNotFound", err)
+	}
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("timer event not published: %v", err)
+	}
+	var ev protocol.Event
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "pr.nudge" || ev.Source != "timer:wf" || ev.Payload["pr"] != float64(42) {
+		t.Errorf("event = %+v", ev)
+	}
+
+	// The cancelled timer never fires.
+	if msg, err := sub.NextMsg(500 * time.Millisecond); err == nil {
+		t.Errorf("cancelled timer fired: %s", msg.Data)
+	}
+
+	// Fired timers are removed.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		list, err := s.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("fired timer still pending: %+v", list)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestScheduler_SurvivesRestart(t *testing.T) {
+	s, nc, kv := newTestScheduler(t)
+	ctx := context.Background()
+
+	sub, err := nc.SubscribeSync("sekia.events.followup")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scheduled before a "crash": the scheduler stops before it is due.
+	tm, err := s.Schedule(ctx, "wf", time.Now().Add(200*time.Millisecond), "sekia.events.followup", "email.followup", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	time.Sleep(400 * time.Millisecond)
+	if _, err := sub.NextMsg(50 * time.Millisecond); err == nil {
+		t.Fatal("stopped scheduler fired a timer")
+	}
+
+	// A new scheduler on the same bucket fires the overdue timer once.
+	restarted := New(nc, kv, zerolog.Nop())
+	t.Cleanup(restarted.Stop)
+	if err := restarted.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.NextMsg(5 * time.Second); err != nil {
+		t.Fatalf("overdue timer not fired after restart: %v", err)
+	}
+	if msg, err := sub.NextMsg(300 * time.Millisecond); err == nil {
+		t.Errorf("timer fired twice: %s", msg.Data)
+	}
+	if _, err := restarted.Get(ctx, tm.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after fire: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWorkflowAdapter_CancelOwnTimersOnly(t *testing.T) {
+	s, _, _ := newTestScheduler(t)
+	a := NewWorkflowAdapter(s)
+	ctx := context.Background()
+
+	id, err := a.Schedule(ctx, "owner", time.Now().Add(time.Hour), "sekia.events.x", "x", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := a.Cancel(ctx, "intruder", id); ok || err != nil {
+		t.Errorf("cancel by another workflow = %v, %v; want false, nil", ok, err)
+	}
+	if ok, err := a.Cancel(ctx, "owner", id); !ok || err != nil {
+		t.Errorf("cancel by owner = %v, %v; want true, nil", ok, err)
+	}
+	if ok, err := a.Cancel(ctx, "owner", id); ok || err != nil {
+		t.Errorf("cancel twice = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestScheduler_WorkflowHandlesOwnTimer(t *testing.T) {
+	s, nc, _ := newTestScheduler(t)
+
+	dir := t.TempDir()
+	src := `
+sekia.on("sekia.events.reminders", function(event)
+	if event.type == "pr.opened" then
+		sekia.after(0.1, "sekia.events.reminders", "pr.nudge", { pr = event.payload.pr })
+	elseif event.type == "pr.nudge" then
+		sekia.publish("sekia.events.nudges", "pr.nudged", { pr = event.payload.pr })
+	end
+end)
+`
+	wfPath := filepath.Join(dir, "nudger.lua")
+	if err := os.WriteFile(wfPath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eng := workflow.New(nc, dir, nil, 0, "", zerolog.Nop())
+	eng.SetTimerStore(NewWorkflowAdapter(s))
+	if err := eng.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Stop()
+	if err := eng.LoadWorkflow("nudger", wfPath); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := nc.SubscribeSync("sekia.events.nudges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(protocol.NewEvent("pr.opened", "github", map[string]any{"pr": 42}))
+	if err := nc.Publish("sekia.events.reminders", data); err != nil {
+		t.Fatal(err)
+	}
+
+	// The workflow that scheduled the timer handles its event.
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("workflow did not handle its own timer: %v", err)
+	}
+	var ev protocol.Event
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "pr.nudged" || ev.Payload["pr"] != float64(42) {
+		t.Errorf("event = %+v", ev)
+	}
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/sekia-ai/sekia/pkg/protocol"
@@ -13,6 +14,7 @@ type DashboardData struct {
 	Status    StatusData
 	Agents    []protocol.AgentInfo
 	Workflows []protocol.WorkflowInfo
+	Shadow    []ShadowIntentData
 	Events    []EventData
 }
 
@@ -32,11 +34,24 @@ type EventData struct {
 	Payload string
 }
 
+// ShadowIntentData holds one intent recorded by a shadow-mode workflow.
+type ShadowIntentData struct {
+	Time     string
+	Workflow string
+	Kind     string
+	Target   string
+	Payload  string
+}
+
+// shadowRows is the number of recent shadow intents shown on the dashboard.
+const shadowRows = 25
+
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	data := DashboardData{
 		Status:    s.buildStatus(),
 		Agents:    s.registry.Agents(),
 		Workflows: s.buildWorkflows(),
+		Shadow:    s.buildShadowIntents(),
 		Events:    s.buildRecentEvents(),
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -58,6 +73,11 @@ func (s *Server) handlePartialWorkflows(w http.ResponseWriter, r *http.Request)
 	s.templates.ExecuteTemplate(w, "workflows", s.buildWorkflows())
 }
 
+func (s *Server) handlePartialShadow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.templates.ExecuteTemplate(w, "shadow", s.buildShadowIntents())
+}
+
 func (s *Server) buildStatus() StatusData {
 	wfCount := 0
 	if s.engine != nil {
@@ -79,18 +99,81 @@ func (s *Server) buildWorkflows() []protocol.WorkflowInfo {
 	var workflows []protocol.WorkflowInfo
 	for _, wf := range s.engine.Workflows() {
 		workflows = append(workflows, protocol.WorkflowInfo{
-			Name:     wf.Name,
-			FilePath: wf.FilePath,
-			Handlers: wf.Handlers,
-			Patterns: wf.Patterns,
-			LoadedAt: wf.LoadedAt,
-			Events:   wf.Events,
-			Errors:   wf.Errors,
+			Name:      wf.Name,
+			FilePath:  wf.FilePath,
+			Handlers:  wf.Handlers,
+			Patterns:  wf.Patterns,
+			Filters:   wf.Filters,
+			LoadedAt:  wf.LoadedAt,
+			Events:    wf.Events,
+			Errors:    wf.Errors,
+			Shadow:    wf.Shadow,
+			Schedules: wf.Schedules,
+			LoadMs:    float64(wf.LoadTime) / float64(time.Millisecond),
+			LoadError: wf.LoadError,
+
+			Concurrency:   wf.Concurrency,
+			OrderKey:      wf.OrderKey,
+			Busy:          wf.Busy,
+			Queued:        wf.Queued,
+			QueueCapacity: wf.QueueCapacity,
+			Dropped:       wf.Dropped,
+
+			Denied: wf.Denied,
 		})
 	}
 	return workflows
 }
 
+// buildShadowIntents merges the most recent intents of all shadow-mode
+// workflows, newest first.
+func (s *Server) buildShadowIntents() []ShadowIntentData {
+	if s.engine == nil {
+		return nil
+	}
+	type entry struct {
+		workflow string
+		intent   protocol.Intent
+	}
+	var all []entry
+	for _, wf := range s.engine.Workflows() {
+		if !wf.Shadow {
+			continue
+		}
+		info, err := s.engine.ShadowIntents(wf.Name, shadowRows)
+		if err != nil {
+			continue
+		}
+		for _, in := range info.Intents {
+			all = append(all, entry{workflow: wf.Name, intent: in})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].intent.At.After(all[j].intent.At) })
+	if len(all) > shadowRows {
+		all = all[:shadowRows]
+	}
+
+	rows := make([]ShadowIntentData, 0, len(all))
+	for _, e := range all {
+		target := e.intent.Subject
+		switch e.intent.Kind {
+		case protocol.IntentCommand:
+			target = e.intent.Agent + " " + e.intent.Command
+		case protocol.IntentHTTP:
+			target = e.intent.Method + " " + e.intent.Subject
+		}
+		payload, _ := json.Marshal(e.intent.Payload)
+		rows = append(rows, ShadowIntentData{
+			Time:     e.intent.At.Local().Format("2006-01-02 15:04:05"),
+			Workflow: e.workflow,
+			Kind:     e.intent.Kind,
+			Target:   target,
+			Payload:  string(payload),
+		})
+	}
+	return rows
+}
+
 func (s *Server) buildRecentEvents() []EventData {
 	raw := s.eventBus.Recent()
 	events := make([]EventData, 0, len(raw))
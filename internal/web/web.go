@@ -80,6 +80,7 @@ func New(cfg Config, reg *registry.Registry, engine *workflow.Engine,
 	mux.HandleFunc("GET /web/partials/status", s.handlePartialStatus)
 	mux.HandleFunc("GET /web/partials/agents", s.handlePartialAgents)
 	mux.HandleFunc("GET /web/partials/workflows", s.handlePartialWorkflows)
+	mux.HandleFunc("GET /web/partials/shadow", s.handlePartialShadow)
 	mux.HandleFunc("GET /web/events/stream", s.handleEventStream)
 
 	s.httpServer = &http.Server{
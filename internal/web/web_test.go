@@ -165,6 +165,28 @@ func TestPartialWorkflows(t *testing.T) {
 	}
 }
 
+func TestPartialShadow(t *testing.T) {
+	srv, _ := setupTest(t)
+
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/web/partials/shadow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "No commands or events recorded") {
+		t.Error("expected shadow empty state")
+	}
+}
+
 func TestStaticAssets(t *testing.T) {
 	srv, _ := setupTest(t)
 
@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+	"github.com/sekia-ai/sekia/pkg/agent"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+const (
+	defaultAgentName = "webhook-agent"
+	agentVersion     = "0.1.0"
+)
+
+// WebhookAgent receives webhooks from arbitrary services and publishes
+// them to the sekia event bus according to its configured routes.
+type WebhookAgent struct {
+	cfg          Config
+	cfgFile      string
+	instanceName string // agent name for NATS registration
+	server       *Server
+	agent        *agent.Agent
+	logger       zerolog.Logger
+	stopCh       chan struct{}
+	readyCh      chan struct{}
+
+	// Overridable for testing.
+	natsOpts []nats.Option
+}
+
+// NewAgent creates a WebhookAgent. Call Run() to start.
+// cfgFile is the config file given on the command line, if any; it is
+// re-read on config reload. instanceName overrides the default agent name
+// for NATS registration. Pass "" to use the default ("webhook-agent").
+func NewAgent(cfg Config, cfgFile, instanceName string, logger zerolog.Logger) *WebhookAgent {
+	if instanceName == "" {
+		instanceName = defaultAgentName
+	}
+	return &WebhookAgent{
+		cfg:          cfg,
+		cfgFile:      cfgFile,
+		instanceName: instanceName,
+		logger:       logger.With().Str("component", instanceName).Logger(),
+		stopCh:       make(chan struct{}),
+		readyCh:      make(chan struct{}),
+	}
+}
+
+// Run starts the agent: connects to NATS, starts the webhook server, and
+// blocks until signal or Stop().
+func (wa *WebhookAgent) Run() error {
+	// 1. Compile routes before connecting, so a bad config fails fast.
+	wa.server = NewServer(wa.cfg.Server.Listen, wa.publishEvent, wa.logger)
+	if err := wa.server.SetRoutes(wa.cfg.Routes); err != nil {
+		return fmt.Errorf("load routes: %w", err)
+	}
+
+	// 2. Connect to NATS via the agent SDK.
+	natsOpts, err := secrets.NATSOptions(wa.cfg.NATS.NATSClientConfig)
+	if err != nil {
+		return err
+	}
+	natsOpts = append(wa.natsOpts, natsOpts...)
+	agentCfg := agent.Config{
+		NATSUrl:         wa.cfg.NATS.URL,
+		NATSOpts:        natsOpts,
+		EventValidation: wa.cfg.Events.Validation,
+	}
+	a, err := agent.New(
+		agentCfg, wa.instanceName, agentVersion,
+		[]string{"webhooks"},
+		[]string{},
+		wa.logger,
+	)
+	if err != nil {
+		wa.server.Shutdown(context.Background())
+		return fmt.Errorf("create agent: %w", err)
+	}
+	wa.agent = a
+
+	// Register config reload handler.
+	if err := a.OnConfigReload(wa.reloadConfig); err != nil {
+		wa.logger.Warn().Err(err).Msg("failed to register config reload handler")
+	}
+
+	// 3. Start the webhook server.
+	if err := wa.server.Listen(); err != nil {
+		wa.shutdown()
+		return fmt.Errorf("listen webhook: %w", err)
+	}
+	serveErrCh := make(chan error, 1)
+	go func() {
+		if err := wa.server.Serve(); err != nil && err != http.ErrServerClosed {
+			serveErrCh <- err
+		}
+	}()
+
+	wa.logger.Info().
+		Str("nats", wa.cfg.NATS.URL).
+		Str("listen", wa.server.Addr()).
+		Int("routes", len(wa.cfg.Routes)).
+		Msg("webhook agent started")
+
+	close(wa.readyCh)
+
+	// 4. Block on signal or stop.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case sig := <-sigCh:
+		wa.logger.Info().Str("signal", sig.String()).Msg("shutting down")
+	case <-wa.stopCh:
+		wa.logger.Info().Msg("stop requested, shutting down")
+	case err := <-serveErrCh:
+		wa.logger.Error().Err(err).Msg("webhook server error")
+		wa.shutdown()
+		return err
+	}
+
+	return wa.shutdown()
+}
+
+// Stop signals the agent to shut down. Safe to call from another goroutine.
+func (wa *WebhookAgent) Stop() {
+	close(wa.stopCh)
+}
+
+// Ready returns a channel that is closed when the agent has finished starting.
+func (wa *WebhookAgent) Ready() <-chan struct{} {
+	return wa.readyCh
+}
+
+// Addr returns the webhook server's listen address, or "" if not yet started.
+func (wa *WebhookAgent) Addr() string {
+	if wa.server == nil {
+		return ""
+	}
+	return wa.server.Addr()
+}
+
+// NewTestAgent creates a WebhookAgent configured for testing with the given
+// routes and in-process NATS connection options.
+func NewTestAgent(natsURL string, natsOpts []nats.Option, listen string, routes []RouteConfig, logger zerolog.Logger) *WebhookAgent {
+	return &WebhookAgent{
+		cfg: Config{
+			NATS:   NATSConfig{URL: natsURL},
+			Server: ServerConfig{Listen: listen},
+			Routes: routes,
+		},
+		instanceName: defaultAgentName,
+		natsOpts:     natsOpts,
+		logger:       logger.With().Str("component", defaultAgentName).Logger(),
+		stopCh:       make(chan struct{}),
+		readyCh:      make(chan struct{}),
+	}
+}
+
+func (wa *WebhookAgent) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if wa.server != nil {
+		wa.server.Shutdown(ctx)
+	}
+	if wa.agent != nil {
+		wa.agent.Close()
+	}
+	return nil
+}
+
+// reloadConfig re-reads the config file and swaps in the new routes.
+// server.listen and the NATS and events settings require a restart.
+func (wa *WebhookAgent) reloadConfig() {
+	wa.logger.Info().Msg("reloading webhook agent configuration")
+
+	newCfg, err := LoadConfig(wa.cfgFile, wa.configName())
+	if err != nil {
+		wa.logger.Error().Err(err).Msg("failed to reload config")
+		return
+	}
+	if err := wa.server.SetRoutes(newCfg.Routes); err != nil {
+		wa.logger.Error().Err(err).Msg("failed to reload routes; keeping the previous ones")
+		return
+	}
+	wa.cfg.Routes = newCfg.Routes
+
+	wa.logger.Info().Int("routes", len(newCfg.Routes)).Msg("webhook agent configuration reloaded")
+}
+
+// configName returns the instance name the config file was looked up by.
+func (wa *WebhookAgent) configName() string {
+	if wa.instanceName == defaultAgentName {
+		return ""
+	}
+	return wa.instanceName
+}
+
+func (wa *WebhookAgent) publishEvent(ev protocol.Event) {
+	subject := protocol.SubjectEventsFor(wa.cfg.Events.Subjects, protocol.SourceToken(ev.Source), ev.Type)
+	if err := wa.agent.PublishEvent(subject, ev); err != nil {
+		wa.logger.Error().Err(err).Str("type", ev.Type).Msg("publish event")
+	}
+}
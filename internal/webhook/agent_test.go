@@ -0,0 +1,149 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/internal/server"
+	webhookagent "github.com/sekia-ai/sekia/internal/webhook"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// TestWebhookAgentEndToEnd tests the full flow:
+//
+//	HTTP delivery → route mapping → event on NATS
+func TestWebhookAgentEndToEnd(t *testing.T) {
+	d := newTestDaemon(t)
+
+	nc, err := nats.Connect(d.NATSClientURL(), d.NATSConnectOpts()...)
+	if err != nil {
+		t.Fatalf("connect nats: %v", err)
+	}
+	defer nc.Drain()
+	sub, err := nc.SubscribeSync("sekia.events.jenkins")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wa := newTestWebhookAgent(t, d, []webhookagent.RouteConfig{{
+		Path:   "/jenkins",
+		Type:   "jenkins.build.finished",
+		Source: "jenkins",
+		Verify: webhookagent.VerifyConfig{Scheme: webhookagent.SchemeBearer, Secret: "tok"},
+		Fields: map[string]string{"job": "$.name", "status": "$.build.status"},
+	}})
+
+	req, _ := http.NewRequest("POST", "http://"+wa.Addr()+"/jenkins",
+		strings.NewReader(`{"name":"app","build":{"status":"FAILURE"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer tok")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("no event received: %v", err)
+	}
+	var ev protocol.Event
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "jenkins.build.finished" || ev.Source != "jenkins" {
+		t.Errorf("type %q source %q", ev.Type, ev.Source)
+	}
+	if ev.Payload["job"] != "app" || ev.Payload["status"] != "FAILURE" {
+		t.Errorf("payload = %v", ev.Payload)
+	}
+}
+
+// --- Test helpers ---
+
+func newTestDaemon(t *testing.T) *server.Daemon {
+	t.Helper()
+	tmpDir := t.TempDir()
+	socketDir, err := os.MkdirTemp("/tmp", "sekia-test-*")
+	if err != nil {
+		t.Fatalf("create socket dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+
+	cfg := server.Config{
+		Server: server.ServerConfig{Socket: filepath.Join(socketDir, "s.sock")},
+		NATS:   server.NATSConfig{Embedded: true, DataDir: filepath.Join(tmpDir, "nats")},
+	}
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+		With().Timestamp().Logger()
+
+	d := server.NewDaemon(cfg, logger)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- d.Run() }()
+
+	select {
+	case <-d.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon did not start in time")
+	}
+
+	t.Cleanup(func() {
+		d.Stop()
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Errorf("daemon error on shutdown: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("daemon did not shut down in time")
+		}
+	})
+
+	return d
+}
+
+func newTestWebhookAgent(t *testing.T, d *server.Daemon, routes []webhookagent.RouteConfig) *webhookagent.WebhookAgent {
+	t.Helper()
+
+	wa := webhookagent.NewTestAgent(
+		d.NATSClientURL(),
+		d.NATSConnectOpts(),
+		"127.0.0.1:0",
+		routes,
+		zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger(),
+	)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- wa.Run() }()
+
+	select {
+	case <-wa.Ready():
+	case err := <-errCh:
+		t.Fatalf("webhook agent failed to start: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook agent did not start in time")
+	}
+
+	t.Cleanup(func() {
+		wa.Stop()
+		select {
+		case <-errCh:
+		case <-time.After(5 * time.Second):
+			t.Error("webhook agent did not shut down in time")
+		}
+	})
+
+	return wa
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/sekia-ai/sekia/internal/secrets"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// Config holds all configuration for the webhook agent.
+type Config struct {
+	NATS   NATSConfig    `mapstructure:"nats"`
+	Server ServerConfig  `mapstructure:"server"`
+	Events EventsConfig  `mapstructure:"events"`
+	Routes []RouteConfig `mapstructure:"routes"`
+}
+
+// NATSConfig holds NATS connection settings.
+type NATSConfig struct {
+	URL string `mapstructure:"url"`
+
+	secrets.NATSClientConfig `mapstructure:",squash"`
+}
+
+// ServerConfig holds the HTTP listener settings.
+type ServerConfig struct {
+	Listen string `mapstructure:"listen"`
+}
+
+// EventsConfig holds event publishing settings.
+type EventsConfig struct {
+	Subjects   string `mapstructure:"subjects"`   // flat, hierarchical or compat (protocol.Subjects*)
+	Validation string `mapstructure:"validation"` // off, warn or reject (protocol.Validation*)
+}
+
+// RouteConfig is one [[routes]] entry: an HTTP path, how deliveries to it
+// are verified, and how they become a sekia event.
+type RouteConfig struct {
+	Path     string            `mapstructure:"path"`
+	Type     string            `mapstructure:"type"`      // event type, or its prefix when type_from is set
+	TypeFrom string            `mapstructure:"type_from"` // JSONPath or header:<Name> appended to type
+	Source   string            `mapstructure:"source"`    // event source and subject token (default "webhook")
+	Verify   VerifyConfig      `mapstructure:"verify"`
+	Fields   map[string]string `mapstructure:"fields"` // payload field -> JSONPath or header:<Name>
+	Script   string            `mapstructure:"script"` // Lua file defining map(req)
+}
+
+// VerifyConfig selects how a route authenticates deliveries.
+type VerifyConfig struct {
+	Scheme    string `mapstructure:"scheme"`     // hmac, bearer, basic or none
+	Secret    string `mapstructure:"secret"`     // #nosec G117 -- HMAC key, bearer token or basic password
+	SecretEnv string `mapstructure:"secret_env"` // environment variable holding the secret
+	Username  string `mapstructure:"username"`   // basic
+	Header    string `mapstructure:"header"`     // hmac signature header (default X-Signature)
+	Algorithm string `mapstructure:"algorithm"`  // hmac: sha256 (default), sha1 or sha512
+	Prefix    string `mapstructure:"prefix"`     // hmac: stripped from the header value, e.g. "sha256="
+	Encoding  string `mapstructure:"encoding"`   // hmac: hex (default) or base64
+}
+
+// LoadConfig reads the webhook agent configuration from file, env vars, and defaults.
+// When instanceName is non-empty and cfgFile is empty, the config file name
+// becomes "sekia-webhook-<instanceName>" (e.g., sekia-webhook-ops.toml).
+// Relative script paths are resolved against the config file's directory.
+func LoadConfig(cfgFile, instanceName string) (Config, error) {
+	v := viper.New()
+
+	v.SetDefault("nats.url", "nats://127.0.0.1:4222")
+	v.SetDefault("server.listen", ":8090")
+	v.SetDefault("events.subjects", protocol.SubjectsFlat)
+	v.SetDefault("events.validation", protocol.ValidationWarn)
+
+	v.SetConfigType("toml")
+
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+	} else {
+		configName := "sekia-webhook"
+		if instanceName != "" {
+			configName = "sekia-webhook-" + instanceName
+		}
+		v.SetConfigName(configName)
+		v.AddConfigPath("/etc/sekia")
+		v.AddConfigPath("$HOME/.config/sekia")
+		v.AddConfigPath(".")
+	}
+
+	v.BindEnv("server.listen", "WEBHOOK_LISTEN")
+	v.BindEnv("nats.url", "SEKIA_NATS_URL")
+	v.BindEnv("nats.token", "SEKIA_NATS_TOKEN")
+	v.BindEnv("nats.credentials", "SEKIA_NATS_CREDENTIALS")
+	v.BindEnv("nats.tls_cert", "SEKIA_NATS_TLS_CERT")
+	v.BindEnv("nats.tls_key", "SEKIA_NATS_TLS_KEY")
+	v.BindEnv("nats.tls_ca", "SEKIA_NATS_TLS_CA")
+	v.BindEnv("events.subjects", "SEKIA_EVENT_SUBJECTS")
+	v.BindEnv("events.validation", "SEKIA_EVENT_VALIDATION")
+
+	_ = v.ReadInConfig() // config file is optional
+
+	// Resolve any encrypted or referenced values (ENC[...], KMS[...], ASM[...]).
+	if err := secrets.ResolveViperConfig(v); err != nil {
+		return Config{}, fmt.Errorf("resolve config secrets: %w", err)
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := protocol.ValidateSubjectScheme(cfg.Events.Subjects); err != nil {
+		return cfg, err
+	}
+	if err := protocol.ValidateValidationMode(cfg.Events.Validation); err != nil {
+		return cfg, err
+	}
+
+	if len(cfg.Routes) == 0 {
+		return cfg, fmt.Errorf("at least one [[routes]] entry is required")
+	}
+	dir := ""
+	if used := v.ConfigFileUsed(); used != "" {
+		dir = filepath.Dir(used)
+	}
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if r.Script != "" && dir != "" && !filepath.IsAbs(r.Script) {
+			r.Script = filepath.Join(dir, r.Script)
+		}
+		if r.Verify.SecretEnv != "" && r.Verify.Secret == "" {
+			r.Verify.Secret = os.Getenv(r.Verify.SecretEnv)
+		}
+	}
+
+	return cfg, nil
+}
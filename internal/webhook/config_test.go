@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "sekia-webhook.toml")
+	os.WriteFile(cfgFile, []byte(`
+[server]
+listen = ":9999"
+
+[nats]
+credentials = "webhook-agent.nk"
+tls_ca = "ca.pem"
+
+[[routes]]
+path = "/sentry"
+script = "scripts/sentry.lua"
+verify = { scheme = "hmac", secret_env = "TEST_SENTRY_SECRET", header = "Sentry-Hook-Signature" }
+
+[[routes]]
+path = "/jenkins"
+type = "jenkins.build"
+verify = { scheme = "basic", username = "jenkins", secret = "pw" }
+fields = { job = "$.name", status = "$.build.status" }
+`), 0644)
+	t.Setenv("TEST_SENTRY_SECRET", "from-env")
+
+	cfg, err := LoadConfig(cfgFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Listen != ":9999" || cfg.NATS.URL != "nats://127.0.0.1:4222" {
+		t.Errorf("listen %q, nats %q", cfg.Server.Listen, cfg.NATS.URL)
+	}
+	if cfg.NATS.Credentials != "webhook-agent.nk" || cfg.NATS.TLSCA != "ca.pem" {
+		t.Errorf("nats = %+v", cfg.NATS)
+	}
+	if len(cfg.Routes) != 2 {
+		t.Fatalf("got %d routes, want 2", len(cfg.Routes))
+	}
+	sentry, jenkins := cfg.Routes[0], cfg.Routes[1]
+	if sentry.Script != filepath.Join(dir, "scripts", "sentry.lua") {
+		t.Errorf("script = %q, want it resolved against the config directory", sentry.Script)
+	}
+	if sentry.Verify.Secret != "from-env" || sentry.Verify.Header != "Sentry-Hook-Signature" {
+		t.Errorf("sentry verify = %+v", sentry.Verify)
+	}
+	if jenkins.Fields["status"] != "$.build.status" || jenkins.Verify.Username != "jenkins" {
+		t.Errorf("jenkins route = %+v", jenkins)
+	}
+}
+
+func TestLoadConfig_NoRoutes(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "sekia-webhook.toml")
+	os.WriteFile(cfgFile, []byte("[server]\nlisten = \":9999\"\n"), 0644)
+	if _, err := LoadConfig(cfgFile, ""); err == nil {
+		t.Error("expected error for a config without routes")
+	}
+}
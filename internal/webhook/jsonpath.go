@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled JSONPath expression. The supported subset covers
+// what webhook mappings need: $, .name, ['name'], [index] (negative counts
+// from the end) and the wildcards .* and [*]. A path with a wildcard yields
+// a list of every match, object members in key order.
+type jsonPath struct {
+	expr  string
+	segs  []pathSegment
+	multi bool
+}
+
+type pathSegment struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// compileJSONPath parses a JSONPath expression.
+func compileJSONPath(expr string) (*jsonPath, error) {
+	rest, ok := strings.CutPrefix(expr, "$")
+	if !ok {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expr)
+	}
+	p := &jsonPath{expr: expr}
+	for rest != "" {
+		var seg pathSegment
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q: unclosed [", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				seg.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				seg.name = inner[1 : len(inner)-1]
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath %q: invalid subscript [%s]", expr, inner)
+				}
+				seg.index, seg.isIndex = n, true
+			}
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q: empty field name", expr)
+			}
+			if name == "*" {
+				seg.wildcard = true
+			} else {
+				seg.name = name
+			}
+		default:
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", expr, rest)
+		}
+		p.multi = p.multi || seg.wildcard
+		p.segs = append(p.segs, seg)
+	}
+	return p, nil
+}
+
+// eval applies the path to a decoded JSON document. For a path without
+// wildcards it returns the single match, if any; otherwise it returns the
+// list of matches, which may be empty.
+func (p *jsonPath) eval(doc any) (any, bool) {
+	vals := []any{doc}
+	for _, seg := range p.segs {
+		var next []any
+		for _, v := range vals {
+			next = seg.apply(v, next)
+		}
+		vals = next
+	}
+	if p.multi {
+		if vals == nil {
+			vals = []any{}
+		}
+		return vals, true
+	}
+	if len(vals) == 0 {
+		return nil, false
+	}
+	return vals[0], true
+}
+
+// apply appends the values selected by seg from v to out.
+func (seg pathSegment) apply(v any, out []any) []any {
+	switch v := v.(type) {
+	case map[string]any:
+		if seg.wildcard {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				out = append(out, v[k])
+			}
+		} else if el, ok := v[seg.name]; ok && !seg.isIndex {
+			out = append(out, el)
+		}
+	case []any:
+		switch {
+		case seg.wildcard:
+			out = append(out, v...)
+		case seg.isIndex:
+			i := seg.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+	}
+	return out
+}
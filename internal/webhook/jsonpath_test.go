@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{
+		"action": "triggered",
+		"data": {"issue": {"title": "boom", "tags": ["a", "b", "c"]}},
+		"odd key": 1,
+		"events": [{"id": 1}, {"id": 2}]
+	}`), &doc)
+
+	tests := []struct {
+		expr  string
+		want  any
+		found bool
+	}{
+		{"$", doc, true},
+		{"$.action", "triggered", true},
+		{"$.data.issue.title", "boom", true},
+		{"$['odd key']", float64(1), true},
+		{`$["data"]['issue'].title`, "boom", true},
+		{"$.data.issue.tags[0]", "a", true},
+		{"$.data.issue.tags[-1]", "c", true},
+		{"$.data.issue.tags[3]", nil, false},
+		{"$.events[*].id", []any{float64(1), float64(2)}, true},
+		{"$.data.issue.*", []any{[]any{"a", "b", "c"}, "boom"}, true},
+		{"$.missing[*]", []any{}, true},
+		{"$.missing", nil, false},
+		{"$.action.length", nil, false},
+	}
+	for _, tt := range tests {
+		p, err := compileJSONPath(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		got, found := p.eval(doc)
+		if found != tt.found || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, %v; want %v, %v", tt.expr, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestCompileJSONPath_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "action", "$.", "$..a", "$[", "$[x]", "$a"} {
+		if _, err := compileJSONPath(expr); err == nil {
+			t.Errorf("compileJSONPath(%q): expected error", expr)
+		}
+	}
+}
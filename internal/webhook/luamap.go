@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/internal/workflow"
+)
+
+// scriptTimeout bounds one call of a mapping script.
+const scriptTimeout = 5 * time.Second
+
+// luaMapper runs a route's mapping script. The script defines a global
+// function map(req) and runs in the same sandbox as workflows:
+//
+//	function map(req)
+//	    -- req.body (decoded JSON), req.raw, req.headers (lower-case names), req.path
+//	    if req.body.action ~= "triggered" then return nil end  -- ignore
+//	    return { title = req.body.data.title }, "sentry.alert.triggered"
+//	end
+//
+// It returns the payload table and, optionally, an event type overriding
+// the route's. Returning nil ignores the delivery.
+type luaMapper struct {
+	mu     sync.Mutex // an LState is not safe for concurrent use
+	L      *lua.LState
+	fn     *lua.LFunction
+	closed bool
+}
+
+func newLuaMapper(path string, logger zerolog.Logger) (*luaMapper, error) {
+	L := workflow.NewSandboxedState("webhook:"+path, logger)
+	if err := L.DoFile(path); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("load script %s: %w", path, err)
+	}
+	fn, ok := L.GetGlobal("map").(*lua.LFunction)
+	if !ok {
+		L.Close()
+		return nil, fmt.Errorf("script %s does not define function map(req)", path)
+	}
+	return &luaMapper{L: L, fn: fn}, nil
+}
+
+// apply calls map(req). ok is false if the script ignored the delivery.
+func (m *luaMapper) apply(req *request) (payload map[string]any, eventType string, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, "", false, fmt.Errorf("routes were reloaded; retry the delivery")
+	}
+
+	L := m.L
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	headers := L.NewTable()
+	for name, vals := range req.header {
+		L.SetField(headers, strings.ToLower(name), lua.LString(strings.Join(vals, ", ")))
+	}
+	arg := L.NewTable()
+	L.SetField(arg, "body", workflow.GoToLua(L, req.body))
+	L.SetField(arg, "raw", lua.LString(req.raw))
+	L.SetField(arg, "headers", headers)
+	L.SetField(arg, "path", lua.LString(req.path))
+
+	if err := L.CallByParam(lua.P{Fn: m.fn, NRet: 2, Protect: true}, arg); err != nil {
+		return nil, "", false, err
+	}
+	ret, typ := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+
+	switch ret := ret.(type) {
+	case *lua.LNilType:
+		return nil, "", false, nil
+	case *lua.LTable:
+		payload, isMap := workflow.TableToMap(ret).(map[string]any)
+		if !isMap {
+			return nil, "", false, fmt.Errorf("map() must return a table with string keys")
+		}
+		if s, isStr := typ.(lua.LString); isStr {
+			eventType = string(s)
+		}
+		return payload, eventType, true, nil
+	default:
+		return nil, "", false, fmt.Errorf("map() must return a table or nil, got %s", ret.Type())
+	}
+}
+
+// close releases the Lua state once any call in progress returns.
+func (m *luaMapper) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.L.Close()
+}
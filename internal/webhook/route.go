@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// defaultSource is the event source of routes that do not set one.
+const defaultSource = "webhook"
+
+// request is a verified delivery, decoded for mapping.
+type request struct {
+	path   string
+	header http.Header
+	raw    []byte
+	body   any // decoded JSON, or map of form values; nil if empty
+}
+
+// route is a compiled [[routes]] entry.
+type route struct {
+	path      string
+	eventType string
+	typeFrom  *selector
+	source    string
+	verify    verifier
+	fields    map[string]*selector
+	script    *luaMapper
+}
+
+// selector reads a value from a delivery: a JSONPath into the body or a
+// header named with header:<Name>.
+type selector struct {
+	header string
+	path   *jsonPath
+}
+
+func compileSelector(expr string) (*selector, error) {
+	if name, ok := strings.CutPrefix(expr, "header:"); ok {
+		if name == "" {
+			return nil, fmt.Errorf("empty header name in %q", expr)
+		}
+		return &selector{header: name}, nil
+	}
+	p, err := compileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &selector{path: p}, nil
+}
+
+func (s *selector) value(req *request) (any, bool) {
+	if s.header != "" {
+		v := req.header.Get(s.header)
+		return v, v != ""
+	}
+	return s.path.eval(req.body)
+}
+
+// compileRoutes validates route configs and builds the routing table,
+// keyed by path. On error any scripts already loaded are closed.
+func compileRoutes(cfgs []RouteConfig, logger zerolog.Logger) (map[string]*route, error) {
+	routes := make(map[string]*route, len(cfgs))
+	for i, cfg := range cfgs {
+		rt, err := compileRoute(cfg, logger)
+		if err == nil && routes[cfg.Path] != nil {
+			rt.close()
+			err = fmt.Errorf("duplicate path %q", cfg.Path)
+		}
+		if err != nil {
+			closeRoutes(routes)
+			return nil, fmt.Errorf("routes[%d]: %w", i, err)
+		}
+		routes[cfg.Path] = rt
+	}
+	return routes, nil
+}
+
+func compileRoute(cfg RouteConfig, logger zerolog.Logger) (*route, error) {
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return nil, fmt.Errorf("path must start with /, got %q", cfg.Path)
+	}
+	if cfg.Type == "" && cfg.Script == "" {
+		return nil, fmt.Errorf("type is required unless a script sets it")
+	}
+	if cfg.Script != "" && (len(cfg.Fields) > 0 || cfg.TypeFrom != "") {
+		return nil, fmt.Errorf("script cannot be combined with fields or type_from")
+	}
+	verify, err := newVerifier(cfg.Verify)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &route{
+		path:      cfg.Path,
+		eventType: cfg.Type,
+		source:    cfg.Source,
+		verify:    verify,
+	}
+	if rt.source == "" {
+		rt.source = defaultSource
+	}
+	if cfg.TypeFrom != "" {
+		if rt.typeFrom, err = compileSelector(cfg.TypeFrom); err != nil {
+			return nil, fmt.Errorf("type_from: %w", err)
+		}
+	}
+	if len(cfg.Fields) > 0 {
+		rt.fields = make(map[string]*selector, len(cfg.Fields))
+		for name, expr := range cfg.Fields {
+			if rt.fields[name], err = compileSelector(expr); err != nil {
+				return nil, fmt.Errorf("fields.%s: %w", name, err)
+			}
+		}
+	}
+	if cfg.Script != "" {
+		if rt.script, err = newLuaMapper(cfg.Script, logger); err != nil {
+			return nil, err
+		}
+	}
+	return rt, nil
+}
+
+// decodeBody parses a delivery body: form-encoded bodies become a map of
+// their first values, anything else must be JSON (or empty).
+func decodeBody(contentType string, raw []byte) (any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		vals, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("decode form body: %w", err)
+		}
+		form := make(map[string]any, len(vals))
+		for k := range vals {
+			form[k] = vals.Get(k)
+		}
+		return form, nil
+	}
+	var body any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("decode JSON body: %w", err)
+	}
+	return body, nil
+}
+
+// event maps a delivery to a sekia event. ok is false if the route's
+// script ignored it.
+func (rt *route) event(req *request) (ev protocol.Event, ok bool, err error) {
+	eventType := rt.eventType
+	var payload map[string]any
+
+	switch {
+	case rt.script != nil:
+		var scriptType string
+		payload, scriptType, ok, err = rt.script.apply(req)
+		if err != nil || !ok {
+			return protocol.Event{}, false, err
+		}
+		if scriptType != "" {
+			eventType = scriptType
+		}
+		if eventType == "" {
+			return protocol.Event{}, false, fmt.Errorf("script returned no event type and the route has none")
+		}
+	case rt.fields != nil:
+		payload = make(map[string]any, len(rt.fields))
+		for name, sel := range rt.fields {
+			if v, found := sel.value(req); found {
+				payload[name] = v
+			}
+		}
+	default:
+		switch body := req.body.(type) {
+		case map[string]any:
+			payload = body
+		case nil:
+			payload = map[string]any{}
+		default:
+			payload = map[string]any{"data": body}
+		}
+	}
+
+	if rt.typeFrom != nil {
+		v, found := rt.typeFrom.value(req)
+		s, isStr := v.(string)
+		if !found || !isStr || s == "" {
+			return protocol.Event{}, false, fmt.Errorf("type_from did not resolve to a string")
+		}
+		eventType += "." + s
+	}
+
+	return protocol.NewEvent(eventType, rt.source, payload), true, nil
+}
+
+func (rt *route) close() {
+	if rt.script != nil {
+		rt.script.close()
+	}
+}
+
+func closeRoutes(routes map[string]*route) {
+	for _, rt := range routes {
+		rt.close()
+	}
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+const maxWebhookBodySize = 10 << 20 // 10 MB
+
+// Server receives webhook deliveries over HTTP and maps them to events
+// using the configured routes.
+type Server struct {
+	listenAddr string
+	routes     atomic.Pointer[map[string]*route]
+	onEvent    func(protocol.Event)
+	httpServer *http.Server
+	listener   net.Listener
+	logger     zerolog.Logger
+}
+
+// NewServer creates a webhook server. onEvent is called for each mapped event.
+func NewServer(listen string, onEvent func(protocol.Event), logger zerolog.Logger) *Server {
+	s := &Server{
+		listenAddr: listen,
+		onEvent:    onEvent,
+		logger:     logger.With().Str("component", "webhook").Logger(),
+	}
+	s.routes.Store(&map[string]*route{})
+	return s
+}
+
+// SetRoutes compiles routes and swaps them in. The previous routes keep
+// serving if any route is invalid.
+func (s *Server) SetRoutes(cfgs []RouteConfig) error {
+	routes, err := compileRoutes(cfgs, s.logger)
+	if err != nil {
+		return err
+	}
+	old := s.routes.Swap(&routes)
+	closeRoutes(*old)
+	return nil
+}
+
+// Listen binds the TCP socket. Call Serve() afterwards to accept connections.
+func (s *Server) Listen() error {
+	ln, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	return nil
+}
+
+// Serve accepts connections on the listener created by Listen. Blocks until shut down.
+func (s *Server) Serve() error {
+	s.httpServer = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.logger.Info().Str("addr", s.listener.Addr().String()).Msg("webhook server listening")
+	return s.httpServer.Serve(s.listener)
+}
+
+// Addr returns the listener address. Only valid after Listen is called.
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown gracefully stops the server and releases route scripts.
+func (s *Server) Shutdown(ctx context.Context) {
+	if s.httpServer != nil {
+		s.httpServer.Shutdown(ctx)
+	}
+	closeRoutes(*s.routes.Swap(&map[string]*route{}))
+}
+
+// ServeHTTP dispatches a delivery to the route registered for its path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := (*s.routes.Load())[r.URL.Path]
+	if rt == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if !rt.verify(r, raw) {
+		s.logger.Warn().Str("path", rt.path).Str("remote", r.RemoteAddr).Msg("rejected delivery: verification failed")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := decodeBody(r.Header.Get("Content-Type"), raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ev, ok, err := rt.event(&request{path: r.URL.Path, header: r.Header, raw: raw, body: body})
+	if err != nil {
+		s.logger.Warn().Err(err).Str("path", rt.path).Msg("mapping failed")
+		http.Error(w, "mapping failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		s.logger.Debug().Str("path", rt.path).Msg("ignoring delivery")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ignored"})
+		return
+	}
+
+	s.onEvent(ev)
+
+	s.logger.Info().
+		Str("path", rt.path).
+		Str("sekia_type", ev.Type).
+		Msg("webhook processed")
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted", "id": ev.ID})
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func newTestServer(t *testing.T, routes ...RouteConfig) (*Server, *[]protocol.Event) {
+	t.Helper()
+	var events []protocol.Event
+	s := NewServer("", func(ev protocol.Event) { events = append(events, ev) }, zerolog.Nop())
+	if err := s.SetRoutes(routes); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closeRoutes(*s.routes.Load()) })
+	return s, &events
+}
+
+func post(s *Server, path, contentType, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+var noVerify = VerifyConfig{Scheme: SchemeNone}
+
+func TestServer_FieldMapping(t *testing.T) {
+	s, events := newTestServer(t, RouteConfig{
+		Path:     "/pagerduty",
+		Type:     "pagerduty",
+		TypeFrom: "$.event.event_type",
+		Source:   "pagerduty",
+		Verify:   noVerify,
+		Fields: map[string]string{
+			"incident_id": "$.event.data.id",
+			"title":       "$.event.data.title",
+			"services":    "$.event.data.services[*].name",
+			"delivery":    "header:X-Delivery",
+			"missing":     "$.event.data.nope",
+		},
+	})
+
+	body := `{"event":{"event_type":"incident.triggered","data":{"id":"P1","title":"CPU high","services":[{"name":"api"},{"name":"db"}]}}}`
+	rec := post(s, "/pagerduty", "application/json", body, "X-Delivery", "d-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("got %d events, want 1", len(*events))
+	}
+	ev := (*events)[0]
+	if ev.Type != "pagerduty.incident.triggered" || ev.Source != "pagerduty" {
+		t.Errorf("type %q source %q", ev.Type, ev.Source)
+	}
+	want := map[string]any{
+		"incident_id": "P1",
+		"title":       "CPU high",
+		"services":    []any{"api", "db"},
+		"delivery":    "d-1",
+	}
+	if !reflect.DeepEqual(ev.Payload, want) {
+		t.Errorf("payload = %v, want %v", ev.Payload, want)
+	}
+
+	rec = post(s, "/pagerduty", "application/json", `{"event":{}}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unresolved type_from: status = %d, want 422", rec.Code)
+	}
+}
+
+func TestServer_DefaultMapping(t *testing.T) {
+	s, events := newTestServer(t,
+		RouteConfig{Path: "/jenkins", Type: "jenkins.build", Verify: noVerify},
+	)
+
+	post(s, "/jenkins", "application/json", `{"name":"app","build":{"status":"FAILURE"}}`)
+	post(s, "/jenkins", "application/json", `[1,2]`)
+	post(s, "/jenkins", "application/x-www-form-urlencoded", "payload=%7B%7D&job=app")
+	post(s, "/jenkins", "", "")
+
+	if len(*events) != 4 {
+		t.Fatalf("got %d events, want 4", len(*events))
+	}
+	if (*events)[0].Payload["name"] != "app" || (*events)[0].Source != "webhook" {
+		t.Errorf("object body: %+v", (*events)[0])
+	}
+	if !reflect.DeepEqual((*events)[1].Payload, map[string]any{"data": []any{float64(1), float64(2)}}) {
+		t.Errorf("array body: payload = %v", (*events)[1].Payload)
+	}
+	if !reflect.DeepEqual((*events)[2].Payload, map[string]any{"payload": "{}", "job": "app"}) {
+		t.Errorf("form body: payload = %v", (*events)[2].Payload)
+	}
+	if len((*events)[3].Payload) != 0 {
+		t.Errorf("empty body: payload = %v", (*events)[3].Payload)
+	}
+}
+
+func TestServer_Script(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "sentry.lua")
+	os.WriteFile(script, []byte(`
+function map(req)
+	if req.body.action ~= "triggered" then return nil end
+	return {
+		title    = req.body.data.event.title,
+		resource = req.headers["sentry-hook-resource"],
+		path     = req.path,
+	}, "sentry." .. req.headers["sentry-hook-resource"] .. "." .. req.body.action
+end
+`), 0644)
+
+	s, events := newTestServer(t, RouteConfig{Path: "/sentry", Script: script, Source: "sentry", Verify: noVerify})
+
+	rec := post(s, "/sentry", "application/json", `{"action":"resolved"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ignored") {
+		t.Errorf("ignored delivery: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = post(s, "/sentry", "application/json", `{"action":"triggered","data":{"event":{"title":"boom"}}}`,
+		"Sentry-Hook-Resource", "event_alert")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp map[string]string
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp["status"] != "accepted" || resp["id"] == "" {
+		t.Errorf("response = %v", resp)
+	}
+
+	if len(*events) != 1 {
+		t.Fatalf("got %d events, want 1", len(*events))
+	}
+	ev := (*events)[0]
+	if ev.Type != "sentry.event_alert.triggered" {
+		t.Errorf("type = %q", ev.Type)
+	}
+	want := map[string]any{"title": "boom", "resource": "event_alert", "path": "/sentry"}
+	if !reflect.DeepEqual(ev.Payload, want) {
+		t.Errorf("payload = %v, want %v", ev.Payload, want)
+	}
+
+	rec = post(s, "/sentry", "application/json", `{"action":"triggered"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("script error: status = %d, want 422", rec.Code)
+	}
+}
+
+func TestServer_Rejections(t *testing.T) {
+	s, events := newTestServer(t,
+		RouteConfig{Path: "/stripe", Type: "stripe.event", Verify: VerifyConfig{Scheme: SchemeBearer, Secret: "tok"}},
+	)
+
+	if rec := post(s, "/stripe", "application/json", `{}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", rec.Code)
+	}
+	if rec := post(s, "/other", "application/json", `{}`, "Authorization", "Bearer tok"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown path: status = %d, want 404", rec.Code)
+	}
+	if rec := post(s, "/stripe", "application/json", `{bad`, "Authorization", "Bearer tok"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad JSON: status = %d, want 400", rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/stripe", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", rec.Code)
+	}
+	if len(*events) != 0 {
+		t.Errorf("got %d events, want 0", len(*events))
+	}
+}
+
+func TestServer_SetRoutes(t *testing.T) {
+	s, _ := newTestServer(t, RouteConfig{Path: "/a", Type: "a", Verify: noVerify})
+
+	invalid := [][]RouteConfig{
+		{{Path: "b", Type: "b", Verify: noVerify}},
+		{{Path: "/b", Verify: noVerify}},
+		{{Path: "/b", Type: "b"}},
+		{{Path: "/b", Type: "b", Verify: noVerify, Fields: map[string]string{"x": "x"}}},
+		{{Path: "/b", Type: "b", Verify: noVerify, Script: "/nonexistent.lua"}},
+		{{Path: "/b", Type: "b", Verify: noVerify}, {Path: "/b", Type: "c", Verify: noVerify}},
+	}
+	for _, routes := range invalid {
+		if err := s.SetRoutes(routes); err == nil {
+			t.Errorf("%+v: expected error", routes)
+		}
+	}
+	if rec := post(s, "/a", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("previous routes not kept after a failed reload: status = %d", rec.Code)
+	}
+
+	if err := s.SetRoutes([]RouteConfig{{Path: "/b", Type: "b", Verify: noVerify}}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := post(s, "/a", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("removed route: status = %d, want 404", rec.Code)
+	}
+	if rec := post(s, "/b", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("added route: status = %d, want 200", rec.Code)
+	}
+}
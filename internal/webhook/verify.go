@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- some vendors still sign webhooks with HMAC-SHA1
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// Verification schemes.
+const (
+	SchemeHMAC   = "hmac"
+	SchemeBearer = "bearer"
+	SchemeBasic  = "basic"
+	SchemeNone   = "none"
+)
+
+// verifier authenticates a delivery from its headers and raw body.
+type verifier func(r *http.Request, body []byte) bool
+
+// newVerifier builds the verifier for a route. The scheme must be given
+// explicitly; "none" accepts every delivery.
+func newVerifier(cfg VerifyConfig) (verifier, error) {
+	if cfg.Scheme != SchemeNone && cfg.Scheme != "" && cfg.Secret == "" {
+		return nil, fmt.Errorf("verify.secret (or verify.secret_env) is required for scheme %q", cfg.Scheme)
+	}
+	secret := []byte(cfg.Secret)
+
+	switch cfg.Scheme {
+	case SchemeNone:
+		return func(*http.Request, []byte) bool { return true }, nil
+
+	case SchemeBearer:
+		return func(r *http.Request, _ []byte) bool {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			return ok && subtle.ConstantTimeCompare([]byte(token), secret) == 1
+		}, nil
+
+	case SchemeBasic:
+		if cfg.Username == "" {
+			return nil, fmt.Errorf("verify.username is required for scheme %q", SchemeBasic)
+		}
+		return func(r *http.Request, _ []byte) bool {
+			user, pass, ok := r.BasicAuth()
+			return ok &&
+				subtle.ConstantTimeCompare([]byte(user), []byte(cfg.Username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(pass), secret) == 1
+		}, nil
+
+	case SchemeHMAC:
+		var newHash func() hash.Hash
+		switch cfg.Algorithm {
+		case "", "sha256":
+			newHash = sha256.New
+		case "sha1":
+			newHash = sha1.New
+		case "sha512":
+			newHash = sha512.New
+		default:
+			return nil, fmt.Errorf("verify.algorithm must be sha256, sha1 or sha512, got %q", cfg.Algorithm)
+		}
+		var decode func(string) ([]byte, error)
+		switch cfg.Encoding {
+		case "", "hex":
+			decode = hex.DecodeString
+		case "base64":
+			decode = base64.StdEncoding.DecodeString
+		default:
+			return nil, fmt.Errorf("verify.encoding must be hex or base64, got %q", cfg.Encoding)
+		}
+		header := cfg.Header
+		if header == "" {
+			header = "X-Signature"
+		}
+		return func(r *http.Request, body []byte) bool {
+			sig, ok := strings.CutPrefix(r.Header.Get(header), cfg.Prefix)
+			if !ok || sig == "" {
+				return false
+			}
+			got, err := decode(sig)
+			if err != nil {
+				return false
+			}
+			mac := hmac.New(newHash, secret)
+			mac.Write(body)
+			return hmac.Equal(got, mac.Sum(nil))
+		}, nil
+
+	case "":
+		return nil, fmt.Errorf("verify.scheme is required (hmac, bearer, basic or none)")
+	default:
+		return nil, fmt.Errorf("verify.scheme must be hmac, bearer, basic or none, got %q", cfg.Scheme)
+	}
+}
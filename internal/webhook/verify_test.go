@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifier_HMAC(t *testing.T) {
+	body := []byte(`{"ok":true}`)
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	v, err := newVerifier(VerifyConfig{Scheme: SchemeHMAC, Secret: "k", Header: "X-Hub-Signature-256", Prefix: "sha256="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		sig  string
+		want bool
+	}{
+		{"sha256=" + hex.EncodeToString(sum), true},
+		{hex.EncodeToString(sum), false},
+		{"sha256=" + hex.EncodeToString(sum[:8]), false},
+		{"sha256=zz", false},
+		{"", false},
+	} {
+		req := httptest.NewRequest("POST", "/hook", nil)
+		if tt.sig != "" {
+			req.Header.Set("X-Hub-Signature-256", tt.sig)
+		}
+		if got := v(req, body); got != tt.want {
+			t.Errorf("signature %q: got %v, want %v", tt.sig, got, tt.want)
+		}
+	}
+	req := httptest.NewRequest("POST", "/hook", nil)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(sum))
+	if v(req, []byte(`{"ok":false}`)) {
+		t.Error("signature accepted for a different body")
+	}
+
+	mac = hmac.New(sha1.New, []byte("k"))
+	mac.Write(body)
+	v, _ = newVerifier(VerifyConfig{Scheme: SchemeHMAC, Secret: "k", Algorithm: "sha1", Encoding: "base64"})
+	req = httptest.NewRequest("POST", "/hook", nil)
+	req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	if !v(req, body) {
+		t.Error("sha1/base64 signature rejected")
+	}
+}
+
+func TestVerifier_BearerAndBasic(t *testing.T) {
+	bearer, _ := newVerifier(VerifyConfig{Scheme: SchemeBearer, Secret: "tok"})
+	basic, _ := newVerifier(VerifyConfig{Scheme: SchemeBasic, Username: "jenkins", Secret: "pw"})
+	none, _ := newVerifier(VerifyConfig{Scheme: SchemeNone})
+
+	req := httptest.NewRequest("POST", "/hook", nil)
+	if bearer(req, nil) || basic(req, nil) {
+		t.Error("request without credentials accepted")
+	}
+	if !none(req, nil) {
+		t.Error("scheme none rejected a request")
+	}
+	req.Header.Set("Authorization", "Bearer tok")
+	if !bearer(req, nil) {
+		t.Error("valid bearer token rejected")
+	}
+	req.SetBasicAuth("jenkins", "pw")
+	if !basic(req, nil) || bearer(req, nil) {
+		t.Error("basic credentials handled incorrectly")
+	}
+	req.SetBasicAuth("jenkins", "nope")
+	if basic(req, nil) {
+		t.Error("wrong basic password accepted")
+	}
+}
+
+func TestNewVerifier_Invalid(t *testing.T) {
+	for _, cfg := range []VerifyConfig{
+		{},
+		{Scheme: "token", Secret: "x"},
+		{Scheme: SchemeHMAC},
+		{Scheme: SchemeBearer},
+		{Scheme: SchemeBasic, Secret: "pw"},
+		{Scheme: SchemeHMAC, Secret: "k", Algorithm: "md5"},
+		{Scheme: SchemeHMAC, Secret: "k", Encoding: "base32"},
+	} {
+		if _, err := newVerifier(cfg); err == nil {
+			t.Errorf("%+v: expected error", cfg)
+		}
+	}
+}
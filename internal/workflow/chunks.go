@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// chunkCache holds compiled Lua chunks keyed by the SHA256 of their source,
+// so a file is parsed once however many VMs load it. A compiled
+// FunctionProto is immutable and can be shared between LStates.
+type chunkCache struct {
+	mu     sync.Mutex
+	protos map[string]*lua.FunctionProto // source hash -> compiled chunk
+	byPath map[string]string             // path -> hash of its last compiled source
+}
+
+func newChunkCache() *chunkCache {
+	return &chunkCache{
+		protos: make(map[string]*lua.FunctionProto),
+		byPath: make(map[string]string),
+	}
+}
+
+// sourceHash returns the hex SHA256 of a chunk's source, as listed in the manifest.
+func sourceHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+// compile returns the compiled chunk for src, read from path, whose
+// sourceHash is hash, and its syntax tree if it was not already cached. A
+// chunk is dropped once its path compiles to something else.
+func (c *chunkCache) compile(path string, src []byte, hash string) (*lua.FunctionProto, []ast.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if proto, ok := c.protos[hash]; ok {
+		c.byPath[path] = hash
+		return proto, nil, nil
+	}
+
+	chunk, err := parse.Parse(bytes.NewReader(src), path)
+	if err != nil {
+		return nil, nil, err
+	}
+	proto, err := lua.Compile(chunk, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if old, ok := c.byPath[path]; ok && old != hash {
+		delete(c.protos, old)
+	}
+	c.protos[hash] = proto
+	c.byPath[path] = hash
+	return proto, chunk, nil
+}
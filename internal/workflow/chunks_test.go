@@ -0,0 +1,120 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkCache(t *testing.T) {
+	c := newChunkCache()
+	src := []byte("return 1")
+	p1, chunk, err := c.compile("a.lua", src, sourceHash(src))
+	if err != nil || chunk == nil {
+		t.Fatalf("compile: chunk = %v, err = %v", chunk, err)
+	}
+	p2, chunk, _ := c.compile("b.lua", src, sourceHash(src))
+	if p1 != p2 || chunk != nil {
+		t.Error("identical source compiled twice")
+	}
+	bad := []byte("return +")
+	if _, _, err := c.compile("bad.lua", bad, sourceHash(bad)); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestLoadWorkflow_ReusesCompiledChunk(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"wf.lua": `sekia.concurrency(3)
+sekia.on("sekia.events.test", function() end)`,
+	})
+	path := filepath.Join(dir, "wf.lua")
+	eng := New(nil, dir, nil, 0, "", testLogger())
+	defer eng.Stop()
+
+	if err := eng.LoadWorkflow("wf", path); err != nil {
+		t.Fatal(err)
+	}
+	first := eng.chunks.protos[eng.chunks.byPath[path]]
+	if err := eng.LoadWorkflow("wf", path); err != nil {
+		t.Fatal(err)
+	}
+	if len(eng.chunks.protos) != 1 || eng.chunks.protos[eng.chunks.byPath[path]] != first {
+		t.Error("unchanged workflow was compiled again on reload")
+	}
+
+	os.WriteFile(path, []byte(`sekia.on("sekia.events.other", function() end)`), 0644)
+	if err := eng.LoadWorkflow("wf", path); err != nil {
+		t.Fatal(err)
+	}
+	if len(eng.chunks.protos) != 1 || eng.chunks.protos[eng.chunks.byPath[path]] == first {
+		t.Error("edited workflow not recompiled, or old chunk kept")
+	}
+	if info := eng.Workflows()[0]; info.LoadTime <= 0 || info.LoadError != nil {
+		t.Errorf("LoadTime = %v, LoadError = %v", info.LoadTime, info.LoadError)
+	}
+}
+
+func TestLoadWorkflow_LoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantLine int
+		wantCol  bool
+	}{
+		{"syntax", "sekia.on(\"sekia.events.test\",\n  function(event) end end)\n", 2, true},
+		{"runtime", "local cfg = nil\nlocal x = cfg.key\n", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeWorkflowFiles(t, map[string]string{"wf.lua": tt.src})
+			path := filepath.Join(dir, "wf.lua")
+			eng := New(nil, dir, nil, 0, "", testLogger())
+			defer eng.Stop()
+
+			if err := eng.LoadWorkflow("wf", path); err == nil {
+				t.Fatal("expected load error")
+			}
+			if eng.Count() != 0 {
+				t.Fatalf("Count = %d, want 0", eng.Count())
+			}
+			infos := eng.Workflows()
+			if len(infos) != 1 || infos[0].LoadError == nil {
+				t.Fatalf("Workflows = %+v, want one entry with a load error", infos)
+			}
+			le := infos[0].LoadError
+			if le.Line != tt.wantLine || (le.Column > 0) != tt.wantCol || le.Message == "" {
+				t.Errorf("LoadError = %+v, want line %d", le, tt.wantLine)
+			}
+
+			os.WriteFile(path, []byte(`sekia.on("sekia.events.test", function() end)`), 0644)
+			if err := eng.LoadWorkflow("wf", path); err != nil {
+				t.Fatal(err)
+			}
+			if infos := eng.Workflows(); len(infos) != 1 || infos[0].LoadError != nil {
+				t.Errorf("load error not cleared: %+v", infos)
+			}
+		})
+	}
+}
+
+func TestLoadWorkflow_FailedReloadKeepsRunning(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"wf.lua": `sekia.on("sekia.events.test", function() end)`,
+	})
+	path := filepath.Join(dir, "wf.lua")
+	eng := New(nil, dir, nil, 0, "", testLogger())
+	defer eng.Stop()
+	if err := eng.LoadWorkflow("wf", path); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(path, []byte("sekia.on(\n"), 0644)
+	if err := eng.LoadWorkflow("wf", path); err == nil {
+		t.Fatal("expected load error")
+	}
+	infos := eng.Workflows()
+	if len(infos) != 1 || infos[0].Handlers != 1 || infos[0].LoadError == nil {
+		t.Errorf("Workflows = %+v, want the previous version with a load error", infos)
+	}
+}
@@ -0,0 +1,75 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// Weekday mornings: Friday 10:00 -> Monday 09:00.
+		{"0 9 * * MON-FRI", time.Date(2024, 5, 3, 10, 0, 0, 0, berlin), time.Date(2024, 5, 6, 9, 0, 0, 0, berlin)},
+		// Strictly after: at 09:00 the next run is tomorrow.
+		{"0 9 * * *", time.Date(2024, 5, 3, 9, 0, 0, 0, berlin), time.Date(2024, 5, 4, 9, 0, 0, 0, berlin)},
+		{"*/15 * * * *", time.Date(2024, 5, 3, 9, 7, 30, 0, time.UTC), time.Date(2024, 5, 3, 9, 15, 0, 0, time.UTC)},
+		{"30 8-18/2 * * *", time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC), time.Date(2024, 5, 3, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 JAN,jul *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// Sunday as 7.
+		{"0 12 * * 7", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 5, 12, 0, 0, 0, time.UTC)},
+		// Day-of-month OR day-of-week when both are restricted: 13th or Friday.
+		{"0 0 13 * FRI", time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Spring-forward in Berlin: 02:30 does not exist on 2024-03-31.
+		{"30 2 * * *", time.Date(2024, 3, 30, 3, 0, 0, 0, berlin), time.Date(2024, 4, 1, 2, 30, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestParseCron_Never(t *testing.T) {
+	s, err := parseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.next(time.Now()); !got.IsZero() {
+		t.Errorf("next = %s, want zero time for Feb 31", got)
+	}
+}
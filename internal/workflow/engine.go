@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 	lua "github.com/yuin/gopher-lua"
 
@@ -21,36 +23,69 @@ import (
 
 // WorkflowInfo describes a loaded workflow for the API.
 type WorkflowInfo struct {
-	Name     string   `json:"name"`
-	FilePath string   `json:"file_path"`
-	Handlers int      `json:"handlers"`
-	Patterns []string `json:"patterns"`
-	LoadedAt time.Time `json:"loaded_at"`
-	Events   int64    `json:"events"`
-	Errors   int64    `json:"errors"`
+	Name      string                  `json:"name"`
+	FilePath  string                  `json:"file_path"`
+	Handlers  int                     `json:"handlers"`
+	Patterns  []string                `json:"patterns"`
+	Filters   []string                `json:"filters,omitempty"`
+	LoadedAt  time.Time               `json:"loaded_at"`
+	Events    int64                   `json:"events"`
+	Errors    int64                   `json:"errors"`
+	Shadow    bool                    `json:"shadow"`
+	Schedules []protocol.ScheduleInfo `json:"schedules,omitempty"`
+
+	// Loading: how long the last successful load took, and the last failed
+	// load if it has not loaded since. A workflow that has never loaded has
+	// only Name, FilePath and LoadError set.
+	LoadTime  time.Duration       `json:"load_time"`
+	LoadError *protocol.LoadError `json:"load_error,omitempty"`
+
+	// Worker pool and backpressure.
+	Concurrency   int      `json:"concurrency"`
+	OrderKey      []string `json:"order_key,omitempty"`
+	Busy          int      `json:"busy"`
+	Queued        int      `json:"queued"`
+	QueueCapacity int      `json:"queue_capacity"`
+	Dropped       int64    `json:"dropped"`
+
+	// Commands refused by the command policy.
+	Denied int64 `json:"denied"`
 }
 
 // scheduleEntry holds a timer-driven callback registered via sekia.schedule().
 type scheduleEntry struct {
 	Interval time.Duration
 	Fn       *lua.LFunction
-	ticker   *time.Ticker
+	next     time.Time // guarded by workflowState.schedMu
 }
 
-// workflowState tracks a loaded workflow and its isolated Lua VM.
+// workflowState tracks a loaded workflow and its isolated Lua VMs.
 type workflowState struct {
 	name           string
 	filePath       string
-	L              *lua.LState
-	modCtx         *moduleContext
+	L              *lua.LState    // primary VM; runs schedules
+	modCtx         *moduleContext // primary VM's module context
+	workers        []*luaWorker   // workers[0] is the primary VM
 	loadedAt       time.Time
+	loadTime       time.Duration // reading, compiling and running the file on every VM
 	events         atomic.Int64
 	errors         atomic.Int64
+	dropped        atomic.Int64  // events dropped because the queue was full
+	denied         *atomic.Int64 // commands refused by the command policy, shared with the workers
+	busy           atomic.Int32  // workers currently running a handler
 	handlerTimeout time.Duration
 
-	eventCh   chan *nats.Msg
+	eventCh   chan *eventMsg
+	quit      chan struct{} // closed when the workflow stops, unblocking senders
+	sendMu    sync.RWMutex  // held for reading by senders, so eventCh is closed only with no send in flight
 	done      chan struct{}
 	schedules []scheduleEntry
+	crons     []*cronEntry
+	schedMu   sync.Mutex
+	cronNext  map[*cronEntry]time.Time // guarded by schedMu
+	libs      *libLoader               // libraries loaded with require()
+	dryRun    bool                     // throwaway copy used by Replay with DryRun set
+	shadow    bool                     // publishes and commands are recorded, not sent
 }
 
 // ErrIntegrityViolation is returned when a workflow file fails SHA256 manifest verification.
@@ -68,14 +103,47 @@ type Engine struct {
 	nc              *nats.Conn
 	logger          zerolog.Logger
 	dir             string
-	sub             *nats.Subscription
+	subs            []*nats.Subscription
 	llm             ai.LLMClient
 	handlerTimeout  time.Duration
 	commandSecret   string
+	commandTTL      time.Duration
+	signingKey      *protocol.SigningKey
 	verifyIntegrity bool
 	skillsIndex     string
 	skillResolver   SkillResolver
 	convoStore      ConversationStore
+	stateStore      StateStore
+	timers          TimerStore
+	subjectScheme   string
+	validation      string // payload schema validation mode (protocol.Validation*)
+	httpCfg         HTTPConfig
+	chunks          *chunkCache             // compiled workflow and library chunks
+	hooks           *testHooks              // set by the workflow test harness
+	policy          *protocol.CommandPolicy // which commands workflows may send (nil = any)
+
+	// agents is the registered agent source. It is read without mu, since
+	// workflows consult it for every command they send.
+	agents atomic.Pointer[func() []protocol.AgentInfo]
+
+	// loadErrors holds the last failed load per workflow name until the
+	// workflow loads again or its file is removed.
+	loadErrors map[string]loadFailure
+
+	// Shadow mode: workflows forced into it by config, and the intents
+	// recorded per workflow name.
+	shadowNames map[string]bool
+	shadowLogs  map[string]*shadowLog
+
+	// Durable event log (nil js = core NATS subscription).
+	js       jetstream.JetStream
+	ackWait  time.Duration
+	consumer jetstream.ConsumeContext
+	stopping atomic.Bool
+
+	// reloadMu pauses event routing while ReloadAll swaps the workflow set,
+	// so events arriving mid-reload wait instead of matching nothing.
+	reloadMu sync.RWMutex
 }
 
 // New creates a workflow engine. Does not start it.
@@ -85,22 +153,41 @@ type Engine struct {
 func New(nc *nats.Conn, dir string, llm ai.LLMClient, handlerTimeout time.Duration, commandSecret string, logger zerolog.Logger) *Engine {
 	return &Engine{
 		workflows:      make(map[string]*workflowState),
+		shadowLogs:     make(map[string]*shadowLog),
 		nc:             nc,
 		logger:         logger.With().Str("component", "workflow").Logger(),
 		dir:            dir,
 		llm:            llm,
 		handlerTimeout: handlerTimeout,
 		commandSecret:  commandSecret,
+		chunks:         newChunkCache(),
+		loadErrors:     make(map[string]loadFailure),
 	}
 }
 
+// eventSubjects are the subjects routed to workflow handlers: agent and
+// workflow events, plus command results reported by agents.
+var eventSubjects = []string{"sekia.events.>", "sekia.results.>"}
+
 // Start subscribes to NATS events. Workflow loading is handled separately by LoadDir.
+// When an event log is configured via SetEventLog, events are consumed through a
+// durable JetStream consumer instead of a core subscription.
 func (e *Engine) Start() error {
-	sub, err := e.nc.Subscribe("sekia.events.>", e.handleEvent)
-	if err != nil {
-		return fmt.Errorf("subscribe to events: %w", err)
+	if e.js != nil {
+		if err := e.startEventLog(); err != nil {
+			return err
+		}
+		e.logger.Info().Str("dir", e.dir).Str("stream", protocol.StreamEvents).Msg("workflow engine started")
+		return nil
+	}
+
+	for _, subject := range eventSubjects {
+		sub, err := e.nc.Subscribe(subject, e.handleEvent)
+		if err != nil {
+			return fmt.Errorf("subscribe to %s: %w", subject, err)
+		}
+		e.subs = append(e.subs, sub)
 	}
-	e.sub = sub
 
 	e.logger.Info().Str("dir", e.dir).Msg("workflow engine started")
 	return nil
@@ -108,9 +195,11 @@ func (e *Engine) Start() error {
 
 // Stop unsubscribes from NATS, stops all workflow goroutines, and closes all LStates.
 func (e *Engine) Stop() {
-	if e.sub != nil {
-		e.sub.Unsubscribe()
+	e.stopping.Store(true)
+	for _, sub := range e.subs {
+		sub.Unsubscribe()
 	}
+	e.stopEventLog()
 
 	// Atomically collect and clear — stop outside the lock to avoid
 	// blocking handleEvent while goroutines drain their channels.
@@ -134,23 +223,47 @@ func (e *Engine) Workflows() []WorkflowInfo {
 	infos := make([]WorkflowInfo, 0, len(e.workflows))
 	for _, ws := range e.workflows {
 		patterns := make([]string, len(ws.modCtx.handlers))
+		var filters []string
 		for i, h := range ws.modCtx.handlers {
 			patterns[i] = h.Pattern
+			if h.Filter != nil {
+				filters = append(filters, h.Pattern+" "+h.Filter.String())
+			}
 		}
 		infos = append(infos, WorkflowInfo{
-			Name:     ws.name,
-			FilePath: ws.filePath,
-			Handlers: len(ws.modCtx.handlers),
-			Patterns: patterns,
-			LoadedAt: ws.loadedAt,
-			Events:   ws.events.Load(),
-			Errors:   ws.errors.Load(),
+			Name:      ws.name,
+			FilePath:  ws.filePath,
+			Handlers:  len(ws.modCtx.handlers),
+			Patterns:  patterns,
+			Filters:   filters,
+			LoadedAt:  ws.loadedAt,
+			Events:    ws.events.Load(),
+			Errors:    ws.errors.Load(),
+			Shadow:    ws.shadow,
+			Schedules: ws.scheduleInfo(),
+			LoadTime:  ws.loadTime,
+			LoadError: e.loadErrors[ws.name].err,
+
+			Concurrency:   len(ws.workers),
+			OrderKey:      ws.modCtx.orderKey,
+			Busy:          int(ws.busy.Load()),
+			Queued:        ws.queued(),
+			QueueCapacity: ws.queueCapacity(),
+			Dropped:       ws.dropped.Load(),
+
+			Denied: ws.denied.Load(),
 		})
 	}
+	for name, f := range e.loadErrors {
+		if _, loaded := e.workflows[name]; !loaded {
+			infos = append(infos, WorkflowInfo{Name: name, FilePath: f.filePath, LoadError: f.err})
+		}
+	}
 	return infos
 }
 
-// Count returns the number of loaded workflows.
+// Count returns the number of loaded workflows. Workflows that failed to
+// load are not counted.
 func (e *Engine) Count() int {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -192,6 +305,107 @@ func (e *Engine) SetConversationStore(cs ConversationStore) {
 	e.convoStore = cs
 }
 
+// SetStateStore sets the store backing sekia.state in Lua.
+func (e *Engine) SetStateStore(s StateStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stateStore = s
+}
+
+// SetTimerStore sets the store backing sekia.after, sekia.at and sekia.cancel in Lua.
+func (e *Engine) SetTimerStore(t TimerStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timers = t
+}
+
+// SetSubjectScheme sets the event subject scheme (protocol.SubjectsFlat,
+// SubjectsHierarchical or SubjectsCompat) used by sekia.publish and
+// sekia.after, and, under compat, by flat sekia.on patterns. Applies to
+// workflows loaded after the call.
+func (e *Engine) SetSubjectScheme(scheme string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subjectScheme = scheme
+}
+
+// SetEventValidation sets how incoming events whose payload does not match
+// the schema for their type are handled: protocol.ValidationWarn (default)
+// logs them, ValidationReject drops them, ValidationOff skips the check.
+func (e *Engine) SetEventValidation(mode string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.validation = mode
+}
+
+// SetHTTPConfig sets the sekia.http host allowlist and limits. Applies to
+// workflows loaded after the call.
+func (e *Engine) SetHTTPConfig(cfg HTTPConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.httpCfg = cfg
+}
+
+// SetAgentSource sets where the engine learns about registered agents:
+// workflows' sekia.command calls are linted against their commands and
+// payload schemas when they load, and commands are signed with the newest
+// signature version each agent supports.
+func (e *Engine) SetAgentSource(agents func() []protocol.AgentInfo) {
+	if agents == nil {
+		e.agents.Store(nil)
+		return
+	}
+	e.agents.Store(&agents)
+}
+
+// registeredAgents returns the registered agents, if known. It does not
+// take e.mu, so commands sent from handlers never wait on a reload or
+// shutdown holding it.
+func (e *Engine) registeredAgents() []protocol.AgentInfo {
+	agents := e.agents.Load()
+	if agents == nil {
+		return nil
+	}
+	return (*agents)()
+}
+
+// SetCommandTTL sets how long commands signed with the version 2 envelope
+// stay valid (0 = protocol.DefaultCommandTTL). Applies to workflows loaded
+// after the call.
+func (e *Engine) SetCommandTTL(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commandTTL = d
+}
+
+// SignCommand signs a command to agent the way workflows do, with the
+// newest signature version the agent supports. The dead-letter queue uses
+// it to re-sign commands it replays.
+func (e *Engine) SignCommand(cmd *protocol.Command, agent string) error {
+	e.mu.RLock()
+	key, secret, ttl := e.signingKey, e.commandSecret, e.commandTTL
+	e.mu.RUnlock()
+	version := protocol.NegotiateSignatureVersion(e.registeredAgents(), agent)
+	return protocol.SignCommandFor(cmd, key, secret, version, ttl)
+}
+
+// SetSigningKey sets the Ed25519 key commands are signed with for agents
+// that advertise protocol.SignatureV3 (nil = sign with the command secret
+// only). Applies to workflows loaded after the call.
+func (e *Engine) SetSigningKey(key *protocol.SigningKey) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.signingKey = key
+}
+
+// SetCommandPolicy sets which agents, commands and payloads each workflow
+// may send commands with. Applies to workflows loaded after the call.
+func (e *Engine) SetCommandPolicy(p *protocol.CommandPolicy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = p
+}
+
 // SetVerifyIntegrity enables or disables SHA256 manifest verification for workflow loading.
 func (e *Engine) SetVerifyIntegrity(v bool) {
 	e.mu.Lock()
@@ -201,71 +415,189 @@ func (e *Engine) SetVerifyIntegrity(v bool) {
 
 // LoadWorkflow loads a single Lua file as a workflow.
 func (e *Engine) LoadWorkflow(name, filePath string) error {
+	ws, err := e.buildWorkflow(name, filePath, nil)
+	if err != nil {
+		e.recordLoadError(name, filePath, err)
+		return err
+	}
+
+	go ws.run()
+
+	// Atomically swap the map entry — stop old workflow OUTSIDE the lock
+	// to avoid blocking handleEvent while the goroutine drains its channel.
+	e.mu.Lock()
+	old := e.workflows[name]
+	e.workflows[name] = ws
+	delete(e.loadErrors, name)
+	e.mu.Unlock()
+
+	if old != nil {
+		e.stopWorkflow(old)
+	}
+
+	ws.modCtx.logger.Info().
+		Int("handlers", len(ws.modCtx.handlers)).
+		Int("concurrency", len(ws.workers)).
+		Dur("load_time", ws.loadTime).
+		Bool("shadow", ws.shadow).
+		Msg("loaded workflow")
+
+	return nil
+}
+
+// buildWorkflow verifies and executes a workflow file in a fresh Lua VM
+// without starting it. A non-nil intercept captures outgoing publishes and
+// commands instead of sending them to NATS; otherwise shadow-mode workflows
+// record them in the engine's shadow log.
+func (e *Engine) buildWorkflow(name, filePath string, intercept func(protocol.Intent)) (*workflowState, error) {
+	start := time.Now()
 	wfLogger := e.logger.With().Str("workflow", name).Logger()
 
+	src, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", filePath, err)
+	}
+	hash := sourceHash(src)
+
+	var manifest *Manifest
 	if e.verifyIntegrity {
-		manifest, err := LoadManifest(e.dir)
+		manifest, err = LoadManifest(e.dir)
 		if err != nil {
-			return fmt.Errorf("%w: load manifest: %v", ErrIntegrityViolation, err)
+			return nil, fmt.Errorf("%w: load manifest: %v", ErrIntegrityViolation, err)
 		}
 		if manifest == nil {
-			return fmt.Errorf("%w: %s not found in %s", ErrIntegrityViolation, ManifestFilename, e.dir)
+			return nil, fmt.Errorf("%w: %s not found in %s", ErrIntegrityViolation, ManifestFilename, e.dir)
 		}
-		filename := filepath.Base(filePath)
-		if err := manifest.Verify(filename, filePath); err != nil {
-			return fmt.Errorf("%w: %v", ErrIntegrityViolation, err)
+		if err := manifest.verifyHash(filepath.Base(filePath), hash); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIntegrityViolation, err)
 		}
 		wfLogger.Debug().Msg("integrity check passed")
 	}
 
-	L := NewSandboxedState(name, wfLogger)
-	modCtx := &moduleContext{
-		name:          name,
-		nc:            e.nc,
-		logger:        wfLogger,
-		llm:           e.llm,
-		commandSecret: e.commandSecret,
-		skillsIndex:   e.skillsIndex,
-		skillResolver: e.skillResolver,
-		convoStore:    e.convoStore,
+	// Every VM of the workflow, and later reloads of unchanged source,
+	// run the same compiled chunk.
+	proto, chunk, err := e.chunks.compile(filePath, src, hash)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", filePath, err)
+	}
+	dryRun := intercept != nil
+	if chunk != nil && !dryRun {
+		// Lint new source only, so reloads do not repeat the warnings.
+		for _, issue := range lintChunk(chunk, e.registeredAgents()) {
+			wfLogger.Warn().Str("file", filepath.Base(filePath)).Int("line", issue.Line).Msg("lint: " + issue.Message)
+		}
+	}
+	shadow := !dryRun && (hasShadowDirective(src) || e.isShadowConfigured(name))
+	if shadow {
+		intercept = e.shadowRecorder(name)
+	}
+
+	// Shadow workflows keep their own state so it does not leak into the
+	// live version later; dry runs read the real state but write to memory.
+	stateStore, stateNS := e.stateStore, name
+	if shadow {
+		stateNS = "shadow:" + name
+	}
+	if dryRun {
+		stateStore = newMemoryState(e.stateStore)
 	}
-	registerSekiaModule(L, modCtx)
+	httpPolicy := newHTTPPolicy(e.httpCfg, name)
+	denied := new(atomic.Int64)
+	libs := newLibLoader(e.dir, e.chunks, manifest)
 
-	if err := L.DoFile(filePath); err != nil {
-		L.Close()
-		return fmt.Errorf("load %s: %w", filePath, err)
+	// Extra VMs run the file only to register their handlers: the
+	// top-level code's publishes, commands, timers and state writes
+	// already happened on the primary, so they are discarded.
+	newWorker := func(extra bool) (*luaWorker, error) {
+		L := NewSandboxedState(name, wfLogger)
+		modCtx := &moduleContext{
+			name:          name,
+			nc:            e.nc,
+			logger:        wfLogger,
+			llm:           e.llm,
+			commandSecret: e.commandSecret,
+			commandTTL:    e.commandTTL,
+			signingKey:    e.signingKey,
+			agents:        e.registeredAgents,
+			skillsIndex:   e.skillsIndex,
+			skillResolver: e.skillResolver,
+			convoStore:    e.convoStore,
+			state:         stateStore,
+			stateNS:       stateNS,
+			timers:        e.timers,
+			subjects:      e.subjectScheme,
+			http:          httpPolicy,
+			policy:        e.policy,
+			denied:        denied,
+			intercept:     intercept,
+			hooks:         e.hooks,
+			concurrency:   1,
+		}
+		registerSekiaModule(L, modCtx)
+		registerRequire(L, libs)
+		if extra {
+			modCtx.intercept = func(protocol.Intent) {}
+			if stateStore != nil {
+				modCtx.state = newMemoryState(stateStore)
+			}
+		}
+
+		L.Push(L.NewFunctionFromProto(proto))
+		if err := L.PCall(0, lua.MultRet, nil); err != nil {
+			L.Close()
+			if verr := libs.integrityErr(); verr != nil {
+				return nil, verr
+			}
+			return nil, fmt.Errorf("load %s: %w", filePath, err)
+		}
+		modCtx.intercept, modCtx.state = intercept, stateStore
+		return &luaWorker{L: L, modCtx: modCtx}, nil
+	}
+
+	primary, err := newWorker(false)
+	if err != nil {
+		return nil, err
+	}
+	modCtx := primary.modCtx
+
+	// Dry runs call processEvent directly, so they need only one VM. Extra
+	// VMs handle events only; schedules run on the primary.
+	workers := []*luaWorker{primary}
+	for !dryRun && len(workers) < modCtx.concurrency {
+		w, err := newWorker(true)
+		if err != nil {
+			for _, w := range workers {
+				w.L.Close()
+			}
+			return nil, err
+		}
+		workers = append(workers, w)
+	}
+	if len(workers) > 1 && len(modCtx.orderKey) > 0 {
+		for _, w := range workers {
+			w.ch = make(chan *eventMsg, workerQueueSize)
+		}
 	}
 
-	ws := &workflowState{
+	return &workflowState{
 		name:           name,
 		filePath:       filePath,
-		L:              L,
+		L:              primary.L,
 		modCtx:         modCtx,
+		workers:        workers,
 		loadedAt:       time.Now(),
+		loadTime:       time.Since(start),
 		handlerTimeout: e.handlerTimeout,
-		eventCh:        make(chan *nats.Msg, 4096),
+		eventCh:        make(chan *eventMsg, eventQueueSize),
+		quit:           make(chan struct{}),
 		done:           make(chan struct{}),
 		schedules:      modCtx.schedules,
-	}
-
-	go ws.run()
-
-	// Atomically swap the map entry — stop old workflow OUTSIDE the lock
-	// to avoid blocking handleEvent while the goroutine drains its channel.
-	e.mu.Lock()
-	old := e.workflows[name]
-	e.workflows[name] = ws
-	e.mu.Unlock()
-
-	if old != nil {
-		e.stopWorkflow(old)
-	}
-
-	wfLogger.Info().
-		Int("handlers", len(modCtx.handlers)).
-		Msg("loaded workflow")
-
-	return nil
+		crons:          modCtx.crons,
+		cronNext:       make(map[*cronEntry]time.Time),
+		libs:           libs,
+		shadow:         shadow,
+		denied:         denied,
+	}, nil
 }
 
 // UnloadWorkflow stops and removes a workflow by name.
@@ -283,118 +615,278 @@ func (e *Engine) UnloadWorkflow(name string) {
 	}
 }
 
-// handleEvent is the NATS callback for sekia.events.>. It routes events to matching workflows.
+// eventMsg is a single event delivery queued on workflow event channels.
+type eventMsg struct {
+	subject  string
+	data     []byte
+	attempts int         // delivery count (1 for core NATS deliveries)
+	ack      *ackTracker // nil for core NATS deliveries
+
+	decodeOnce sync.Once
+	decoded    map[string]any
+}
+
+// fields returns the event decoded as generic JSON for handler filters and
+// ordering keys. It is decoded once and shared by every workflow; nil if
+// the data is not a JSON object.
+func (ev *eventMsg) fields() map[string]any {
+	ev.decodeOnce.Do(func() {
+		json.Unmarshal(ev.data, &ev.decoded)
+	})
+	return ev.decoded
+}
+
+// handleEvent is the NATS callback for event subjects. It routes events to matching workflows.
 func (e *Engine) handleEvent(msg *nats.Msg) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	e.routeEvent(&eventMsg{subject: msg.Subject, data: msg.Data, attempts: 1})
+}
 
-	routed := false
+// routeEvent delivers an event to every workflow with a matching handler.
+// Core NATS deliveries are dropped when a workflow's channel is full; durable
+// deliveries block instead, applying backpressure to the JetStream consumer.
+// Blocking sends happen after e.mu is released.
+func (e *Engine) routeEvent(ev *eventMsg) {
+	e.reloadMu.RLock()
+	defer e.reloadMu.RUnlock()
+
+	e.mu.RLock()
+	if !e.checkSchema(ev) {
+		e.mu.RUnlock()
+		return
+	}
+	source := extractSource(ev.data)
+	var targets []*workflowState
 	for _, ws := range e.workflows {
 		// Self-event guard: skip events published by this workflow.
-		source := extractSource(msg.Data)
 		if source == fmt.Sprintf("workflow:%s", ws.name) {
 			continue
 		}
 
-		// Check if any handler matches this subject.
-		matched := false
-		for _, h := range ws.modCtx.handlers {
-			if SubjectMatches(h.Pattern, msg.Subject) {
-				matched = true
-				break
-			}
+		// Check if any handler wants this event. Structured filters are
+		// evaluated here so rejected events never reach the Lua VM.
+		if ws.handles(ev) {
+			targets = append(targets, ws)
 		}
-		if !matched {
+	}
+	loaded := len(e.workflows)
+	e.mu.RUnlock()
+
+	routed := false
+	for _, ws := range targets {
+		if ev.ack != nil {
+			ev.ack.add()
+			if ws = e.sendDurable(ws, ev); ws == nil {
+				continue
+			}
+			routed = true
+			e.logger.Debug().
+				Str("workflow", ws.name).
+				Str("subject", ev.subject).
+				Msg("routed event to workflow")
 			continue
 		}
 
 		// Non-blocking send to the workflow's event channel.
-		select {
-		case ws.eventCh <- msg:
+		if ws.trySend(ev) {
 			routed = true
 			e.logger.Debug().
 				Str("workflow", ws.name).
-				Str("subject", msg.Subject).
+				Str("subject", ev.subject).
 				Msg("routed event to workflow")
-		default:
+		} else {
 			ws.errors.Add(1)
+			ws.dropped.Add(1)
 			e.logger.Warn().
 				Str("workflow", ws.name).
-				Str("subject", msg.Subject).
+				Str("subject", ev.subject).
 				Msg("event channel full, dropping event")
 		}
 	}
 
 	if !routed {
 		e.logger.Debug().
-			Str("subject", msg.Subject).
-			Int("workflows", len(e.workflows)).
+			Str("subject", ev.subject).
+			Int("workflows", loaded).
 			Msg("event matched no workflows")
 	}
 }
 
-// run is the per-workflow goroutine that processes events and schedules sequentially.
+// sendDurable queues a durable event on ws, waiting for room. If ws is
+// stopped meanwhile because its workflow was reloaded, the event goes to
+// the replacement instead, so it is never acknowledged unhandled. It
+// returns the workflow the event was queued on, or nil if the workflow was
+// unloaded (the event is then redelivered) or no longer handles it. The
+// caller has added a reference to ev.ack, which is released on nil.
+func (e *Engine) sendDurable(ws *workflowState, ev *eventMsg) *workflowState {
+	for ws.send(context.Background(), ev) != nil {
+		e.mu.RLock()
+		cur := e.workflows[ws.name]
+		e.mu.RUnlock()
+		switch {
+		case cur == nil || cur == ws:
+			ev.ack.fail()
+			ev.ack.done()
+			return nil
+		case !cur.handles(ev):
+			ev.ack.done()
+			return nil
+		}
+		ws = cur
+	}
+	return ws
+}
+
+// checkSchema validates an incoming event's payload against the schema
+// registered for its type and version. It reports whether the event should
+// still be routed. Callers hold e.mu.
+func (e *Engine) checkSchema(ev *eventMsg) bool {
+	if e.validation == protocol.ValidationOff {
+		return true
+	}
+	fields := ev.fields()
+	eventType, _ := fields["type"].(string)
+	version, _ := fields["schema_version"].(float64)
+	payload, _ := fields["payload"].(map[string]any)
+	err := protocol.ValidatePayload(eventType, int(version), payload)
+	if err == nil {
+		return true
+	}
+
+	reject := e.validation == protocol.ValidationReject
+	id, _ := fields["id"].(string)
+	e.logger.Warn().
+		Err(err).
+		Str("subject", ev.subject).
+		Str("event_id", id).
+		Bool("rejected", reject).
+		Msg("event does not match its schema")
+	return !reject
+}
+
+// run is the per-workflow goroutine. It processes events and schedules on
+// the primary VM, and runs any extra workers alongside until the event
+// channel is closed.
 func (ws *workflowState) run() {
 	defer close(ws.done)
 
-	// Start schedule tickers and merge into a single channel.
-	type scheduleTick struct {
-		fn *lua.LFunction
-	}
-	scheduleCh := make(chan scheduleTick, 16)
+	// Start schedule timers and merge into a single channel. Closing stop
+	// ends the timer goroutines once the workflow is unloaded.
+	scheduleCh := make(chan *lua.LFunction, 16)
+	stop := make(chan struct{})
+	defer close(stop)
 	for i := range ws.schedules {
-		s := &ws.schedules[i]
-		s.ticker = time.NewTicker(s.Interval)
-		go func(t *time.Ticker, fn *lua.LFunction) {
-			for range t.C {
-				scheduleCh <- scheduleTick{fn: fn}
-			}
-		}(s.ticker, s.Fn)
+		go ws.runInterval(&ws.schedules[i], scheduleCh, stop)
+	}
+	for _, c := range ws.crons {
+		go ws.runCron(c, scheduleCh, stop)
 	}
 
+	events, workers := ws.runWorkers()
+	defer workers.Wait()
+
+	primary := ws.workers[0]
 	for {
 		select {
-		case msg, ok := <-ws.eventCh:
+		case ev, ok := <-events:
 			if !ok {
-				// Channel closed — stop all tickers and drain scheduleCh.
-				for i := range ws.schedules {
-					ws.schedules[i].ticker.Stop()
-				}
 				return
 			}
-			ws.processEvent(msg)
-		case tick := <-scheduleCh:
-			ws.callScheduleHandler(tick.fn)
+			ws.processEvent(primary, ev)
+		case fn := <-scheduleCh:
+			ws.callScheduleHandler(fn)
 		}
 	}
 }
 
-func (ws *workflowState) processEvent(msg *nats.Msg) {
+// runInterval fires a sekia.schedule() entry every Interval until stop is closed.
+func (ws *workflowState) runInterval(s *scheduleEntry, ticks chan<- *lua.LFunction, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	ws.setIntervalNext(s, time.Now().Add(s.Interval))
+
+	for {
+		select {
+		case t := <-ticker.C:
+			ws.setIntervalNext(s, t.Add(s.Interval))
+			select {
+			case ticks <- s.Fn:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (ws *workflowState) setIntervalNext(s *scheduleEntry, next time.Time) {
+	ws.schedMu.Lock()
+	s.next = next
+	ws.schedMu.Unlock()
+}
+
+// scheduleInfo describes the workflow's schedules and their next run times.
+func (ws *workflowState) scheduleInfo() []protocol.ScheduleInfo {
+	ws.schedMu.Lock()
+	defer ws.schedMu.Unlock()
+
+	var infos []protocol.ScheduleInfo
+	for _, s := range ws.schedules {
+		infos = append(infos, protocol.ScheduleInfo{
+			Kind:    protocol.ScheduleInterval,
+			Spec:    s.Interval.String(),
+			NextRun: s.next,
+		})
+	}
+	for _, c := range ws.crons {
+		infos = append(infos, protocol.ScheduleInfo{
+			Kind:     protocol.ScheduleCron,
+			Spec:     c.Expr,
+			Timezone: c.loc.String(),
+			NextRun:  ws.cronNext[c],
+		})
+	}
+	return infos
+}
+
+// processEvent runs every matching handler of worker w for one event.
+func (ws *workflowState) processEvent(w *luaWorker, msg *eventMsg) {
+	// Acknowledge durable deliveries only once every handler has run.
+	defer msg.ack.done()
+	ws.busy.Add(1)
+	defer ws.busy.Add(-1)
+
 	var ev protocol.Event
-	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+	if err := json.Unmarshal(msg.data, &ev); err != nil {
 		ws.errors.Add(1)
 		ws.modCtx.logger.Error().Err(err).Msg("unmarshal event")
+		ws.deadLetter(msg, "", err)
 		return
 	}
 
 	ws.modCtx.logger.Debug().
 		Str("event_type", ev.Type).
 		Str("event_id", ev.ID).
-		Str("subject", msg.Subject).
+		Str("subject", msg.subject).
 		Msg("processing event")
 
-	eventTable := EventToLua(ws.L, ev)
+	eventTable := EventToLua(w.L, ev)
+	w.modCtx.currentEventID = ev.ID
+	defer func() { w.modCtx.currentEventID = "" }()
 
-	for _, h := range ws.modCtx.handlers {
-		if !SubjectMatches(h.Pattern, msg.Subject) {
+	for _, h := range w.modCtx.handlers {
+		if !h.matches(msg) {
 			continue
 		}
-		ws.callHandler(h, ev.ID, eventTable)
+		ws.callHandler(w.L, h, msg, ev.ID, eventTable)
 	}
 	ws.events.Add(1)
 }
 
 func (ws *workflowState) callScheduleHandler(fn *lua.LFunction) {
+	ws.busy.Add(1)
+	defer ws.busy.Add(-1)
+
 	var cancel context.CancelFunc
 	if ws.handlerTimeout > 0 {
 		var ctx context.Context
@@ -420,24 +912,25 @@ func (ws *workflowState) callScheduleHandler(fn *lua.LFunction) {
 }
 
 // callHandler invokes a single Lua handler with an optional execution timeout.
-func (ws *workflowState) callHandler(h handlerEntry, eventID string, eventTable *lua.LTable) {
+// Failed invocations are recorded in the dead-letter queue.
+func (ws *workflowState) callHandler(L *lua.LState, h handlerEntry, msg *eventMsg, eventID string, eventTable *lua.LTable) {
 	var cancel context.CancelFunc
 	if ws.handlerTimeout > 0 {
 		var ctx context.Context
 		ctx, cancel = context.WithTimeout(context.Background(), ws.handlerTimeout)
-		ws.L.SetContext(ctx)
+		L.SetContext(ctx)
 	}
 
-	err := ws.L.CallByParam(lua.P{
+	err := L.CallByParam(lua.P{
 		Fn:      h.Fn,
 		NRet:    0,
 		Protect: true,
 	}, eventTable)
 
 	if cancel != nil {
-		timedOut := ws.L.Context() != nil && ws.L.Context().Err() != nil
+		timedOut := L.Context() != nil && L.Context().Err() != nil
 		cancel()
-		ws.L.SetContext(nil)
+		L.SetContext(nil)
 		if err != nil && timedOut {
 			ws.errors.Add(1)
 			ws.modCtx.logger.Error().
@@ -445,6 +938,7 @@ func (ws *workflowState) callHandler(h handlerEntry, eventID string, eventTable
 				Str("pattern", h.Pattern).
 				Str("event_id", eventID).
 				Msg("handler timed out")
+			ws.deadLetter(msg, h.Pattern, fmt.Errorf("handler timed out after %s", ws.handlerTimeout))
 			return
 		}
 	}
@@ -456,14 +950,113 @@ func (ws *workflowState) callHandler(h handlerEntry, eventID string, eventTable
 			Str("pattern", h.Pattern).
 			Str("event_id", eventID).
 			Msg("handler error")
+		ws.deadLetter(msg, h.Pattern, err)
+	}
+}
+
+// deadLetter publishes a failed event to sekia.dlq.workflow.<name>.
+// Failures of dry-run copies are not dead-lettered.
+func (ws *workflowState) deadLetter(msg *eventMsg, pattern string, cause error) {
+	if ws.dryRun {
+		if ws.modCtx.hooks != nil {
+			ws.modCtx.hooks.handlerError(pattern, cause)
+		}
+		return
+	}
+	dl := protocol.DeadLetter{
+		Kind:     protocol.DeadLetterEvent,
+		Workflow: ws.name,
+		Subject:  msg.subject,
+		Handler:  pattern,
+		Error:    cause.Error(),
+		Attempts: msg.attempts,
+		FailedAt: time.Now().UTC(),
+		Data:     msg.data,
+	}
+	if !json.Valid(msg.data) {
+		// Keep the dead letter itself decodable.
+		raw, _ := json.Marshal(string(msg.data))
+		dl.Data = raw
+	}
+	data, err := json.Marshal(dl)
+	if err != nil {
+		ws.modCtx.logger.Error().Err(err).Msg("marshal dead letter")
+		return
+	}
+	if err := ws.modCtx.nc.Publish(protocol.SubjectDLQWorkflow(ws.name), data); err != nil {
+		ws.modCtx.logger.Error().Err(err).Msg("publish dead letter")
 	}
 }
 
-// stopWorkflow closes the event channel and waits for the goroutine to finish, then closes the LState.
+// Redeliver hands an event directly to one workflow, bypassing subject
+// routing to the others. It is used to replay dead letters after a fix.
+func (e *Engine) Redeliver(workflow, subject string, data []byte) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ws, ok := e.workflows[workflow]
+	if !ok {
+		return fmt.Errorf("workflow %q is not loaded", workflow)
+	}
+	if !ws.trySend(&eventMsg{subject: subject, data: data, attempts: 1}) {
+		return fmt.Errorf("workflow %q event channel is full", workflow)
+	}
+	return nil
+}
+
+// stopWorkflow closes the event channel and waits for the goroutines to finish, then closes the LStates.
 func (e *Engine) stopWorkflow(ws *workflowState) {
+	close(ws.quit)
+	ws.sendMu.Lock()
 	close(ws.eventCh)
+	ws.sendMu.Unlock()
 	<-ws.done
-	ws.L.Close()
+	for _, w := range ws.workers {
+		w.L.Close()
+	}
+}
+
+// errWorkflowStopped is returned when sending an event to a workflow that
+// has been stopped.
+var errWorkflowStopped = errors.New("workflow stopped")
+
+// send queues an event on the workflow's channel, waiting for room until
+// ctx is done or the workflow stops. Callers must not hold e.mu, so a full
+// channel never blocks reloads and the workflow's own handlers.
+func (ws *workflowState) send(ctx context.Context, ev *eventMsg) error {
+	ws.sendMu.RLock()
+	defer ws.sendMu.RUnlock()
+	select {
+	case <-ws.quit:
+		return errWorkflowStopped
+	default:
+	}
+	select {
+	case ws.eventCh <- ev:
+		return nil
+	case <-ws.quit:
+		return errWorkflowStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trySend queues an event on the workflow's channel without waiting. It
+// reports false if the channel is full or the workflow has stopped.
+func (ws *workflowState) trySend(ev *eventMsg) bool {
+	ws.sendMu.RLock()
+	defer ws.sendMu.RUnlock()
+	select {
+	case <-ws.quit:
+		return false
+	default:
+	}
+	select {
+	case ws.eventCh <- ev:
+		return true
+	default:
+		return false
+	}
 }
 
 // extractSource does a lightweight parse of the JSON "source" field without full unmarshal.
@@ -2,12 +2,15 @@ package workflow
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
 
@@ -569,3 +572,203 @@ end)
 
 	publishAndExpect("after-reloadall")
 }
+
+func TestRouteEvent_SchemaValidation(t *testing.T) {
+	dir := t.TempDir()
+	src := `sekia.on("sekia.events.github", function(event) end)`
+	if err := os.WriteFile(filepath.Join(dir, "all.lua"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	valid, _ := json.Marshal(protocol.NewEvent("github.push", "github", map[string]any{
+		"owner": "acme", "repo": "app", "ref": "refs/heads/main", "before": "a", "after": "b",
+		"commits_count": 1, "pusher": "alice",
+	}))
+	invalid, _ := json.Marshal(protocol.NewEvent("github.push", "github", map[string]any{"owner": "acme"}))
+
+	tests := []struct {
+		mode string
+		want int // events queued for the workflow
+	}{
+		{protocol.ValidationOff, 2},
+		{protocol.ValidationWarn, 2},
+		{protocol.ValidationReject, 1},
+	}
+	for _, tt := range tests {
+		e := New(nil, dir, nil, 0, "", testLogger())
+		e.SetEventValidation(tt.mode)
+		ws, err := e.buildWorkflow("all", filepath.Join(dir, "all.lua"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.workflows["all"] = ws
+
+		e.routeEvent(&eventMsg{subject: "sekia.events.github", data: valid})
+		e.routeEvent(&eventMsg{subject: "sekia.events.github", data: invalid})
+		if n := len(ws.eventCh); n != tt.want {
+			t.Errorf("%s: %d events queued, want %d", tt.mode, n, tt.want)
+		}
+		ws.L.Close()
+	}
+}
+
+func TestRouteEvent_DurableSendReleasesLock(t *testing.T) {
+	dir := t.TempDir()
+	src := `sekia.on("sekia.events.github", function(event) end)`
+	if err := os.WriteFile(filepath.Join(dir, "all.lua"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := New(nil, dir, nil, 0, "", testLogger())
+	ws, err := e.buildWorkflow("all", filepath.Join(dir, "all.lua"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.L.Close()
+	e.workflows["all"] = ws
+	for range cap(ws.eventCh) {
+		ws.eventCh <- &eventMsg{subject: "sekia.events.github"}
+	}
+
+	// A durable delivery waits for room in the full channel...
+	tracker := &ackTracker{}
+	tracker.add()
+	routed := make(chan struct{})
+	go func() {
+		e.routeEvent(&eventMsg{subject: "sekia.events.github", data: []byte(`{}`), ack: tracker})
+		close(routed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// ...without holding the engine lock.
+	locked := make(chan struct{})
+	go func() {
+		e.mu.Lock()
+		e.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("engine lock held while waiting to deliver")
+	}
+
+	// Stopping the workflow releases the waiting delivery.
+	close(ws.quit)
+	select {
+	case <-routed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery still waiting after the workflow stopped")
+	}
+	if n := tracker.pending.Load(); n != 1 {
+		t.Errorf("pending = %d, want 1", n)
+	}
+	if !tracker.failed.Load() {
+		t.Error("event undelivered to a stopped workflow not marked for redelivery")
+	}
+}
+
+// ackRecorder is a JetStream message recording how it was acknowledged.
+type ackRecorder struct {
+	jetstream.Msg
+	onAck func()
+	nak   atomic.Bool
+}
+
+func (m *ackRecorder) Ack() error { m.onAck(); return nil }
+func (m *ackRecorder) Nak() error { m.nak.Store(true); return nil }
+
+func TestRouteEvent_DurableSendFollowsReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "all.lua")
+	src := `sekia.on("sekia.events.github", function(event) end)`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := New(nil, dir, nil, 0, "", testLogger())
+	old, err := e.buildWorkflow("all", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.workflows["all"] = old
+	for range cap(old.eventCh) {
+		old.eventCh <- &eventMsg{subject: "sekia.events.github"}
+	}
+
+	// A durable delivery waits for room in the old instance's full channel.
+	acked := make(chan int64, 1)
+	msg := &ackRecorder{onAck: func() {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		acked <- e.workflows["all"].events.Load()
+	}}
+	tracker := &ackTracker{msg: msg}
+	tracker.add()
+	routed := make(chan struct{})
+	go func() {
+		e.routeEvent(&eventMsg{
+			subject: "sekia.events.github",
+			data:    []byte(`{"id":"ev-1","type":"github.push","source":"github"}`),
+			ack:     tracker,
+		})
+		tracker.done()
+		close(routed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Reloading the workflow hands the event to the replacement, which
+	// handles it before it is acknowledged.
+	loaded := make(chan error, 1)
+	go func() { loaded <- e.LoadWorkflow("all", path) }()
+	select {
+	case <-routed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery still waiting after the workflow was reloaded")
+	}
+	select {
+	case n := <-acked:
+		if n != 1 {
+			t.Errorf("replacement handled %d events before the ack, want 1", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not acknowledged")
+	}
+	if msg.nak.Load() {
+		t.Error("event negatively acknowledged")
+	}
+
+	close(old.done) // old was never started
+	if err := <-loaded; err != nil {
+		t.Fatal(err)
+	}
+	e.UnloadWorkflow("all")
+}
+
+func TestEngine_SignCommandWhileLocked(t *testing.T) {
+	e := New(nil, t.TempDir(), nil, 0, "secret", testLogger())
+	e.SetAgentSource(func() []protocol.AgentInfo {
+		return []protocol.AgentInfo{{Name: "github-agent", SignatureVersion: protocol.SignatureV2}}
+	})
+
+	// A reload waiting on a handler holds e.mu; the handler's commands
+	// must still be signed.
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ctx := &moduleContext{name: "test", commandSecret: "secret", agents: e.registeredAgents}
+	done := make(chan error, 1)
+	go func() {
+		cmd := protocol.Command{Command: "add_label", Source: "workflow:test"}
+		err := ctx.signCommand(&cmd, "github-agent")
+		if err == nil && cmd.SigVersion != protocol.SignatureV2 {
+			err = fmt.Errorf("signed with version %d, want 2", cmd.SigVersion)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("signCommand: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("signCommand blocked on the engine lock")
+	}
+}
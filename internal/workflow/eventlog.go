@@ -0,0 +1,120 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// DurableConsumer is the name of the JetStream consumer the engine uses on
+// the SEKIA_EVENTS stream. Its ack floor survives daemon restarts.
+const DurableConsumer = "sekia-workflows"
+
+// maxAckPending bounds how many events may be in flight (delivered but not
+// yet acknowledged) across all workflows.
+const maxAckPending = 1024
+
+// SetEventLog switches the engine to consume events from the durable
+// SEKIA_EVENTS stream. ackWait bounds how long an event may stay
+// unacknowledged before JetStream redelivers it (0 = server default).
+// Must be called before Start.
+func (e *Engine) SetEventLog(js jetstream.JetStream, ackWait time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.js = js
+	e.ackWait = ackWait
+}
+
+// startEventLog creates (or resumes) the durable consumer and starts consuming.
+func (e *Engine) startEventLog() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cons, err := e.js.CreateOrUpdateConsumer(ctx, protocol.StreamEvents, jetstream.ConsumerConfig{
+		Durable:       DurableConsumer,
+		Description:   "sekia workflow engine",
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       e.ackWait,
+		MaxAckPending: maxAckPending,
+	})
+	if err != nil {
+		return fmt.Errorf("create event consumer: %w", err)
+	}
+
+	cc, err := cons.Consume(e.handleLogEvent)
+	if err != nil {
+		return fmt.Errorf("consume events: %w", err)
+	}
+	e.consumer = cc
+	return nil
+}
+
+// stopEventLog stops the durable consumer and waits for the in-flight
+// callback to return. Unacknowledged events are redelivered on next start.
+func (e *Engine) stopEventLog() {
+	if e.consumer == nil {
+		return
+	}
+	e.consumer.Stop()
+	select {
+	case <-e.consumer.Closed():
+	case <-time.After(5 * time.Second):
+		e.logger.Warn().Msg("timed out waiting for event consumer to stop")
+	}
+}
+
+// handleLogEvent is the JetStream callback for the durable consumer.
+func (e *Engine) handleLogEvent(msg jetstream.Msg) {
+	if e.stopping.Load() {
+		// Leave the event unacknowledged so it is redelivered after restart.
+		return
+	}
+
+	attempts := 1
+	if meta, err := msg.Metadata(); err == nil {
+		attempts = int(meta.NumDelivered)
+	}
+
+	tracker := &ackTracker{msg: msg}
+	tracker.add() // held by the router until routing completes
+	e.routeEvent(&eventMsg{subject: msg.Subject(), data: msg.Data(), attempts: attempts, ack: tracker})
+	tracker.done()
+}
+
+// ackTracker acknowledges a JetStream message once every workflow it was
+// routed to has finished running its handlers. If a delivery failed, the
+// message is negatively acknowledged instead, so JetStream redelivers it.
+type ackTracker struct {
+	msg     jetstream.Msg
+	pending atomic.Int32
+	failed  atomic.Bool
+}
+
+func (a *ackTracker) add() {
+	a.pending.Add(1)
+}
+
+// done releases one reference. Safe to call on a nil tracker (core NATS).
+func (a *ackTracker) done() {
+	if a == nil {
+		return
+	}
+	if a.pending.Add(-1) == 0 {
+		if a.failed.Load() {
+			a.msg.Nak()
+		} else {
+			a.msg.Ack()
+		}
+	}
+}
+
+// fail marks the message for redelivery once every reference is released.
+func (a *ackTracker) fail() {
+	a.failed.Store(true)
+}
@@ -0,0 +1,229 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// startTestJetStream starts an in-process NATS server with JetStream and
+// creates the SEKIA_EVENTS stream.
+func startTestJetStream(t *testing.T) (*nats.Conn, jetstream.JetStream) {
+	t.Helper()
+
+	opts := &server.Options{
+		NoLog:      true,
+		NoSigs:     true,
+		DontListen: true,
+		JetStream:  true,
+		StoreDir:   t.TempDir(),
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	nc, err := nats.Connect("", nats.InProcessServer(ns))
+	if err != nil {
+		ns.Shutdown()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+	})
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     protocol.StreamEvents,
+		Subjects: []string{"sekia.events.>", "sekia.results.>"},
+		Storage:  jetstream.MemoryStorage,
+	}); err != nil {
+		t.Fatalf("create stream: %v", err)
+	}
+
+	return nc, js
+}
+
+func TestEngine_EventLogSurvivesRestart(t *testing.T) {
+	nc, js := startTestJetStream(t)
+
+	tmpDir := t.TempDir()
+	workflowCode := `
+sekia.on("sekia.events.test", function(event)
+	sekia.command("durable-agent", "handle", { n = event.payload.n })
+end)
+`
+	wfPath := filepath.Join(tmpDir, "durable.lua")
+	os.WriteFile(wfPath, []byte(workflowCode), 0644)
+
+	received := make(chan map[string]any, 10)
+	sub, err := nc.Subscribe("sekia.commands.durable-agent", func(msg *nats.Msg) {
+		var cmd protocol.Command
+		json.Unmarshal(msg.Data, &cmd)
+		received <- cmd.Payload
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	publish := func(n int) {
+		t.Helper()
+		ev := protocol.NewEvent("test.event", "external", map[string]any{"n": n})
+		data, _ := json.Marshal(ev)
+		if err := nc.Publish("sekia.events.test", data); err != nil {
+			t.Fatal(err)
+		}
+		nc.Flush()
+	}
+	expect := func(n int) {
+		t.Helper()
+		select {
+		case payload := <-received:
+			if payload["n"] != float64(n) {
+				t.Errorf("n = %v, want %d", payload["n"], n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for command %d", n)
+		}
+	}
+
+	startEngine := func() *Engine {
+		t.Helper()
+		eng := New(nc, tmpDir, nil, 0, "", testLogger())
+		eng.SetEventLog(js, 0)
+		if err := eng.LoadWorkflow("durable", wfPath); err != nil {
+			t.Fatalf("load workflow: %v", err)
+		}
+		if err := eng.Start(); err != nil {
+			t.Fatalf("engine start: %v", err)
+		}
+		return eng
+	}
+
+	eng := startEngine()
+	publish(1)
+	expect(1)
+	eng.Stop()
+
+	// Published while no engine is running — must be delivered after restart.
+	publish(2)
+
+	eng = startEngine()
+	defer eng.Stop()
+	expect(2)
+
+	// Everything has been acknowledged: nothing left pending on the consumer.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cons, err := js.Consumer(ctx, protocol.StreamEvents, DurableConsumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := cons.Info(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.NumAckPending == 0 && info.NumPending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("consumer still has ack_pending=%d pending=%d", info.NumAckPending, info.NumPending)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestEngine_ReplayDryRun(t *testing.T) {
+	nc, js := startTestJetStream(t)
+
+	tmpDir := t.TempDir()
+	workflowCode := `
+sekia.on("sekia.events.github", function(event)
+	sekia.command("github-agent", "add_label", { number = event.payload.n, label = "triage" })
+end)
+`
+	wfPath := filepath.Join(tmpDir, "labeler.lua")
+	os.WriteFile(wfPath, []byte(workflowCode), 0644)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i, subj := range []string{"sekia.events.github", "sekia.events.slack", "sekia.events.github"} {
+		ev := protocol.NewEvent("test.event", "external", map[string]any{"n": i})
+		data, _ := json.Marshal(ev)
+		if _, err := js.Publish(ctx, subj, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sent atomic.Int32
+	sub, err := nc.Subscribe("sekia.commands.github-agent", func(*nats.Msg) { sent.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	eng := New(nc, tmpDir, nil, 0, "", testLogger())
+	eng.SetEventLog(js, 0)
+	if err := eng.LoadWorkflow("labeler", wfPath); err != nil {
+		t.Fatalf("load workflow: %v", err)
+	}
+
+	res, err := eng.Replay(ctx, "labeler", ReplayOptions{
+		Since:  time.Now().Add(-time.Hour),
+		DryRun: true,
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if res.Events != 2 {
+		t.Errorf("events = %d, want 2", res.Events)
+	}
+	if len(res.Intents) != 2 {
+		t.Fatalf("intents = %d, want 2", len(res.Intents))
+	}
+	for i, in := range res.Intents {
+		if in.Kind != protocol.IntentCommand || in.Agent != "github-agent" || in.Command != "add_label" {
+			t.Errorf("intent %d = %+v", i, in)
+		}
+		if in.EventID == "" {
+			t.Errorf("intent %d has no event ID", i)
+		}
+	}
+	if n := res.Intents[1].Payload["number"]; n != float64(2) {
+		t.Errorf("second intent number = %v, want 2", n)
+	}
+
+	nc.Flush()
+	time.Sleep(100 * time.Millisecond)
+	if n := sent.Load(); n != 0 {
+		t.Errorf("dry run sent %d commands, want 0", n)
+	}
+
+	if _, err := eng.Replay(ctx, "missing", ReplayOptions{Since: time.Now()}); !errors.Is(err, ErrWorkflowNotLoaded) {
+		t.Errorf("replay of unknown workflow: err = %v, want ErrWorkflowNotLoaded", err)
+	}
+}
@@ -0,0 +1,332 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// eventFilter is the structured part of a sekia.on() table, evaluated in Go
+// before an event reaches the Lua VM:
+//
+//	sekia.on({
+//	    subject = "sekia.events.github",
+//	    type    = "github.issue.*",
+//	    where   = {
+//	        ["payload.repo"]   = "acme/app",
+//	        ["payload.labels"] = { contains = "bug" },
+//	    },
+//	}, handler)
+//
+// type and source are globs (protocol.MatchGlob syntax) or lists of globs. Every
+// where condition must hold.
+type eventFilter struct {
+	types   []string
+	sources []string
+	where   []condition // sorted by path, then op
+}
+
+// condition is one operator applied to the value at a dotted event path.
+type condition struct {
+	path  string
+	op    string
+	value any // []any for "in"
+}
+
+// filterOps are the operators accepted in a where condition table. A bare
+// value is shorthand for eq.
+var filterOps = map[string]bool{
+	"eq": true, "ne": true, "in": true, "contains": true, "prefix": true,
+	"match": true, "exists": true, "gt": true, "gte": true, "lt": true, "lte": true,
+}
+
+// checkFilterTable parses the table form of sekia.on's first argument into
+// a subject pattern and filter, raising a Lua argument error on bad input.
+func checkFilterTable(L *lua.LState, n int, tbl *lua.LTable) (string, *eventFilter) {
+	pattern := ">"
+	f := &eventFilter{}
+
+	tbl.ForEach(func(k, v lua.LValue) {
+		key, ok := k.(lua.LString)
+		if !ok {
+			L.ArgError(n, "filter keys must be strings")
+		}
+		switch key {
+		case "subject":
+			s, ok := v.(lua.LString)
+			if !ok || s == "" {
+				L.ArgError(n, "subject must be a non-empty string")
+			}
+			pattern = string(s)
+		case "type":
+			f.types = checkGlobs(L, n, "type", v)
+		case "source":
+			f.sources = checkGlobs(L, n, "source", v)
+		case "where":
+			where, ok := v.(*lua.LTable)
+			if !ok {
+				L.ArgError(n, "where must be a table")
+			}
+			f.where = checkConditions(L, n, where)
+		default:
+			L.ArgError(n, fmt.Sprintf("unknown filter key %q (want subject, type, source or where)", string(key)))
+		}
+	})
+
+	if len(f.types) == 0 && len(f.sources) == 0 && len(f.where) == 0 {
+		return pattern, nil
+	}
+	return pattern, f
+}
+
+// checkGlobs reads a glob or list of globs.
+func checkGlobs(L *lua.LState, n int, name string, v lua.LValue) []string {
+	var globs []string
+	switch v := v.(type) {
+	case lua.LString:
+		globs = []string{string(v)}
+	case *lua.LTable:
+		v.ForEach(func(_, g lua.LValue) {
+			globs = append(globs, g.String())
+		})
+	default:
+		L.ArgError(n, fmt.Sprintf("%s must be a string or a list of strings", name))
+	}
+	for _, g := range globs {
+		if err := protocol.CheckGlob(g); err != nil {
+			L.ArgError(n, fmt.Sprintf("invalid %s pattern %q", name, g))
+		}
+	}
+	return globs
+}
+
+// checkConditions reads a where table: path -> value or {op = value, ...}.
+func checkConditions(L *lua.LState, n int, where *lua.LTable) []condition {
+	var conds []condition
+	where.ForEach(func(k, v lua.LValue) {
+		p, ok := k.(lua.LString)
+		if !ok || p == "" {
+			L.ArgError(n, "where keys must be event paths such as \"payload.repo\"")
+		}
+		ops, isTable := v.(*lua.LTable)
+		if !isTable {
+			conds = append(conds, condition{path: string(p), op: "eq", value: LuaToGo(v)})
+			return
+		}
+		ops.ForEach(func(opKey, opVal lua.LValue) {
+			op, ok := opKey.(lua.LString)
+			if !ok || !filterOps[string(op)] {
+				L.ArgError(n, fmt.Sprintf("where[%q]: unknown operator %s (use {in = {...}} to match a list of values)", string(p), opKey.String()))
+			}
+			c := condition{path: string(p), op: string(op), value: LuaToGo(opVal)}
+			switch c.op {
+			case "in":
+				if _, ok := c.value.([]any); !ok {
+					L.ArgError(n, fmt.Sprintf("where[%q]: in needs a list", string(p)))
+				}
+			case "exists":
+				if _, ok := c.value.(bool); !ok {
+					L.ArgError(n, fmt.Sprintf("where[%q]: exists needs true or false", string(p)))
+				}
+			case "prefix", "match":
+				s, ok := c.value.(string)
+				if !ok {
+					L.ArgError(n, fmt.Sprintf("where[%q]: %s needs a string", string(p), c.op))
+				}
+				if err := protocol.CheckGlob(s); c.op == "match" && err != nil {
+					L.ArgError(n, fmt.Sprintf("where[%q]: invalid pattern %q", string(p), s))
+				}
+			case "gt", "gte", "lt", "lte":
+				switch c.value.(type) {
+				case float64, string:
+				default:
+					L.ArgError(n, fmt.Sprintf("where[%q]: %s needs a number or string", string(p), c.op))
+				}
+			}
+			conds = append(conds, c)
+		})
+	})
+	sort.Slice(conds, func(i, j int) bool {
+		if conds[i].path != conds[j].path {
+			return conds[i].path < conds[j].path
+		}
+		return conds[i].op < conds[j].op
+	})
+	return conds
+}
+
+// matches reports whether the decoded event satisfies the filter.
+func (f *eventFilter) matches(ev map[string]any) bool {
+	if len(f.types) > 0 && !matchesGlob(f.types, ev["type"]) {
+		return false
+	}
+	if len(f.sources) > 0 && !matchesGlob(f.sources, ev["source"]) {
+		return false
+	}
+	for _, c := range f.where {
+		v, ok := protocol.LookupPath(ev, c.path)
+		if !c.holds(v, ok) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesGlob(globs []string, v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	for _, g := range globs {
+		if protocol.MatchGlob(g, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// holds applies the condition to the value found at its path.
+func (c condition) holds(v any, found bool) bool {
+	switch c.op {
+	case "exists":
+		return found == c.value.(bool)
+	case "ne":
+		return !found || !valuesEqual(v, c.value)
+	}
+	if !found {
+		return false
+	}
+
+	switch c.op {
+	case "eq":
+		return valuesEqual(v, c.value)
+	case "in":
+		for _, want := range c.value.([]any) {
+			if valuesEqual(v, want) {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		switch v := v.(type) {
+		case []any:
+			for _, el := range v {
+				if valuesEqual(el, c.value) {
+					return true
+				}
+			}
+		case string:
+			s, ok := c.value.(string)
+			return ok && strings.Contains(v, s)
+		}
+		return false
+	case "prefix":
+		s, ok := v.(string)
+		return ok && strings.HasPrefix(s, c.value.(string))
+	case "match":
+		s, ok := v.(string)
+		return ok && protocol.MatchGlob(c.value.(string), s)
+	case "gt", "gte", "lt", "lte":
+		cmp, ok := compareValues(v, c.value)
+		if !ok {
+			return false
+		}
+		switch c.op {
+		case "gt":
+			return cmp > 0
+		case "gte":
+			return cmp >= 0
+		case "lt":
+			return cmp < 0
+		default:
+			return cmp <= 0
+		}
+	}
+	return false
+}
+
+// valuesEqual compares decoded JSON values with Lua-converted values. Both
+// sides use float64 for numbers.
+func valuesEqual(a, b any) bool {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case float64:
+		b, ok := b.(float64)
+		return ok && a == b
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	}
+	return false
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+// String describes the filter for the API and dashboard, e.g.
+// `type=github.issue.* payload.labels contains "bug"`.
+func (f *eventFilter) String() string {
+	var parts []string
+	if len(f.types) > 0 {
+		parts = append(parts, "type="+strings.Join(f.types, "|"))
+	}
+	if len(f.sources) > 0 {
+		parts = append(parts, "source="+strings.Join(f.sources, "|"))
+	}
+	for _, c := range f.where {
+		switch c.op {
+		case "eq":
+			parts = append(parts, c.path+"="+formatFilterValue(c.value))
+		case "ne":
+			parts = append(parts, c.path+"!="+formatFilterValue(c.value))
+		default:
+			parts = append(parts, c.path+" "+c.op+" "+formatFilterValue(c.value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatFilterValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		vals := make([]string, len(v))
+		for i, el := range v {
+			vals[i] = formatFilterValue(el)
+		}
+		return "[" + strings.Join(vals, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// registerFilter runs sekia.on(<filter>, fn) and returns the handler entry.
+func registerFilter(t *testing.T, filter string) (handlerEntry, error) {
+	t.Helper()
+	L := NewSandboxedState("test-wf", testLogger())
+	t.Cleanup(L.Close)
+	ctx := &moduleContext{name: "test-wf", logger: testLogger()}
+	registerSekiaModule(L, ctx)
+	if err := L.DoString(`sekia.on(` + filter + `, function(event) end)`); err != nil {
+		return handlerEntry{}, err
+	}
+	return ctx.handlers[0], nil
+}
+
+func TestEventFilter_Matches(t *testing.T) {
+	issue := `{"id":"e1","type":"github.issue.opened","source":"github","payload":{"repo":"acme/app","number":7,"labels":["bug","p1"],"title":"Crash on start","draft":false}}`
+
+	tests := []struct {
+		filter string
+		event  string
+		want   bool
+	}{
+		{`{ subject = "sekia.events.github" }`, issue, true},
+		{`{ type = "github.issue.*" }`, issue, true},
+		{`{ type = "github.pr.*" }`, issue, false},
+		{`{ type = { "github.pr.*", "github.issue.opened" } }`, issue, true},
+		{`{ source = "git*" }`, issue, true},
+		{`{ where = { ["payload.repo"] = "acme/app" } }`, issue, true},
+		{`{ where = { ["payload.repo"] = "acme/other" } }`, issue, false},
+		{`{ where = { ["payload.number"] = 7 } }`, issue, true},
+		{`{ where = { ["payload.draft"] = false } }`, issue, true},
+		{`{ where = { ["payload.labels"] = { contains = "bug" } } }`, issue, true},
+		{`{ where = { ["payload.labels"] = { contains = "wontfix" } } }`, issue, false},
+		{`{ where = { ["payload.title"] = { contains = "Crash" } } }`, issue, true},
+		{`{ where = { ["payload.repo"] = { prefix = "acme/" } } }`, issue, true},
+		{`{ where = { ["payload.repo"] = { match = "*/app" } } }`, issue, true},
+		{`{ where = { ["payload.repo"] = { ["in"] = { "acme/web", "acme/app" } } } }`, issue, true},
+		{`{ where = { ["payload.repo"] = { ne = "acme/app" } } }`, issue, false},
+		{`{ where = { ["payload.missing"] = { ne = "x" } } }`, issue, true},
+		{`{ where = { ["payload.number"] = { gte = 5, lt = 10 } } }`, issue, true},
+		{`{ where = { ["payload.number"] = { gt = 7 } } }`, issue, false},
+		{`{ where = { ["payload.assignee"] = { exists = false } } }`, issue, true},
+		{`{ where = { ["payload.assignee"] = { exists = true } } }`, issue, false},
+		{`{ where = { ["payload.repo.name"] = "acme" } }`, issue, false},
+		// Every condition must hold.
+		{`{ type = "github.issue.*", where = { ["payload.repo"] = "acme/app", ["payload.labels"] = { contains = "p2" } } }`, issue, false},
+		// Unparseable events never match a filter.
+		{`{ type = "*" }`, `not json`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			h, err := registerFilter(t, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ev := &eventMsg{subject: "sekia.events.github", data: []byte(tt.event)}
+			if got := h.matches(ev); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventFilter_SubjectOnlyTable(t *testing.T) {
+	h, err := registerFilter(t, `{ subject = "sekia.events.slack" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Pattern != "sekia.events.slack" || h.Filter != nil {
+		t.Errorf("handler = %+v, want plain subject pattern", h)
+	}
+	if h, _ := registerFilter(t, `{ type = "x" }`); h.Pattern != ">" {
+		t.Errorf("default pattern = %q, want >", h.Pattern)
+	}
+}
+
+func TestEventFilter_Invalid(t *testing.T) {
+	for _, filter := range []string{
+		`{ subjects = "sekia.events.github" }`,
+		`{ type = 5 }`,
+		`{ type = "[" }`,
+		`{ where = "payload.repo" }`,
+		`{ where = { ["payload.labels"] = { "bug" } } }`,
+		`{ where = { ["payload.labels"] = { has = "bug" } } }`,
+		`{ where = { ["payload.repo"] = { ["in"] = "acme/app" } } }`,
+		`{ where = { ["payload.repo"] = { exists = "yes" } } }`,
+		`{ where = { ["payload.number"] = { gt = true } } }`,
+	} {
+		if _, err := registerFilter(t, filter); err == nil {
+			t.Errorf("%s: expected error", filter)
+		}
+	}
+}
+
+func TestEventFilter_String(t *testing.T) {
+	h, err := registerFilter(t, `{ type = "github.issue.*", where = { ["payload.repo"] = "acme/app", ["payload.labels"] = { contains = "bug" }, ["payload.number"] = { ["in"] = { 1, 2 } } } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `type=github.issue.* payload.labels contains "bug" payload.number in [1, 2] payload.repo="acme/app"`
+	if got := h.Filter.String(); got != want {
+		t.Errorf("String() = %s\nwant       %s", got, want)
+	}
+}
+
+func TestRouteEvent_Filters(t *testing.T) {
+	dir := t.TempDir()
+	src := `
+		sekia.on({ subject = "sekia.events.github", type = "github.issue.*", where = { ["payload.repo"] = "acme/app" } }, function(event) end)
+	`
+	if err := os.WriteFile(filepath.Join(dir, "issues.lua"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := New(nil, dir, nil, 0, "", testLogger())
+	ws, err := e.buildWorkflow("issues", filepath.Join(dir, "issues.lua"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.L.Close()
+	e.workflows["issues"] = ws
+
+	e.routeEvent(&eventMsg{subject: "sekia.events.github", data: []byte(`{"type":"github.pr.opened","payload":{"repo":"acme/app"}}`)})
+	e.routeEvent(&eventMsg{subject: "sekia.events.github", data: []byte(`{"type":"github.issue.opened","payload":{"repo":"acme/web"}}`)})
+	if n := len(ws.eventCh); n != 0 {
+		t.Fatalf("%d non-matching events queued", n)
+	}
+	e.routeEvent(&eventMsg{subject: "sekia.events.github", data: []byte(`{"type":"github.issue.opened","payload":{"repo":"acme/app"}}`)})
+	if n := len(ws.eventCh); n != 1 {
+		t.Fatalf("matching event not queued (queue %d)", n)
+	}
+
+	infos := e.Workflows()
+	if len(infos) != 1 || len(infos[0].Filters) != 1 || !strings.HasPrefix(infos[0].Filters[0], "sekia.events.github type=github.issue.*") {
+		t.Errorf("Filters = %v", infos[0].Filters)
+	}
+}
@@ -0,0 +1,438 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// LintIssue is a problem Lint found in a workflow.
+type LintIssue struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// arity is the number of arguments a sekia function accepts.
+type arity struct{ min, max int }
+
+// sekiaFuncs lists the functions of the sekia module by their path below it.
+var sekiaFuncs = map[string]arity{
+	"on":           {2, 2},
+	"publish":      {3, 3},
+	"command":      {3, 3},
+	"command_sync": {3, 4},
+	"log":          {2, 2},
+	"ai":           {1, 2},
+	"ai_json":      {1, 2},
+	"skill":        {1, 1},
+	"conversation": {2, 3},
+	"schedule":     {2, 2},
+	"cron":         {2, 3},
+	"concurrency":  {1, 2},
+	"after":        {4, 4},
+	"at":           {4, 4},
+	"cancel":       {1, 1},
+	"state.get":    {1, 1},
+	"state.set":    {2, 3},
+	"state.delete": {1, 1},
+	"state.incr":   {1, 3},
+	"state.cas":    {3, 4},
+	"http.request": {1, 1},
+}
+
+// sekiaFields are the other names defined in the sekia module.
+var sekiaFields = []string{"name", "state", "http"}
+
+// Lint parses a workflow and reports likely mistakes without running it:
+// calls to sekia functions that do not exist or with the wrong number of
+// arguments, and handler subject patterns that can never match. Calls to
+// sekia.command and sekia.command_sync naming an agent in agents are checked
+// against its registered commands and their payload schemas; other agent
+// names are reported only if they look like a typo of a registered one. A
+// source that does not parse is returned as the error.
+func Lint(name string, src []byte, agents []protocol.AgentInfo) ([]LintIssue, error) {
+	chunk, err := parse.Parse(bytes.NewReader(src), name)
+	if err != nil {
+		return nil, err
+	}
+	return lintChunk(chunk, agents), nil
+}
+
+// lintChunk lints a parsed workflow.
+func lintChunk(chunk []ast.Stmt, agents []protocol.AgentInfo) []LintIssue {
+	l := &linter{agents: make(map[string]protocol.AgentInfo, len(agents))}
+	for _, a := range agents {
+		l.agents[a.Name] = a
+	}
+	l.stmts(chunk)
+	sort.SliceStable(l.issues, func(i, j int) bool { return l.issues[i].Line < l.issues[j].Line })
+	return l.issues
+}
+
+type linter struct {
+	agents map[string]protocol.AgentInfo
+	issues []LintIssue
+}
+
+func (l *linter) report(line int, format string, args ...any) {
+	l.issues = append(l.issues, LintIssue{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) stmts(stmts []ast.Stmt) {
+	for _, s := range stmts {
+		l.stmt(s)
+	}
+}
+
+func (l *linter) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.AssignStmt:
+		for _, e := range s.Lhs {
+			// Assigning to a field is not a lookup of it.
+			if get, ok := e.(*ast.AttrGetExpr); ok {
+				l.expr(get.Object)
+				l.expr(get.Key)
+				continue
+			}
+			l.expr(e)
+		}
+		l.exprs(s.Rhs)
+	case *ast.LocalAssignStmt:
+		l.exprs(s.Exprs)
+	case *ast.FuncCallStmt:
+		l.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		l.stmts(s.Stmts)
+	case *ast.WhileStmt:
+		l.expr(s.Condition)
+		l.stmts(s.Stmts)
+	case *ast.RepeatStmt:
+		l.stmts(s.Stmts)
+		l.expr(s.Condition)
+	case *ast.IfStmt:
+		l.expr(s.Condition)
+		l.stmts(s.Then)
+		l.stmts(s.Else)
+	case *ast.NumberForStmt:
+		l.exprs([]ast.Expr{s.Init, s.Limit, s.Step})
+		l.stmts(s.Stmts)
+	case *ast.GenericForStmt:
+		l.exprs(s.Exprs)
+		l.stmts(s.Stmts)
+	case *ast.FuncDefStmt:
+		l.stmts(s.Func.Stmts)
+	case *ast.ReturnStmt:
+		l.exprs(s.Exprs)
+	}
+}
+
+func (l *linter) exprs(exprs []ast.Expr) {
+	for _, e := range exprs {
+		l.expr(e)
+	}
+}
+
+func (l *linter) expr(e ast.Expr) {
+	switch e := e.(type) {
+	case nil:
+	case *ast.AttrGetExpr:
+		if path, ok := sekiaPath(e); ok {
+			// The whole chain is one name; do not report its prefixes.
+			if _, fn := sekiaFuncs[path]; !fn && !slices.Contains(sekiaFields, path) {
+				l.report(e.Line(), "unknown function sekia.%s%s", path, suggest(path, sekiaNames()))
+			}
+			return
+		}
+		l.expr(e.Object)
+		l.expr(e.Key)
+	case *ast.FuncCallExpr:
+		l.call(e)
+		l.expr(e.Func)
+		l.expr(e.Receiver)
+		l.exprs(e.Args)
+	case *ast.TableExpr:
+		for _, f := range e.Fields {
+			l.expr(f.Key)
+			l.expr(f.Value)
+		}
+	case *ast.LogicalOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.RelationalOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.StringConcatOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.ArithmeticOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.UnaryMinusOpExpr:
+		l.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		l.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		l.expr(e.Expr)
+	case *ast.FunctionExpr:
+		l.stmts(e.Stmts)
+	}
+}
+
+// call checks a call to a sekia function.
+func (l *linter) call(e *ast.FuncCallExpr) {
+	get, ok := e.Func.(*ast.AttrGetExpr)
+	if !ok || e.Method != "" {
+		return
+	}
+	path, ok := sekiaPath(get)
+	if !ok {
+		return
+	}
+	want, ok := sekiaFuncs[path]
+	if !ok {
+		return // reported by expr
+	}
+
+	// A call or ... as the last argument may expand to any number of values.
+	n, open := len(e.Args), false
+	if n > 0 {
+		switch last := e.Args[n-1].(type) {
+		case *ast.FuncCallExpr:
+			open = !last.AdjustRet
+		case *ast.Comma3Expr:
+			open = !last.AdjustRet
+		}
+		if open {
+			n--
+		}
+	}
+	if n > want.max || (!open && n < want.min) {
+		l.report(e.Line(), "sekia.%s expects %s, got %d", path, want, len(e.Args))
+		return
+	}
+
+	switch path {
+	case "on":
+		l.handlerPattern(e)
+	case "command", "command_sync":
+		l.command(path, e)
+	}
+}
+
+func (a arity) String() string {
+	if a.min == a.max {
+		if a.min == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", a.min)
+	}
+	return fmt.Sprintf("%d to %d arguments", a.min, a.max)
+}
+
+// handlerPattern checks the subject pattern of sekia.on, given as a string
+// or as the subject of a filter table.
+func (l *linter) handlerPattern(e *ast.FuncCallExpr) {
+	var pattern *ast.StringExpr
+	switch arg := e.Args[0].(type) {
+	case *ast.StringExpr:
+		pattern = arg
+	case *ast.TableExpr:
+		for _, f := range arg.Fields {
+			if key, ok := f.Key.(*ast.StringExpr); ok && key.Value == "subject" {
+				pattern, _ = f.Value.(*ast.StringExpr)
+			}
+		}
+	}
+	if pattern == nil {
+		return
+	}
+	if err := checkSubjectPattern(pattern.Value); err != nil {
+		l.report(e.Line(), "sekia.on: %v", err)
+		return
+	}
+	for _, s := range eventSubjects {
+		if subjectsOverlap(pattern.Value, s) {
+			return
+		}
+	}
+	l.report(e.Line(), "sekia.on: pattern %q never matches; workflows receive only %s", pattern.Value, strings.Join(eventSubjects, " and "))
+}
+
+// command checks the agent, command name and payload of sekia.command and
+// sekia.command_sync against the agents' registrations.
+func (l *linter) command(fn string, e *ast.FuncCallExpr) {
+	if len(e.Args) < 2 {
+		return
+	}
+	agentArg, ok := e.Args[0].(*ast.StringExpr)
+	if !ok {
+		return
+	}
+	info, ok := l.agents[agentArg.Value]
+	if !ok {
+		names := make([]string, 0, len(l.agents))
+		for name := range l.agents {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if hint := suggest(agentArg.Value, names); hint != "" {
+			l.report(e.Line(), "sekia.%s: unknown agent %q%s", fn, agentArg.Value, hint)
+		}
+		return
+	}
+	cmdArg, ok := e.Args[1].(*ast.StringExpr)
+	if !ok || len(info.Commands) == 0 {
+		return
+	}
+	if !slices.Contains(info.Commands, cmdArg.Value) {
+		l.report(e.Line(), "sekia.%s: agent %q has no command %q%s", fn, info.Name, cmdArg.Value, suggest(cmdArg.Value, info.Commands))
+		return
+	}
+
+	schema := info.CommandSchemas[cmdArg.Value]
+	if schema == nil || len(e.Args) < 3 {
+		return
+	}
+	keys, ok := literalKeys(e.Args[2])
+	if !ok {
+		return
+	}
+	var unknown []string
+	for key := range keys {
+		if _, ok := schema.Properties[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	for _, field := range schema.Required {
+		if keys[field] {
+			continue
+		}
+		msg := fmt.Sprintf("sekia.%s %s %s: payload is missing required field %q", fn, info.Name, cmdArg.Value, field)
+		for _, key := range unknown {
+			if suggest(key, []string{field}) != "" {
+				msg += fmt.Sprintf(" (found %q)", key)
+			}
+		}
+		l.report(e.Line(), "%s", msg)
+	}
+}
+
+// literalKeys returns the keys of a table constructor, if it is one whose
+// keys are all constant strings.
+func literalKeys(e ast.Expr) (map[string]bool, bool) {
+	tbl, ok := e.(*ast.TableExpr)
+	if !ok {
+		return nil, false
+	}
+	keys := make(map[string]bool, len(tbl.Fields))
+	for _, f := range tbl.Fields {
+		key, ok := f.Key.(*ast.StringExpr)
+		if !ok {
+			return nil, false
+		}
+		keys[key.Value] = true
+	}
+	return keys, true
+}
+
+// sekiaPath returns the path below the sekia module that e looks up, such
+// as "command" for sekia.command or "state.get" for sekia.state.get.
+func sekiaPath(e *ast.AttrGetExpr) (string, bool) {
+	key, ok := e.Key.(*ast.StringExpr)
+	if !ok {
+		return "", false
+	}
+	switch obj := e.Object.(type) {
+	case *ast.IdentExpr:
+		return key.Value, obj.Value == "sekia"
+	case *ast.AttrGetExpr:
+		prefix, ok := sekiaPath(obj)
+		return prefix + "." + key.Value, ok
+	}
+	return "", false
+}
+
+func sekiaNames() []string {
+	names := slices.Clone(sekiaFields)
+	for name := range sekiaFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkSubjectPattern reports a NATS subject pattern that is malformed.
+func checkSubjectPattern(pattern string) error {
+	tokens := strings.Split(pattern, ".")
+	for i, tok := range tokens {
+		switch {
+		case tok == "":
+			return fmt.Errorf("invalid pattern %q: empty token", pattern)
+		case strings.ContainsAny(tok, " \t\r\n"):
+			return fmt.Errorf("invalid pattern %q: contains whitespace", pattern)
+		case tok == ">" && i != len(tokens)-1:
+			return fmt.Errorf("invalid pattern %q: '>' must be the last token", pattern)
+		case len(tok) > 1 && strings.ContainsAny(tok, "*>"):
+			return fmt.Errorf("invalid pattern %q: wildcards must be whole tokens", pattern)
+		}
+	}
+	return nil
+}
+
+// subjectsOverlap reports whether some subject matches both patterns.
+func subjectsOverlap(a, b string) bool {
+	at, bt := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		switch {
+		case at[i] == ">" || bt[i] == ">":
+			return true
+		case at[i] != "*" && bt[i] != "*" && at[i] != bt[i]:
+			return false
+		}
+	}
+	return len(at) == len(bt)
+}
+
+// suggest returns ` (did you mean "x"?)` for the candidate closest to s
+// within two edits, or "" if there is none.
+func suggest(s string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(s, c); d > 0 && d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance is the Levenshtein distance between a and b, counting an
+// adjacent transposition as one edit.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func TestLint(t *testing.T) {
+	agents := []protocol.AgentInfo{
+		{
+			Name:           "github-agent",
+			Commands:       []string{"add_label", "create_comment"},
+			CommandSchemas: protocol.CommandSchemas("github"),
+		},
+		{Name: "webhook-agent"},
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want []LintIssue
+	}{
+		{
+			name: "clean",
+			src: `
+sekia.concurrency(2, { key = "payload.repo" })
+sekia.on("sekia.events.github", function(event)
+	local p = event.payload
+	sekia.command("github-agent", "add_label", { owner = p.owner, repo = p.repo, number = p.number, label = "bug" })
+	sekia.command("github-agent", "create_comment", p)
+	sekia.command("linear-agent", "anything", {})
+	local n = sekia.state.incr("seen:" .. p.number)
+	sekia.log("info", sekia.name .. " saw " .. n)
+end)
+sekia.on({ subject = "sekia.results.*", type = "command.failed" }, function() end)
+sekia.on("sekia.>", function() end)
+local function args() return "x", "y" end
+sekia.log(args())`,
+		},
+		{
+			name: "typos",
+			src: `
+sekia.on("sekia.events.github", function(event)
+	sekia.command("github-agnet", "add_label", {})
+	sekia.command("github-agent", "add_lable", {})
+	sekia.comand("github-agent", "add_label", {})
+	sekia.command("github-agent", "add_label", { owner = "o", repo = "r", number = 1, lable = "bug" })
+end)`,
+			want: []LintIssue{
+				{3, `sekia.command: unknown agent "github-agnet" (did you mean "github-agent"?)`},
+				{4, `sekia.command: agent "github-agent" has no command "add_lable" (did you mean "add_label"?)`},
+				{5, `unknown function sekia.comand (did you mean "command"?)`},
+				{6, `sekia.command github-agent add_label: payload is missing required field "label" (found "lable")`},
+			},
+		},
+		{
+			name: "arity",
+			src: `
+sekia.publish("sekia.events.x", "x.happened")
+sekia.log("hello")
+sekia.state.get("a", "b")
+sekia.after(60, "sekia.events.x", "x.later", {}, "extra")`,
+			want: []LintIssue{
+				{2, "sekia.publish expects 3 arguments, got 2"},
+				{3, "sekia.log expects 2 arguments, got 1"},
+				{4, "sekia.state.get expects 1 argument, got 2"},
+				{5, "sekia.after expects 4 arguments, got 5"},
+			},
+		},
+		{
+			name: "patterns",
+			src: `
+sekia.on("github.issue.opened", function() end)
+sekia.on("sekia.event.github", function() end)
+sekia.on("sekia.events.>.opened", function() end)
+sekia.on({ subject = "sekia.events.git*" }, function() end)
+sekia.on("sekia.events", function() end)`,
+			want: []LintIssue{
+				{2, `sekia.on: pattern "github.issue.opened" never matches; workflows receive only sekia.events.> and sekia.results.>`},
+				{3, `sekia.on: pattern "sekia.event.github" never matches; workflows receive only sekia.events.> and sekia.results.>`},
+				{4, `sekia.on: invalid pattern "sekia.events.>.opened": '>' must be the last token`},
+				{5, `sekia.on: invalid pattern "sekia.events.git*": wildcards must be whole tokens`},
+				{6, `sekia.on: pattern "sekia.events" never matches; workflows receive only sekia.events.> and sekia.results.>`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lint(tt.name+".lua", []byte(tt.src), agents)
+			if err != nil {
+				t.Fatalf("Lint: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Lint("bad.lua", []byte("sekia.on("), nil); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"add_label", "add_label", 0},
+		{"add_lable", "add_label", 1},
+		{"github-agnet", "github-agent", 1},
+		{"comand", "command", 1},
+		{"slack", "github", 6},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
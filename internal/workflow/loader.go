@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +12,12 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
+// isWorkflowFile reports whether a file name in the workflow directory is a
+// workflow. Workflow tests (*_test.lua) sit beside them but are never loaded.
+func isWorkflowFile(name string) bool {
+	return strings.HasSuffix(name, ".lua") && !strings.HasSuffix(name, TestFileSuffix)
+}
+
 // LoadDir scans the workflow directory and loads all .lua files.
 func (e *Engine) LoadDir() error {
 	if err := os.MkdirAll(e.dir, 0750); err != nil {
@@ -23,7 +30,7 @@ func (e *Engine) LoadDir() error {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lua") {
+		if entry.IsDir() || !isWorkflowFile(entry.Name()) {
 			continue
 		}
 		name := strings.TrimSuffix(entry.Name(), ".lua")
@@ -38,12 +45,17 @@ func (e *Engine) LoadDir() error {
 }
 
 // ReloadAll unloads all workflows and reloads from disk.
+// Event routing is paused for the duration so no event falls into the gap.
 func (e *Engine) ReloadAll() error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
 	// Atomically collect and clear — stop outside the lock to avoid
 	// blocking handleEvent while goroutines drain their channels.
 	e.mu.Lock()
 	old := e.workflows
 	e.workflows = make(map[string]*workflowState)
+	e.loadErrors = make(map[string]loadFailure)
 	e.mu.Unlock()
 
 	for _, ws := range old {
@@ -53,8 +65,8 @@ func (e *Engine) ReloadAll() error {
 	return e.LoadDir()
 }
 
-// StartWatcher starts an fsnotify watcher on the workflow directory.
-// It debounces file changes and reloads affected workflows.
+// StartWatcher starts an fsnotify watcher on the workflow directory and
+// its lib directory. It debounces file changes and reloads affected workflows.
 func (e *Engine) StartWatcher() error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -64,6 +76,7 @@ func (e *Engine) StartWatcher() error {
 		watcher.Close()
 		return err
 	}
+	e.watchLibDirs(watcher, filepath.Join(e.dir, LibDirname))
 
 	go e.watchLoop(watcher)
 
@@ -94,6 +107,9 @@ func (e *Engine) watchLoop(watcher *fsnotify.Watcher) {
 			if !ok {
 				return
 			}
+			if event.Op&fsnotify.Create != 0 && e.isLibPath(event.Name) {
+				e.watchLibDirs(watcher, event.Name) // new lib directory
+			}
 			mu.Lock()
 			pending[event.Name] = event.Op
 			if timer != nil {
@@ -125,21 +141,77 @@ func (e *Engine) processBatch(batch map[string]fsnotify.Op) {
 		}
 	}
 
+	libs := make(map[string]bool)
 	for path, op := range batch {
+		if e.isLibPath(path) {
+			if rel, err := filepath.Rel(e.dir, path); err == nil && strings.HasSuffix(rel, ".lua") {
+				libs[filepath.ToSlash(rel)] = true
+			}
+			continue
+		}
 		e.processFileEvent(path, op)
 	}
+	if len(libs) > 0 {
+		e.reloadDependents(libs)
+	}
+}
+
+// isLibPath reports whether path is the lib directory or inside it.
+func (e *Engine) isLibPath(path string) bool {
+	libDir := filepath.Join(e.dir, LibDirname)
+	return path == libDir || strings.HasPrefix(path, libDir+string(filepath.Separator))
+}
+
+// watchLibDirs adds root and the directories below it to the watcher.
+// A missing root is skipped; it is picked up when created.
+func (e *Engine) watchLibDirs(watcher *fsnotify.Watcher, root string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			e.logger.Warn().Err(err).Str("dir", path).Msg("failed to watch library directory")
+		}
+		return nil
+	})
+}
+
+// reloadDependents reloads the workflows that required any of the changed
+// libraries (paths relative to the workflow directory).
+func (e *Engine) reloadDependents(libs map[string]bool) {
+	e.mu.RLock()
+	dependents := make(map[string]string)
+	for name, ws := range e.workflows {
+		if ws.libs.uses(libs) {
+			dependents[name] = ws.filePath
+		}
+	}
+	e.mu.RUnlock()
+
+	for name, path := range dependents {
+		if err := e.LoadWorkflow(name, path); err != nil {
+			e.logger.Error().Err(err).Str("workflow", name).Msg("failed to reload workflow after library change")
+			if errors.Is(err, ErrIntegrityViolation) {
+				e.UnloadWorkflow(name)
+				e.logger.Warn().Str("workflow", name).Msg("unloaded workflow due to integrity violation")
+			}
+			continue
+		}
+		e.logger.Info().Str("workflow", name).Msg("reloaded workflow after library change")
+	}
 }
 
 // processFileEvent handles a single file change event within a batch.
 func (e *Engine) processFileEvent(path string, op fsnotify.Op) {
 	base := filepath.Base(path)
-	if !strings.HasSuffix(base, ".lua") {
+	if !isWorkflowFile(base) {
 		return
 	}
 	name := strings.TrimSuffix(base, ".lua")
 
 	if op&(fsnotify.Remove|fsnotify.Rename) != 0 {
 		e.UnloadWorkflow(name)
+		e.clearLoadError(name)
 		return
 	}
 
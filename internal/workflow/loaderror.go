@@ -0,0 +1,68 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// loadFailure is the last failed load of a workflow.
+type loadFailure struct {
+	filePath string
+	err      *protocol.LoadError
+}
+
+// luaErrorLine matches the "<chunk>:<line>:" prefix of Lua runtime errors.
+var luaErrorLine = regexp.MustCompile(`^[^\n]*?:(\d+): `)
+
+// newLoadError describes a failed load, with the source position for
+// syntax errors and errors raised while the file ran.
+func newLoadError(err error) *protocol.LoadError {
+	le := &protocol.LoadError{Message: err.Error(), At: time.Now().UTC()}
+
+	var (
+		parseErr   *parse.Error
+		compileErr *lua.CompileError
+		apiErr     *lua.ApiError
+	)
+	switch {
+	case errors.As(err, &parseErr):
+		if parseErr.Pos.Line != parse.EOF {
+			le.Line, le.Column = parseErr.Pos.Line, parseErr.Pos.Column
+			le.Message = fmt.Sprintf("%s near '%s'", parseErr.Message, parseErr.Token)
+		} else {
+			le.Message = parseErr.Message + " at end of file"
+		}
+	case errors.As(err, &compileErr):
+		le.Line, le.Message = compileErr.Line, compileErr.Message
+	case errors.As(err, &apiErr) && apiErr.Object != nil:
+		le.Message = apiErr.Object.String()
+		if m := luaErrorLine.FindStringSubmatch(le.Message); m != nil {
+			le.Line, _ = strconv.Atoi(m[1])
+		}
+	}
+	le.Message = strings.TrimSpace(le.Message)
+	return le
+}
+
+// recordLoadError keeps a failed load for Workflows.
+func (e *Engine) recordLoadError(name, filePath string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.loadErrors[name] = loadFailure{filePath: filePath, err: newLoadError(err)}
+}
+
+// clearLoadError forgets a workflow's failed load.
+func (e *Engine) clearLoadError(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.loadErrors, name)
+}
@@ -0,0 +1,231 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Catch-up policies for cron runs missed while the daemon was down.
+const (
+	cronCatchupSkip = "skip" // wait for the next scheduled time
+	cronCatchupOnce = "once" // run once right away, then resume the schedule
+)
+
+// cronEntry holds a wall-clock schedule registered via sekia.cron().
+type cronEntry struct {
+	Expr    string
+	Fn      *lua.LFunction
+	key     string // state key persisting the next fire time
+	sched   *cronSchedule
+	loc     *time.Location
+	jitter  time.Duration
+	catchup string
+}
+
+// luaCron registers a cron handler: sekia.cron(expr, handler [, opts])
+// opts: tz (IANA zone, default local), jitter (max random delay in seconds),
+// catchup ("skip" or "once").
+func (ctx *moduleContext) luaCron(L *lua.LState) int {
+	expr := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	opts := L.OptTable(3, L.NewTable())
+
+	sched, err := parseCron(expr)
+	if err != nil {
+		L.ArgError(1, err.Error())
+		return 0
+	}
+
+	loc := time.Local
+	if tz := opts.RawGetString("tz"); tz != lua.LNil {
+		if loc, err = time.LoadLocation(tz.String()); err != nil {
+			L.ArgError(3, fmt.Sprintf("unknown tz %q", tz.String()))
+			return 0
+		}
+	}
+
+	var jitter time.Duration
+	if j, ok := opts.RawGetString("jitter").(lua.LNumber); ok {
+		if j < 0 {
+			L.ArgError(3, "jitter must not be negative")
+			return 0
+		}
+		jitter = time.Duration(float64(j) * float64(time.Second))
+	}
+
+	catchup := cronCatchupSkip
+	if c := opts.RawGetString("catchup"); c != lua.LNil {
+		catchup = c.String()
+		if catchup != cronCatchupSkip && catchup != cronCatchupOnce {
+			L.ArgError(3, `catchup must be "skip" or "once"`)
+			return 0
+		}
+	}
+
+	key := expr + "|" + loc.String()
+	n := 0
+	for _, c := range ctx.crons {
+		if c.Expr == expr && c.loc.String() == loc.String() {
+			n++
+		}
+	}
+	if n > 0 {
+		key = fmt.Sprintf("%s#%d", key, n)
+	}
+
+	ctx.crons = append(ctx.crons, &cronEntry{
+		Expr:    expr,
+		Fn:      fn,
+		key:     key,
+		sched:   sched,
+		loc:     loc,
+		jitter:  jitter,
+		catchup: catchup,
+	})
+
+	ctx.logger.Debug().
+		Str("expr", expr).
+		Str("tz", loc.String()).
+		Msg("registered cron handler")
+
+	return 0
+}
+
+// cronPersistTimeout bounds reads and writes of persisted fire times.
+const cronPersistTimeout = 5 * time.Second
+
+// cronMissGrace is how late a fire time may be and still run as scheduled
+// rather than count as missed, so a reload the moment a run falls due
+// does not skip it.
+const cronMissGrace = time.Minute
+
+// runCron fires a cron entry until stop is closed. The next fire time,
+// jitter included, is persisted in the state store, and each run first
+// claims its fire time by advancing the persisted one with a
+// compare-and-set. A restart or reload therefore neither repeats a run nor
+// starts the schedule over, and when the old and new instances of a
+// reloaded workflow are both waiting for the same fire time only one of
+// them runs it.
+func (ws *workflowState) runCron(c *cronEntry, ticks chan<- *lua.LFunction, stop <-chan struct{}) {
+	fire, rev, ok := ws.loadCronNext(c)
+	for {
+		if ok && fire.After(time.Now()) {
+			ws.recordCronNext(c, fire)
+			timer := time.NewTimer(time.Until(fire))
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+			// Another instance may have claimed or rescheduled this fire
+			// time while we waited.
+			cur, curRev, curOK := ws.loadCronNext(c)
+			if ws.modCtx.state != nil && (!curOK || !cur.Equal(fire)) {
+				fire, rev, ok = cur, curRev, curOK
+				continue
+			}
+			rev = curRev
+		}
+
+		now := time.Now().In(c.loc)
+		run := false
+		switch {
+		case !ok:
+			// Nothing persisted yet: start the schedule from now.
+		case now.Sub(fire) < cronMissGrace:
+			run = true
+		case c.catchup == cronCatchupOnce:
+			ws.modCtx.logger.Info().Str("expr", c.Expr).Time("missed", fire).Msg("running missed cron handler")
+			run = true
+		}
+
+		// Persist the following fire time before running, so a crash
+		// during the handler does not repeat this run.
+		next := c.nextFire(now)
+		if !ws.claimCron(c, rev, next) {
+			fire, rev, ok = ws.loadCronNext(c)
+			continue
+		}
+		ws.recordCronNext(c, next)
+		if run {
+			select {
+			case ticks <- c.Fn:
+			case <-stop:
+				return
+			}
+		}
+		if next.IsZero() {
+			ws.modCtx.logger.Warn().Str("expr", c.Expr).Msg("cron expression has no further fire times")
+			return
+		}
+		fire, ok = next, true
+	}
+}
+
+// nextFire returns the first scheduled time after t plus a random jitter,
+// or the zero time if there is none.
+func (c *cronEntry) nextFire(t time.Time) time.Time {
+	next := c.sched.next(t)
+	if next.IsZero() || c.jitter <= 0 {
+		return next
+	}
+	return next.Add(rand.N(c.jitter))
+}
+
+// cronNamespace is the state namespace holding a workflow's cron fire times.
+func (ws *workflowState) cronNamespace() string {
+	return "cron:" + ws.modCtx.stateNS
+}
+
+// loadCronNext returns the persisted fire time and its revision. ok is
+// false if there is none (or it cannot be read); the revision is still
+// that of the stored value, so claimCron can replace a corrupt one.
+func (ws *workflowState) loadCronNext(c *cronEntry) (next time.Time, rev uint64, ok bool) {
+	if ws.modCtx.state == nil {
+		return time.Time{}, 0, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cronPersistTimeout)
+	defer cancel()
+	data, rev, err := ws.modCtx.state.Get(ctx, ws.cronNamespace(), c.key)
+	if err != nil {
+		ws.modCtx.logger.Warn().Err(err).Str("expr", c.Expr).Msg("load cron fire time")
+		return time.Time{}, 0, false
+	}
+	var t time.Time
+	if rev == 0 || json.Unmarshal(data, &t) != nil || t.IsZero() {
+		return time.Time{}, rev, false
+	}
+	return t.In(c.loc), rev, true
+}
+
+// claimCron replaces the persisted fire time at revision rev with next. It
+// reports false if the value changed since it was read, i.e. another
+// instance claimed it first. Without a state store, or if the store fails,
+// the claim succeeds so the schedule keeps running.
+func (ws *workflowState) claimCron(c *cronEntry, rev uint64, next time.Time) bool {
+	if ws.modCtx.state == nil {
+		return true
+	}
+	data, _ := json.Marshal(next.UTC())
+	ctx, cancel := context.WithTimeout(context.Background(), cronPersistTimeout)
+	defer cancel()
+	ok, err := ws.modCtx.state.CompareAndSet(ctx, ws.cronNamespace(), c.key, data, rev, 0)
+	if err != nil {
+		ws.modCtx.logger.Warn().Err(err).Str("expr", c.Expr).Msg("persist cron fire time")
+		return true
+	}
+	return ok
+}
+
+// recordCronNext records the next fire time for WorkflowInfo.
+func (ws *workflowState) recordCronNext(c *cronEntry, next time.Time) {
+	ws.schedMu.Lock()
+	ws.cronNext[c] = next
+	ws.schedMu.Unlock()
+}
@@ -0,0 +1,307 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// HTTPConfig controls sekia.http. A workflow can only reach the hosts
+// allowlisted for it.
+type HTTPConfig struct {
+	// Allow maps a workflow name to the host patterns it may call:
+	// "api.example.com", "*.example.com" (any subdomain) or "host:port" to
+	// pin the port. Patterns under "*" apply to every workflow.
+	Allow        map[string][]string `mapstructure:"allow"`
+	MaxBodyBytes int64               `mapstructure:"max_body_bytes"` // request and response body limit (0 = 1 MiB)
+}
+
+// Validate checks the host patterns.
+func (c HTTPConfig) Validate() error {
+	for name, patterns := range c.Allow {
+		for _, p := range patterns {
+			if p == "" || strings.ContainsAny(p, "/?#@") {
+				return fmt.Errorf("allow.%s: invalid host pattern %q (want host, *.domain or host:port)", name, p)
+			}
+		}
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must not be negative")
+	}
+	return nil
+}
+
+// hostsFor returns the host patterns allowlisted for a workflow. Names are
+// compared case-insensitively, since config keys are lower-cased on load.
+func (c HTTPConfig) hostsFor(name string) []string {
+	var hosts []string
+	for key, patterns := range c.Allow {
+		if key == "*" || strings.EqualFold(key, name) {
+			hosts = append(hosts, patterns...)
+		}
+	}
+	return hosts
+}
+
+// Bounds for sekia.http.request.
+const (
+	defaultHTTPMaxBody = 1 << 20 // 1 MiB
+	defaultHTTPTimeout = 10 * time.Second
+	maxHTTPTimeout     = 60 * time.Second
+)
+
+// httpPolicy is a workflow's sekia.http allowlist and limits. It is shared
+// by all of the workflow's VMs.
+type httpPolicy struct {
+	hosts   []string
+	maxBody int64
+	client  *http.Client
+}
+
+func newHTTPPolicy(cfg HTTPConfig, name string) *httpPolicy {
+	p := &httpPolicy{
+		hosts:   cfg.hostsFor(name),
+		maxBody: cfg.MaxBodyBytes,
+	}
+	if p.maxBody == 0 {
+		p.maxBody = defaultHTTPMaxBody
+	}
+	p.client = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !p.allowed(req.URL) {
+				return fmt.Errorf("redirect to %s: host is not allowlisted", req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return p
+}
+
+// allowed reports whether u is an http(s) URL whose host matches one of
+// the policy's patterns. A pattern without a port matches any port.
+func (p *httpPolicy) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	for _, pattern := range p.hosts {
+		pHost, pPort := strings.ToLower(pattern), ""
+		if h, pt, err := net.SplitHostPort(pHost); err == nil {
+			pHost, pPort = h, pt
+		}
+		if pPort != "" && pPort != port {
+			continue
+		}
+		if domain, ok := strings.CutPrefix(pHost, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if host == pHost {
+			return true
+		}
+	}
+	return false
+}
+
+// registerHTTPModule adds the sekia.http table:
+//
+//	sekia.http.request{method=, url=, headers=, body=, timeout=} -> response, err
+//
+// response is {status=, headers=, body=} plus json when the response is
+// JSON. headers has lower-case names.
+func registerHTTPModule(L *lua.LState, mod *lua.LTable, ctx *moduleContext) {
+	h := L.NewTable()
+	L.SetField(h, "request", L.NewFunction(ctx.luaHTTPRequest))
+	L.SetField(mod, "http", h)
+}
+
+// luaHTTPRequest implements sekia.http.request. Requests to hosts that are
+// not allowlisted, transport errors and oversized responses are returned as
+// err; every request is logged. In dry-run and shadow mode, requests other
+// than GET and HEAD are recorded instead of sent.
+func (ctx *moduleContext) luaHTTPRequest(L *lua.LState) int {
+	opts := L.CheckTable(1)
+
+	method := strings.ToUpper(optString(L, opts, "method", http.MethodGet))
+	rawURL := optString(L, opts, "url", "")
+	if rawURL == "" {
+		L.ArgError(1, "url is required")
+		return 0
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		L.ArgError(1, "invalid url: "+err.Error())
+		return 0
+	}
+	timeout := defaultHTTPTimeout
+	if v, ok := L.GetField(opts, "timeout").(lua.LNumber); ok {
+		timeout = time.Duration(float64(v) * float64(time.Second))
+		if timeout <= 0 || timeout > maxHTTPTimeout {
+			L.ArgError(1, fmt.Sprintf("timeout must be between 0 and %d seconds", int(maxHTTPTimeout.Seconds())))
+			return 0
+		}
+	}
+	header := http.Header{}
+	if tbl, ok := L.GetField(opts, "headers").(*lua.LTable); ok {
+		tbl.ForEach(func(k, v lua.LValue) {
+			header.Set(k.String(), v.String())
+		})
+	}
+	var body []byte
+	switch v := L.GetField(opts, "body").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		body = []byte(v)
+	case *lua.LTable:
+		if body, err = json.Marshal(TableToMap(v)); err != nil {
+			L.ArgError(1, "encode body: "+err.Error())
+			return 0
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+	default:
+		L.ArgError(1, "body must be a string or a table")
+		return 0
+	}
+
+	policy := ctx.http
+	if policy == nil {
+		policy = &httpPolicy{}
+	}
+	target := u.Scheme + "://" + u.Host + u.Path // logged without the query, which may hold credentials
+	log := ctx.logger.With().
+		Str("method", method).
+		Str("url", target).
+		Str("event_id", ctx.currentEventID).
+		Logger()
+
+	if !policy.allowed(u) {
+		log.Warn().Msg("sekia.http request denied: host is not allowlisted")
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("host %s is not allowlisted for workflow %s", u.Host, ctx.name)))
+		return 2
+	}
+	if int64(len(body)) > policy.maxBody {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("request body exceeds %d bytes", policy.maxBody)))
+		return 2
+	}
+
+	if ctx.intercept != nil && method != http.MethodGet && method != http.MethodHead {
+		payload := map[string]any{"body": string(body)}
+		if len(header) > 0 {
+			hdrs := make(map[string]any, len(header))
+			for name := range header {
+				hdrs[name] = header.Get(name)
+			}
+			payload["headers"] = hdrs
+		}
+		ctx.intercept(protocol.Intent{
+			Kind:    protocol.IntentHTTP,
+			Subject: rawURL,
+			Method:  method,
+			Payload: payload,
+			EventID: ctx.currentEventID,
+			At:      time.Now().UTC(),
+		})
+		resp := L.NewTable()
+		L.SetField(resp, "status", lua.LNumber(0))
+		L.SetField(resp, "headers", L.NewTable())
+		L.SetField(resp, "body", lua.LString(""))
+		L.Push(resp)
+		L.Push(lua.LNil)
+		return 2
+	}
+
+	// The handler timeout, if any, also bounds the request.
+	parent := L.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	c, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(c, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		L.ArgError(1, err.Error())
+		return 0
+	}
+	req.Header = header
+
+	start := time.Now()
+	res, err := policy.client.Do(req)
+	if err != nil {
+		log.Warn().Err(err).Dur("duration", time.Since(start)).Msg("sekia.http request failed")
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, policy.maxBody+1))
+	if err == nil && int64(len(data)) > policy.maxBody {
+		err = fmt.Errorf("response body exceeds %d bytes", policy.maxBody)
+	}
+	log.Info().
+		Int("status", res.StatusCode).
+		Int("bytes", len(data)).
+		Dur("duration", time.Since(start)).
+		Msg("sekia.http request")
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	headers := L.NewTable()
+	for name, vals := range res.Header {
+		L.SetField(headers, strings.ToLower(name), lua.LString(strings.Join(vals, ", ")))
+	}
+	resp := L.NewTable()
+	L.SetField(resp, "status", lua.LNumber(res.StatusCode))
+	L.SetField(resp, "headers", headers)
+	L.SetField(resp, "body", lua.LString(data))
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		var v any
+		if json.Unmarshal(data, &v) == nil {
+			L.SetField(resp, "json", GoToLua(L, v))
+		}
+	}
+	L.Push(resp)
+	L.Push(lua.LNil)
+	return 2
+}
+
+// optString reads an optional string field of an options table.
+func optString(L *lua.LState, tbl *lua.LTable, field, def string) string {
+	switch v := L.GetField(tbl, field).(type) {
+	case *lua.LNilType:
+		return def
+	case lua.LString:
+		return string(v)
+	default:
+		L.ArgError(1, fmt.Sprintf("%s must be a string", field))
+		return def
+	}
+}
@@ -0,0 +1,219 @@
+package workflow
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// newHTTPTestState returns a VM whose sekia.http may reach the given host patterns.
+func newHTTPTestState(t *testing.T, cfg HTTPConfig) (*lua.LState, *moduleContext) {
+	t.Helper()
+	L := NewSandboxedState("http-wf", testLogger())
+	t.Cleanup(L.Close)
+	ctx := &moduleContext{
+		name:   "http-wf",
+		logger: testLogger(),
+		http:   newHTTPPolicy(cfg, "http-wf"),
+	}
+	registerSekiaModule(L, ctx)
+	return L, ctx
+}
+
+func TestLuaHTTP_GetJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "abc")
+		io.WriteString(w, `{"status":"ok","components":[{"name":"api"}]}`)
+	}))
+	defer srv.Close()
+
+	L, _ := newHTTPTestState(t, HTTPConfig{Allow: map[string][]string{"http-wf": {"127.0.0.1"}}})
+	L.SetGlobal("url", lua.LString(srv.URL+"/status"))
+	err := L.DoString(`
+		local resp, err = sekia.http.request{ url = url, headers = { Authorization = "Bearer t0ken" } }
+		assert(err == nil, "unexpected error: " .. tostring(err))
+		assert(resp.status == 200, "status = " .. tostring(resp.status))
+		assert(resp.headers["x-request-id"] == "abc", "missing header")
+		assert(resp.json.status == "ok", "json not decoded")
+		assert(resp.json.components[1].name == "api", "nested json not decoded")
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+}
+
+func TestLuaHTTP_PostTableBody(t *testing.T) {
+	var got map[string]any
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	}))
+	defer srv.Close()
+
+	L, _ := newHTTPTestState(t, HTTPConfig{Allow: map[string][]string{"*": {"127.0.0.1"}}})
+	L.SetGlobal("url", lua.LString(srv.URL))
+	err := L.DoString(`
+		local resp, err = sekia.http.request{ method = "post", url = url, body = { title = "outage" } }
+		assert(err == nil, tostring(err))
+		assert(resp.status == 201)
+		assert(resp.body == "created")
+		assert(resp.json == nil)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+	if contentType != "application/json" || got["title"] != "outage" {
+		t.Errorf("server got content type %q, body %v", contentType, got)
+	}
+}
+
+func TestLuaHTTP_Denied(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	// Allowlisted for another workflow only.
+	L, _ := newHTTPTestState(t, HTTPConfig{Allow: map[string][]string{"other-wf": {"127.0.0.1"}}})
+	L.SetGlobal("url", lua.LString(srv.URL))
+	err := L.DoString(`
+		local resp, err = sekia.http.request{ url = url }
+		assert(resp == nil)
+		assert(string.find(err, "not allowlisted"), err)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+	if called {
+		t.Error("request reached a host that is not allowlisted")
+	}
+}
+
+func TestLuaHTTP_RedirectToDeniedHost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.invalid/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	L, _ := newHTTPTestState(t, HTTPConfig{Allow: map[string][]string{"http-wf": {"127.0.0.1"}}})
+	L.SetGlobal("url", lua.LString(srv.URL))
+	err := L.DoString(`
+		local resp, err = sekia.http.request{ url = url }
+		assert(resp == nil)
+		assert(string.find(err, "not allowlisted"), err)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+}
+
+func TestLuaHTTP_ResponseTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	L, _ := newHTTPTestState(t, HTTPConfig{
+		Allow:        map[string][]string{"http-wf": {"127.0.0.1"}},
+		MaxBodyBytes: 64,
+	})
+	L.SetGlobal("url", lua.LString(srv.URL))
+	err := L.DoString(`
+		local resp, err = sekia.http.request{ url = url }
+		assert(resp == nil)
+		assert(string.find(err, "exceeds 64 bytes"), err)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+}
+
+func TestLuaHTTP_Intercept(t *testing.T) {
+	var gets int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("%s request was sent in shadow mode", r.Method)
+		}
+		gets++
+	}))
+	defer srv.Close()
+
+	L, ctx := newHTTPTestState(t, HTTPConfig{Allow: map[string][]string{"http-wf": {"127.0.0.1"}}})
+	var intents []protocol.Intent
+	ctx.intercept = func(in protocol.Intent) { intents = append(intents, in) }
+	L.SetGlobal("url", lua.LString(srv.URL))
+	err := L.DoString(`
+		local resp, err = sekia.http.request{ url = url }
+		assert(resp.status == 200)
+		resp, err = sekia.http.request{ method = "PUT", url = url .. "/incident", body = "ack" }
+		assert(err == nil and resp.status == 0)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+	if gets != 1 {
+		t.Errorf("server saw %d GETs, want 1", gets)
+	}
+	if len(intents) != 1 {
+		t.Fatalf("recorded %d intents, want 1", len(intents))
+	}
+	in := intents[0]
+	if in.Kind != protocol.IntentHTTP || in.Method != "PUT" || in.Subject != srv.URL+"/incident" || in.Payload["body"] != "ack" {
+		t.Errorf("intent = %+v", in)
+	}
+}
+
+func TestHTTPPolicyAllowed(t *testing.T) {
+	p := newHTTPPolicy(HTTPConfig{Allow: map[string][]string{
+		"wf": {"status.example.com", "*.internal.example.com", "localhost:8080"},
+	}}, "WF")
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://status.example.com/api", true},
+		{"http://STATUS.example.com:9000/", true},
+		{"https://example.com/", false},
+		{"https://evil-status.example.com/", false},
+		{"https://api.internal.example.com/v1", true},
+		{"https://internal.example.com/", false},
+		{"http://localhost:8080/", true},
+		{"http://localhost/", false},
+		{"ftp://status.example.com/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := p.allowed(u); got != tt.want {
+			t.Errorf("allowed(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPConfigValidate(t *testing.T) {
+	if err := (HTTPConfig{Allow: map[string][]string{"wf": {"api.example.com", "*.example.com:443"}}}).Validate(); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+	for _, bad := range []string{"", "https://api.example.com", "api.example.com/path"} {
+		if err := (HTTPConfig{Allow: map[string][]string{"wf": {bad}}}).Validate(); err == nil {
+			t.Errorf("pattern %q: expected error", bad)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package workflow
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -17,7 +19,17 @@ import (
 // handlerEntry binds a NATS subject pattern to a Lua callback.
 type handlerEntry struct {
 	Pattern string
+	Filter  *eventFilter // nil = subject match only
 	Fn      *lua.LFunction
+	legacy  bool // flat sekia.events.<source> pattern also matching hierarchical subjects
+}
+
+// matches reports whether the handler wants the event.
+func (h handlerEntry) matches(ev *eventMsg) bool {
+	if !SubjectMatches(h.Pattern, ev.subject) && !(h.legacy && SubjectMatches(h.Pattern+".>", ev.subject)) {
+		return false
+	}
+	return h.Filter == nil || h.Filter.matches(ev.fields())
 }
 
 // moduleContext holds the state shared between Lua module functions and the Go engine.
@@ -28,11 +40,33 @@ type moduleContext struct {
 	logger        zerolog.Logger
 	handlers      []handlerEntry
 	schedules     []scheduleEntry
-	llm           ai.LLMClient          // nil if AI is not configured
-	commandSecret string                 // HMAC-SHA256 secret for signing commands (empty = no signing)
-	skillsIndex   string                 // compact skills summary for AI prompts
-	skillResolver SkillResolver          // resolves full skill instructions by name
-	convoStore    ConversationStore      // conversation store (nil if not configured)
+	crons         []*cronEntry
+	llm           ai.LLMClient                // nil if AI is not configured
+	commandSecret string                      // HMAC-SHA256 secret for signing commands (empty = no signing)
+	commandTTL    time.Duration               // validity of version 2 signed commands (0 = protocol.DefaultCommandTTL)
+	signingKey    *protocol.SigningKey        // Ed25519 key for agents that trust it (nil = HMAC only)
+	agents        func() []protocol.AgentInfo // registered agents, for signature version negotiation (nil = version 1)
+	skillsIndex   string                      // compact skills summary for AI prompts
+	skillResolver SkillResolver               // resolves full skill instructions by name
+	convoStore    ConversationStore           // conversation store (nil if not configured)
+	state         StateStore                  // persistent key-value state (nil without JetStream)
+	stateNS       string                      // namespace for sekia.state keys
+	timers        TimerStore                  // delayed events (nil without JetStream)
+	concurrency   int                         // VMs handling events, set by sekia.concurrency
+	orderKey      []string                    // event paths keeping per-key order across VMs
+	subjects      string                      // event subject scheme (protocol.Subjects*)
+	http          *httpPolicy                 // sekia.http allowlist and limits (nil = no hosts allowed)
+	policy        *protocol.CommandPolicy     // commands the workflow may send (nil = any)
+	denied        *atomic.Int64               // commands refused by policy, across the workflow's VMs
+
+	// intercept, when set, receives outgoing publishes and commands instead
+	// of NATS (dry-run replay, shadow mode). currentEventID tags them with the event being handled.
+	intercept      func(protocol.Intent)
+	currentEventID string
+
+	// hooks lets the workflow test harness observe logs and answer
+	// intercepted sekia.command_sync calls (nil outside tests).
+	hooks *testHooks
 }
 
 // ConversationStore is the interface the workflow engine uses for conversation state.
@@ -52,37 +86,68 @@ func registerSekiaModule(L *lua.LState, ctx *moduleContext) {
 	L.SetField(mod, "on", L.NewFunction(ctx.luaOn))
 	L.SetField(mod, "publish", L.NewFunction(ctx.luaPublish))
 	L.SetField(mod, "command", L.NewFunction(ctx.luaCommand))
+	L.SetField(mod, "command_sync", L.NewFunction(ctx.luaCommandSync))
 	L.SetField(mod, "log", L.NewFunction(ctx.luaLog))
 	L.SetField(mod, "ai", L.NewFunction(ctx.luaAI))
 	L.SetField(mod, "ai_json", L.NewFunction(ctx.luaAIJSON))
 	L.SetField(mod, "skill", L.NewFunction(ctx.luaSkill))
 	L.SetField(mod, "conversation", L.NewFunction(ctx.luaConversation))
 	L.SetField(mod, "schedule", L.NewFunction(ctx.luaSchedule))
+	L.SetField(mod, "cron", L.NewFunction(ctx.luaCron))
+	L.SetField(mod, "concurrency", L.NewFunction(ctx.luaConcurrency))
+	L.SetField(mod, "after", L.NewFunction(ctx.luaAfter))
+	L.SetField(mod, "at", L.NewFunction(ctx.luaAt))
+	L.SetField(mod, "cancel", L.NewFunction(ctx.luaCancel))
+	registerStateModule(L, mod, ctx)
+	registerHTTPModule(L, mod, ctx)
 
 	L.SetGlobal("sekia", mod)
 }
 
-// luaOn registers an event handler: sekia.on(pattern, handler)
+// luaOn registers an event handler: sekia.on(pattern, handler) or
+// sekia.on({subject=..., type=..., source=..., where={...}}, handler).
 func (ctx *moduleContext) luaOn(L *lua.LState) int {
-	pattern := L.CheckString(1)
+	var (
+		pattern string
+		filter  *eventFilter
+	)
+	if tbl, ok := L.Get(1).(*lua.LTable); ok {
+		pattern, filter = checkFilterTable(L, 1, tbl)
+	} else {
+		pattern = L.CheckString(1)
+	}
 	fn := L.CheckFunction(2)
 
 	ctx.handlers = append(ctx.handlers, handlerEntry{
 		Pattern: pattern,
+		Filter:  filter,
 		Fn:      fn,
+		legacy:  ctx.subjects == protocol.SubjectsCompat && isFlatEventPattern(pattern),
 	})
 
-	ctx.logger.Debug().
-		Str("pattern", pattern).
-		Msg("registered event handler")
+	log := ctx.logger.Debug().Str("pattern", pattern)
+	if filter != nil {
+		log = log.Stringer("filter", filter)
+	}
+	log.Msg("registered event handler")
 
 	return 0
 }
 
+// isFlatEventPattern reports whether pattern is a flat per-source event
+// pattern (sekia.events.<source> or sekia.events.*), which the compat
+// subject scheme extends to the source's hierarchical subjects.
+func isFlatEventPattern(pattern string) bool {
+	source, ok := strings.CutPrefix(pattern, "sekia.events.")
+	return ok && source != "" && source != ">" && !strings.Contains(source, ".")
+}
+
 // luaPublish publishes an event: sekia.publish(subject, event_type, payload)
+// Under the hierarchical and compat subject schemes, a flat
+// sekia.events.<source> subject gets the event type appended.
 func (ctx *moduleContext) luaPublish(L *lua.LState) int {
-	subject := L.CheckString(1)
 	eventType := L.CheckString(2)
+	subject := protocol.ExpandEventSubject(ctx.subjects, L.CheckString(1), eventType)
 	payloadTbl := L.CheckTable(3)
 
 	payloadRaw := TableToMap(payloadTbl)
@@ -93,6 +158,18 @@ func (ctx *moduleContext) luaPublish(L *lua.LState) int {
 	}
 
 	ev := protocol.NewEvent(eventType, fmt.Sprintf("workflow:%s", ctx.name), payload)
+	if ctx.intercept != nil {
+		ctx.intercept(protocol.Intent{
+			Kind:      protocol.IntentPublish,
+			Subject:   subject,
+			EventType: eventType,
+			Payload:   payload,
+			EventID:   ctx.currentEventID,
+			At:        time.Now().UTC(),
+		})
+		return 0
+	}
+
 	data, err := json.Marshal(ev)
 	if err != nil {
 		L.RaiseError("marshal event: %s", err)
@@ -112,9 +189,146 @@ func (ctx *moduleContext) luaPublish(L *lua.LState) int {
 	return 0
 }
 
-// luaCommand sends a command to an agent: sekia.command(agent_name, command, payload)
+// luaCommand sends a command to an agent: sekia.command(agent_name, command, payload) -> command_id
+// The outcome is reported on sekia.results.<agent_name> with the same command_id.
 func (ctx *moduleContext) luaCommand(L *lua.LState) int {
 	agentName := L.CheckString(1)
+	cmd, data := ctx.buildCommand(L)
+	if err := ctx.authorize(agentName, cmd); err != nil {
+		L.RaiseError("%s", err)
+		return 0
+	}
+
+	if ctx.intercept != nil {
+		ctx.recordCommand(agentName, protocol.SubjectCommands(agentName), cmd)
+		L.Push(lua.LString(cmd.ID))
+		return 1
+	}
+
+	msg := nats.NewMsg(protocol.SubjectCommands(agentName))
+	msg.Header.Set(nats.MsgIdHdr, cmd.ID) // lets the work queue drop duplicate publishes
+	msg.Data = data
+	if err := ctx.nc.PublishMsg(msg); err != nil {
+		L.RaiseError("publish command: %s", err)
+		return 0
+	}
+
+	ctx.logger.Debug().
+		Str("agent", agentName).
+		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
+		Msg("sent command")
+
+	L.Push(lua.LString(cmd.ID))
+	return 1
+}
+
+// Bounds for sekia.command_sync timeouts.
+const (
+	defaultCommandSyncTimeout = 10 * time.Second
+	maxCommandSyncTimeout     = 120 * time.Second
+)
+
+// luaCommandSync sends a command and waits for the agent's reply:
+// sekia.command_sync(agent_name, command, payload [, timeout_seconds]) -> result, err
+// The command bypasses the durable work queue, so it fails fast when the agent is offline.
+func (ctx *moduleContext) luaCommandSync(L *lua.LState) int {
+	agentName := L.CheckString(1)
+	cmd, data := ctx.buildCommand(L)
+
+	timeout := defaultCommandSyncTimeout
+	if L.GetTop() >= 4 {
+		timeout = time.Duration(float64(L.CheckNumber(4)) * float64(time.Second))
+		if timeout <= 0 || timeout > maxCommandSyncTimeout {
+			L.ArgError(4, fmt.Sprintf("timeout must be between 0 and %d seconds", int(maxCommandSyncTimeout.Seconds())))
+			return 0
+		}
+	}
+
+	if err := ctx.authorize(agentName, cmd); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	if ctx.intercept != nil {
+		ctx.recordCommand(agentName, protocol.SubjectCommandsSync(agentName), cmd)
+		if ctx.hooks != nil {
+			if result, errMsg, ok := ctx.hooks.commandReply(agentName, cmd.Command); ok {
+				if errMsg != "" {
+					L.Push(lua.LNil)
+					L.Push(lua.LString(errMsg))
+					return 2
+				}
+				L.Push(MapToTable(L, result))
+				L.Push(lua.LNil)
+				return 2
+			}
+		}
+		// Nothing is executed, so there is no result to return.
+		L.Push(L.NewTable())
+		L.Push(lua.LNil)
+		return 2
+	}
+
+	reply, err := ctx.nc.Request(protocol.SubjectCommandsSync(agentName), data, timeout)
+	if err != nil {
+		switch {
+		case errors.Is(err, nats.ErrNoResponders):
+			err = fmt.Errorf("agent %s is not connected", agentName)
+		case errors.Is(err, nats.ErrTimeout):
+			err = fmt.Errorf("command %s to %s timed out after %s", cmd.Command, agentName, timeout)
+		}
+		ctx.logger.Warn().
+			Err(err).
+			Str("agent", agentName).
+			Str("command", cmd.Command).
+			Str("command_id", cmd.ID).
+			Msg("sekia.command_sync() failed")
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	var res protocol.CommandResult
+	if err := json.Unmarshal(reply.Data, &res); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("invalid reply from agent: " + err.Error()))
+		return 2
+	}
+	if res.Status != protocol.ResultOK {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(res.Error))
+		return 2
+	}
+
+	ctx.logger.Debug().
+		Str("agent", agentName).
+		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
+		Msg("command_sync completed")
+
+	L.Push(MapToTable(L, res.Result))
+	L.Push(lua.LNil)
+	return 2
+}
+
+// recordCommand hands an intercepted command to ctx.intercept.
+func (ctx *moduleContext) recordCommand(agentName, subject string, cmd protocol.Command) {
+	ctx.intercept(protocol.Intent{
+		Kind:    protocol.IntentCommand,
+		Subject: subject,
+		Agent:   agentName,
+		Command: cmd.Command,
+		Payload: cmd.Payload,
+		EventID: ctx.currentEventID,
+		At:      time.Now().UTC(),
+	})
+}
+
+// buildCommand reads the command name and payload (arguments 2 and 3) and
+// returns the signed command and its JSON encoding.
+func (ctx *moduleContext) buildCommand(L *lua.LState) (protocol.Command, []byte) {
 	command := L.CheckString(2)
 	payloadTbl := L.CheckTable(3)
 
@@ -122,36 +336,55 @@ func (ctx *moduleContext) luaCommand(L *lua.LState) int {
 	payload, ok := payloadRaw.(map[string]any)
 	if !ok {
 		L.ArgError(3, "expected a table with string keys")
-		return 0
 	}
 
-	cmd := &protocol.Command{
-		Command: command,
-		Payload: payload,
-		Source:  fmt.Sprintf("workflow:%s", ctx.name),
-	}
-	if err := protocol.SignCommand(cmd, ctx.commandSecret); err != nil {
+	cmd := protocol.NewCommand(command, payload, commandSource(ctx.name))
+	if err := ctx.signCommand(&cmd, L.CheckString(1)); err != nil {
 		L.RaiseError("sign command: %s", err)
-		return 0
 	}
 	data, err := json.Marshal(cmd)
 	if err != nil {
 		L.RaiseError("marshal command: %s", err)
-		return 0
 	}
+	return cmd, data
+}
 
-	subject := protocol.SubjectCommands(agentName)
-	if err := ctx.nc.Publish(subject, data); err != nil {
-		L.RaiseError("publish command: %s", err)
-		return 0
+// signCommand signs cmd with the signature version the agent supports.
+func (ctx *moduleContext) signCommand(cmd *protocol.Command, agentName string) error {
+	version := protocol.SignatureV1
+	if ctx.agents != nil {
+		version = protocol.NegotiateSignatureVersion(ctx.agents(), agentName)
 	}
+	return protocol.SignCommandFor(cmd, ctx.signingKey, ctx.commandSecret, version, ctx.commandTTL)
+}
 
-	ctx.logger.Debug().
-		Str("agent", agentName).
-		Str("command", command).
-		Msg("sent command")
+// commandSource returns the Source of the commands a workflow sends:
+// skill:<name> for skill handlers (loaded as workflow "skill:<name>"),
+// workflow:<name> otherwise.
+func commandSource(name string) string {
+	if strings.HasPrefix(name, "skill:") {
+		return name
+	}
+	return "workflow:" + name
+}
 
-	return 0
+// authorize checks a command against the command policy, logging and
+// counting denials.
+func (ctx *moduleContext) authorize(agentName string, cmd protocol.Command) error {
+	err := ctx.policy.Authorize(cmd.Source, agentName, cmd.Command, cmd.Payload)
+	if err == nil {
+		return nil
+	}
+	if ctx.denied != nil {
+		ctx.denied.Add(1)
+	}
+	ctx.logger.Warn().
+		Err(err).
+		Str("agent", agentName).
+		Str("command", cmd.Command).
+		Str("source", cmd.Source).
+		Msg("command denied by policy")
+	return err
 }
 
 // luaSkill returns the full instructions for a named skill: sekia.skill(name) -> string
@@ -193,6 +426,9 @@ func (ctx *moduleContext) luaSchedule(L *lua.LState) int {
 func (ctx *moduleContext) luaLog(L *lua.LState) int {
 	level := L.CheckString(1)
 	message := L.CheckString(2)
+	if ctx.hooks != nil {
+		ctx.hooks.log(strings.ToLower(level), message)
+	}
 
 	switch strings.ToLower(level) {
 	case "debug":
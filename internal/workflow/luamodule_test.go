@@ -2,11 +2,16 @@ package workflow
 
 import (
 	"encoding/json"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
 )
 
 // startTestNATS starts an in-process NATS server for testing.
@@ -81,6 +86,71 @@ func TestLuaOn(t *testing.T) {
 	}
 }
 
+func TestLuaOn_CompatSubjects(t *testing.T) {
+	for _, scheme := range []string{protocol.SubjectsFlat, protocol.SubjectsHierarchical, protocol.SubjectsCompat} {
+		L := NewSandboxedState("test-wf", testLogger())
+		ctx := &moduleContext{name: "test-wf", logger: testLogger(), subjects: scheme}
+		registerSekiaModule(L, ctx)
+
+		err := L.DoString(`
+			sekia.on("sekia.events.github", function(event) end)
+			sekia.on("sekia.events.github.pr.>", function(event) end)
+		`)
+		L.Close()
+		if err != nil {
+			t.Fatalf("%s: DoString: %v", scheme, err)
+		}
+
+		flat, typed := ctx.handlers[0], ctx.handlers[1]
+		ev := &eventMsg{subject: "sekia.events.github.pr.opened", data: []byte(`{}`)}
+		if got, want := flat.matches(ev), scheme == protocol.SubjectsCompat; got != want {
+			t.Errorf("%s: flat pattern matches hierarchical subject = %v, want %v", scheme, got, want)
+		}
+		if !flat.matches(&eventMsg{subject: "sekia.events.github", data: []byte(`{}`)}) {
+			t.Errorf("%s: flat pattern should match flat subject", scheme)
+		}
+		if !typed.matches(ev) {
+			t.Errorf("%s: sekia.events.github.pr.> should match %s", scheme, ev.subject)
+		}
+		if typed.matches(&eventMsg{subject: "sekia.events.github.issue.opened", data: []byte(`{}`)}) {
+			t.Errorf("%s: sekia.events.github.pr.> should not match issue events", scheme)
+		}
+	}
+}
+
+func TestLuaPublish_HierarchicalSubjects(t *testing.T) {
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	var intents []protocol.Intent
+	ctx := &moduleContext{
+		name:      "test-wf",
+		logger:    testLogger(),
+		subjects:  protocol.SubjectsHierarchical,
+		intercept: func(in protocol.Intent) { intents = append(intents, in) },
+	}
+	registerSekiaModule(L, ctx)
+
+	err := L.DoString(`
+		sekia.publish("sekia.events.digest", "digest.daily.ready", {})
+		sekia.publish("sekia.events.digest.custom", "digest.daily.ready", {})
+		sekia.publish("alerts.ops", "page", {})
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	want := []string{"sekia.events.digest.daily.ready", "sekia.events.digest.custom", "alerts.ops"}
+	if len(intents) != len(want) {
+		t.Fatalf("got %d intents, want %d", len(intents), len(want))
+	}
+	for i, w := range want {
+		if intents[i].Subject != w {
+			t.Errorf("intent %d subject = %q, want %q", i, intents[i].Subject, w)
+		}
+	}
+}
+
 func TestLuaPublish(t *testing.T) {
 	_, nc := startTestNATS(t)
 
@@ -150,9 +220,9 @@ func TestLuaCommand(t *testing.T) {
 	registerSekiaModule(L, ctx)
 
 	// Subscribe to the target agent's command subject.
-	received := make(chan []byte, 1)
+	received := make(chan *nats.Msg, 1)
 	sub, err := nc.Subscribe("sekia.commands.github-agent", func(msg *nats.Msg) {
-		received <- msg.Data
+		received <- msg
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -160,7 +230,7 @@ func TestLuaCommand(t *testing.T) {
 	defer sub.Unsubscribe()
 
 	err = L.DoString(`
-		sekia.command("github-agent", "add_label", {
+		command_id = sekia.command("github-agent", "add_label", {
 			issue = 42,
 			label = "bug",
 		})
@@ -171,11 +241,18 @@ func TestLuaCommand(t *testing.T) {
 	nc.Flush()
 
 	select {
-	case data := <-received:
+	case natsMsg := <-received:
 		var msg map[string]any
-		if err := json.Unmarshal(data, &msg); err != nil {
+		if err := json.Unmarshal(natsMsg.Data, &msg); err != nil {
 			t.Fatalf("unmarshal: %v", err)
 		}
+		commandID := L.GetGlobal("command_id").String()
+		if msg["id"] != commandID || commandID == "" {
+			t.Errorf("id = %v, want returned command_id %q", msg["id"], commandID)
+		}
+		if got := natsMsg.Header.Get(nats.MsgIdHdr); got != commandID {
+			t.Errorf("Nats-Msg-Id = %q, want %q", got, commandID)
+		}
 		if msg["command"] != "add_label" {
 			t.Errorf("command = %v, want add_label", msg["command"])
 		}
@@ -191,6 +268,129 @@ func TestLuaCommand(t *testing.T) {
 	}
 }
 
+func TestLuaCommandSync(t *testing.T) {
+	_, nc := startTestNATS(t)
+
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	ctx := &moduleContext{
+		name:   "test-wf",
+		nc:     nc,
+		logger: testLogger(),
+	}
+	registerSekiaModule(L, ctx)
+
+	// Stand in for the agent: reply with a structured result, or an error
+	// for unknown commands.
+	sub, err := nc.Subscribe("sekia.commands.linear-agent.sync", func(msg *nats.Msg) {
+		var cmd protocol.Command
+		json.Unmarshal(msg.Data, &cmd)
+		res := protocol.CommandResult{ID: cmd.ID, Agent: "linear-agent", Command: cmd.Command, Attempts: 1}
+		if cmd.Command == "create_issue" {
+			res.Status = protocol.ResultOK
+			res.Result = map[string]any{"issue_id": "iss_" + cmd.Payload["title"].(string)}
+		} else {
+			res.Status = protocol.ResultError
+			res.Error = "unknown command: " + cmd.Command
+		}
+		data, _ := json.Marshal(res)
+		msg.Respond(data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	err = L.DoString(`
+		res, err = sekia.command_sync("linear-agent", "create_issue", { title = "abc" }, 2)
+		issue_id = res and res.issue_id
+		create_err = err
+
+		res2, bad_err = sekia.command_sync("linear-agent", "explode", {})
+		bad_res = res2
+
+		_, offline_err = sekia.command_sync("offline-agent", "anything", {}, 1)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	if got := L.GetGlobal("issue_id").String(); got != "iss_abc" {
+		t.Errorf("issue_id = %q, want iss_abc", got)
+	}
+	if got := L.GetGlobal("create_err"); got != lua.LNil {
+		t.Errorf("create_err = %v, want nil", got)
+	}
+	if got := L.GetGlobal("bad_res"); got != lua.LNil {
+		t.Errorf("bad_res = %v, want nil", got)
+	}
+	if got := L.GetGlobal("bad_err").String(); got != "unknown command: explode" {
+		t.Errorf("bad_err = %q", got)
+	}
+	if got := L.GetGlobal("offline_err").String(); !strings.Contains(got, "not connected") {
+		t.Errorf("offline_err = %q, want not connected", got)
+	}
+}
+
+func TestLuaCommand_Policy(t *testing.T) {
+	policy, err := protocol.ParseCommandPolicy([]byte(`
+[[sources."workflow:test-wf".allow]]
+agent = "slack-agent"
+commands = ["send_reply"]
+payload = { channel = "C*" }
+
+[[sources."skill:*".allow]]
+agent = "*"
+commands = ["*"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(name, src string) (*lua.LState, []protocol.Intent, int64) {
+		t.Helper()
+		L := NewSandboxedState(name, testLogger())
+		t.Cleanup(L.Close)
+		var intents []protocol.Intent
+		ctx := &moduleContext{
+			name:      name,
+			logger:    testLogger(),
+			policy:    policy,
+			denied:    new(atomic.Int64),
+			intercept: func(in protocol.Intent) { intents = append(intents, in) },
+		}
+		registerSekiaModule(L, ctx)
+		if err := L.DoString(src); err != nil {
+			t.Fatalf("DoString: %v", err)
+		}
+		return L, intents, ctx.denied.Load()
+	}
+
+	L, intents, denied := run("test-wf", `
+		sekia.command("slack-agent", "send_reply", { channel = "C123", text = "hi" })
+		ok, delete_err = pcall(sekia.command, "google-agent", "delete", { id = "m1" })
+		_, channel_err = sekia.command_sync("slack-agent", "send_reply", { channel = "D9", text = "hi" })
+	`)
+	if len(intents) != 1 || intents[0].Command != "send_reply" {
+		t.Errorf("intents = %+v, want only the allowed send_reply", intents)
+	}
+	if denied != 2 {
+		t.Errorf("denied = %d, want 2", denied)
+	}
+	if got := L.GetGlobal("delete_err").String(); !strings.Contains(got, "workflow:test-wf may not send delete to google-agent") {
+		t.Errorf("delete_err = %q", got)
+	}
+	if got := L.GetGlobal("channel_err").String(); !strings.Contains(got, "payload field channel = D9 is not allowed") {
+		t.Errorf("channel_err = %q", got)
+	}
+
+	_, intents, denied = run("skill:triage", `sekia.command("google-agent", "delete", { id = "m1" })`)
+	if len(intents) != 1 || denied != 0 {
+		t.Errorf("skill: intents = %+v, denied = %d", intents, denied)
+	}
+}
+
 func TestLuaLog(t *testing.T) {
 	_, nc := startTestNATS(t)
 
@@ -1,12 +1,16 @@
 package workflow
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	lua "github.com/yuin/gopher-lua"
 )
 
 func TestLuaSchedule_Registration(t *testing.T) {
@@ -111,3 +115,163 @@ end)
 		t.Fatal("timed out waiting for scheduled event")
 	}
 }
+
+func TestLuaCron_Registration(t *testing.T) {
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	ctx := &moduleContext{name: "test-wf", logger: testLogger()}
+	registerSekiaModule(L, ctx)
+
+	err := L.DoString(`
+		sekia.cron("0 9 * * MON-FRI", function() end, { tz = "UTC", jitter = 30, catchup = "once" })
+		sekia.cron("@hourly", function() end)
+		sekia.cron("@hourly", function() end)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	if len(ctx.crons) != 3 {
+		t.Fatalf("expected 3 crons, got %d", len(ctx.crons))
+	}
+	c := ctx.crons[0]
+	if c.loc != time.UTC || c.jitter != 30*time.Second || c.catchup != cronCatchupOnce {
+		t.Errorf("cron[0] = %+v", c)
+	}
+	if ctx.crons[1].catchup != cronCatchupSkip {
+		t.Errorf("default catchup = %q, want skip", ctx.crons[1].catchup)
+	}
+	if ctx.crons[1].key == ctx.crons[2].key {
+		t.Error("identical crons share a persistence key")
+	}
+
+	for _, code := range []string{
+		`sekia.cron("not a cron", function() end)`,
+		`sekia.cron("@daily", function() end, { tz = "Mars/Olympus" })`,
+		`sekia.cron("@daily", function() end, { catchup = "always" })`,
+	} {
+		if err := L.DoString(code); err == nil {
+			t.Errorf("%s: expected error", code)
+		}
+	}
+}
+
+func TestCron_PersistedNextFire(t *testing.T) {
+	newWS := func(store StateStore, catchup string) (*workflowState, *cronEntry) {
+		sched, _ := parseCron("0 9 * * *")
+		c := &cronEntry{Expr: "0 9 * * *", key: "k", sched: sched, loc: time.UTC, catchup: catchup}
+		ws := &workflowState{
+			modCtx:   &moduleContext{name: "wf", logger: testLogger(), state: store, stateNS: "wf"},
+			crons:    []*cronEntry{c},
+			cronNext: make(map[*cronEntry]time.Time),
+		}
+		return ws, c
+	}
+	run := func(ws *workflowState, c *cronEntry) (fired bool) {
+		ticks := make(chan *lua.LFunction, 1)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			ws.runCron(c, ticks, stop)
+			close(done)
+		}()
+		// Wait for the goroutine to record its next fire time.
+		deadline := time.Now().Add(5 * time.Second)
+		for ws.scheduleInfo()[0].NextRun.IsZero() && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(stop)
+		<-done
+		select {
+		case <-ticks:
+			return true
+		default:
+			return false
+		}
+	}
+	persist := func(store StateStore, next time.Time) {
+		data, _ := json.Marshal(next)
+		store.Set(context.Background(), "cron:wf", "k", data, 0)
+	}
+
+	// A fire time still in the future is kept across restarts.
+	store := newMemoryState(nil)
+	future := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Minute)
+	persist(store, future)
+	ws, c := newWS(store, cronCatchupOnce)
+	if run(ws, c) {
+		t.Error("fired although the persisted run is in the future")
+	}
+	if got := ws.scheduleInfo()[0].NextRun; !got.Equal(future) {
+		t.Errorf("next run = %s, want persisted %s", got, future)
+	}
+
+	// A missed run fires once with catchup = "once"...
+	store = newMemoryState(nil)
+	persist(store, time.Now().Add(-time.Hour))
+	ws, c = newWS(store, cronCatchupOnce)
+	if !run(ws, c) {
+		t.Error("missed run not caught up with catchup = once")
+	}
+	if next := ws.scheduleInfo()[0].NextRun; !next.After(time.Now()) {
+		t.Errorf("next run after catch-up = %s, want future", next)
+	}
+
+	// ...and is skipped by default.
+	store = newMemoryState(nil)
+	persist(store, time.Now().Add(-time.Hour))
+	ws, c = newWS(store, cronCatchupSkip)
+	if run(ws, c) {
+		t.Error("missed run fired with catchup = skip")
+	}
+
+	// A fire time that fell due moments ago (say, during a reload) is not
+	// a missed run.
+	store = newMemoryState(nil)
+	persist(store, time.Now().Add(-5*time.Second))
+	ws, c = newWS(store, cronCatchupSkip)
+	if !run(ws, c) {
+		t.Error("run due seconds ago skipped as missed")
+	}
+}
+
+func TestCron_ClaimsEachFireOnce(t *testing.T) {
+	// The old and new instances of a reloaded workflow wait for the same
+	// persisted fire time; only one of them may run it.
+	store := newMemoryState(nil)
+	fire := time.Now().Add(100 * time.Millisecond)
+	data, _ := json.Marshal(fire.UTC())
+	store.Set(context.Background(), "cron:wf", "k", data, 0)
+
+	sched, _ := parseCron("0 9 * * *")
+	ticks := make(chan *lua.LFunction, 2)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 2 {
+		c := &cronEntry{Expr: "0 9 * * *", key: "k", sched: sched, loc: time.UTC, jitter: time.Minute, catchup: cronCatchupSkip}
+		ws := &workflowState{
+			modCtx:   &moduleContext{name: "wf", logger: testLogger(), state: store, stateNS: "wf"},
+			crons:    []*cronEntry{c},
+			cronNext: make(map[*cronEntry]time.Time),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws.runCron(c, ticks, stop)
+		}()
+	}
+	time.Sleep(500 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	if n := len(ticks); n != 1 {
+		t.Errorf("fired %d times, want 1", n)
+	}
+	data, _, _ = store.Get(context.Background(), "cron:wf", "k")
+	var next time.Time
+	json.Unmarshal(data, &next)
+	if !next.After(fire) {
+		t.Errorf("persisted fire time = %s, want after %s", next, fire)
+	}
+}
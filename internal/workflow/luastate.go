@@ -0,0 +1,299 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// StateStore is the interface the workflow engine uses for persistent
+// key-value state. Keys are namespaced by workflow; values are JSON.
+type StateStore interface {
+	// Get returns the value and revision of key. Revision 0 means the key
+	// does not exist.
+	Get(ctx context.Context, workflow, key string) ([]byte, uint64, error)
+	// Set stores value under key. A positive ttl makes the key expire.
+	Set(ctx context.Context, workflow, key string, value []byte, ttl time.Duration) error
+	// CompareAndSet stores value only if key is still at revision (0 = key
+	// must not exist) and reports whether it did.
+	CompareAndSet(ctx context.Context, workflow, key string, value []byte, revision uint64, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, workflow, key string) error
+	// Incr atomically adds delta to the integer under key and returns the result.
+	Incr(ctx context.Context, workflow, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// stateTimeout bounds a single sekia.state call.
+const stateTimeout = 10 * time.Second
+
+// registerStateModule adds the sekia.state table:
+//
+//	sekia.state.get(key) -> value | nil
+//	sekia.state.set(key, value [, ttl])
+//	sekia.state.delete(key)
+//	sekia.state.incr(key [, ttl [, by]]) -> number
+//	sekia.state.cas(key, expected, value [, ttl]) -> bool
+//
+// ttl is in seconds. Values may be strings, numbers, booleans or tables.
+func registerStateModule(L *lua.LState, mod *lua.LTable, ctx *moduleContext) {
+	st := L.NewTable()
+	L.SetField(st, "get", L.NewFunction(ctx.luaStateGet))
+	L.SetField(st, "set", L.NewFunction(ctx.luaStateSet))
+	L.SetField(st, "delete", L.NewFunction(ctx.luaStateDelete))
+	L.SetField(st, "incr", L.NewFunction(ctx.luaStateIncr))
+	L.SetField(st, "cas", L.NewFunction(ctx.luaStateCAS))
+	L.SetField(mod, "state", st)
+}
+
+func (ctx *moduleContext) luaStateGet(L *lua.LState) int {
+	key := L.CheckString(1)
+	c, cancel := ctx.stateContext(L)
+	defer cancel()
+
+	data, rev, err := ctx.state.Get(c, ctx.stateNS, key)
+	if err != nil {
+		L.RaiseError("sekia.state.get: %s", err)
+		return 0
+	}
+	if rev == 0 {
+		L.Push(lua.LNil)
+		return 1
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		L.RaiseError("sekia.state.get: decode %s: %s", key, err)
+		return 0
+	}
+	L.Push(GoToLua(L, v))
+	return 1
+}
+
+func (ctx *moduleContext) luaStateSet(L *lua.LState) int {
+	key := L.CheckString(1)
+	value := L.Get(2)
+	ttl := checkStateTTL(L, 3)
+	c, cancel := ctx.stateContext(L)
+	defer cancel()
+
+	if value == lua.LNil {
+		if err := ctx.state.Delete(c, ctx.stateNS, key); err != nil {
+			L.RaiseError("sekia.state.set: %s", err)
+		}
+		return 0
+	}
+	data := encodeStateValue(L, 2, value)
+	if err := ctx.state.Set(c, ctx.stateNS, key, data, ttl); err != nil {
+		L.RaiseError("sekia.state.set: %s", err)
+	}
+	return 0
+}
+
+func (ctx *moduleContext) luaStateDelete(L *lua.LState) int {
+	key := L.CheckString(1)
+	c, cancel := ctx.stateContext(L)
+	defer cancel()
+
+	if err := ctx.state.Delete(c, ctx.stateNS, key); err != nil {
+		L.RaiseError("sekia.state.delete: %s", err)
+	}
+	return 0
+}
+
+func (ctx *moduleContext) luaStateIncr(L *lua.LState) int {
+	key := L.CheckString(1)
+	ttl := checkStateTTL(L, 2)
+	by := L.OptNumber(3, 1)
+	if float64(by) != math.Trunc(float64(by)) {
+		L.ArgError(3, "increment must be an integer")
+		return 0
+	}
+	c, cancel := ctx.stateContext(L)
+	defer cancel()
+
+	n, err := ctx.state.Incr(c, ctx.stateNS, key, int64(by), ttl)
+	if err != nil {
+		L.RaiseError("sekia.state.incr: %s", err)
+		return 0
+	}
+	L.Push(lua.LNumber(n))
+	return 1
+}
+
+// luaStateCAS sets key to value only if its current value equals expected
+// (nil = key must not exist). Returns true if the value was stored.
+func (ctx *moduleContext) luaStateCAS(L *lua.LState) int {
+	key := L.CheckString(1)
+	expected := L.Get(2)
+	value := L.Get(3)
+	if value == lua.LNil {
+		L.ArgError(3, "value must not be nil")
+		return 0
+	}
+	data := encodeStateValue(L, 3, value)
+	ttl := checkStateTTL(L, 4)
+	c, cancel := ctx.stateContext(L)
+	defer cancel()
+
+	cur, rev, err := ctx.state.Get(c, ctx.stateNS, key)
+	if err != nil {
+		L.RaiseError("sekia.state.cas: %s", err)
+		return 0
+	}
+	if rev == 0 {
+		if expected != lua.LNil {
+			L.Push(lua.LFalse)
+			return 1
+		}
+	} else {
+		var curVal any
+		if expected == lua.LNil || json.Unmarshal(cur, &curVal) != nil || !reflect.DeepEqual(curVal, LuaToGo(expected)) {
+			L.Push(lua.LFalse)
+			return 1
+		}
+	}
+
+	ok, err := ctx.state.CompareAndSet(c, ctx.stateNS, key, data, rev, ttl)
+	if err != nil {
+		L.RaiseError("sekia.state.cas: %s", err)
+		return 0
+	}
+	L.Push(lua.LBool(ok))
+	return 1
+}
+
+// stateContext returns the context for a state call, raising a Lua error if
+// no state store is configured. It derives from the handler's context so
+// handler timeouts also cancel pending state calls.
+func (ctx *moduleContext) stateContext(L *lua.LState) (context.Context, context.CancelFunc) {
+	if ctx.state == nil {
+		L.RaiseError("workflow state not available: the daemon runs without JetStream")
+	}
+	parent := L.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, stateTimeout)
+}
+
+// checkStateTTL reads an optional TTL in seconds at argument n.
+func checkStateTTL(L *lua.LState, n int) time.Duration {
+	secs := L.OptNumber(n, 0)
+	if secs < 0 || (secs > 0 && secs < 1) {
+		L.ArgError(n, "ttl must be at least 1 second")
+		return 0
+	}
+	return time.Duration(float64(secs) * float64(time.Second))
+}
+
+// encodeStateValue converts the Lua value at argument n to JSON.
+func encodeStateValue(L *lua.LState, n int, v lua.LValue) []byte {
+	switch v.Type() {
+	case lua.LTString, lua.LTNumber, lua.LTBool, lua.LTTable:
+	default:
+		L.ArgError(n, "value must be a string, number, boolean or table")
+		return nil
+	}
+	data, err := json.Marshal(LuaToGo(v))
+	if err != nil {
+		L.ArgError(n, err.Error())
+		return nil
+	}
+	return data
+}
+
+// memoryState is an in-memory StateStore. Reads fall through to base (if
+// set) for keys not written locally, so a dry run sees the real state but
+// never changes it. TTLs are ignored.
+type memoryState struct {
+	base StateStore
+
+	mu     sync.Mutex
+	values map[string]memoryValue
+	rev    uint64
+}
+
+type memoryValue struct {
+	data     []byte // nil = deleted
+	revision uint64
+}
+
+func newMemoryState(base StateStore) *memoryState {
+	return &memoryState{base: base, values: make(map[string]memoryValue)}
+}
+
+func (m *memoryState) Get(ctx context.Context, workflow, key string) ([]byte, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(ctx, workflow, key)
+}
+
+// get is Get with m.mu held, so read-modify-write operations are atomic.
+func (m *memoryState) get(ctx context.Context, workflow, key string) ([]byte, uint64, error) {
+	if v, ok := m.values[workflow+"\x00"+key]; ok {
+		if v.data == nil {
+			return nil, 0, nil
+		}
+		return v.data, v.revision, nil
+	}
+	if m.base == nil {
+		return nil, 0, nil
+	}
+	return m.base.Get(ctx, workflow, key)
+}
+
+func (m *memoryState) Set(_ context.Context, workflow, key string, value []byte, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(workflow, key, value)
+	return nil
+}
+
+func (m *memoryState) CompareAndSet(ctx context.Context, workflow, key string, value []byte, revision uint64, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, cur, err := m.get(ctx, workflow, key)
+	if err != nil {
+		return false, err
+	}
+	if cur != revision {
+		return false, nil
+	}
+	m.put(workflow, key, value)
+	return true, nil
+}
+
+func (m *memoryState) Delete(_ context.Context, workflow, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[workflow+"\x00"+key] = memoryValue{}
+	return nil
+}
+
+func (m *memoryState) Incr(ctx context.Context, workflow, key string, delta int64, _ time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, rev, err := m.get(ctx, workflow, key)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if rev != 0 {
+		if err := json.Unmarshal(data, &n); err != nil {
+			return 0, err
+		}
+	}
+	n += delta
+	m.put(workflow, key, []byte(strconv.FormatInt(n, 10)))
+	return n, nil
+}
+
+// put stores a value under a fresh revision. Caller holds m.mu.
+func (m *memoryState) put(workflow, key string, value []byte) {
+	m.rev++
+	m.values[workflow+"\x00"+key] = memoryValue{data: value, revision: m.rev}
+}
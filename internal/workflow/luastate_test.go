@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLuaState(t *testing.T) {
+	store := newMemoryState(nil)
+
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	ctx := &moduleContext{
+		name:    "test-wf",
+		logger:  testLogger(),
+		state:   store,
+		stateNS: "test-wf",
+	}
+	registerSekiaModule(L, ctx)
+
+	err := L.DoString(`
+		assert(sekia.state.get("missing") == nil, "missing key should be nil")
+
+		sekia.state.set("greeting", "hello")
+		assert(sekia.state.get("greeting") == "hello", "string round trip")
+
+		sekia.state.set("seen", { ids = { "a", "b" }, at = 42 }, 3600)
+		local seen = sekia.state.get("seen")
+		assert(seen.at == 42 and seen.ids[2] == "b", "table round trip")
+
+		assert(sekia.state.incr("count") == 1, "first incr")
+		assert(sekia.state.incr("count") == 2, "second incr")
+		assert(sekia.state.incr("count", nil, 5) == 7, "incr by 5")
+
+		assert(sekia.state.cas("lock", nil, "mine") == true, "cas on absent key")
+		assert(sekia.state.cas("lock", nil, "theirs") == false, "cas on existing key")
+		assert(sekia.state.cas("lock", "mine", "released") == true, "cas with matching value")
+		assert(sekia.state.get("lock") == "released", "cas stored value")
+
+		sekia.state.delete("greeting")
+		assert(sekia.state.get("greeting") == nil, "deleted key should be nil")
+		sekia.state.set("count", nil)
+		assert(sekia.state.get("count") == nil, "set nil deletes")
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	// Keys are namespaced by workflow.
+	if _, rev, _ := store.Get(context.Background(), "other-wf", "lock"); rev != 0 {
+		t.Error("key visible in another workflow's namespace")
+	}
+}
+
+func TestLuaState_Errors(t *testing.T) {
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	ctx := &moduleContext{name: "test-wf", logger: testLogger(), state: newMemoryState(nil), stateNS: "test-wf"}
+	registerSekiaModule(L, ctx)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`sekia.state.set("k", "v", 0.5)`, "ttl must be at least 1 second"},
+		{`sekia.state.set("k", function() end)`, "value must be"},
+		{`sekia.state.incr("k", nil, 1.5)`, "increment must be an integer"},
+	}
+	for _, tt := range tests {
+		err := L.DoString(tt.code)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+
+	// No store configured.
+	L2 := NewSandboxedState("test-wf", testLogger())
+	defer L2.Close()
+	registerSekiaModule(L2, &moduleContext{name: "test-wf", logger: testLogger()})
+	if err := L2.DoString(`sekia.state.get("k")`); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("get without store: err = %v", err)
+	}
+}
+
+func TestMemoryStateReadsThrough(t *testing.T) {
+	ctx := context.Background()
+	base := newMemoryState(nil)
+	base.Set(ctx, "wf", "k", []byte(`"real"`), 0)
+
+	overlay := newMemoryState(base)
+	if v, _, _ := overlay.Get(ctx, "wf", "k"); string(v) != `"real"` {
+		t.Errorf("overlay read = %s, want base value", v)
+	}
+	overlay.Set(ctx, "wf", "k", []byte(`"dry"`), 0)
+	overlay.Delete(ctx, "wf", "other")
+	if v, _, _ := base.Get(ctx, "wf", "k"); string(v) != `"real"` {
+		t.Errorf("base changed by overlay write: %s", v)
+	}
+	if v, _, _ := overlay.Get(ctx, "wf", "k"); string(v) != `"dry"` {
+		t.Errorf("overlay read after write = %s", v)
+	}
+}
@@ -0,0 +1,126 @@
+package workflow
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// TimerStore is the interface the workflow engine uses to durably schedule
+// delayed event publishes (sekia.after and sekia.at).
+type TimerStore interface {
+	// Schedule persists a publish of eventType with payload on subject at
+	// fireAt and returns the timer ID.
+	Schedule(ctx context.Context, workflow string, fireAt time.Time, subject, eventType string, payload map[string]any) (string, error)
+	// Cancel removes a pending timer owned by workflow and reports whether
+	// one was removed.
+	Cancel(ctx context.Context, workflow, id string) (bool, error)
+}
+
+// timerTimeout bounds a single sekia.after, sekia.at or sekia.cancel call.
+const timerTimeout = 10 * time.Second
+
+// luaAfter schedules a delayed event: sekia.after(seconds, subject, event_type, payload) -> id
+func (ctx *moduleContext) luaAfter(L *lua.LState) int {
+	secs := L.CheckNumber(1)
+	if secs < 0 {
+		L.ArgError(1, "delay must not be negative")
+		return 0
+	}
+	fireAt := time.Now().Add(time.Duration(float64(secs) * float64(time.Second)))
+	return ctx.scheduleEvent(L, "sekia.after", fireAt)
+}
+
+// luaAt schedules an event at a unix time: sekia.at(unix_ts, subject, event_type, payload) -> id
+// A time in the past fires right away.
+func (ctx *moduleContext) luaAt(L *lua.LState) int {
+	ts := float64(L.CheckNumber(1))
+	sec, frac := math.Modf(ts)
+	fireAt := time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	return ctx.scheduleEvent(L, "sekia.at", fireAt)
+}
+
+// scheduleEvent reads subject, event_type and payload from arguments 2-4 and
+// schedules their publish at fireAt, pushing the timer ID.
+func (ctx *moduleContext) scheduleEvent(L *lua.LState, fn string, fireAt time.Time) int {
+	eventType := L.CheckString(3)
+	subject := protocol.ExpandEventSubject(ctx.subjects, L.CheckString(2), eventType)
+	payloadTbl := L.CheckTable(4)
+
+	payload, ok := TableToMap(payloadTbl).(map[string]any)
+	if !ok {
+		L.ArgError(4, "expected a table with string keys")
+		return 0
+	}
+
+	if ctx.intercept != nil {
+		ctx.intercept(protocol.Intent{
+			Kind:      protocol.IntentSchedule,
+			Subject:   subject,
+			EventType: eventType,
+			Payload:   payload,
+			FireAt:    fireAt.UTC(),
+			EventID:   ctx.currentEventID,
+			At:        time.Now().UTC(),
+		})
+		L.Push(lua.LString("tmr_dryrun_" + uuid.NewString()))
+		return 1
+	}
+
+	c, cancel := ctx.timerContext(L)
+	defer cancel()
+	id, err := ctx.timers.Schedule(c, ctx.name, fireAt, subject, eventType, payload)
+	if err != nil {
+		L.RaiseError("%s: %s", fn, err)
+		return 0
+	}
+
+	ctx.logger.Debug().
+		Str("id", id).
+		Str("subject", subject).
+		Str("event_type", eventType).
+		Time("fire_at", fireAt).
+		Msg("scheduled delayed event")
+
+	L.Push(lua.LString(id))
+	return 1
+}
+
+// luaCancel cancels a pending delayed event: sekia.cancel(id) -> bool
+// Returns false if the timer already fired, was cancelled, or belongs to
+// another workflow.
+func (ctx *moduleContext) luaCancel(L *lua.LState) int {
+	id := L.CheckString(1)
+	if ctx.intercept != nil {
+		L.Push(lua.LFalse)
+		return 1
+	}
+
+	c, cancel := ctx.timerContext(L)
+	defer cancel()
+	ok, err := ctx.timers.Cancel(c, ctx.name, id)
+	if err != nil {
+		L.RaiseError("sekia.cancel: %s", err)
+		return 0
+	}
+	L.Push(lua.LBool(ok))
+	return 1
+}
+
+// timerContext returns the context for a timer call, raising a Lua error if
+// no timer store is configured.
+func (ctx *moduleContext) timerContext(L *lua.LState) (context.Context, context.CancelFunc) {
+	if ctx.timers == nil {
+		L.RaiseError("delayed events not available: the daemon runs without JetStream")
+	}
+	parent := L.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, timerTimeout)
+}
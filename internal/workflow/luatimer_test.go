@@ -0,0 +1,131 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// memoryTimers is an in-memory TimerStore.
+type memoryTimers struct {
+	timers map[string]protocol.Timer
+	n      int
+}
+
+func newMemoryTimers() *memoryTimers {
+	return &memoryTimers{timers: make(map[string]protocol.Timer)}
+}
+
+func (m *memoryTimers) Schedule(_ context.Context, workflow string, fireAt time.Time, subject, eventType string, payload map[string]any) (string, error) {
+	m.n++
+	id := fmt.Sprintf("tmr_%d", m.n)
+	m.timers[id] = protocol.Timer{ID: id, Workflow: workflow, Subject: subject, EventType: eventType, Payload: payload, FireAt: fireAt}
+	return id, nil
+}
+
+func (m *memoryTimers) Cancel(_ context.Context, workflow, id string) (bool, error) {
+	t, ok := m.timers[id]
+	if !ok || t.Workflow != workflow {
+		return false, nil
+	}
+	delete(m.timers, id)
+	return true, nil
+}
+
+func TestLuaTimers(t *testing.T) {
+	timers := newMemoryTimers()
+	timers.timers["tmr_other"] = protocol.Timer{ID: "tmr_other", Workflow: "other-wf"}
+
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	ctx := &moduleContext{name: "test-wf", logger: testLogger(), timers: timers}
+	registerSekiaModule(L, ctx)
+
+	start := time.Now()
+	err := L.DoString(`
+		remind = sekia.after(7200, "sekia.events.reminders", "pr.nudge", { pr = 42 })
+		followup = sekia.at(1900000000, "sekia.events.reminders", "email.followup", { thread = "t1" })
+		assert(type(remind) == "string" and remind ~= followup, "ids returned")
+
+		assert(sekia.cancel(followup) == true, "cancel own timer")
+		assert(sekia.cancel(followup) == false, "cancel twice")
+		assert(sekia.cancel("tmr_other") == false, "cancel another workflow's timer")
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	if len(timers.timers) != 2 {
+		t.Fatalf("expected 2 pending timers, got %d", len(timers.timers))
+	}
+	remind := timers.timers["tmr_1"]
+	if remind.Workflow != "test-wf" || remind.Subject != "sekia.events.reminders" || remind.EventType != "pr.nudge" || remind.Payload["pr"] != float64(42) {
+		t.Errorf("timer = %+v", remind)
+	}
+	if d := remind.FireAt.Sub(start); d < 2*time.Hour || d > 2*time.Hour+time.Minute {
+		t.Errorf("sekia.after(7200) fires in %s, want 2h", d)
+	}
+	if _, ok := timers.timers["tmr_other"]; !ok {
+		t.Error("another workflow's timer was cancelled")
+	}
+}
+
+func TestLuaTimers_Intercepted(t *testing.T) {
+	timers := newMemoryTimers()
+	var intents []protocol.Intent
+
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	ctx := &moduleContext{
+		name:      "test-wf",
+		logger:    testLogger(),
+		timers:    timers,
+		intercept: func(in protocol.Intent) { intents = append(intents, in) },
+	}
+	registerSekiaModule(L, ctx)
+
+	err := L.DoString(`
+		local id = sekia.at(1900000000, "sekia.events.reminders", "email.followup", { thread = "t1" })
+		assert(type(id) == "string", "id returned")
+		assert(sekia.cancel(id) == false, "cancel is a no-op")
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	if len(timers.timers) != 0 {
+		t.Errorf("intercepted call scheduled %d timers", len(timers.timers))
+	}
+	if len(intents) != 1 {
+		t.Fatalf("expected 1 intent, got %d", len(intents))
+	}
+	in := intents[0]
+	if in.Kind != protocol.IntentSchedule || in.EventType != "email.followup" || !in.FireAt.Equal(time.Unix(1900000000, 0)) {
+		t.Errorf("intent = %+v", in)
+	}
+}
+
+func TestLuaTimers_Errors(t *testing.T) {
+	L := NewSandboxedState("test-wf", testLogger())
+	defer L.Close()
+
+	registerSekiaModule(L, &moduleContext{name: "test-wf", logger: testLogger()})
+
+	err := L.DoString(`sekia.after(60, "sekia.events.x", "x", {})`)
+	if err == nil || !strings.Contains(err.Error(), "delayed events not available") {
+		t.Errorf("without store: err = %v", err)
+	}
+
+	L2 := NewSandboxedState("test-wf", testLogger())
+	defer L2.Close()
+	registerSekiaModule(L2, &moduleContext{name: "test-wf", logger: testLogger(), timers: newMemoryTimers()})
+	if err := L2.DoString(`sekia.after(-1, "sekia.events.x", "x", {})`); err == nil {
+		t.Error("negative delay: expected error")
+	}
+}
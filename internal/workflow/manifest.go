@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -59,14 +61,22 @@ func LoadManifest(dir string) (*Manifest, error) {
 
 // Verify checks that the file at filePath matches the expected hash in the manifest.
 func (m *Manifest) Verify(filename, filePath string) error {
-	expected, ok := m.entries[filename]
-	if !ok {
+	if _, ok := m.entries[filename]; !ok {
 		return fmt.Errorf("file %q not in manifest", filename)
 	}
 	actual, err := HashFile(filePath)
 	if err != nil {
 		return fmt.Errorf("hash %s: %w", filename, err)
 	}
+	return m.verifyHash(filename, actual)
+}
+
+// verifyHash checks an already computed hash against the manifest entry.
+func (m *Manifest) verifyHash(filename, actual string) error {
+	expected, ok := m.entries[filename]
+	if !ok {
+		return fmt.Errorf("file %q not in manifest", filename)
+	}
 	if actual != expected {
 		return fmt.Errorf("hash mismatch for %s: expected %s, got %s", filename, expected, actual)
 	}
@@ -93,6 +103,7 @@ func HashFile(path string) (string, error) {
 }
 
 // GenerateManifest scans a directory for .lua files and produces a manifest with their SHA256 hashes.
+// Libraries under the lib subdirectory are included with their relative paths (lib/name.lua).
 func GenerateManifest(dir string) (*Manifest, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -100,7 +111,7 @@ func GenerateManifest(dir string) (*Manifest, error) {
 	}
 	m := &Manifest{entries: make(map[string]string)}
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lua") {
+		if entry.IsDir() || !isWorkflowFile(entry.Name()) {
 			continue
 		}
 		hash, err := HashFile(filepath.Join(dir, entry.Name()))
@@ -109,6 +120,29 @@ func GenerateManifest(dir string) (*Manifest, error) {
 		}
 		m.entries[entry.Name()] = hash
 	}
+
+	err = filepath.WalkDir(filepath.Join(dir, LibDirname), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == filepath.Join(dir, LibDirname) {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".lua") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		hash, err := HashFile(path)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", rel, err)
+		}
+		m.entries[rel] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
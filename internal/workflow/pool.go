@@ -0,0 +1,171 @@
+package workflow
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+const (
+	// eventQueueSize is the capacity of a workflow's event channel.
+	eventQueueSize = 4096
+	// workerQueueSize is the capacity of each worker's channel when events
+	// are dispatched by key. It is kept small so that a busy key backs up
+	// the shared event channel, where it shows in the queue metrics.
+	workerQueueSize = 16
+	// maxConcurrency bounds sekia.concurrency().
+	maxConcurrency = 64
+)
+
+// luaWorker is one Lua VM of a workflow. Every workflow has at least one;
+// sekia.concurrency() adds more, each loaded from the same file.
+type luaWorker struct {
+	L      *lua.LState
+	modCtx *moduleContext
+	ch     chan *eventMsg // keyed deliveries (nil unless ordered by key)
+}
+
+// luaConcurrency opts a workflow into parallel event processing:
+// sekia.concurrency(n [, {key = "payload.repo"}])
+// key is a dotted path into the event, or a list of paths; events with the
+// same key are handled in order by the same VM. Without a key, events are
+// handled by whichever VM is free. Each extra VM runs the file again to
+// register its handlers, but top-level publishes, commands, timers and
+// state writes take effect once, and schedules run on the first VM only.
+func (ctx *moduleContext) luaConcurrency(L *lua.LState) int {
+	n := L.CheckInt(1)
+	opts := L.OptTable(2, L.NewTable())
+
+	if n < 1 || n > maxConcurrency {
+		L.ArgError(1, fmt.Sprintf("concurrency must be between 1 and %d", maxConcurrency))
+		return 0
+	}
+
+	var key []string
+	switch v := opts.RawGetString("key").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		key = []string{string(v)}
+	case *lua.LTable:
+		v.ForEach(func(_, p lua.LValue) {
+			key = append(key, p.String())
+		})
+	default:
+		L.ArgError(2, "key must be a string or a list of strings")
+		return 0
+	}
+	for _, p := range key {
+		if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") {
+			L.ArgError(2, fmt.Sprintf("invalid key path %q", p))
+			return 0
+		}
+	}
+
+	ctx.concurrency = n
+	ctx.orderKey = key
+
+	ctx.logger.Debug().
+		Int("concurrency", n).
+		Strs("key", key).
+		Msg("configured concurrency")
+
+	return 0
+}
+
+// runWorkers starts one goroutine per worker except the primary, plus the
+// key dispatcher if events are ordered by key. It returns the primary's
+// input channel and a WaitGroup that completes once the event channel is
+// closed and every started goroutine has drained.
+func (ws *workflowState) runWorkers() (<-chan *eventMsg, *sync.WaitGroup) {
+	var wg sync.WaitGroup
+	inputs := make([]<-chan *eventMsg, len(ws.workers))
+	for i, w := range ws.workers {
+		if w.ch != nil {
+			inputs[i] = w.ch
+		} else {
+			inputs[i] = ws.eventCh
+		}
+	}
+	if ws.workers[0].ch != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws.dispatch()
+		}()
+	}
+	for i, w := range ws.workers[1:] {
+		wg.Add(1)
+		go func(in <-chan *eventMsg) {
+			defer wg.Done()
+			for ev := range in {
+				ws.processEvent(w, ev)
+			}
+		}(inputs[i+1])
+	}
+	return inputs[0], &wg
+}
+
+// dispatch hands each event to the worker owning its key, so events with
+// the same key run in order. Events without a key go round-robin.
+func (ws *workflowState) dispatch() {
+	defer func() {
+		for _, w := range ws.workers {
+			close(w.ch)
+		}
+	}()
+
+	next := 0
+	for ev := range ws.eventCh {
+		var i int
+		if key := eventKey(ev.fields(), ws.modCtx.orderKey); key != "" {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			i = int(h.Sum32() % uint32(len(ws.workers)))
+		} else {
+			i = next
+			next = (next + 1) % len(ws.workers)
+		}
+		ws.workers[i].ch <- ev
+	}
+}
+
+// queued returns the number of events waiting for a worker.
+func (ws *workflowState) queued() int {
+	n := len(ws.eventCh)
+	for _, w := range ws.workers {
+		n += len(w.ch)
+	}
+	return n
+}
+
+// queueCapacity returns the number of events that can wait before core
+// NATS deliveries are dropped.
+func (ws *workflowState) queueCapacity() int {
+	n := cap(ws.eventCh)
+	for _, w := range ws.workers {
+		n += cap(w.ch)
+	}
+	return n
+}
+
+// eventKey extracts the ordering key of a decoded event: the values at
+// paths, joined. It returns "" if no path resolves to a value.
+func eventKey(ev map[string]any, paths []string) string {
+	parts := make([]string, len(paths))
+	found := false
+	for i, p := range paths {
+		if v, ok := protocol.LookupPath(ev, p); ok {
+			parts[i] = fmt.Sprint(v)
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func TestEventKey(t *testing.T) {
+	ev := &eventMsg{data: []byte(`{"id":"e1","source":"github","payload":{"repo":"sekia","number":42,"thread":{"ts":"1.5"}}}`)}
+
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"payload.repo"}, "sekia"},
+		{[]string{"payload.number"}, "42"},
+		{[]string{"payload.thread.ts"}, "1.5"},
+		{[]string{"source", "payload.repo"}, "github\x00sekia"},
+		{[]string{"payload.missing"}, ""},
+		{[]string{"payload.repo.deeper"}, ""},
+	}
+	for _, tt := range tests {
+		if got := eventKey(ev.fields(), tt.paths); got != tt.want {
+			t.Errorf("eventKey(%v) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+	invalid := &eventMsg{data: []byte("not json")}
+	if got := eventKey(invalid.fields(), []string{"payload.repo"}); got != "" {
+		t.Errorf("eventKey(invalid) = %q, want empty", got)
+	}
+}
+
+func TestLuaConcurrency_Errors(t *testing.T) {
+	for _, code := range []string{
+		`sekia.concurrency(0)`,
+		`sekia.concurrency(1000)`,
+		`sekia.concurrency(4, { key = 5 })`,
+		`sekia.concurrency(4, { key = "payload." })`,
+	} {
+		L := NewSandboxedState("test-wf", testLogger())
+		registerSekiaModule(L, &moduleContext{name: "test-wf", logger: testLogger()})
+		if err := L.DoString(code); err == nil {
+			t.Errorf("%s: expected error", code)
+		}
+		L.Close()
+	}
+}
+
+// slowState delays every Get, standing in for a slow call such as sekia.ai.
+type slowState struct {
+	*memoryState
+	delay time.Duration
+}
+
+func (s slowState) Get(ctx context.Context, workflow, key string) ([]byte, uint64, error) {
+	time.Sleep(s.delay)
+	return s.memoryState.Get(ctx, workflow, key)
+}
+
+// loadPoolWorkflow builds and starts a workflow from src without NATS.
+func loadPoolWorkflow(t *testing.T, src string, store StateStore) *workflowState {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pool.lua")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(nil, dir, nil, 0, "", testLogger())
+	e.SetStateStore(store)
+	ws, err := e.buildWorkflow("pool", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ws.run()
+	t.Cleanup(func() { e.stopWorkflow(ws) })
+	return ws
+}
+
+func poolEvent(t *testing.T, payload map[string]any) *eventMsg {
+	t.Helper()
+	data, err := json.Marshal(protocol.NewEvent("test", "github", payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &eventMsg{subject: "sekia.events.github", data: data, attempts: 1}
+}
+
+func waitForEvents(t *testing.T, ws *workflowState, n int64, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for ws.events.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("processed %d of %d events within %s", ws.events.Load(), n, timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorkerPool_Parallel(t *testing.T) {
+	ws := loadPoolWorkflow(t, `
+		sekia.concurrency(4)
+		sekia.on("sekia.events.github", function(event)
+			sekia.state.get("slow")
+		end)
+	`, slowState{newMemoryState(nil), 200 * time.Millisecond})
+	if len(ws.workers) != 4 {
+		t.Fatalf("workers = %d, want 4", len(ws.workers))
+	}
+
+	start := time.Now()
+	for i := range 4 {
+		ws.eventCh <- poolEvent(t, map[string]any{"n": i})
+	}
+	waitForEvents(t, ws, 4, 5*time.Second)
+	// Serially this takes 800ms; in parallel about 200ms.
+	if d := time.Since(start); d > 600*time.Millisecond {
+		t.Errorf("4 events of 200ms took %s; want them handled in parallel", d)
+	}
+	if ws.errors.Load() != 0 {
+		t.Errorf("errors = %d", ws.errors.Load())
+	}
+}
+
+func TestWorkerPool_OrderedByKey(t *testing.T) {
+	store := newMemoryState(nil)
+	ws := loadPoolWorkflow(t, `
+		sekia.concurrency(4, { key = "payload.repo" })
+		sekia.on("sekia.events.github", function(event)
+			local key = "last:" .. event.payload.repo
+			local last = sekia.state.get(key) or 0
+			if event.payload.seq ~= last + 1 then
+				error("out of order: got " .. event.payload.seq .. " after " .. last)
+			end
+			sekia.state.set(key, event.payload.seq)
+		end)
+	`, store)
+
+	repos := []string{"alpha", "beta", "gamma", "delta", "epsilon"}
+	const perRepo = 40
+	for seq := 1; seq <= perRepo; seq++ {
+		for _, repo := range repos {
+			ws.eventCh <- poolEvent(t, map[string]any{"repo": repo, "seq": seq})
+		}
+	}
+	waitForEvents(t, ws, int64(perRepo*len(repos)), 10*time.Second)
+
+	if n := ws.errors.Load(); n != 0 {
+		t.Errorf("%d events handled out of order", n)
+	}
+	for _, repo := range repos {
+		data, _, _ := store.Get(t.Context(), "pool", "last:"+repo)
+		if strings.TrimSpace(string(data)) != fmt.Sprint(perRepo) {
+			t.Errorf("last %s = %s, want %d", repo, data, perRepo)
+		}
+	}
+}
+
+func TestWorkerPool_SchedulesRunOnce(t *testing.T) {
+	ws := loadPoolWorkflow(t, `
+		sekia.concurrency(3)
+		sekia.schedule(1, function() end)
+		sekia.on("sekia.events.github", function(event) end)
+	`, nil)
+	if n := len(ws.scheduleInfo()); n != 1 {
+		t.Errorf("schedules = %d, want 1 (primary VM only)", n)
+	}
+	if c := ws.queueCapacity(); c != eventQueueSize {
+		t.Errorf("queue capacity = %d, want %d", c, eventQueueSize)
+	}
+}
+
+func TestWorkerPool_TopLevelRunsOnce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pool.lua")
+	src := `-- sekia: shadow
+		sekia.concurrency(4)
+		sekia.publish("sekia.events.pool", "pool.loaded", {})
+		sekia.state.incr("loads")
+		sekia.on("sekia.events.github", function(event)
+			sekia.publish("sekia.events.pool", "pool.handled", {})
+		end)
+	`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := newMemoryState(nil)
+	e := New(nil, dir, nil, 0, "", testLogger())
+	e.SetStateStore(store)
+	ws, err := e.buildWorkflow("pool", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ws.run()
+	t.Cleanup(func() { e.stopWorkflow(ws) })
+
+	// Every VM's handlers still publish.
+	for i := range 8 {
+		ws.eventCh <- poolEvent(t, map[string]any{"n": i})
+	}
+	waitForEvents(t, ws, 8, 5*time.Second)
+
+	counts := map[string]int{}
+	intents, _ := e.shadowLogs["pool"].snapshot(0)
+	for _, in := range intents {
+		counts[in.EventType]++
+	}
+	if counts["pool.loaded"] != 1 || counts["pool.handled"] != 8 {
+		t.Errorf("published %v, want pool.loaded once and pool.handled 8 times", counts)
+	}
+	data, _, _ := store.Get(t.Context(), "shadow:pool", "loads")
+	if strings.TrimSpace(string(data)) != "1" {
+		t.Errorf("loads = %s, want 1", data)
+	}
+}
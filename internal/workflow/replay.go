@@ -0,0 +1,173 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// ReplayOptions selects the stored events fed to a workflow by Replay.
+type ReplayOptions struct {
+	Since   time.Time // replay events stored at or after this time
+	Subject string    // subject filter ("" = every event subject)
+	DryRun  bool      // run a throwaway copy and capture publishes/commands
+	Limit   int       // maximum number of events (0 = no limit)
+}
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	Events  int               // events fed to the workflow
+	Errors  int64             // handler errors (dry run only)
+	Intents []protocol.Intent // captured publishes and commands (dry run only)
+}
+
+// ErrEventLogDisabled is returned by Replay when the engine has no JetStream
+// event log to read from.
+var ErrEventLogDisabled = errors.New("event log not enabled")
+
+// ErrWorkflowNotLoaded is returned when the named workflow is not loaded.
+var ErrWorkflowNotLoaded = errors.New("workflow not loaded")
+
+// replayFetchWait bounds how long Replay waits for more stored events.
+const replayFetchWait = 2 * time.Second
+
+// Replay re-processes stored events from the SEKIA_EVENTS log through a single
+// workflow, without fanning out to the others. Only events matching one of the
+// workflow's handler patterns are delivered.
+//
+// Normally events are queued on the live workflow and processed like new
+// events, including any commands they send. With DryRun, a fresh copy of the
+// workflow processes them synchronously and its outgoing sekia.publish and
+// sekia.command calls are captured in the result instead of being sent.
+func (e *Engine) Replay(ctx context.Context, name string, opts ReplayOptions) (ReplayResult, error) {
+	if e.js == nil {
+		return ReplayResult{}, ErrEventLogDisabled
+	}
+
+	e.mu.RLock()
+	live, ok := e.workflows[name]
+	e.mu.RUnlock()
+	if !ok {
+		return ReplayResult{}, fmt.Errorf("%w: %s", ErrWorkflowNotLoaded, name)
+	}
+
+	var (
+		res     ReplayResult
+		ws      = live
+		mu      sync.Mutex
+		deliver func(*eventMsg) error
+	)
+	if opts.DryRun {
+		copyWS, err := e.buildWorkflow(name, live.filePath, func(in protocol.Intent) {
+			mu.Lock()
+			res.Intents = append(res.Intents, in)
+			mu.Unlock()
+		})
+		if err != nil {
+			return ReplayResult{}, err
+		}
+		copyWS.dryRun = true
+		defer copyWS.L.Close()
+		ws = copyWS
+		deliver = func(msg *eventMsg) error {
+			ws.processEvent(ws.workers[0], msg)
+			return nil
+		}
+	} else {
+		deliver = func(msg *eventMsg) error {
+			return e.deliverTo(ctx, name, msg)
+		}
+	}
+
+	cfg := jetstream.OrderedConsumerConfig{
+		DeliverPolicy: jetstream.DeliverByStartTimePolicy,
+		OptStartTime:  &opts.Since,
+	}
+	if opts.Subject != "" {
+		cfg.FilterSubjects = []string{opts.Subject}
+	}
+	cons, err := e.js.OrderedConsumer(ctx, protocol.StreamEvents, cfg)
+	if err != nil {
+		return ReplayResult{}, fmt.Errorf("open event log: %w", err)
+	}
+
+	selfSource := fmt.Sprintf("workflow:%s", name)
+	for {
+		batch, err := cons.Fetch(256, jetstream.FetchMaxWait(replayFetchWait))
+		if err != nil {
+			return res, fmt.Errorf("read event log: %w", err)
+		}
+		received, caughtUp := 0, false
+		for msg := range batch.Messages() {
+			received++
+			if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+				caughtUp = true
+			}
+			ev := &eventMsg{subject: msg.Subject(), data: msg.Data(), attempts: 1}
+			if !ws.handles(ev) || extractSource(msg.Data()) == selfSource {
+				continue
+			}
+			if err := deliver(ev); err != nil {
+				return res, err
+			}
+			res.Events++
+			if opts.Limit > 0 && res.Events >= opts.Limit {
+				caughtUp = true
+				break
+			}
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) && !errors.Is(err, jetstream.ErrNoMessages) {
+			return res, fmt.Errorf("read event log: %w", err)
+		}
+		if caughtUp || received == 0 {
+			break
+		}
+	}
+
+	if opts.DryRun {
+		res.Errors = ws.errors.Load()
+	}
+	e.logger.Info().
+		Str("workflow", name).
+		Time("since", opts.Since).
+		Str("subject", opts.Subject).
+		Bool("dry_run", opts.DryRun).
+		Int("events", res.Events).
+		Msg("replayed events")
+	return res, nil
+}
+
+// deliverTo queues an event on a live workflow, waiting for room in its
+// channel without holding e.mu.
+func (e *Engine) deliverTo(ctx context.Context, name string, msg *eventMsg) error {
+	e.mu.RLock()
+	ws, ok := e.workflows[name]
+	e.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("workflow %q is no longer loaded", name)
+	}
+	if err := ws.send(ctx, msg); err != nil {
+		if errors.Is(err, errWorkflowStopped) {
+			return fmt.Errorf("workflow %q is no longer loaded", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// handles reports whether any of the workflow's handlers wants the event.
+func (ws *workflowState) handles(ev *eventMsg) bool {
+	for _, h := range ws.modCtx.handlers {
+		if h.matches(ev) {
+			return true
+		}
+	}
+	return false
+}
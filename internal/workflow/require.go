@@ -0,0 +1,147 @@
+package workflow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LibDirname is the subdirectory of the workflow directory that require()
+// loads shared libraries from.
+const LibDirname = "lib"
+
+// libSegment matches one dot-separated part of a library name.
+var libSegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// libPath maps a library name such as "lib.slack.format" to its path
+// relative to the workflow directory ("lib/slack/format.lua"), which is
+// also its key in the manifest.
+func libPath(module string) (string, error) {
+	parts := strings.Split(module, ".")
+	if len(parts) < 2 || parts[0] != LibDirname {
+		return "", fmt.Errorf("module name must start with %q, got %q", LibDirname+".", module)
+	}
+	for _, p := range parts[1:] {
+		if !libSegment.MatchString(p) {
+			return "", fmt.Errorf("invalid module name %q", module)
+		}
+	}
+	return strings.Join(parts, "/") + ".lua", nil
+}
+
+// libLoader loads the libraries required by one workflow. It is shared by
+// the workflow's VMs and records which libraries the workflow depends on,
+// so that editing one reloads the workflows using it.
+type libLoader struct {
+	dir      string
+	chunks   *chunkCache
+	manifest *Manifest // verifies libraries when integrity verification is on
+
+	mu        sync.Mutex
+	deps      map[string]bool // library paths relative to dir
+	violation error           // first integrity violation, if any
+}
+
+func newLibLoader(dir string, chunks *chunkCache, manifest *Manifest) *libLoader {
+	return &libLoader{dir: dir, chunks: chunks, manifest: manifest, deps: make(map[string]bool)}
+}
+
+// load verifies and compiles the library at rel. The dependency is recorded
+// even if loading fails, so creating or fixing the file reloads the workflow.
+func (l *libLoader) load(rel string) (*lua.FunctionProto, error) {
+	l.mu.Lock()
+	l.deps[rel] = true
+	l.mu.Unlock()
+
+	path := filepath.Join(l.dir, filepath.FromSlash(rel))
+	src, err := os.ReadFile(path) // #nosec G304 -- path is built from validated name segments under the workflow dir
+	if err != nil {
+		return nil, err
+	}
+	hash := sourceHash(src)
+	if l.manifest != nil {
+		if err := l.manifest.verifyHash(rel, hash); err != nil {
+			err = fmt.Errorf("%w: %v", ErrIntegrityViolation, err)
+			l.mu.Lock()
+			if l.violation == nil {
+				l.violation = err
+			}
+			l.mu.Unlock()
+			return nil, err
+		}
+	}
+	proto, _, err := l.chunks.compile(path, src, hash)
+	return proto, err
+}
+
+// integrityErr returns the first library that failed manifest
+// verification. Lua errors carry only the message, so buildWorkflow uses
+// this to report the failure as ErrIntegrityViolation.
+func (l *libLoader) integrityErr() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.violation
+}
+
+// uses reports whether the workflow required any of the given libraries.
+func (l *libLoader) uses(changed map[string]bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for rel := range changed {
+		if l.deps[rel] {
+			return true
+		}
+	}
+	return false
+}
+
+// registerRequire installs a sandboxed require(name) that loads only
+// libraries under the lib directory. Like Lua's require, each library runs
+// once per VM and its return value (true if it returns nothing) is cached.
+func registerRequire(L *lua.LState, libs *libLoader) {
+	loaded := make(map[string]lua.LValue) // nil value = still loading
+	L.SetGlobal("require", L.NewFunction(func(L *lua.LState) int {
+		module := L.CheckString(1)
+		if v, ok := loaded[module]; ok {
+			if v == nil {
+				L.RaiseError("require %q: circular dependency", module)
+				return 0
+			}
+			L.Push(v)
+			return 1
+		}
+
+		rel, err := libPath(module)
+		if err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+		proto, err := libs.load(rel)
+		if err != nil {
+			L.RaiseError("require %q: %s", module, err)
+			return 0
+		}
+
+		loaded[module] = nil
+		L.Push(L.NewFunctionFromProto(proto))
+		L.Push(lua.LString(module))
+		if err := L.PCall(1, 1, nil); err != nil {
+			delete(loaded, module)
+			L.RaiseError("require %q: %s", module, err)
+			return 0
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+		if ret == lua.LNil {
+			ret = lua.LTrue
+		}
+		loaded[module] = ret
+		L.Push(ret)
+		return 1
+	}))
+}
@@ -0,0 +1,161 @@
+package workflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// writeWorkflowFiles writes files (paths relative to dir) and returns dir.
+func writeWorkflowFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for rel, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// newRequireState returns a VM whose require() loads from dir.
+func newRequireState(t *testing.T, dir string) *lua.LState {
+	t.Helper()
+	L := NewSandboxedState("test", testLogger())
+	t.Cleanup(L.Close)
+	registerRequire(L, newLibLoader(dir, newChunkCache(), nil))
+	return L
+}
+
+func TestRequire(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"lib/repo.lua": `
+			loads = (loads or 0) + 1
+			local M = {}
+			function M.parse(full) return string.match(full, "([^/]+)/(.+)") end
+			return M`,
+		"lib/slack/format.lua": `
+			local repo = require("lib.repo")
+			return { link = function(full) local o, r = repo.parse(full); return "<" .. o .. "|" .. r .. ">" end }`,
+		"lib/noreturn.lua": `x = 1`,
+	})
+	L := newRequireState(t, dir)
+
+	err := L.DoString(`
+		local repo = require("lib.repo")
+		local owner, name = repo.parse("sekia-ai/sekia")
+		assert(owner == "sekia-ai" and name == "sekia")
+		assert(require("lib.slack.format").link("a/b") == "<a|b>")
+		assert(require("lib.repo") == repo, "library not cached")
+		assert(loads == 1, "library ran " .. loads .. " times")
+		assert(require("lib.noreturn") == true)
+	`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+}
+
+func TestRequire_Rejected(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"lib/a.lua":  `return require("lib.b")`,
+		"lib/b.lua":  `return require("lib.a")`,
+		"secret.lua": `return "outside lib"`,
+	})
+	L := newRequireState(t, dir)
+
+	tests := []struct {
+		module string
+		want   string
+	}{
+		{"os", "must start with"},
+		{"secret", "must start with"},
+		{"lib", "must start with"},
+		{"lib..secret", "invalid module name"},
+		{"lib./secret", "invalid module name"},
+		{"lib.missing", "no such file"},
+		{"lib.a", "circular dependency"},
+	}
+	for _, tt := range tests {
+		err := L.DoString(`require("` + tt.module + `")`)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("require(%q) error = %v, want %q", tt.module, err, tt.want)
+		}
+	}
+}
+
+func TestRequire_Integrity(t *testing.T) {
+	lib := `return { v = 1 }`
+	dir := writeWorkflowFiles(t, map[string]string{
+		"wf.lua":     `local m = require("lib.m"); assert(m.v == 1)`,
+		"lib/m.lua":  lib,
+		"other.lua":  `sekia.on("sekia.events.test", function() end)`,
+		"unused.lua": `return nil`,
+	})
+	m, err := GenerateManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.entries["lib/m.lua"] != sha256Hex(lib) {
+		t.Fatalf("manifest does not cover lib/m.lua: %v", m.entries)
+	}
+	if err := m.WriteFile(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	eng := New(nil, dir, nil, 0, "", testLogger())
+	eng.SetVerifyIntegrity(true)
+	if _, err := eng.buildWorkflow("wf", filepath.Join(dir, "wf.lua"), nil); err != nil {
+		t.Fatalf("signed library: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "lib", "m.lua"), []byte(`return { v = 2 }`), 0644)
+	_, err = eng.buildWorkflow("wf", filepath.Join(dir, "wf.lua"), nil)
+	if !errors.Is(err, ErrIntegrityViolation) {
+		t.Fatalf("tampered library: err = %v, want ErrIntegrityViolation", err)
+	}
+}
+
+func TestReloadDependents(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"uses.lua":       `local c = require("lib.consts"); sekia.on("sekia.events." .. c.source, function() end)`,
+		"other.lua":      `sekia.on("sekia.events.other", function() end)`,
+		"lib/consts.lua": `return { source = "v1" }`,
+	})
+	eng := New(nil, dir, nil, 0, "", testLogger())
+	defer eng.Stop()
+	if err := eng.LoadDir(); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns := func() map[string]string {
+		got := make(map[string]string)
+		for _, wf := range eng.Workflows() {
+			got[wf.Name] = strings.Join(wf.Patterns, ",")
+		}
+		return got
+	}
+	if got := patterns()["uses"]; got != "sekia.events.v1" {
+		t.Fatalf("uses patterns = %q", got)
+	}
+
+	libPath := filepath.Join(dir, "lib", "consts.lua")
+	os.WriteFile(libPath, []byte(`return { source = "v2" }`), 0644)
+	eng.processBatch(map[string]fsnotify.Op{libPath: fsnotify.Write})
+
+	got := patterns()
+	if got["uses"] != "sekia.events.v2" {
+		t.Errorf("dependent not reloaded: patterns = %q", got["uses"])
+	}
+	if got["other"] != "sekia.events.other" {
+		t.Errorf("other workflow changed: patterns = %q", got["other"])
+	}
+}
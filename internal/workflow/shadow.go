@@ -0,0 +1,127 @@
+package workflow
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// shadowLogSize is the number of intents kept per shadow-mode workflow.
+const shadowLogSize = 500
+
+// ShadowInfo holds what a shadow-mode workflow would have sent.
+type ShadowInfo struct {
+	Shadow  bool              // workflow is currently loaded in shadow mode
+	Total   int64             // intents recorded since the daemon started
+	Intents []protocol.Intent // most recent intents, oldest first
+}
+
+// shadowLog is a bounded in-memory record of a workflow's intents. It is
+// kept per workflow name so the history survives reloads.
+type shadowLog struct {
+	mu      sync.Mutex
+	intents []protocol.Intent
+	total   int64
+}
+
+func (l *shadowLog) record(in protocol.Intent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.intents = append(l.intents, in)
+	if len(l.intents) > shadowLogSize {
+		l.intents = l.intents[len(l.intents)-shadowLogSize:]
+	}
+	l.total++
+}
+
+// snapshot returns up to limit of the most recent intents (0 = all kept).
+func (l *shadowLog) snapshot(limit int) ([]protocol.Intent, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	intents := l.intents
+	if limit > 0 && len(intents) > limit {
+		intents = intents[len(intents)-limit:]
+	}
+	return append([]protocol.Intent(nil), intents...), l.total
+}
+
+// SetShadowWorkflows sets the workflows loaded in shadow mode regardless of
+// their header directives. It applies to workflows loaded afterwards.
+func (e *Engine) SetShadowWorkflows(names []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shadowNames = make(map[string]bool, len(names))
+	for _, n := range names {
+		e.shadowNames[n] = true
+	}
+}
+
+// shadowRecorder returns the intercept function recording intents for a
+// shadow-mode workflow, creating its log on first use.
+func (e *Engine) shadowRecorder(name string) func(protocol.Intent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l, ok := e.shadowLogs[name]
+	if !ok {
+		l = &shadowLog{}
+		e.shadowLogs[name] = l
+	}
+	return l.record
+}
+
+// isShadowConfigured reports whether the config forces name into shadow mode.
+func (e *Engine) isShadowConfigured(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.shadowNames[name]
+}
+
+// ShadowIntents returns the intents recorded for a loaded workflow while it
+// ran in shadow mode, keeping at most limit of the most recent (0 = all).
+func (e *Engine) ShadowIntents(name string, limit int) (ShadowInfo, error) {
+	e.mu.RLock()
+	ws, ok := e.workflows[name]
+	l := e.shadowLogs[name]
+	e.mu.RUnlock()
+	if !ok {
+		return ShadowInfo{}, fmt.Errorf("%w: %s", ErrWorkflowNotLoaded, name)
+	}
+
+	info := ShadowInfo{Shadow: ws.shadow, Intents: []protocol.Intent{}}
+	if l != nil {
+		info.Intents, info.Total = l.snapshot(limit)
+	}
+	return info, nil
+}
+
+// hasShadowDirective reports whether the workflow source declares shadow mode
+// in its header — the comment lines before the first line of code:
+//
+//	-- sekia: shadow
+func hasShadowDirective(src []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "--")
+		if !ok {
+			return false
+		}
+		opts, ok := strings.CutPrefix(strings.TrimSpace(comment), "sekia:")
+		if !ok {
+			continue
+		}
+		for _, opt := range strings.FieldsFunc(opts, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if opt == "shadow" {
+				return true
+			}
+		}
+	}
+	return false
+}
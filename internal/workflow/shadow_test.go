@@ -0,0 +1,147 @@
+package workflow
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+func TestHasShadowDirective(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"directive", "-- sekia: shadow\nsekia.on('x', function() end)", true},
+		{"after other comments", "-- Auto-close stale issues.\n\n--sekia:shadow\nlocal x = 1", true},
+		{"among options", "-- sekia: foo, shadow\n", true},
+		{"no directive", "-- just a comment\nlocal x = 1", false},
+		{"after code", "local x = 1\n-- sekia: shadow", false},
+		{"other word", "-- sekia: shadowed\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasShadowDirective([]byte(tt.src)); got != tt.want {
+				t.Errorf("hasShadowDirective() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_ShadowMode(t *testing.T) {
+	_, nc := startTestNATS(t)
+
+	tmpDir := t.TempDir()
+	directive := `-- sekia: shadow
+sekia.on("sekia.events.test", function(event)
+	sekia.command("shadow-agent", "close_issue", { n = event.payload.n })
+	sekia.publish("sekia.events.closer", "issue.closed", { n = event.payload.n })
+end)
+`
+	configured := `
+sekia.on("sekia.events.test", function(event)
+	sekia.command("shadow-agent", "reply", { n = event.payload.n })
+end)
+`
+	live := `
+sekia.on("sekia.events.test", function(event)
+	sekia.command("live-agent", "echo", { n = event.payload.n })
+end)
+`
+	os.WriteFile(filepath.Join(tmpDir, "closer.lua"), []byte(directive), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "replier.lua"), []byte(configured), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "echo.lua"), []byte(live), 0644)
+
+	var shadowSent atomic.Int32
+	sub, err := nc.Subscribe("sekia.commands.shadow-agent", func(*nats.Msg) { shadowSent.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	liveCh := make(chan struct{}, 1)
+	sub2, err := nc.Subscribe("sekia.commands.live-agent", func(*nats.Msg) { liveCh <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub2.Unsubscribe()
+
+	eng := New(nc, tmpDir, nil, 0, "", testLogger())
+	eng.SetShadowWorkflows([]string{"replier"})
+	if err := eng.Start(); err != nil {
+		t.Fatalf("engine start: %v", err)
+	}
+	defer eng.Stop()
+	if err := eng.LoadDir(); err != nil {
+		t.Fatalf("load dir: %v", err)
+	}
+
+	ev := protocol.NewEvent("test.event", "test-source", map[string]any{"n": 7})
+	data, _ := json.Marshal(ev)
+	nc.Publish("sekia.events.test", data)
+	nc.Flush()
+
+	select {
+	case <-liveCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for live workflow command")
+	}
+
+	waitFor := func(name string, n int) ShadowInfo {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			info, err := eng.ShadowIntents(name, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(info.Intents) >= n || time.Now().After(deadline) {
+				return info
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	closer := waitFor("closer", 2)
+	if !closer.Shadow || closer.Total != 2 || len(closer.Intents) != 2 {
+		t.Fatalf("closer shadow info = %+v", closer)
+	}
+	if in := closer.Intents[0]; in.Kind != protocol.IntentCommand || in.Command != "close_issue" || in.EventID != ev.ID {
+		t.Errorf("closer intent 0 = %+v", in)
+	}
+	if in := closer.Intents[1]; in.Kind != protocol.IntentPublish || in.Subject != "sekia.events.closer" {
+		t.Errorf("closer intent 1 = %+v", in)
+	}
+
+	replier := waitFor("replier", 1)
+	if !replier.Shadow || len(replier.Intents) != 1 || replier.Intents[0].Command != "reply" {
+		t.Errorf("replier shadow info = %+v", replier)
+	}
+
+	echo, err := eng.ShadowIntents("echo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if echo.Shadow || len(echo.Intents) != 0 {
+		t.Errorf("live workflow shadow info = %+v", echo)
+	}
+
+	nc.Flush()
+	time.Sleep(100 * time.Millisecond)
+	if n := shadowSent.Load(); n != 0 {
+		t.Errorf("shadow workflows sent %d commands, want 0", n)
+	}
+
+	// The recorded history survives a reload.
+	if err := eng.ReloadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if info := waitFor("closer", 2); info.Total != 2 {
+		t.Errorf("closer total after reload = %d, want 2", info.Total)
+	}
+}
@@ -0,0 +1,593 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sekia-ai/sekia/internal/ai"
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// TestFileSuffix names workflow test files: foo_test.lua tests foo.lua in
+// the same directory.
+const TestFileSuffix = "_test.lua"
+
+// defaultTestHandlerTimeout bounds each handler call under test, so a
+// runaway loop fails the test instead of hanging the run.
+const defaultTestHandlerTimeout = 10 * time.Second
+
+// TestOptions configures RunTestFile.
+type TestOptions struct {
+	Run            *regexp.Regexp // run only tests whose name matches (nil = all)
+	HandlerTimeout time.Duration  // per handler call (0 = 10s)
+	Logger         zerolog.Logger // receives the workflow's logs
+}
+
+// TestResult is the outcome of one test() case.
+type TestResult struct {
+	Name     string
+	Failure  string // empty if the test passed
+	Duration time.Duration
+}
+
+// TestFileResult is the outcome of one *_test.lua file.
+type TestFileResult struct {
+	File     string
+	Workflow string
+	Tests    []TestResult
+	Err      error // the test file could not be loaded
+}
+
+// Failed reports whether the file could not be loaded or any test failed.
+func (r TestFileResult) Failed() bool {
+	if r.Err != nil {
+		return true
+	}
+	for _, t := range r.Tests {
+		if t.Failure != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTestFiles expands paths, which may be test files or directories, to
+// the workflow test files they contain, sorted. Directories are searched
+// without descending into subdirectories, like LoadDir.
+func FindTestFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !strings.HasSuffix(p, TestFileSuffix) {
+				return nil, fmt.Errorf("%s: test files must end in %s", p, TestFileSuffix)
+			}
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), TestFileSuffix) {
+				files = append(files, filepath.Join(p, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// testHooks connects a workflow under test to the harness.
+type testHooks struct {
+	log          func(level, message string)
+	commandReply func(agent, command string) (result map[string]any, errMsg string, ok bool)
+	handlerError func(pattern string, err error)
+}
+
+// testCase is a test() registered by a test file.
+type testCase struct {
+	name string
+	fn   *lua.LFunction
+}
+
+// testLog is a sekia.log call made by the workflow under test.
+type testLog struct {
+	level   string
+	message string
+}
+
+// commandMock is the canned reply to a sekia.command_sync call.
+type commandMock struct {
+	result map[string]any
+	errMsg string
+}
+
+// testHarness runs the tests of one file. Each test gets a fresh copy of
+// the workflow, built as for a dry-run replay: publishes, commands, timers
+// and HTTP writes are recorded instead of sent, and state lives in memory.
+type testHarness struct {
+	eng      *Engine
+	dir      string
+	name     string
+	filePath string
+	llm      *queuedLLM
+
+	// Per test; reset by run.
+	ws        *workflowState
+	intents   []protocol.Intent
+	logs      []testLog
+	errs      []string // handler errors not yet claimed by expect_error
+	responses map[string]commandMock
+}
+
+// RunTestFile runs the tests in a *_test.lua file against the workflow
+// file next to it (foo_test.lua tests foo.lua).
+func RunTestFile(path string, opts TestOptions) TestFileResult {
+	dir := filepath.Dir(path)
+	name := strings.TrimSuffix(filepath.Base(path), TestFileSuffix)
+	res := TestFileResult{File: path, Workflow: name}
+
+	wfPath := filepath.Join(dir, name+".lua")
+	if _, err := os.Stat(wfPath); err != nil {
+		res.Err = fmt.Errorf("workflow under test: %w", err)
+		return res
+	}
+	if opts.HandlerTimeout <= 0 {
+		opts.HandlerTimeout = defaultTestHandlerTimeout
+	}
+
+	h := &testHarness{
+		dir:      dir,
+		name:     name,
+		filePath: wfPath,
+		llm:      &queuedLLM{},
+	}
+	h.eng = New(nil, dir, h.llm, opts.HandlerTimeout, "", opts.Logger)
+	h.eng.hooks = &testHooks{
+		log:          h.recordLog,
+		commandReply: h.commandReply,
+		handlerError: h.recordHandlerError,
+	}
+
+	L := NewSandboxedState(name+"_test", opts.Logger)
+	defer L.Close()
+	var cases []testCase
+	L.SetGlobal("test", L.NewFunction(func(L *lua.LState) int {
+		cases = append(cases, testCase{name: L.CheckString(1), fn: L.CheckFunction(2)})
+		return 0
+	}))
+	h.register(L)
+	registerRequire(L, newLibLoader(dir, h.eng.chunks, nil))
+
+	if err := L.DoFile(path); err != nil {
+		res.Err = err
+		return res
+	}
+	for _, c := range cases {
+		if opts.Run != nil && !opts.Run.MatchString(c.name) {
+			continue
+		}
+		start := time.Now()
+		failure := h.run(L, c)
+		res.Tests = append(res.Tests, TestResult{Name: c.name, Failure: failure, Duration: time.Since(start)})
+	}
+	return res
+}
+
+// run loads a fresh copy of the workflow and runs one test against it. It
+// returns the failure message, or "" if the test passed.
+func (h *testHarness) run(L *lua.LState, c testCase) string {
+	h.intents, h.logs, h.errs = nil, nil, nil
+	h.responses = make(map[string]commandMock)
+	h.llm.reset()
+
+	ws, err := h.eng.buildWorkflow(h.name, h.filePath, func(in protocol.Intent) {
+		h.intents = append(h.intents, in)
+	})
+	if err != nil {
+		return err.Error()
+	}
+	ws.dryRun = true
+	h.ws = ws
+	defer func() {
+		ws.L.Close()
+		h.ws = nil
+	}()
+
+	if err := L.CallByParam(lua.P{Fn: c.fn, NRet: 0, Protect: true}); err != nil {
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) && apiErr.Object != nil {
+			return apiErr.Object.String()
+		}
+		return err.Error()
+	}
+	if len(h.errs) > 0 {
+		return "handler error: " + strings.Join(h.errs, "; handler error: ")
+	}
+	return ""
+}
+
+func (h *testHarness) recordLog(level, message string) {
+	h.logs = append(h.logs, testLog{level: level, message: message})
+}
+
+func (h *testHarness) recordHandlerError(pattern string, err error) {
+	h.errs = append(h.errs, fmt.Sprintf("%s: %v", pattern, err))
+}
+
+func (h *testHarness) commandReply(agent, command string) (map[string]any, string, bool) {
+	m, ok := h.responses[agent+"\x00"+command]
+	return m.result, m.errMsg, ok
+}
+
+// register installs the test API in the test file's VM.
+func (h *testHarness) register(L *lua.LState) {
+	for name, fn := range map[string]lua.LGFunction{
+		"emit":                h.luaEmit,
+		"fixture":             h.luaFixture,
+		"mock_ai":             h.luaMockAI,
+		"mock_command":        h.luaMockCommand,
+		"intents":             h.luaIntents,
+		"expect_command":      h.luaExpectCommand,
+		"expect_publish":      h.luaExpectPublish,
+		"expect_log":          h.luaExpectLog,
+		"expect_error":        h.luaExpectError,
+		"expect_no_commands":  h.expectNone(protocol.IntentCommand),
+		"expect_no_publishes": h.expectNone(protocol.IntentPublish),
+	} {
+		L.SetGlobal(name, L.NewFunction(fn))
+	}
+}
+
+// luaEmit delivers an event to the workflow's handlers and returns whether
+// any handler matched.
+// Lua: emit(event) or emit(subject, event). The event needs a type; the
+// source defaults to "test" and the subject to sekia.events.<source>.
+func (h *testHarness) luaEmit(L *lua.LState) int {
+	if h.ws == nil {
+		L.RaiseError("emit() must be called inside a test")
+		return 0
+	}
+	var subject string
+	tbl, ok := L.Get(1).(*lua.LTable)
+	if !ok {
+		subject = L.CheckString(1)
+		tbl = L.CheckTable(2)
+	}
+
+	fields, _ := TableToMap(tbl).(map[string]any)
+	if s, ok := fields["subject"].(string); ok && subject == "" {
+		subject = s
+	}
+	ev := protocol.Event{Source: "test"}
+	if err := decodeJSON(fields, &ev); err != nil {
+		L.ArgError(L.GetTop(), "invalid event: "+err.Error())
+		return 0
+	}
+	if ev.Type == "" {
+		L.ArgError(L.GetTop(), "event type is required")
+		return 0
+	}
+	if ev.ID == "" {
+		ev.ID = "evt_" + uuid.NewString()
+	}
+	if ev.Timestamp == 0 {
+		ev.Timestamp = time.Now().Unix()
+	}
+	if subject == "" {
+		subject = "sekia.events." + ev.Source
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		L.RaiseError("encode event: %v", err)
+		return 0
+	}
+	msg := &eventMsg{subject: subject, data: data, attempts: 1}
+	matched := h.ws.handles(msg)
+	h.ws.processEvent(h.ws.workers[0], msg)
+	L.Push(lua.LBool(matched))
+	return 1
+}
+
+// luaFixture reads a JSON or YAML file, relative to the test file, as a table.
+// Lua: fixture(path)
+func (h *testHarness) luaFixture(L *lua.LState) int {
+	path := L.CheckString(1)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(h.dir, path)
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- fixtures are chosen by the test author
+	if err != nil {
+		L.RaiseError("fixture: %v", err)
+		return 0
+	}
+
+	var v any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &v)
+	default:
+		err = json.Unmarshal(data, &v)
+	}
+	if err == nil {
+		// Round-trip through JSON so numbers arrive as they would in an event.
+		err = decodeJSON(v, &v)
+	}
+	if err != nil {
+		L.RaiseError("fixture %s: %v", filepath.Base(path), err)
+		return 0
+	}
+	L.Push(GoToLua(L, v))
+	return 1
+}
+
+// luaMockAI queues a response for the workflow's next sekia.ai or
+// sekia.ai_json call. Tables are encoded as JSON.
+// Lua: mock_ai(response)
+func (h *testHarness) luaMockAI(L *lua.LState) int {
+	var resp string
+	switch v := L.CheckAny(1).(type) {
+	case lua.LString:
+		resp = string(v)
+	case *lua.LTable:
+		data, err := json.Marshal(TableToMap(v))
+		if err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+		resp = string(data)
+	default:
+		L.ArgError(1, "string or table expected")
+		return 0
+	}
+	h.llm.push(resp)
+	return 0
+}
+
+// luaMockCommand sets the reply to sekia.command_sync calls of a command.
+// Without one, command_sync returns an empty table.
+// Lua: mock_command(agent, command, result [, err])
+func (h *testHarness) luaMockCommand(L *lua.LState) int {
+	agent := L.CheckString(1)
+	command := L.CheckString(2)
+	var m commandMock
+	if tbl := L.OptTable(3, nil); tbl != nil {
+		m.result, _ = TableToMap(tbl).(map[string]any)
+	}
+	m.errMsg = L.OptString(4, "")
+	h.responses[agent+"\x00"+command] = m
+	return 0
+}
+
+// luaIntents returns everything the workflow published, commanded,
+// scheduled or sent over HTTP so far in the test.
+// Lua: intents()
+func (h *testHarness) luaIntents(L *lua.LState) int {
+	list := L.NewTable()
+	for _, in := range h.intents {
+		list.Append(intentToLua(L, in))
+	}
+	L.Push(list)
+	return 1
+}
+
+// luaExpectCommand fails the test unless the workflow sent the command.
+// Payload fields given are compared; others are ignored.
+// Lua: expect_command(agent, command [, payload])
+func (h *testHarness) luaExpectCommand(L *lua.LState) int {
+	agent := L.CheckString(1)
+	command := L.CheckString(2)
+	want := optPayload(L, 3)
+	for _, in := range h.intents {
+		if in.Kind == protocol.IntentCommand && in.Agent == agent && in.Command == command && payloadContains(in.Payload, want) {
+			return 0
+		}
+	}
+	L.RaiseError("expected command %s %s%s; sent: %s", agent, command, formatPayload(want), h.describe(protocol.IntentCommand))
+	return 0
+}
+
+// luaExpectPublish fails the test unless the workflow published an event
+// of the type on a subject matching the pattern.
+// Lua: expect_publish(subject, event_type [, payload])
+func (h *testHarness) luaExpectPublish(L *lua.LState) int {
+	pattern := L.CheckString(1)
+	eventType := L.CheckString(2)
+	want := optPayload(L, 3)
+	for _, in := range h.intents {
+		if in.Kind == protocol.IntentPublish && SubjectMatches(pattern, in.Subject) &&
+			in.EventType == eventType && payloadContains(in.Payload, want) {
+			return 0
+		}
+	}
+	L.RaiseError("expected publish %s %s%s; published: %s", pattern, eventType, formatPayload(want), h.describe(protocol.IntentPublish))
+	return 0
+}
+
+// luaExpectLog fails the test unless the workflow logged a message at the
+// level containing the text.
+// Lua: expect_log(level, text)
+func (h *testHarness) luaExpectLog(L *lua.LState) int {
+	level := strings.ToLower(L.CheckString(1))
+	text := L.CheckString(2)
+	var got []string
+	for _, l := range h.logs {
+		if l.level == level && strings.Contains(l.message, text) {
+			return 0
+		}
+		got = append(got, fmt.Sprintf("[%s] %s", l.level, l.message))
+	}
+	L.RaiseError("expected %s log containing %q; logged: %s", level, text, orNone(got))
+	return 0
+}
+
+// luaExpectError fails the test unless a handler raised an error
+// containing the text. Handler errors not expected this way fail the test.
+// Lua: expect_error(text)
+func (h *testHarness) luaExpectError(L *lua.LState) int {
+	text := L.CheckString(1)
+	for i, e := range h.errs {
+		if strings.Contains(e, text) {
+			h.errs = append(h.errs[:i], h.errs[i+1:]...)
+			return 0
+		}
+	}
+	L.RaiseError("expected handler error containing %q; errors: %s", text, orNone(h.errs))
+	return 0
+}
+
+// expectNone returns an assertion that nothing of the kind was recorded.
+func (h *testHarness) expectNone(kind string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		for _, in := range h.intents {
+			if in.Kind == kind {
+				L.RaiseError("expected no %ss; got: %s", kind, h.describe(kind))
+			}
+		}
+		return 0
+	}
+}
+
+// describe lists the recorded intents of a kind for failure messages.
+func (h *testHarness) describe(kind string) string {
+	var got []string
+	for _, in := range h.intents {
+		if in.Kind != kind {
+			continue
+		}
+		switch kind {
+		case protocol.IntentCommand:
+			got = append(got, in.Agent+" "+in.Command+formatPayload(in.Payload))
+		default:
+			got = append(got, in.Subject+" "+in.EventType+formatPayload(in.Payload))
+		}
+	}
+	return orNone(got)
+}
+
+func intentToLua(L *lua.LState, in protocol.Intent) *lua.LTable {
+	tbl := L.NewTable()
+	L.SetField(tbl, "kind", lua.LString(in.Kind))
+	L.SetField(tbl, "subject", lua.LString(in.Subject))
+	L.SetField(tbl, "payload", MapToTable(L, in.Payload))
+	for field, v := range map[string]string{
+		"method":     in.Method,
+		"event_type": in.EventType,
+		"agent":      in.Agent,
+		"command":    in.Command,
+	} {
+		if v != "" {
+			L.SetField(tbl, field, lua.LString(v))
+		}
+	}
+	if !in.FireAt.IsZero() {
+		L.SetField(tbl, "fire_at", lua.LNumber(in.FireAt.Unix()))
+	}
+	return tbl
+}
+
+// optPayload reads an optional payload table argument.
+func optPayload(L *lua.LState, n int) map[string]any {
+	tbl := L.OptTable(n, nil)
+	if tbl == nil {
+		return nil
+	}
+	m, _ := TableToMap(tbl).(map[string]any)
+	return m
+}
+
+// payloadContains reports whether every field of want is in got. Nested
+// tables are compared the same way; lists must match exactly.
+func payloadContains(got, want map[string]any) bool {
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			return false
+		}
+		wm, wIsMap := w.(map[string]any)
+		gm, gIsMap := g.(map[string]any)
+		switch {
+		case wIsMap && gIsMap:
+			if !payloadContains(gm, wm) {
+				return false
+			}
+		case !reflect.DeepEqual(g, w):
+			return false
+		}
+	}
+	return true
+}
+
+func formatPayload(p map[string]any) string {
+	if len(p) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(p)
+	return " " + string(data)
+}
+
+func orNone(items []string) string {
+	if len(items) == 0 {
+		return "(none)"
+	}
+	return strings.Join(items, ", ")
+}
+
+// decodeJSON converts v into out by way of its JSON encoding.
+func decodeJSON(v, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// queuedLLM answers sekia.ai calls with responses queued by mock_ai.
+type queuedLLM struct {
+	mu        sync.Mutex
+	responses []string
+}
+
+func (q *queuedLLM) Complete(_ context.Context, _ ai.CompleteRequest) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.responses) == 0 {
+		return "", errors.New("no AI response queued; call mock_ai() in the test")
+	}
+	resp := q.responses[0]
+	q.responses = q.responses[1:]
+	return resp, nil
+}
+
+func (q *queuedLLM) push(resp string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.responses = append(q.responses, resp)
+}
+
+func (q *queuedLLM) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.responses = nil
+}
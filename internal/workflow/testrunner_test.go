@@ -0,0 +1,141 @@
+package workflow
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const labelerWorkflow = `
+sekia.on("sekia.events.github", function(event)
+	if event.type ~= "github.issue.opened" then return end
+	local label, err = sekia.ai("Classify: " .. event.payload.title)
+	if err then error(err) end
+	sekia.log("info", "classified issue #" .. event.payload.number .. " as " .. label)
+	sekia.command("github-agent", "add_label", { number = event.payload.number, label = label })
+	local user
+	user, err = sekia.command_sync("github-agent", "get_user", { login = event.payload.author })
+	if err then error("lookup failed: " .. err) end
+	sekia.publish("sekia.events.labeler", "issue.labeled", { label = label, admin = user.admin })
+end)
+`
+
+func TestRunTestFile(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"labeler.lua": labelerWorkflow,
+		"labeler_test.lua": `
+			test("labels bugs", function()
+				mock_ai("bug")
+				mock_command("github-agent", "get_user", { admin = true })
+				assert(emit(fixture("fixtures/issue.yaml")))
+				expect_command("github-agent", "add_label", { label = "bug" })
+				expect_publish("sekia.events.*", "issue.labeled", { admin = true })
+				expect_log("info", "#42 as bug")
+			end)
+
+			test("ignores other sources", function()
+				assert(not emit({ type = "gmail.message.received", source = "gmail", payload = {} }))
+				expect_no_commands()
+				assert(#intents() == 0)
+			end)
+
+			test("lookup failure", function()
+				mock_ai("bug")
+				mock_command("github-agent", "get_user", nil, "not found")
+				emit("sekia.events.github", fixture("fixtures/issue.yaml"))
+				expect_error("lookup failed: not found")
+				expect_no_publishes()
+			end)
+
+			test("wrong label", function()
+				mock_ai("feature")
+				emit(fixture("fixtures/issue.yaml"))
+				expect_command("github-agent", "add_label", { label = "bug" })
+			end)
+
+			test("unexpected handler error", function()
+				emit(fixture("fixtures/issue.yaml"))
+			end)
+		`,
+		"fixtures/issue.yaml": `
+type: github.issue.opened
+source: github
+payload:
+  number: 42
+  title: Crash on startup
+  author: octocat
+`,
+	})
+
+	res := RunTestFile(filepath.Join(dir, "labeler_test.lua"), TestOptions{Logger: testLogger()})
+	if res.Err != nil {
+		t.Fatalf("RunTestFile: %v", res.Err)
+	}
+	if !res.Failed() {
+		t.Error("Failed() = false with failing tests")
+	}
+
+	want := map[string]string{
+		"labels bugs":              "",
+		"ignores other sources":    "",
+		"lookup failure":           "",
+		"wrong label":              `expected command github-agent add_label {"label":"bug"}; sent: github-agent add_label {"label":"feature","number":42}`,
+		"unexpected handler error": "no AI response queued",
+	}
+	if len(res.Tests) != len(want) {
+		t.Fatalf("ran %d tests, want %d", len(res.Tests), len(want))
+	}
+	for _, tr := range res.Tests {
+		w := want[tr.Name]
+		if (w == "") != (tr.Failure == "") || !strings.Contains(tr.Failure, w) {
+			t.Errorf("%s: failure = %q, want %q", tr.Name, tr.Failure, w)
+		}
+	}
+
+	res = RunTestFile(filepath.Join(dir, "labeler_test.lua"), TestOptions{
+		Run:    regexp.MustCompile("^labels"),
+		Logger: testLogger(),
+	})
+	if len(res.Tests) != 1 || res.Failed() {
+		t.Errorf("filtered run = %+v", res)
+	}
+}
+
+func TestRunTestFile_Errors(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"orphan_test.lua": `test("x", function() end)`,
+		"broken.lua":      `sekia.on("sekia.events.test", function() end)`,
+		"broken_test.lua": `test("x", function(`,
+	})
+
+	if res := RunTestFile(filepath.Join(dir, "orphan_test.lua"), TestOptions{Logger: testLogger()}); res.Err == nil {
+		t.Error("test file without a workflow: expected error")
+	}
+	if res := RunTestFile(filepath.Join(dir, "broken_test.lua"), TestOptions{Logger: testLogger()}); res.Err == nil {
+		t.Error("test file with a syntax error: expected error")
+	}
+
+	files, err := FindTestFiles([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "broken_test.lua" {
+		t.Errorf("FindTestFiles = %v", files)
+	}
+}
+
+func TestLoadDir_SkipsTestFiles(t *testing.T) {
+	dir := writeWorkflowFiles(t, map[string]string{
+		"wf.lua":      `sekia.on("sekia.events.test", function() end)`,
+		"wf_test.lua": `test("x", function() end)`,
+	})
+	eng := New(nil, dir, nil, 0, "", testLogger())
+	defer eng.Stop()
+	if err := eng.LoadDir(); err != nil {
+		t.Fatal(err)
+	}
+	if wfs := eng.Workflows(); len(wfs) != 1 || wfs[0].Name != "wf" {
+		t.Errorf("Workflows() = %+v", wfs)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/rs/zerolog"
 	"github.com/sekia-ai/sekia/pkg/protocol"
 )
@@ -16,6 +17,34 @@ import (
 type Config struct {
 	NATSUrl  string
 	NATSOpts []nats.Option
+
+	// EventValidation controls how PublishEvent treats events whose payload
+	// does not match the schema for their type: protocol.ValidationWarn
+	// (default), ValidationReject or ValidationOff.
+	EventValidation string
+
+	// CommandSchemas describes the payloads of the agent's commands; it is
+	// sent with the registration for workflow linting (see
+	// protocol.CommandSchemas).
+	CommandSchemas map[string]*protocol.Schema
+
+	// CommandPolicy restricts which sources may send the agent which
+	// commands (nil = any). Command handlers enforce it by calling
+	// AuthorizeCommand once the command's signature has been verified.
+	CommandPolicy *protocol.CommandPolicy
+
+	// MinSignatureVersion is the oldest command signature version
+	// VerifyCommand accepts. The default, protocol.SignatureV1, accepts
+	// both versions while senders are upgraded; set protocol.SignatureV2
+	// once they all sign with it to refuse replayable version 1 commands,
+	// and protocol.SignatureV3 to accept only Ed25519-signed commands.
+	MinSignatureVersion int
+
+	// TrustedKeys are the public keys of the command senders the agent
+	// accepts Ed25519 (version 3) signatures from. With trusted keys, the
+	// agent advertises protocol.SignatureV3 and senders that hold a signing
+	// key use it instead of the shared secret.
+	TrustedKeys *protocol.KeySet
 }
 
 // Agent is the base for all sekia agents.
@@ -25,12 +54,20 @@ type Agent struct {
 	Capabilities []string
 	Commands     []string
 
-	nc     *nats.Conn
-	logger zerolog.Logger
-	cancel context.CancelFunc
+	nc         *nats.Conn
+	logger     zerolog.Logger
+	cancel     context.CancelFunc
+	commands   jetstream.ConsumeContext    // set by ServeCommands in work-queue mode
+	validation string                      // Config.EventValidation
+	schemas    map[string]*protocol.Schema // Config.CommandSchemas
+	policy     atomic.Pointer[protocol.CommandPolicy]
+	minSig     atomic.Int32         // Config.MinSignatureVersion
+	nonces     *protocol.NonceCache // nonces of verified version 2 and 3 commands
+	keys       atomic.Pointer[protocol.KeySet]
 
 	eventsProcessed atomic.Int64
 	errors          atomic.Int64
+	commandsDenied  atomic.Int64
 	lastEvent       atomic.Value // stores time.Time
 }
 
@@ -53,6 +90,14 @@ func New(cfg Config, name, version string, capabilities, commands []string, logg
 		nats.ClosedHandler(func(_ *nats.Conn) {
 			agentLogger.Warn().Msg("NATS connection closed")
 		}),
+		// Permission violations under per-agent credentials are reported
+		// asynchronously.
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			agentLogger.Error().Err(err).Msg("NATS error")
+		}),
+		// Replies arrive on the agent's own inboxes, which its
+		// credentials allow it to subscribe to.
+		nats.CustomInboxPrefix(protocol.SubjectInbox(name)),
 	}
 
 	opts := append(resilienceOpts, cfg.NATSOpts...)
@@ -68,8 +113,14 @@ func New(cfg Config, name, version string, capabilities, commands []string, logg
 		Commands:     commands,
 		nc:           nc,
 		logger:       agentLogger,
+		validation:   cfg.EventValidation,
+		schemas:      cfg.CommandSchemas,
+		nonces:       protocol.NewNonceCache(0),
 	}
 	a.lastEvent.Store(time.Time{})
+	a.policy.Store(cfg.CommandPolicy)
+	a.SetMinSignatureVersion(cfg.MinSignatureVersion)
+	a.keys.Store(cfg.TrustedKeys)
 
 	if err := a.register(); err != nil {
 		nc.Close()
@@ -89,6 +140,16 @@ func (a *Agent) register() error {
 		Version:      a.Version,
 		Capabilities: a.Capabilities,
 		Commands:     a.Commands,
+
+		SignatureVersion: a.signatureVersion(),
+	}
+	for _, name := range a.Commands {
+		if s, ok := a.schemas[name]; ok {
+			if reg.CommandSchemas == nil {
+				reg.CommandSchemas = make(map[string]*protocol.Schema)
+			}
+			reg.CommandSchemas[name] = s
+		}
 	}
 	data, err := json.Marshal(reg)
 	if err != nil {
@@ -121,6 +182,9 @@ func (a *Agent) sendHeartbeat() {
 		LastEvent:       a.lastEvent.Load().(time.Time),
 		EventsProcessed: a.eventsProcessed.Load(),
 		Errors:          a.errors.Load(),
+		CommandsDenied:  a.commandsDenied.Load(),
+
+		SignatureVersion: a.signatureVersion(),
 	}
 	data, _ := json.Marshal(hb)
 	if err := a.nc.Publish(protocol.SubjectHeartbeat(a.Name), data); err != nil {
@@ -128,9 +192,44 @@ func (a *Agent) sendHeartbeat() {
 	}
 }
 
+// signatureVersion returns the newest command signature version the agent
+// can verify.
+func (a *Agent) signatureVersion() int {
+	if a.keys.Load() != nil {
+		return protocol.SignatureV3
+	}
+	return protocol.SignatureV2
+}
+
 // Conn returns the underlying NATS connection for custom subscriptions.
 func (a *Agent) Conn() *nats.Conn { return a.nc }
 
+// PublishEvent validates an event against the schema for its type and
+// publishes it on subject, recording it in the agent's stats. Depending on
+// Config.EventValidation, a payload that does not match is logged and
+// published anyway, or rejected with a *protocol.SchemaError.
+func (a *Agent) PublishEvent(subject string, ev protocol.Event) error {
+	if a.validation != protocol.ValidationOff {
+		if err := protocol.ValidateEvent(ev); err != nil {
+			if a.validation == protocol.ValidationReject {
+				a.RecordError()
+				return err
+			}
+			a.logger.Warn().Err(err).Str("event_id", ev.ID).Msg("publishing event that does not match its schema")
+		}
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	if err := a.nc.Publish(subject, data); err != nil {
+		return fmt.Errorf("publish event: %w", err)
+	}
+	a.RecordEvent()
+	return nil
+}
+
 // RecordEvent increments counters after processing an event.
 func (a *Agent) RecordEvent() {
 	a.eventsProcessed.Add(1)
@@ -162,10 +261,19 @@ func (a *Agent) OnConfigReload(fn func()) error {
 	return nil
 }
 
-// Close stops heartbeating and disconnects.
+// Close stops heartbeating and command consumption, then disconnects.
+// A command in flight is allowed to finish; queued ones stay in the work queue.
 func (a *Agent) Close() {
 	if a.cancel != nil {
 		a.cancel()
 	}
+	if a.commands != nil {
+		a.commands.Stop()
+		select {
+		case <-a.commands.Closed():
+		case <-time.After(5 * time.Second):
+			a.logger.Warn().Msg("timed out waiting for command consumer to stop")
+		}
+	}
 	a.nc.Drain()
 }
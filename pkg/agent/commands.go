@@ -0,0 +1,428 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+// ErrInvalidSignature is returned by command handlers that reject a command
+// whose signature is missing or does not verify.
+var ErrInvalidSignature = errors.New("rejected command: invalid or missing signature")
+
+// AuthorizeCommand checks a command against the agent's command policy
+// (Config.CommandPolicy). Call it after verifying the command's signature,
+// so that its Source can be trusted. Denials are logged, counted in the
+// heartbeat and returned as an error wrapping protocol.ErrCommandDenied.
+func (a *Agent) AuthorizeCommand(cmd *protocol.Command) error {
+	err := a.policy.Load().Authorize(cmd.Source, a.Name, cmd.Command, cmd.Payload)
+	if err == nil {
+		return nil
+	}
+	a.commandsDenied.Add(1)
+	a.logger.Warn().
+		Err(err).
+		Str("command", cmd.Command).
+		Str("command_id", cmd.ID).
+		Str("source", cmd.Source).
+		Msg("rejected command: denied by policy")
+	return err
+}
+
+// SetCommandPolicy replaces the command policy, e.g. after a config reload.
+func (a *Agent) SetCommandPolicy(p *protocol.CommandPolicy) {
+	a.policy.Store(p)
+}
+
+// VerifyCommand checks a command's signature, with the trusted keys
+// (Config.TrustedKeys) for Ed25519 signatures and with secret otherwise,
+// and, for the version 2 and 3 envelopes, that it has not expired and that its nonce has not
+// been used before. Retries of a command (redeliveries from the work queue
+// and in-process retries) carry the same nonce, so only the first attempt
+// records it; ctx must be the one the CommandHandler was called with.
+// Commands signed with a version older than Config.MinSignatureVersion are
+// refused. Rejections are logged. If secret is empty and there are no
+// trusted keys, every command is accepted; if only secret is empty, every
+// command must be signed with a trusted key.
+//
+// Nonces are remembered in memory only: after a restart, a command can be
+// replayed until it expires (protocol.DefaultCommandTTL by default).
+func (a *Agent) VerifyCommand(ctx context.Context, cmd *protocol.Command, secret string) error {
+	if secret == "" && a.keys.Load() == nil {
+		return nil
+	}
+	err := a.verifyCommand(ctx, cmd, secret)
+	if err != nil {
+		a.logger.Warn().
+			Err(err).
+			Str("command", cmd.Command).
+			Str("command_id", cmd.ID).
+			Str("source", cmd.Source).
+			Msg("rejected command")
+	}
+	return err
+}
+
+func (a *Agent) verifyCommand(ctx context.Context, cmd *protocol.Command, secret string) error {
+	if cmd.SigVersion >= protocol.SignatureV3 || secret == "" {
+		if err := a.keys.Load().Verify(cmd); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+	} else if !protocol.VerifyCommand(cmd, secret) {
+		return ErrInvalidSignature
+	}
+	version := max(cmd.SigVersion, protocol.SignatureV1)
+	if required := int(a.minSig.Load()); version < required {
+		return fmt.Errorf("%w: signature version %d, at least %d required", ErrInvalidSignature, version, required)
+	}
+	now := time.Now()
+	if err := protocol.CheckCommandFreshness(cmd, now); err != nil {
+		return err
+	}
+	if attempt, _ := ctx.Value(attemptKey{}).(int); attempt > 1 {
+		return nil
+	}
+	return a.nonces.Check(cmd, now)
+}
+
+// SetMinSignatureVersion sets the oldest command signature version
+// VerifyCommand accepts (see Config.MinSignatureVersion).
+func (a *Agent) SetMinSignatureVersion(v int) {
+	a.minSig.Store(int32(max(v, protocol.SignatureV1)))
+}
+
+// SetTrustedKeys replaces the public keys VerifyCommand accepts Ed25519
+// signatures from, e.g. after a config reload. The agent advertises
+// protocol.SignatureV3 from its next heartbeat while it has any.
+func (a *Agent) SetTrustedKeys(ks *protocol.KeySet) {
+	a.keys.Store(ks)
+}
+
+// attemptKey is the context key under which runCommand stores the attempt
+// number (1-based) of the command being executed.
+type attemptKey struct{}
+
+// CommandHandler executes a single command addressed to the agent. The
+// returned map (may be nil) is reported back to the caller as the command's
+// result. Wrap errors with Transient to have the command retried.
+type CommandHandler func(ctx context.Context, cmd *protocol.Command) (map[string]any, error)
+
+// CommandOptions tunes command delivery. Zero values select the defaults.
+type CommandOptions struct {
+	Timeout     time.Duration   // per-attempt execution timeout (default 30s)
+	MaxAttempts int             // attempts before a transient failure is final (default 5)
+	Backoff     []time.Duration // delay before retry N; the last entry repeats (default 1s, 5s, 30s, 2m)
+}
+
+var defaultBackoff = []time.Duration{1 * time.Second, 5 * time.Second, 30 * time.Second, 2 * time.Minute}
+
+func (o CommandOptions) withDefaults() CommandOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if len(o.Backoff) == 0 {
+		o.Backoff = defaultBackoff
+	}
+	return o
+}
+
+// backoff returns the delay before the retry following the given attempt (1-based).
+func (o CommandOptions) backoff(attempt int) time.Duration {
+	if attempt > len(o.Backoff) {
+		return o.Backoff[len(o.Backoff)-1]
+	}
+	return o.Backoff[attempt-1]
+}
+
+// transientError marks an error as worth retrying.
+type transientError struct{ err error }
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient wraps err so that command delivery retries it with backoff.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// TransientStatus reports whether an HTTP status code indicates a transient
+// upstream failure (rate limiting or a server error).
+func TransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// IsTransient reports whether err is worth retrying: errors wrapped with
+// Transient, timeouts, network errors, and errors that declare themselves
+// retryable (such as Slack rate-limit errors).
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var te *transientError
+	if errors.As(err, &te) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	var re interface{ Retryable() bool }
+	if errors.As(err, &re) {
+		return re.Retryable()
+	}
+	return false
+}
+
+// ServeCommands delivers commands published on sekia.commands.<name> to h.
+// When the daemon provides the SEKIA_COMMANDS work queue, commands are
+// consumed through a durable consumer: they survive agent restarts, are
+// acknowledged only after h returns, and transient failures are redelivered
+// with backoff. Otherwise it falls back to a core NATS subscription.
+// Commands that still fail are recorded on sekia.dlq.agent.<name>.
+// Synchronous requests on sekia.commands.<name>.sync are executed once and
+// answered with a protocol.CommandResult. Every outcome is published on
+// sekia.results.<name>.
+func (a *Agent) ServeCommands(opts CommandOptions, h CommandHandler) error {
+	opts = opts.withDefaults()
+
+	if _, err := a.nc.Subscribe(protocol.SubjectCommandsSync(a.Name), func(msg *nats.Msg) {
+		a.handleCoreCommand(msg, opts, h)
+	}); err != nil {
+		return fmt.Errorf("subscribe sync commands: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	js, err := jetstream.New(a.nc)
+	if err != nil {
+		return fmt.Errorf("jetstream init: %w", err)
+	}
+	stream, err := js.Stream(ctx, protocol.StreamCommands)
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("command work queue unavailable, falling back to core NATS")
+		_, err := a.nc.Subscribe(protocol.SubjectCommands(a.Name), func(msg *nats.Msg) {
+			a.handleCoreCommand(msg, opts, h)
+		})
+		if err != nil {
+			return fmt.Errorf("subscribe commands: %w", err)
+		}
+		return nil
+	}
+
+	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       a.Name,
+		Description:   "sekia agent " + a.Name,
+		FilterSubject: protocol.SubjectCommands(a.Name),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       opts.Timeout + 30*time.Second,
+		MaxAckPending: 1, // execute commands one at a time, in order
+	})
+	if err != nil {
+		return fmt.Errorf("create command consumer: %w", err)
+	}
+
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		a.handleDurableCommand(msg, opts, h)
+	})
+	if err != nil {
+		return fmt.Errorf("consume commands: %w", err)
+	}
+	a.commands = cc
+	return nil
+}
+
+// handleDurableCommand executes one command from the work queue. Transient
+// failures are negatively acknowledged with a delay so JetStream redelivers
+// them; anything else is final and removed from the queue.
+func (a *Agent) handleDurableCommand(msg jetstream.Msg, opts CommandOptions, h CommandHandler) {
+	var cmd protocol.Command
+	if err := json.Unmarshal(msg.Data(), &cmd); err != nil {
+		a.RecordError()
+		a.logger.Error().Err(err).Msg("unmarshal command")
+		msg.Term()
+		return
+	}
+
+	attempt := 1
+	if meta, err := msg.Metadata(); err == nil {
+		attempt = int(meta.NumDelivered)
+	}
+
+	result, err := a.runCommand(&cmd, attempt, opts, h)
+	if err != nil && IsTransient(err) && attempt < opts.MaxAttempts {
+		delay := opts.backoff(attempt)
+		a.logger.Warn().
+			Err(err).
+			Str("command", cmd.Command).
+			Str("command_id", cmd.ID).
+			Int("attempt", attempt).
+			Dur("retry_in", delay).
+			Msg("command failed, will retry")
+		msg.NakWithDelay(delay)
+		return
+	}
+
+	if err != nil {
+		msg.Term()
+	} else {
+		msg.Ack()
+	}
+	res := a.finishCommand(&cmd, result, err, attempt)
+	if err != nil {
+		a.deadLetter(&cmd, msg.Subject(), res)
+	}
+}
+
+// handleCoreCommand executes one command received over core NATS. Transient
+// failures are retried in place, except for requests (messages with a reply
+// subject), which get a single attempt and an immediate reply so the caller's
+// timeout decides how long to wait.
+func (a *Agent) handleCoreCommand(msg *nats.Msg, opts CommandOptions, h CommandHandler) {
+	var cmd protocol.Command
+	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+		a.RecordError()
+		a.logger.Error().Err(err).Msg("unmarshal command")
+		a.reply(msg, protocol.CommandResult{
+			Agent:  a.Name,
+			Status: protocol.ResultError,
+			Error:  "invalid command: " + err.Error(),
+		})
+		return
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if msg.Reply != "" {
+		maxAttempts = 1
+	}
+
+	var (
+		result  map[string]any
+		err     error
+		attempt int
+	)
+	for attempt = 1; ; attempt++ {
+		result, err = a.runCommand(&cmd, attempt, opts, h)
+		if err == nil || !IsTransient(err) || attempt >= maxAttempts {
+			break
+		}
+		delay := opts.backoff(attempt)
+		a.logger.Warn().
+			Err(err).
+			Str("command", cmd.Command).
+			Str("command_id", cmd.ID).
+			Int("attempt", attempt).
+			Dur("retry_in", delay).
+			Msg("command failed, will retry")
+		time.Sleep(delay)
+	}
+	res := a.finishCommand(&cmd, result, err, attempt)
+	if msg.Reply != "" {
+		// The caller receives the error directly and decides what to do.
+		a.reply(msg, res)
+	} else if err != nil {
+		a.deadLetter(&cmd, msg.Subject, res)
+	}
+}
+
+// deadLetter publishes a command that failed for good to sekia.dlq.agent.<name>.
+func (a *Agent) deadLetter(cmd *protocol.Command, subject string, res protocol.CommandResult) {
+	original, err := json.Marshal(cmd)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("marshal dead-lettered command")
+		return
+	}
+	data, err := json.Marshal(protocol.DeadLetter{
+		Kind:     protocol.DeadLetterCommand,
+		Agent:    a.Name,
+		Subject:  subject,
+		Error:    res.Error,
+		Attempts: res.Attempts,
+		FailedAt: time.Now().UTC(),
+		Data:     original,
+	})
+	if err != nil {
+		a.logger.Error().Err(err).Msg("marshal dead letter")
+		return
+	}
+	if err := a.nc.Publish(protocol.SubjectDLQAgent(a.Name), data); err != nil {
+		a.logger.Error().Err(err).Msg("publish dead letter")
+	}
+}
+
+// reply answers a command request. It is a no-op for plain publishes.
+func (a *Agent) reply(msg *nats.Msg, res protocol.CommandResult) {
+	if msg.Reply == "" {
+		return
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("marshal command reply")
+		return
+	}
+	if err := msg.Respond(data); err != nil {
+		a.logger.Error().Err(err).Msg("reply to command request")
+	}
+}
+
+// runCommand invokes the handler with the per-attempt timeout.
+func (a *Agent) runCommand(cmd *protocol.Command, attempt int, opts CommandOptions, h CommandHandler) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), attemptKey{}, attempt), opts.Timeout)
+	defer cancel()
+	return h(ctx, cmd)
+}
+
+// finishCommand records the final outcome of a command, publishes it on
+// sekia.results.<name>, and returns it.
+func (a *Agent) finishCommand(cmd *protocol.Command, result map[string]any, err error, attempts int) protocol.CommandResult {
+	res := protocol.CommandResult{
+		ID:          cmd.ID,
+		Agent:       a.Name,
+		Command:     cmd.Command,
+		RequestedBy: cmd.Source,
+		Status:      protocol.ResultOK,
+		Result:      result,
+		Attempts:    attempts,
+	}
+	if err != nil {
+		a.RecordError()
+		res.Status = protocol.ResultError
+		res.Error = err.Error()
+		a.logger.Error().
+			Err(err).
+			Str("command", cmd.Command).
+			Str("command_id", cmd.ID).
+			Int("attempts", attempts).
+			Msg("command failed")
+	} else {
+		a.RecordEvent()
+	}
+
+	data, err := json.Marshal(protocol.ResultEvent(res))
+	if err != nil {
+		a.logger.Error().Err(err).Msg("marshal command result")
+		return res
+	}
+	if err := a.nc.Publish(protocol.SubjectResults(a.Name), data); err != nil {
+		a.logger.Error().Err(err).Msg("publish command result")
+	}
+	return res
+}
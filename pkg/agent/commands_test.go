@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/sekia-ai/sekia/pkg/protocol"
+)
+
+type retryableErr struct{ retry bool }
+
+func (e retryableErr) Error() string   { return "retryable" }
+func (e retryableErr) Retryable() bool { return e.retry }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", errors.New("missing required field: owner"), false},
+		{"wrapped transient", fmt.Errorf("create issue: %w", Transient(errors.New("503"))), true},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), true},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"retryable", retryableErr{retry: true}, true},
+		{"not retryable", retryableErr{retry: false}, false},
+		{"signature", ErrInvalidSignature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransientStatus(t *testing.T) {
+	for code, want := range map[int]bool{200: false, 400: false, 404: false, 429: true, 500: true, 503: true} {
+		if got := TransientStatus(code); got != want {
+			t.Errorf("TransientStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestCommandOptionsBackoff(t *testing.T) {
+	opts := CommandOptions{Backoff: []time.Duration{time.Second, 2 * time.Second}}.withDefaults()
+	if opts.Timeout != 30*time.Second || opts.MaxAttempts != 5 {
+		t.Fatalf("defaults not applied: %+v", opts)
+	}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 2 * time.Second} {
+		if got := opts.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestVerifyCommand(t *testing.T) {
+	const secret = "s3cret"
+	a := &Agent{Name: "test-agent", logger: zerolog.Nop(), nonces: protocol.NewNonceCache(0)}
+	a.SetMinSignatureVersion(0)
+
+	attempt := func(n int) context.Context { return context.WithValue(context.Background(), attemptKey{}, n) }
+
+	cmd := protocol.NewCommand("close_issue", map[string]any{"number": float64(1)}, "workflow:closer")
+	if err := protocol.SignCommandV2(&cmd, secret, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.VerifyCommand(attempt(1), &cmd, secret); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := a.VerifyCommand(attempt(2), &cmd, secret); err != nil {
+		t.Errorf("retry: %v", err)
+	}
+	if err := a.VerifyCommand(attempt(1), &cmd, secret); !errors.Is(err, protocol.ErrReplayedCommand) {
+		t.Errorf("replay: err = %v, want ErrReplayedCommand", err)
+	}
+
+	forged := cmd
+	forged.Command = "delete_repo"
+	if err := a.VerifyCommand(attempt(1), &forged, secret); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("forged: err = %v, want ErrInvalidSignature", err)
+	}
+
+	v1 := protocol.NewCommand("close_issue", map[string]any{"number": float64(1)}, "workflow:closer")
+	if err := protocol.SignCommand(&v1, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.VerifyCommand(attempt(1), &v1, secret); err != nil {
+		t.Errorf("v1 during the compat window: %v", err)
+	}
+	a.SetMinSignatureVersion(protocol.SignatureV2)
+	if err := a.VerifyCommand(attempt(1), &v1, secret); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("v1 after the compat window: err = %v, want ErrInvalidSignature", err)
+	}
+
+	if err := a.VerifyCommand(attempt(1), &protocol.Command{Command: "x"}, ""); err != nil {
+		t.Errorf("no secret: %v", err)
+	}
+}
+
+func TestVerifyCommand_TrustedKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &protocol.SigningKey{ID: protocol.KeyID("sekiad", pub), PrivateKey: priv}
+	ks, err := protocol.ParseKeySet([]byte(fmt.Sprintf("[[keys]]\nid = %q\npublic_key = %q\nsources = [\"workflow:*\"]\n",
+		key.ID, base64.StdEncoding.EncodeToString(pub))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Agent{Name: "test-agent", logger: zerolog.Nop(), nonces: protocol.NewNonceCache(0)}
+	a.SetMinSignatureVersion(0)
+	a.SetTrustedKeys(ks)
+	if v := a.signatureVersion(); v != protocol.SignatureV3 {
+		t.Errorf("advertised signature version = %d, want 3", v)
+	}
+
+	signed := protocol.NewCommand("close_issue", map[string]any{}, "workflow:closer")
+	if err := protocol.SignCommandEd25519(&signed, key, 0); err != nil {
+		t.Fatal(err)
+	}
+	hmac := protocol.NewCommand("close_issue", map[string]any{}, "workflow:closer")
+	if err := protocol.SignCommandV2(&hmac, "s3cret", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// With both a secret and trusted keys, either signature is accepted.
+	if err := a.VerifyCommand(context.Background(), &signed, "s3cret"); err != nil {
+		t.Errorf("key-signed: %v", err)
+	}
+	if err := a.VerifyCommand(context.Background(), &hmac, "s3cret"); err != nil {
+		t.Errorf("HMAC-signed: %v", err)
+	}
+
+	// Without a secret, only key signatures are.
+	hmac2 := protocol.NewCommand("close_issue", map[string]any{}, "workflow:closer")
+	if err := protocol.SignCommandV2(&hmac2, "s3cret", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.VerifyCommand(context.Background(), &hmac2, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("HMAC-signed without a secret: err = %v, want ErrInvalidSignature", err)
+	}
+	if err := a.VerifyCommand(context.Background(), &protocol.Command{Command: "x"}, ""); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("unsigned: err = %v, want ErrInvalidSignature", err)
+	}
+
+	a.SetTrustedKeys(nil)
+	if v := a.signatureVersion(); v != protocol.SignatureV2 {
+		t.Errorf("advertised signature version without keys = %d, want 2", v)
+	}
+}
@@ -14,15 +14,19 @@ type StatusResponse struct {
 
 // AgentInfo is one entry in the GET /api/v1/agents response.
 type AgentInfo struct {
-	Name            string    `json:"name"`
-	Version         string    `json:"version"`
-	Status          string    `json:"status"`
-	Capabilities    []string  `json:"capabilities"`
-	Commands        []string  `json:"commands"`
-	RegisteredAt    time.Time `json:"registered_at"`
-	LastHeartbeat   time.Time `json:"last_heartbeat"`
-	EventsProcessed int64    `json:"events_processed"`
-	Errors          int64    `json:"errors"`
+	Name            string             `json:"name"`
+	Version         string             `json:"version"`
+	Status          string             `json:"status"`
+	Capabilities    []string           `json:"capabilities"`
+	Commands        []string           `json:"commands"`
+	CommandSchemas  map[string]*Schema `json:"command_schemas,omitempty"`
+	RegisteredAt    time.Time          `json:"registered_at"`
+	LastHeartbeat   time.Time          `json:"last_heartbeat"`
+	EventsProcessed int64              `json:"events_processed"`
+	Errors          int64              `json:"errors"`
+	CommandsDenied  int64              `json:"commands_denied,omitempty"` // commands refused by the command policy
+
+	SignatureVersion int `json:"signature_version,omitempty"` // highest command signature version verified (0 = 1)
 }
 
 // AgentsResponse is returned by GET /api/v1/agents.
@@ -36,9 +40,52 @@ type WorkflowInfo struct {
 	FilePath string   `json:"file_path"`
 	Handlers int      `json:"handlers"`
 	Patterns []string `json:"patterns"`
+	Filters  []string `json:"filters,omitempty"` // structured sekia.on filters, one per filtered handler
 	LoadedAt time.Time `json:"loaded_at"`
 	Events   int64    `json:"events"`
 	Errors   int64    `json:"errors"`
+	Shadow   bool     `json:"shadow"` // publishes and commands are recorded, not sent
+
+	Schedules []ScheduleInfo `json:"schedules,omitempty"`
+
+	// Loading. A workflow whose file has never loaded has only Name,
+	// FilePath and LoadError set.
+	LoadMs    float64    `json:"load_ms"`              // time the last successful load took
+	LoadError *LoadError `json:"load_error,omitempty"` // last failed load, until the workflow loads again
+
+	// Worker pool (sekia.concurrency) and backpressure.
+	Concurrency   int      `json:"concurrency"`         // Lua VMs handling events
+	OrderKey      []string `json:"order_key,omitempty"` // event paths keeping per-key order
+	Busy          int      `json:"busy"`                // VMs currently running a handler
+	Queued        int      `json:"queued"`              // events waiting for a VM
+	QueueCapacity int      `json:"queue_capacity"`
+	Dropped       int64    `json:"dropped"` // events dropped because the queue was full
+
+	Denied int64 `json:"denied,omitempty"` // commands refused by the command policy
+}
+
+// LoadError describes why a workflow file failed to load. Line and Column
+// are set for syntax errors; Line alone for errors raised while the file
+// ran.
+type LoadError struct {
+	Message string    `json:"message"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Schedule kinds.
+const (
+	ScheduleInterval = "interval" // sekia.schedule
+	ScheduleCron     = "cron"     // sekia.cron
+)
+
+// ScheduleInfo describes one timer-driven handler of a workflow.
+type ScheduleInfo struct {
+	Kind     string    `json:"kind"`
+	Spec     string    `json:"spec"` // interval ("5m0s") or cron expression
+	Timezone string    `json:"timezone,omitempty"`
+	NextRun  time.Time `json:"next_run"`
 }
 
 // WorkflowsResponse is returned by GET /api/v1/workflows.
@@ -51,3 +98,59 @@ type ConfigReloadResponse struct {
 	Status string `json:"status"`
 	Target string `json:"target"`
 }
+
+// EventIngestResponse is returned by POST /api/v1/events.
+type EventIngestResponse struct {
+	Status  string `json:"status"`
+	ID      string `json:"id"`
+	Subject string `json:"subject"`
+}
+
+// Intent kinds.
+const (
+	IntentPublish  = "publish"
+	IntentCommand  = "command"
+	IntentSchedule = "schedule" // sekia.after / sekia.at
+	IntentHTTP     = "http"     // sekia.http.request other than GET and HEAD
+)
+
+// Intent is an outgoing sekia.publish, sekia.command, sekia.after,
+// sekia.at or sekia.http call that a workflow running in dry-run or shadow
+// mode recorded instead of sending.
+type Intent struct {
+	Kind      string         `json:"kind"`
+	Subject   string         `json:"subject"`              // the URL for http
+	Method    string         `json:"method,omitempty"`     // http only
+	EventType string         `json:"event_type,omitempty"` // publish and schedule only
+	Agent     string         `json:"agent,omitempty"`      // command only
+	Command   string         `json:"command,omitempty"`    // command only
+	Payload   map[string]any `json:"payload"`
+	FireAt    time.Time      `json:"fire_at,omitzero"`   // schedule only
+	EventID   string         `json:"event_id,omitempty"` // event being handled when the call was made
+	At        time.Time      `json:"at"`
+}
+
+// ReplayRequest is the body of POST /api/v1/workflows/<name>/replay.
+type ReplayRequest struct {
+	Since   time.Time `json:"since"`
+	Subject string    `json:"subject,omitempty"` // subject filter (default: all events)
+	DryRun  bool      `json:"dry_run"`
+	Limit   int       `json:"limit,omitempty"` // maximum events to replay (0 = no limit)
+}
+
+// ReplayResponse is returned by POST /api/v1/workflows/<name>/replay.
+type ReplayResponse struct {
+	Workflow string   `json:"workflow"`
+	DryRun   bool     `json:"dry_run"`
+	Events   int      `json:"events"`
+	Errors   int64    `json:"errors"` // handler errors (dry run only)
+	Intents  []Intent `json:"intents,omitempty"`
+}
+
+// ShadowResponse is returned by GET /api/v1/workflows/<name>/shadow.
+type ShadowResponse struct {
+	Workflow string   `json:"workflow"`
+	Shadow   bool     `json:"shadow"` // currently loaded in shadow mode
+	Total    int64    `json:"total"`  // intents recorded since the daemon started
+	Intents  []Intent `json:"intents"`
+}
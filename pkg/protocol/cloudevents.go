@@ -0,0 +1,267 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloudEventsSpecVersion is the CloudEvents version sekia reads and writes.
+const CloudEventsSpecVersion = "1.0"
+
+// ContentTypeCloudEvents is the media type of a structured-mode CloudEvent.
+const ContentTypeCloudEvents = "application/cloudevents+json"
+
+// HeaderOrigin is the NATS header marking events that entered sekia through
+// the CloudEvents ingress, so egress sinks do not send them back out.
+const HeaderOrigin = "Sekia-Origin"
+
+// OriginCloudEvents is the HeaderOrigin value set by the CloudEvents ingress.
+const OriginCloudEvents = "cloudevents"
+
+// CloudEvent is a CloudEvents 1.0 event in the JSON event format. Exactly
+// one of Data (JSON data) and DataBase64 (any other data) is set.
+// Extension attributes are not carried.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time,omitzero"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// ToCloudEvent maps a sekia event to a CloudEvent with JSON data. The
+// payload schema, if any, is referenced by dataschema.
+func ToCloudEvent(ev Event) (CloudEvent, error) {
+	ce := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              ev.ID,
+		Source:          ev.Source,
+		Type:            ev.Type,
+		Time:            time.Unix(ev.Timestamp, 0).UTC(),
+		DataContentType: "application/json",
+	}
+	if ce.Source == "" {
+		ce.Source = "sekia"
+	}
+	if ev.SchemaVersion > 0 {
+		ce.DataSchema = SchemaID(ev.Type, ev.SchemaVersion)
+	}
+	if ev.Payload != nil {
+		data, err := json.Marshal(ev.Payload)
+		if err != nil {
+			return CloudEvent{}, fmt.Errorf("encode payload: %w", err)
+		}
+		ce.Data = data
+	}
+	return ce, nil
+}
+
+// FromCloudEvent maps a CloudEvent to a sekia event. JSON object data
+// becomes the payload; any other data is stored under payload.data (as a
+// string for non-JSON content). A dataschema naming a sekia schema sets
+// the schema version.
+func FromCloudEvent(ce CloudEvent) (Event, error) {
+	if err := ce.validate(); err != nil {
+		return Event{}, err
+	}
+
+	payload := map[string]any{}
+	switch {
+	case ce.DataBase64 != nil:
+		payload["data"] = string(ce.DataBase64)
+	case len(ce.Data) > 0:
+		var data any
+		if err := json.Unmarshal(ce.Data, &data); err != nil {
+			return Event{}, fmt.Errorf("decode data: %w", err)
+		}
+		if m, ok := data.(map[string]any); ok {
+			payload = m
+		} else {
+			payload["data"] = data
+		}
+	}
+
+	ev := Event{
+		ID:        ce.ID,
+		Type:      ce.Type,
+		Source:    ce.Source,
+		Timestamp: ce.Time.Unix(),
+		Payload:   payload,
+	}
+	if ce.Time.IsZero() {
+		ev.Timestamp = time.Now().Unix()
+	}
+	if t, v, ok := ParseSchemaID(ce.DataSchema); ok && t == ce.Type {
+		ev.SchemaVersion = v
+	}
+	return ev, nil
+}
+
+func (ce CloudEvent) validate() error {
+	var missing []string
+	for _, attr := range []struct{ name, value string }{
+		{"specversion", ce.SpecVersion},
+		{"id", ce.ID},
+		{"source", ce.Source},
+		{"type", ce.Type},
+	} {
+		if attr.value == "" {
+			missing = append(missing, attr.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required attribute(s): %s", strings.Join(missing, ", "))
+	}
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return fmt.Errorf("unsupported specversion %q (want %s)", ce.SpecVersion, CloudEventsSpecVersion)
+	}
+	return nil
+}
+
+// binaryHeaders maps ce-* HTTP headers to the attributes they carry in
+// binary content mode. datacontenttype travels as Content-Type.
+var binaryHeaders = []struct {
+	header string
+	field  func(*CloudEvent) *string
+}{
+	{"Ce-Specversion", func(ce *CloudEvent) *string { return &ce.SpecVersion }},
+	{"Ce-Id", func(ce *CloudEvent) *string { return &ce.ID }},
+	{"Ce-Source", func(ce *CloudEvent) *string { return &ce.Source }},
+	{"Ce-Type", func(ce *CloudEvent) *string { return &ce.Type }},
+	{"Ce-Subject", func(ce *CloudEvent) *string { return &ce.Subject }},
+	{"Ce-Dataschema", func(ce *CloudEvent) *string { return &ce.DataSchema }},
+}
+
+// ReadCloudEventHTTP decodes a CloudEvent from an HTTP message in either
+// structured mode (Content-Type application/cloudevents+json) or binary
+// mode (attributes in ce-* headers, data in the body).
+func ReadCloudEventHTTP(h http.Header, body []byte) (CloudEvent, error) {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if mediaType == ContentTypeCloudEvents {
+		var ce CloudEvent
+		if err := json.Unmarshal(body, &ce); err != nil {
+			return CloudEvent{}, fmt.Errorf("decode structured event: %w", err)
+		}
+		return ce, ce.validate()
+	}
+	if h.Get("Ce-Specversion") == "" {
+		return CloudEvent{}, errors.New("not a CloudEvent: need Content-Type " + ContentTypeCloudEvents + " or ce-* headers")
+	}
+
+	var ce CloudEvent
+	for _, b := range binaryHeaders {
+		*b.field(&ce) = unescapeHeader(h.Get(b.header))
+	}
+	if t := h.Get("Ce-Time"); t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, unescapeHeader(t))
+		if err != nil {
+			return CloudEvent{}, fmt.Errorf("invalid ce-time %q", t)
+		}
+		ce.Time = parsed
+	}
+	ce.DataContentType = h.Get("Content-Type")
+	if len(body) > 0 {
+		if isJSONMediaType(mediaType) {
+			if !json.Valid(body) {
+				return CloudEvent{}, errors.New("body is not valid JSON")
+			}
+			ce.Data = json.RawMessage(body)
+		} else {
+			ce.DataBase64 = body
+		}
+	}
+	return ce, ce.validate()
+}
+
+// EncodeCloudEventHTTP returns the headers and body for sending ce over
+// HTTP in binary mode (binary true) or structured mode.
+func EncodeCloudEventHTTP(ce CloudEvent, binary bool) (http.Header, []byte, error) {
+	h := http.Header{}
+	if !binary {
+		body, err := json.Marshal(ce)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.Set("Content-Type", ContentTypeCloudEvents)
+		return h, body, nil
+	}
+
+	for _, b := range binaryHeaders {
+		if v := *b.field(&ce); v != "" {
+			h.Set(b.header, escapeHeader(v))
+		}
+	}
+	if !ce.Time.IsZero() {
+		h.Set("Ce-Time", ce.Time.Format(time.RFC3339Nano))
+	}
+	if ce.DataContentType != "" {
+		h.Set("Content-Type", ce.DataContentType)
+	}
+	if ce.DataBase64 != nil {
+		return h, ce.DataBase64, nil
+	}
+	return h, ce.Data, nil
+}
+
+// isJSONMediaType reports whether data of this media type is JSON. An
+// empty media type defaults to JSON, as in the CloudEvents JSON format.
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "" || mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// escapeHeader percent-encodes a binary-mode attribute value: characters
+// outside printable ASCII, space, '"' and '%'.
+func escapeHeader(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func unescapeHeader(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// SchemaID returns the identifier of a payload schema, also used as its
+// $id and as the CloudEvents dataschema: urn:sekia:event:<type>:<version>.
+func SchemaID(eventType string, version int) string {
+	return fmt.Sprintf("urn:sekia:event:%s:%d", eventType, version)
+}
+
+// ParseSchemaID splits an identifier produced by SchemaID.
+func ParseSchemaID(id string) (eventType string, version int, ok bool) {
+	rest, ok := strings.CutPrefix(id, "urn:sekia:event:")
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	v, err := strconv.Atoi(rest[i+1:])
+	if err != nil || v < 1 {
+		return "", 0, false
+	}
+	return rest[:i], v, true
+}
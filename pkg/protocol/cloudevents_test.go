@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloudEventRoundTrip(t *testing.T) {
+	ev := NewEvent("github.push", "github", map[string]any{"repo": "acme/app", "commits": 2})
+	ce, err := ToCloudEvent(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ce.SpecVersion != "1.0" || ce.ID != ev.ID || ce.Source != "github" || ce.Type != "github.push" {
+		t.Errorf("unexpected attributes: %+v", ce)
+	}
+	if ce.DataSchema != "urn:sekia:event:github.push:1" {
+		t.Errorf("dataschema = %q", ce.DataSchema)
+	}
+
+	for _, binary := range []bool{false, true} {
+		h, body, err := EncodeCloudEventHTTP(ce, binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadCloudEventHTTP(h, body)
+		if err != nil {
+			t.Fatalf("binary=%v: %v", binary, err)
+		}
+		back, err := FromCloudEvent(got)
+		if err != nil {
+			t.Fatalf("binary=%v: %v", binary, err)
+		}
+		if back.ID != ev.ID || back.Type != ev.Type || back.Source != ev.Source ||
+			back.Timestamp != ev.Timestamp || back.SchemaVersion != 1 {
+			t.Errorf("binary=%v: round trip changed the event: %+v", binary, back)
+		}
+		if back.Payload["repo"] != "acme/app" || back.Payload["commits"] != float64(2) {
+			t.Errorf("binary=%v: payload = %v", binary, back.Payload)
+		}
+	}
+}
+
+func TestReadCloudEventHTTP_Structured(t *testing.T) {
+	h := http.Header{"Content-Type": {"application/cloudevents+json; charset=utf-8"}}
+	body := `{"specversion":"1.0","id":"A234","source":"https://ci.example.com","type":"build.finished",
+		"time":"2026-01-02T03:04:05Z","data":{"status":"ok"}}`
+	ce, err := ReadCloudEventHTTP(h, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := FromCloudEvent(ce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.ID != "A234" || ev.Type != "build.finished" || ev.Source != "https://ci.example.com" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.Timestamp != time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Unix() {
+		t.Errorf("timestamp = %d", ev.Timestamp)
+	}
+	if ev.Payload["status"] != "ok" {
+		t.Errorf("payload = %v", ev.Payload)
+	}
+}
+
+func TestReadCloudEventHTTP_Binary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Ce-Specversion", "1.0")
+	h.Set("Ce-Id", "42")
+	h.Set("Ce-Source", "/sensors/tn-1")
+	h.Set("Ce-Type", "sensor.reading")
+	h.Set("Ce-Subject", "room%20101")
+
+	h.Set("Content-Type", "text/plain")
+	ce, err := ReadCloudEventHTTP(h, []byte("21.5C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ce.Subject != "room 101" {
+		t.Errorf("subject = %q, want unescaped value", ce.Subject)
+	}
+	ev, err := FromCloudEvent(ce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Payload["data"] != "21.5C" {
+		t.Errorf("non-JSON data: payload = %v", ev.Payload)
+	}
+	if ev.Timestamp == 0 {
+		t.Error("missing ce-time should default to now")
+	}
+
+	h.Set("Content-Type", "application/json")
+	ce, err = ReadCloudEventHTTP(h, []byte(`[1,2]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, _ = FromCloudEvent(ce)
+	if data, ok := ev.Payload["data"].([]any); !ok || len(data) != 2 {
+		t.Errorf("JSON array data: payload = %v", ev.Payload)
+	}
+
+	if _, err := ReadCloudEventHTTP(h, []byte(`{bad`)); err == nil {
+		t.Error("expected error for invalid JSON body")
+	}
+	h.Set("Ce-Time", "yesterday")
+	if _, err := ReadCloudEventHTTP(h, []byte(`{}`)); err == nil {
+		t.Error("expected error for invalid ce-time")
+	}
+}
+
+func TestReadCloudEventHTTP_Invalid(t *testing.T) {
+	structured := http.Header{"Content-Type": {ContentTypeCloudEvents}}
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   string
+	}{
+		{"plain JSON", http.Header{"Content-Type": {"application/json"}}, `{}`, "not a CloudEvent"},
+		{"missing attributes", structured, `{"specversion":"1.0","type":"x"}`, "id, source"},
+		{"wrong version", structured, `{"specversion":"0.3","id":"1","source":"s","type":"x"}`, "unsupported specversion"},
+		{"bad JSON", structured, `{`, "decode structured event"},
+	}
+	for _, tt := range tests {
+		_, err := ReadCloudEventHTTP(tt.header, []byte(tt.body))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeCloudEventHTTP_Binary(t *testing.T) {
+	ce := CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              "1",
+		Source:          "slack",
+		Type:            "slack.message.received",
+		Subject:         `café "x" 100%`,
+		Time:            time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		DataContentType: "application/json",
+		Data:            json.RawMessage(`{"text":"hi"}`),
+	}
+	h, body, err := EncodeCloudEventHTTP(ce, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Get("Ce-Subject"); got != "caf%C3%A9%20%22x%22%20100%25" {
+		t.Errorf("ce-subject = %q", got)
+	}
+	if got := h.Get("Ce-Time"); got != "2026-01-02T03:04:05Z" {
+		t.Errorf("ce-time = %q", got)
+	}
+	if h.Get("Content-Type") != "application/json" || string(body) != `{"text":"hi"}` {
+		t.Errorf("content-type %q, body %s", h.Get("Content-Type"), body)
+	}
+	if h.Get("Ce-Dataschema") != "" {
+		t.Error("empty attributes should not be sent")
+	}
+}
+
+func TestParseSchemaID(t *testing.T) {
+	typ, v, ok := ParseSchemaID(SchemaID("google.calendar.event.created", 3))
+	if !ok || typ != "google.calendar.event.created" || v != 3 {
+		t.Errorf("got %q, %d, %v", typ, v, ok)
+	}
+	for _, id := range []string{"", "https://example.com/schema.json", "urn:sekia:event:x", "urn:sekia:event:x:0", "urn:sekia:event::1"} {
+		if _, _, ok := ParseSchemaID(id); ok {
+			t.Errorf("ParseSchemaID(%q) should fail", id)
+		}
+	}
+}
@@ -1,9 +1,33 @@
 package protocol
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
 // Command is the canonical command envelope published on sekia.commands.<agent>.
 type Command struct {
+	ID        string         `json:"id,omitempty"`
 	Command   string         `json:"command"`
 	Payload   map[string]any `json:"payload"`
 	Source    string         `json:"source"`
 	Signature string         `json:"signature,omitempty"`
+
+	// Signed envelope (SignatureV2 and SignatureV3; see SignCommandV2).
+	SigVersion int       `json:"sig_version,omitempty"`
+	IssuedAt   time.Time `json:"issued_at,omitzero"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+	Nonce      string    `json:"nonce,omitempty"`
+	KeyID      string    `json:"key_id,omitempty"` // Ed25519 signing key (SignatureV3 only)
+}
+
+// NewCommand creates a Command with a generated ID.
+func NewCommand(command string, payload map[string]any, source string) Command {
+	return Command{
+		ID:      "cmd_" + uuid.NewString(),
+		Command: command,
+		Payload: payload,
+		Source:  source,
+	}
 }
@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Command payload schemas of the bundled agents, one file per command, named
+// <agent>.<command>.json after the agent's kind (github, slack, ...) rather
+// than its instance name. Agents send them in their Registration so that
+// workflow linting can check the payloads workflows send.
+//
+//go:embed schemas/commands/*.json
+var commandSchemaFiles embed.FS
+
+// commandSchemas maps agent kind -> command -> schema.
+var commandSchemas = loadCommandSchemas()
+
+func loadCommandSchemas() map[string]map[string]*Schema {
+	const dir = "schemas/commands"
+	entries, err := commandSchemaFiles.ReadDir(dir)
+	if err != nil {
+		panic(fmt.Sprintf("read embedded command schemas: %v", err))
+	}
+	reg := make(map[string]map[string]*Schema)
+	for _, e := range entries {
+		kind, command, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".json"), ".")
+		if !ok {
+			panic(fmt.Sprintf("command schema %s: file name must be <agent>.<command>.json", e.Name()))
+		}
+		data, err := commandSchemaFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("read command schema %s: %v", e.Name(), err))
+		}
+		s := &Schema{raw: data}
+		if err := json.Unmarshal(data, s); err != nil {
+			panic(fmt.Sprintf("parse command schema %s: %v", e.Name(), err))
+		}
+		if reg[kind] == nil {
+			reg[kind] = make(map[string]*Schema)
+		}
+		reg[kind][command] = s
+	}
+	return reg
+}
+
+// CommandSchemas returns the payload schemas of an agent kind's commands,
+// keyed by command name, or nil if the kind has none.
+func CommandSchemas(kind string) map[string]*Schema {
+	return commandSchemas[kind]
+}
@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Dead-letter kinds.
+const (
+	DeadLetterEvent   = "event"   // a workflow handler failed on an event
+	DeadLetterCommand = "command" // an agent failed to execute a command
+)
+
+// DeadLetter records an event or command that could not be processed. Data
+// holds the original message so it can be replayed once the cause is fixed.
+type DeadLetter struct {
+	Seq      uint64          `json:"seq,omitempty"` // SEKIA_DLQ stream sequence, set when read back
+	Kind     string          `json:"kind"`
+	Workflow string          `json:"workflow,omitempty"`
+	Agent    string          `json:"agent,omitempty"`
+	Subject  string          `json:"subject"`           // subject the original message was delivered on
+	Handler  string          `json:"handler,omitempty"` // sekia.on pattern that failed (events only)
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// SubjectDLQWorkflow returns the dead-letter subject for a workflow's failed events.
+func SubjectDLQWorkflow(workflow string) string {
+	return fmt.Sprintf("sekia.dlq.workflow.%s", workflow)
+}
+
+// SubjectDLQAgent returns the dead-letter subject for an agent's failed commands.
+func SubjectDLQAgent(agentName string) string {
+	return fmt.Sprintf("sekia.dlq.agent.%s", agentName)
+}
+
+// DLQResponse is returned by GET /api/v1/dlq.
+type DLQResponse struct {
+	Entries []DeadLetter `json:"entries"`
+}
+
+// DLQReplayResponse is returned by the /api/v1/dlq replay endpoints.
+type DLQReplayResponse struct {
+	Replayed []uint64          `json:"replayed"`
+	Failed   map[uint64]string `json:"failed,omitempty"`
+}
+
+// DLQPurgeResponse is returned by the /api/v1/dlq purge endpoints.
+type DLQPurgeResponse struct {
+	Purged uint64 `json:"purged"`
+}
@@ -8,20 +8,23 @@ import (
 
 // Event is the canonical event envelope published on sekia.events.<source>.
 type Event struct {
-	ID        string         `json:"id"`
-	Type      string         `json:"type"`
-	Source    string         `json:"source"`
-	Timestamp int64          `json:"timestamp"`
-	Payload   map[string]any `json:"payload"`
+	ID            string         `json:"id"`
+	Type          string         `json:"type"`
+	Source        string         `json:"source"`
+	Timestamp     int64          `json:"timestamp"`
+	SchemaVersion int            `json:"schema_version,omitempty"` // payload schema version; 0 = no registered schema
+	Payload       map[string]any `json:"payload"`
 }
 
-// NewEvent creates an Event with a generated ID and current timestamp.
+// NewEvent creates an Event with a generated ID and current timestamp. The
+// event is tagged with the latest schema version registered for its type.
 func NewEvent(eventType, source string, payload map[string]any) Event {
 	return Event{
-		ID:        "evt_" + uuid.NewString(),
-		Type:      eventType,
-		Source:    source,
-		Timestamp: time.Now().Unix(),
-		Payload:   payload,
+		ID:            "evt_" + uuid.NewString(),
+		Type:          eventType,
+		Source:        source,
+		Timestamp:     time.Now().Unix(),
+		SchemaVersion: LatestSchemaVersion(eventType),
+		Payload:       payload,
 	}
 }
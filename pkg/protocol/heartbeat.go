@@ -7,6 +7,10 @@ type Heartbeat struct {
 	Name            string    `json:"name"`
 	Status          string    `json:"status"`
 	LastEvent       time.Time `json:"last_event"`
-	EventsProcessed int64    `json:"events_processed"`
-	Errors          int64    `json:"errors"`
+	EventsProcessed int64     `json:"events_processed"`
+	Errors          int64     `json:"errors"`
+	CommandsDenied  int64     `json:"commands_denied,omitempty"` // commands refused by the command policy
+
+	// SignatureVersion repeats the registration's, for daemons that missed it.
+	SignatureVersion int `json:"signature_version,omitempty"`
 }
@@ -0,0 +1,197 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Trusted key statuses. Agents accept commands signed with active and
+// retired keys; senders sign only with active ones. A retired key has been
+// replaced by a rotation and is kept until commands signed with it have
+// expired.
+const (
+	KeyActive  = "active"
+	KeyRetired = "retired"
+	KeyRevoked = "revoked"
+)
+
+// ErrUntrustedKey is wrapped by the errors KeySet.Verify returns for
+// commands not signed with a key in the set.
+var ErrUntrustedKey = errors.New("untrusted signing key")
+
+// SigningKey is the Ed25519 private key a command sender (sekiad, the MCP
+// server) signs commands with. Agents verify them with the matching
+// TrustedKey.
+type SigningKey struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+}
+
+// KeyID returns the ID of a key named name: the name and the first 8 hex
+// digits of the SHA-256 of the public key, e.g. "sekiad-1a2b3c4d".
+func KeyID(name string, pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return name + "-" + hex.EncodeToString(sum[:4])
+}
+
+// SignCommandEd25519 signs the command with the version 3 envelope: the
+// version 2 envelope (see SignCommandV2) and key's ID, signed with key.
+// Only send it to agents that advertise SignatureV3.
+func SignCommandEd25519(cmd *Command, key *SigningKey, ttl time.Duration) error {
+	if err := stampEnvelope(cmd, SignatureV3, key.ID, ttl); err != nil {
+		return err
+	}
+	data, err := canonical(cmd)
+	if err != nil {
+		return err
+	}
+	cmd.Signature = hex.EncodeToString(ed25519.Sign(key.PrivateKey, data))
+	return nil
+}
+
+// KeySet is the set of Ed25519 public keys an agent trusts to sign
+// commands. It is loaded from a TOML file (security.trusted_keys) that
+// sekiactl keys maintains:
+//
+//	[[keys]]
+//	id = "sekiad-1a2b3c4d"
+//	name = "sekiad"
+//	public_key = "q6bV...="
+//	sources = ["workflow:*", "skill:*"]
+//	created = 2026-03-01T12:00:00Z
+//	status = "active"
+//
+// A key may sign only commands whose Source matches one of its sources,
+// which may contain '*' wildcards.
+type KeySet struct {
+	Keys []TrustedKey `toml:"keys"`
+}
+
+// TrustedKey is a public key in a KeySet.
+type TrustedKey struct {
+	ID        string    `toml:"id"`
+	Name      string    `toml:"name"`
+	PublicKey string    `toml:"public_key"` // base64
+	Sources   []string  `toml:"sources"`
+	Created   time.Time `toml:"created"`
+	Status    string    `toml:"status"` // KeyActive, KeyRetired or KeyRevoked
+
+	pub ed25519.PublicKey
+}
+
+// LoadKeySet reads a trusted keys file. An empty path returns a nil set,
+// which trusts no keys.
+func LoadKeySet(path string) (*KeySet, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the operator's config
+	if err != nil {
+		return nil, fmt.Errorf("read trusted keys: %w", err)
+	}
+	ks, err := ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("trusted keys %s: %w", path, err)
+	}
+	return ks, nil
+}
+
+// ParseKeySet parses and checks a TOML trusted keys file.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var ks KeySet
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ks); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			return nil, errors.New(strings.TrimSpace(strict.String()))
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(ks.Keys))
+	for i := range ks.Keys {
+		k := &ks.Keys[i]
+		where := fmt.Sprintf("keys[%d]", i)
+		if k.ID == "" {
+			return nil, fmt.Errorf("%s: id is required", where)
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("%s: duplicate key %s", where, k.ID)
+		}
+		seen[k.ID] = true
+		pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: public_key must be a base64 Ed25519 public key", where)
+		}
+		k.pub = pub
+		switch k.Status {
+		case "":
+			k.Status = KeyActive
+		case KeyActive, KeyRetired, KeyRevoked:
+		default:
+			return nil, fmt.Errorf("%s: status must be %q, %q or %q, got %q", where, KeyActive, KeyRetired, KeyRevoked, k.Status)
+		}
+	}
+	return &ks, nil
+}
+
+// Key returns the key with the given ID, or nil.
+func (ks *KeySet) Key(id string) *TrustedKey {
+	if ks == nil {
+		return nil
+	}
+	for i := range ks.Keys {
+		if ks.Keys[i].ID == id {
+			return &ks.Keys[i]
+		}
+	}
+	return nil
+}
+
+// Verify checks a version 3 command's Ed25519 signature. It returns nil if
+// the command is signed by a key in the set that is not revoked and may sign
+// for the command's source, and an error wrapping ErrUntrustedKey or saying
+// why the signature is invalid otherwise. Like VerifyCommand, it does not
+// check that the command is fresh.
+func (ks *KeySet) Verify(cmd *Command) error {
+	if cmd.SigVersion != SignatureV3 || cmd.KeyID == "" {
+		return fmt.Errorf("%w: command is not signed with a key", ErrUntrustedKey)
+	}
+	k := ks.Key(cmd.KeyID)
+	switch {
+	case k == nil:
+		return fmt.Errorf("%w: unknown key %s", ErrUntrustedKey, cmd.KeyID)
+	case k.Status == KeyRevoked:
+		return fmt.Errorf("%w: key %s is revoked", ErrUntrustedKey, cmd.KeyID)
+	case !anyMatch(k.Sources, cmd.Source):
+		return fmt.Errorf("%w: key %s may not sign commands from %s", ErrUntrustedKey, cmd.KeyID, cmd.Source)
+	}
+	sig, err := hex.DecodeString(cmd.Signature)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	data, err := canonical(cmd)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(k.pub, data, sig) {
+		return fmt.Errorf("signature does not verify with key %s", cmd.KeyID)
+	}
+	return nil
+}
+
+// Marshal encodes the set in the trusted keys file format.
+func (ks *KeySet) Marshal() ([]byte, error) {
+	return toml.Marshal(ks)
+}
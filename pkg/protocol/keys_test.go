@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testKeySet(t *testing.T) (*KeySet, *SigningKey, *SigningKey) {
+	t.Helper()
+	gen := func(name string) (*SigningKey, string) {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &SigningKey{ID: KeyID(name, pub), PrivateKey: priv}, base64.StdEncoding.EncodeToString(pub)
+	}
+	daemon, daemonPub := gen("sekiad")
+	mcp, mcpPub := gen("mcp")
+	ks, err := ParseKeySet([]byte(fmt.Sprintf(`
+[[keys]]
+id = %q
+name = "sekiad"
+public_key = %q
+sources = ["workflow:*", "skill:*"]
+created = 2026-03-01T12:00:00Z
+
+[[keys]]
+id = %q
+name = "mcp"
+public_key = %q
+sources = ["mcp"]
+created = 2026-03-01T12:00:00Z
+status = "active"
+`, daemon.ID, daemonPub, mcp.ID, mcpPub)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks, daemon, mcp
+}
+
+func TestKeySet_Verify(t *testing.T) {
+	ks, daemon, mcp := testKeySet(t)
+
+	sign := func(key *SigningKey, source string) Command {
+		cmd := NewCommand("close_issue", map[string]any{"number": float64(42)}, source)
+		if err := SignCommandEd25519(&cmd, key, 0); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+
+	cmd := sign(daemon, "workflow:triage")
+	if cmd.SigVersion != SignatureV3 || cmd.KeyID != daemon.ID || cmd.Nonce == "" {
+		t.Fatalf("envelope = v%d key %q nonce %q", cmd.SigVersion, cmd.KeyID, cmd.Nonce)
+	}
+	if err := ks.Verify(&cmd); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if VerifyCommand(&cmd, "any-secret") {
+		t.Error("VerifyCommand accepted an Ed25519 signature as an HMAC")
+	}
+
+	tampered := cmd
+	tampered.Payload = map[string]any{"number": float64(43)}
+	if err := ks.Verify(&tampered); err == nil || errors.Is(err, ErrUntrustedKey) {
+		t.Errorf("tampered payload: err = %v, want a bad signature", err)
+	}
+
+	swapped := cmd
+	swapped.KeyID = mcp.ID
+	if err := ks.Verify(&swapped); err == nil {
+		t.Error("swapped key id: expected an error")
+	}
+
+	// The MCP key may not sign for workflows.
+	forged := sign(mcp, "workflow:triage")
+	if err := ks.Verify(&forged); !errors.Is(err, ErrUntrustedKey) || !strings.Contains(err.Error(), "may not sign commands from workflow:triage") {
+		t.Errorf("wrong source: err = %v", err)
+	}
+
+	hmac := NewCommand("close_issue", nil, "workflow:triage")
+	if err := SignCommandV2(&hmac, "s", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Verify(&hmac); !errors.Is(err, ErrUntrustedKey) {
+		t.Errorf("HMAC command: err = %v, want ErrUntrustedKey", err)
+	}
+
+	ks.Key(daemon.ID).Status = KeyRetired
+	if err := ks.Verify(&cmd); err != nil {
+		t.Errorf("retired key: %v", err)
+	}
+	ks.Key(daemon.ID).Status = KeyRevoked
+	if err := ks.Verify(&cmd); !errors.Is(err, ErrUntrustedKey) {
+		t.Errorf("revoked key: err = %v, want ErrUntrustedKey", err)
+	}
+
+	var none *KeySet
+	if err := none.Verify(&cmd); !errors.Is(err, ErrUntrustedKey) {
+		t.Errorf("nil set: err = %v, want ErrUntrustedKey", err)
+	}
+}
+
+func TestKeySet_MarshalRoundTrip(t *testing.T) {
+	ks, daemon, _ := testKeySet(t)
+	data, err := ks.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseKeySet(data)
+	if err != nil {
+		t.Fatalf("ParseKeySet(Marshal()): %v\n%s", err, data)
+	}
+	k := got.Key(daemon.ID)
+	if k == nil || k.Status != KeyActive || len(k.Sources) != 2 || k.Created.IsZero() {
+		t.Errorf("round trip: %+v", k)
+	}
+}
+
+func TestParseKeySet_Errors(t *testing.T) {
+	pub := base64.StdEncoding.EncodeToString(make([]byte, ed25519.PublicKeySize))
+	tests := map[string]string{
+		`[[keys]]
+public_key = "` + pub + `"`: "id is required",
+		`[[keys]]
+id = "a"
+public_key = "c2hvcnQ="`: "public_key must be a base64 Ed25519 public key",
+		`[[keys]]
+id = "a"
+public_key = "` + pub + `"
+status = "lost"`: `status must be "active", "retired" or "revoked"`,
+		`[[keys]]
+id = "a"
+public_key = "` + pub + `"
+[[keys]]
+id = "a"
+public_key = "` + pub + `"`: "duplicate key a",
+		`[[keys]]
+id = "a"
+key = "x"`: "key",
+	}
+	for src, want := range tests {
+		if _, err := ParseKeySet([]byte(src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseKeySet(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestSignCommandFor_Ed25519(t *testing.T) {
+	ks, daemon, _ := testKeySet(t)
+	agents := []AgentInfo{{Name: "keyed-agent", SignatureVersion: SignatureV3}, {Name: "v2-agent", SignatureVersion: SignatureV2}}
+
+	cmd := NewCommand("noop", map[string]any{}, "workflow:x")
+	if err := SignCommandFor(&cmd, daemon, "s", NegotiateSignatureVersion(agents, "keyed-agent"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Verify(&cmd); err != nil {
+		t.Errorf("keyed agent: %v", err)
+	}
+
+	cmd = NewCommand("noop", map[string]any{}, "workflow:x")
+	if err := SignCommandFor(&cmd, daemon, "s", NegotiateSignatureVersion(agents, "v2-agent"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.SigVersion != SignatureV2 || cmd.KeyID != "" || !VerifyCommand(&cmd, "s") {
+		t.Errorf("v2 agent: sig_version = %d, key_id = %q", cmd.SigVersion, cmd.KeyID)
+	}
+
+	// Without a key, agents that trust keys still get HMAC signatures.
+	cmd = NewCommand("noop", map[string]any{}, "workflow:x")
+	if err := SignCommandFor(&cmd, nil, "s", SignatureV3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.SigVersion != SignatureV2 || !VerifyCommand(&cmd, "s") {
+		t.Errorf("no key: sig_version = %d", cmd.SigVersion)
+	}
+}
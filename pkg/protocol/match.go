@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"path"
+	"strings"
+)
+
+// LookupPath returns the value at a dot-separated path such as
+// "payload.repo" in a decoded JSON document. A JSON null counts as
+// missing.
+func LookupPath(doc map[string]any, p string) (any, bool) {
+	var v any = doc
+	for _, field := range strings.Split(p, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[field]; !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// MatchGlob reports whether s matches pattern, in path.Match syntax: '*'
+// stands for any run of characters other than '/', '?' for one, and
+// [...] for a class. A malformed pattern matches nothing; see CheckGlob.
+func MatchGlob(pattern, s string) bool {
+	m, _ := path.Match(pattern, s)
+	return m
+}
+
+// CheckGlob reports whether pattern is a well-formed MatchGlob pattern.
+func CheckGlob(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
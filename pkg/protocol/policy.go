@@ -0,0 +1,261 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Command policy defaults, for sources no [sources] entry matches.
+const (
+	PolicyDeny  = "deny"
+	PolicyAllow = "allow"
+)
+
+// ErrCommandDenied is wrapped by the errors CommandPolicy.Authorize returns.
+var ErrCommandDenied = errors.New("command denied by policy")
+
+// CommandPolicy restricts which commands each command source may send. A
+// source is the Source of a Command: workflow:<name>, skill:<name> or mcp.
+// It is loaded from a TOML file (security.policy_file) by the daemon, which
+// checks commands before sending them, and by the agents, which check them
+// again after verifying their signature:
+//
+//	default = "deny"
+//
+//	[[sources."workflow:slack-autoreply".allow]]
+//	agent = "slack-agent"
+//	commands = ["send_reply", "add_reaction"]
+//
+//	[[sources."workflow:github-*".allow]]
+//	agent = "github-agent"
+//	commands = ["add_label", "remove_label"]
+//	payload = { owner = "acme", repo = ["api", "web-*"] }
+//
+// Source, agent and command names may be globs (see MatchGlob). A source may
+// send what any entry matching it allows. A rule's payload constraints map
+// a field (a dot-separated path for nested fields) to the values it may
+// take; the field must be present, and every element of a list must match.
+//
+// A nil *CommandPolicy allows every command.
+type CommandPolicy struct {
+	Default string                  `toml:"default"` // PolicyDeny (the default) or PolicyAllow
+	Sources map[string]SourcePolicy `toml:"sources"`
+}
+
+// SourcePolicy lists the commands a source may send.
+type SourcePolicy struct {
+	Allow []CommandRule `toml:"allow"`
+}
+
+// CommandRule allows commands to one agent, optionally only with certain
+// payload values.
+type CommandRule struct {
+	Agent    string         `toml:"agent"`
+	Commands []string       `toml:"commands"`
+	Payload  map[string]any `toml:"payload,omitempty"` // field -> pattern or list of patterns
+
+	payload map[string][]string // Payload with every value as a list of patterns
+}
+
+// LoadCommandPolicy reads a policy file. An empty path returns a nil policy,
+// which allows everything.
+func LoadCommandPolicy(path string) (*CommandPolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from the operator's config
+	if err != nil {
+		return nil, fmt.Errorf("read command policy: %w", err)
+	}
+	p, err := ParseCommandPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("command policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ParseCommandPolicy parses and checks a TOML command policy.
+func ParseCommandPolicy(data []byte) (*CommandPolicy, error) {
+	var p CommandPolicy
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		var strict *toml.StrictMissingError
+		if errors.As(err, &strict) {
+			return nil, errors.New(strings.TrimSpace(strict.String()))
+		}
+		return nil, err
+	}
+
+	switch p.Default {
+	case "":
+		p.Default = PolicyDeny
+	case PolicyDeny, PolicyAllow:
+	default:
+		return nil, fmt.Errorf("default must be %q or %q, got %q", PolicyDeny, PolicyAllow, p.Default)
+	}
+	for source, sp := range p.Sources {
+		if err := CheckGlob(source); err != nil {
+			return nil, fmt.Errorf("sources.%q: invalid pattern", source)
+		}
+		for i := range sp.Allow {
+			r := &sp.Allow[i]
+			where := fmt.Sprintf("sources.%q.allow[%d]", source, i)
+			if r.Agent == "" {
+				return nil, fmt.Errorf("%s: agent is required", where)
+			}
+			if len(r.Commands) == 0 {
+				return nil, fmt.Errorf(`%s: commands is required (use ["*"] for all)`, where)
+			}
+			for _, g := range append([]string{r.Agent}, r.Commands...) {
+				if err := CheckGlob(g); err != nil {
+					return nil, fmt.Errorf("%s: invalid pattern %q", where, g)
+				}
+			}
+			r.payload = make(map[string][]string, len(r.Payload))
+			for field, v := range r.Payload {
+				patterns, err := policyPatterns(v)
+				if err != nil {
+					return nil, fmt.Errorf("%s: payload.%s: %w", where, field, err)
+				}
+				r.payload[field] = patterns
+			}
+		}
+	}
+	return &p, nil
+}
+
+// policyPatterns converts a payload constraint to a list of patterns.
+func policyPatterns(v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		list = []any{v}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no values allowed")
+	}
+	patterns := make([]string, len(list))
+	for i, e := range list {
+		s, ok := policyScalar(e)
+		if !ok {
+			return nil, fmt.Errorf("values must be strings, numbers or booleans, got %T", e)
+		}
+		if err := CheckGlob(s); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", s)
+		}
+		patterns[i] = s
+	}
+	return patterns, nil
+}
+
+// policyScalar formats a string, number or boolean for matching.
+func policyScalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool, int64, float64, int:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// Authorize reports whether source may send command to agent with payload.
+// It returns nil if so, and an error wrapping ErrCommandDenied saying why
+// not otherwise.
+func (p *CommandPolicy) Authorize(source, agent, command string, payload map[string]any) error {
+	if p == nil {
+		return nil
+	}
+
+	var rules []*CommandRule
+	matched := false
+	for _, pattern := range p.sourcePatterns() {
+		if !MatchGlob(pattern, source) {
+			continue
+		}
+		matched = true
+		allow := p.Sources[pattern].Allow
+		for i := range allow {
+			rules = append(rules, &allow[i])
+		}
+	}
+	if !matched {
+		if p.Default == PolicyAllow {
+			return nil
+		}
+		return fmt.Errorf("%w: no policy for source %s", ErrCommandDenied, source)
+	}
+
+	var payloadErr error
+	for _, r := range rules {
+		if !MatchGlob(r.Agent, agent) || !anyMatch(r.Commands, command) {
+			continue
+		}
+		err := r.checkPayload(payload)
+		if err == nil {
+			return nil
+		}
+		if payloadErr == nil {
+			payloadErr = err
+		}
+	}
+	if payloadErr != nil {
+		return fmt.Errorf("%w: %s may not send %s to %s: %v", ErrCommandDenied, source, command, agent, payloadErr)
+	}
+	return fmt.Errorf("%w: %s may not send %s to %s", ErrCommandDenied, source, command, agent)
+}
+
+// sourcePatterns returns the source names in a stable order, so that the
+// first failing payload constraint reported does not vary.
+func (p *CommandPolicy) sourcePatterns() []string {
+	patterns := make([]string, 0, len(p.Sources))
+	for pattern := range p.Sources {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// checkPayload checks payload against the rule's payload constraints.
+func (r *CommandRule) checkPayload(payload map[string]any) error {
+	fields := make([]string, 0, len(r.payload))
+	for field := range r.payload {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		patterns := r.payload[field]
+		v, ok := LookupPath(payload, field)
+		if !ok {
+			return fmt.Errorf("payload field %s is missing", field)
+		}
+		values, isList := v.([]any)
+		if !isList {
+			values = []any{v}
+		}
+		for _, e := range values {
+			s, ok := policyScalar(e)
+			if !ok || !anyMatch(patterns, s) {
+				return fmt.Errorf("payload field %s = %v is not allowed", field, e)
+			}
+		}
+	}
+	return nil
+}
+
+// anyMatch reports whether s matches one of patterns.
+func anyMatch(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, s) {
+			return true
+		}
+	}
+	return false
+}
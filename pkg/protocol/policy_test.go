@@ -0,0 +1,186 @@
+package protocol
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+[[sources."workflow:slack-autoreply".allow]]
+agent = "slack-agent"
+commands = ["send_reply", "add_reaction"]
+
+[[sources."workflow:github-*".allow]]
+agent = "github-agent"
+commands = ["add_label", "remove_label"]
+payload = { owner = "acme", repo = ["api", "web-*"], labels = ["bug", "needs-*"], "issue.state" = "open" }
+
+[[sources."workflow:github-triage".allow]]
+agent = "github-agent"
+commands = ["create_comment"]
+
+[sources.mcp]
+allow = [{ agent = "*", commands = ["*"] }]
+`
+
+func TestCommandPolicy_Authorize(t *testing.T) {
+	p, err := ParseCommandPolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source, agent, command string
+		payload                map[string]any
+		denied                 string // substring of the error; "" = allowed
+	}{
+		{"workflow:slack-autoreply", "slack-agent", "send_reply", nil, ""},
+		{"workflow:slack-autoreply", "google-agent", "delete", nil, "workflow:slack-autoreply may not send delete to google-agent"},
+		{"workflow:slack-autoreply", "slack-agent", "send_message", nil, "may not send send_message to slack-agent"},
+		{"workflow:github-triage", "github-agent", "add_label", labelPayload("acme", "web-app", "bug", "needs-triage"), ""},
+		{"workflow:github-triage", "github-agent", "create_comment", nil, ""},
+		{"workflow:github-labeler", "github-agent", "create_comment", nil, "may not send create_comment"},
+		{"workflow:github-triage", "github-agent", "add_label", labelPayload("evil", "api", "bug"), "payload field owner = evil is not allowed"},
+		{"workflow:github-triage", "github-agent", "add_label", labelPayload("acme", "api", "bug", "wontfix"), "payload field labels = wontfix is not allowed"},
+		{"workflow:github-triage", "github-agent", "add_label", map[string]any{"owner": "acme", "labels": []any{"bug"}}, "payload field issue.state is missing"},
+		{"workflow:github-triage", "github-agent", "add_label", map[string]any{"owner": "acme", "repo": "api", "labels": []any{"bug"}, "issue": map[string]any{"state": "closed"}}, "payload field issue.state = closed is not allowed"},
+		{"mcp", "google-agent", "delete", nil, ""},
+		{"skill:triage", "slack-agent", "send_reply", nil, "no policy for source skill:triage"},
+	}
+	for _, tt := range tests {
+		err := p.Authorize(tt.source, tt.agent, tt.command, tt.payload)
+		if tt.denied == "" {
+			if err != nil {
+				t.Errorf("%s -> %s %s: unexpected denial: %v", tt.source, tt.agent, tt.command, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrCommandDenied) || !strings.Contains(err.Error(), tt.denied) {
+			t.Errorf("%s -> %s %s: err = %v, want denial containing %q", tt.source, tt.agent, tt.command, err, tt.denied)
+		}
+	}
+
+	allow, err := ParseCommandPolicy([]byte(`default = "allow"` + testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := allow.Authorize("skill:triage", "slack-agent", "send_reply", nil); err != nil {
+		t.Errorf("default allow: %v", err)
+	}
+	if err := allow.Authorize("workflow:slack-autoreply", "google-agent", "delete", nil); err == nil {
+		t.Error("default allow: listed source should still be restricted")
+	}
+
+	var none *CommandPolicy
+	if err := none.Authorize("workflow:x", "google-agent", "delete", nil); err != nil {
+		t.Errorf("nil policy: %v", err)
+	}
+}
+
+func labelPayload(owner, repo string, labels ...any) map[string]any {
+	return map[string]any{
+		"owner":  owner,
+		"repo":   repo,
+		"labels": labels,
+		"issue":  map[string]any{"state": "open"},
+	}
+}
+
+func TestParseCommandPolicy_Errors(t *testing.T) {
+	tests := map[string]string{
+		`default = "maybe"`: `default must be "deny" or "allow"`,
+		`[[sources.mcp.allow]]
+commands = ["*"]`: "agent is required",
+		`[[sources.mcp.allow]]
+agent = "slack-agent"`: "commands is required",
+		`[[sources.mcp.allow]]
+agent = "slack-agent"
+commands = ["*"]
+payload = { channel = { id = "C1" } }`: "payload.channel: values must be strings",
+		`[[sources.mcp.allow]]
+agent = "slack-agent"
+command = ["*"]`: "command",
+		`[[sources.mcp.allow]]
+agent = "slack-agent"
+commands = ["send_[message"]`: "invalid pattern",
+		`[[sources.mcp.allow]]
+agent = "slack-agent"
+commands = ["*"]
+payload = { channel = "[" }`: "payload.channel: invalid pattern",
+	}
+	for src, want := range tests {
+		if _, err := ParseCommandPolicy([]byte(src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCommandPolicy(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestLoadCommandPolicy(t *testing.T) {
+	if p, err := LoadCommandPolicy(""); p != nil || err != nil {
+		t.Errorf("empty path = %v, %v; want nil, nil", p, err)
+	}
+	path := filepath.Join(t.TempDir(), "policy.toml")
+	if _, err := LoadCommandPolicy(path); err == nil {
+		t.Error("missing file: expected error")
+	}
+	if err := os.WriteFile(path, []byte(testPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadCommandPolicy(path)
+	if err != nil || p.Default != PolicyDeny || len(p.Sources) != 4 {
+		t.Errorf("LoadCommandPolicy = %+v, %v", p, err)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"slack-agent", "slack-agent", true},
+		{"slack-agent", "slack-agent2", false},
+		{"*", "", true},
+		{"workflow:*", "workflow:a.b", true},
+		{"workflow:*", "skill:a", false},
+		{"*@acme.com", "bob@acme.com", true},
+		{"*@acme.com", "bob@acme.com.evil.io", false},
+		{"a*b*c", "abc", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "acb", false},
+		{"ab*ba", "aba", false},
+		{"acme/*", "acme/app", true},
+		{"*", "acme/app", false},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	doc := map[string]any{
+		"payload": map[string]any{"repo": "app", "draft": false, "milestone": nil},
+	}
+	tests := []struct {
+		path  string
+		want  any
+		found bool
+	}{
+		{"payload.repo", "app", true},
+		{"payload.draft", false, true},
+		{"payload.milestone", nil, false},
+		{"payload.missing", nil, false},
+		{"payload.repo.name", nil, false},
+	}
+	for _, tt := range tests {
+		got, found := LookupPath(doc, tt.path)
+		if got != tt.want || found != tt.found {
+			t.Errorf("LookupPath(%q) = %v, %v; want %v, %v", tt.path, got, found, tt.want, tt.found)
+		}
+	}
+}
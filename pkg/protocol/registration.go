@@ -7,4 +7,11 @@ type Registration struct {
 	Capabilities []string       `json:"capabilities"`
 	Commands     []string       `json:"commands"`
 	ConfigSchema map[string]any `json:"config_schema,omitempty"`
+
+	// CommandSchemas describes the payload of each command, for workflow linting.
+	CommandSchemas map[string]*Schema `json:"command_schemas,omitempty"`
+
+	// SignatureVersion is the highest command signature version the agent
+	// verifies (SignatureV2); absent for agents that only know version 1.
+	SignatureVersion int `json:"signature_version,omitempty"`
 }
@@ -0,0 +1,49 @@
+package protocol
+
+// Command result statuses.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Event types published on sekia.results.<agent>.
+const (
+	EventCommandSucceeded = "command.succeeded"
+	EventCommandFailed    = "command.failed"
+)
+
+// CommandResult reports the outcome of a command executed by an agent. It is
+// also the reply to a request on sekia.commands.<agent>.sync.
+type CommandResult struct {
+	ID          string         `json:"id"`
+	Agent       string         `json:"agent"`
+	Command     string         `json:"command"`
+	RequestedBy string         `json:"requested_by,omitempty"`
+	Status      string         `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	Result      map[string]any `json:"result,omitempty"`
+	Attempts    int            `json:"attempts"`
+}
+
+// ResultEvent wraps a CommandResult as the Event published on sekia.results.<agent>,
+// so workflows can handle it like any other event.
+func ResultEvent(res CommandResult) Event {
+	eventType := EventCommandSucceeded
+	if res.Status != ResultOK {
+		eventType = EventCommandFailed
+	}
+	payload := map[string]any{
+		"command_id":   res.ID,
+		"command":      res.Command,
+		"requested_by": res.RequestedBy,
+		"status":       res.Status,
+		"attempts":     res.Attempts,
+	}
+	if res.Error != "" {
+		payload["error"] = res.Error
+	}
+	if res.Result != nil {
+		payload["result"] = res.Result
+	}
+	return NewEvent(eventType, res.Agent, payload)
+}
@@ -0,0 +1,44 @@
+package protocol
+
+import "testing"
+
+func TestResultEvent(t *testing.T) {
+	ok := ResultEvent(CommandResult{
+		ID:          "cmd_1",
+		Agent:       "linear-agent",
+		Command:     "create_issue",
+		RequestedBy: "workflow:triage",
+		Status:      ResultOK,
+		Result:      map[string]any{"issue_id": "LIN-1"},
+		Attempts:    1,
+	})
+	if ok.Type != EventCommandSucceeded {
+		t.Errorf("type = %q, want %q", ok.Type, EventCommandSucceeded)
+	}
+	if ok.Source != "linear-agent" {
+		t.Errorf("source = %q, want linear-agent", ok.Source)
+	}
+	if ok.Payload["command_id"] != "cmd_1" {
+		t.Errorf("command_id = %v, want cmd_1", ok.Payload["command_id"])
+	}
+	if _, has := ok.Payload["error"]; has {
+		t.Error("successful result should not carry an error")
+	}
+
+	failed := ResultEvent(CommandResult{
+		ID:      "cmd_2",
+		Agent:   "linear-agent",
+		Command: "create_issue",
+		Status:  ResultError,
+		Error:   "boom",
+	})
+	if failed.Type != EventCommandFailed {
+		t.Errorf("type = %q, want %q", failed.Type, EventCommandFailed)
+	}
+	if failed.Payload["error"] != "boom" {
+		t.Errorf("error = %v, want boom", failed.Payload["error"])
+	}
+	if _, has := failed.Payload["result"]; has {
+		t.Error("failed result without data should not carry a result")
+	}
+}
@@ -0,0 +1,275 @@
+package protocol
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Payload validation modes, selected with events.validation in the daemon's
+// and each agent's config.
+const (
+	// ValidationOff skips schema validation.
+	ValidationOff = "off"
+	// ValidationWarn logs events whose payload does not match their schema
+	// and delivers them anyway.
+	ValidationWarn = "warn"
+	// ValidationReject drops events whose payload does not match their schema.
+	ValidationReject = "reject"
+)
+
+// ValidateValidationMode checks an events.validation setting. Empty means warn.
+func ValidateValidationMode(mode string) error {
+	switch mode {
+	case "", ValidationOff, ValidationWarn, ValidationReject:
+		return nil
+	}
+	return fmt.Errorf("events.validation must be %q, %q or %q, got %q", ValidationOff, ValidationWarn, ValidationReject, mode)
+}
+
+// Event payload schemas, one file per event type and version, named
+// <type>.v<version>.json.
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// Schema is a JSON Schema describing an event payload. Only the keywords
+// used by sekia's schemas are evaluated: type, properties, required, items,
+// enum and additionalProperties (as a boolean).
+type Schema struct {
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+
+	raw []byte
+}
+
+// schemaTypes is the type keyword: a single type name or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = many
+	return nil
+}
+
+// JSON returns the schema document as embedded.
+func (s *Schema) JSON() []byte { return s.raw }
+
+// schemas maps event type -> version -> schema.
+var schemas = loadSchemas()
+
+func loadSchemas() map[string]map[int]*Schema {
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic(fmt.Sprintf("read embedded schemas: %v", err))
+	}
+	reg := make(map[string]map[int]*Schema)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		i := strings.LastIndex(name, ".v")
+		if i < 0 {
+			panic(fmt.Sprintf("schema %s: file name must be <type>.v<version>.json", e.Name()))
+		}
+		version, err := strconv.Atoi(name[i+2:])
+		if err != nil || version < 1 {
+			panic(fmt.Sprintf("schema %s: invalid version in file name", e.Name()))
+		}
+		data, err := schemaFiles.ReadFile(path.Join("schemas", e.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("read schema %s: %v", e.Name(), err))
+		}
+		s := &Schema{raw: data}
+		if err := json.Unmarshal(data, s); err != nil {
+			panic(fmt.Sprintf("parse schema %s: %v", e.Name(), err))
+		}
+		eventType := name[:i]
+		if reg[eventType] == nil {
+			reg[eventType] = make(map[int]*Schema)
+		}
+		reg[eventType][version] = s
+	}
+	return reg
+}
+
+// EventSchema returns the payload schema for an event type. Version 0 means
+// the latest version.
+func EventSchema(eventType string, version int) (*Schema, bool) {
+	if version == 0 {
+		version = LatestSchemaVersion(eventType)
+	}
+	s, ok := schemas[eventType][version]
+	return s, ok
+}
+
+// LatestSchemaVersion returns the newest schema version registered for an
+// event type, or 0 if it has none.
+func LatestSchemaVersion(eventType string) int {
+	latest := 0
+	for v := range schemas[eventType] {
+		latest = max(latest, v)
+	}
+	return latest
+}
+
+// SchemaTypes returns every event type with a registered schema, sorted.
+func SchemaTypes() []string {
+	types := make([]string, 0, len(schemas))
+	for t := range schemas {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SchemaError lists the ways an event payload violates its schema.
+type SchemaError struct {
+	Type     string
+	Version  int
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("event %s does not match schema v%d: %s", e.Type, e.Version, strings.Join(e.Problems, "; "))
+}
+
+// ValidateEvent checks an event's payload against the schema for its type
+// and schema version (the latest if unset). Events whose type or version has
+// no registered schema are not checked. The payload may hold Go values
+// (int, []string, ...); they are compared in their JSON form. A mismatch is
+// returned as a *SchemaError.
+func ValidateEvent(ev Event) error {
+	payload := ev.Payload
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("encode payload: %w", err)
+		}
+		payload = nil
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+	}
+	return ValidatePayload(ev.Type, ev.SchemaVersion, payload)
+}
+
+// ValidatePayload is ValidateEvent for a payload already decoded from JSON.
+func ValidatePayload(eventType string, version int, payload map[string]any) error {
+	s, ok := EventSchema(eventType, version)
+	if !ok {
+		return nil
+	}
+	var problems []string
+	var doc any = payload
+	if payload == nil {
+		doc = map[string]any{}
+	}
+	s.check("payload", doc, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	if version == 0 {
+		version = LatestSchemaVersion(eventType)
+	}
+	return &SchemaError{Type: eventType, Version: version, Problems: problems}
+}
+
+// check appends a problem for every way v, found at path p, violates s.
+func (s *Schema) check(p string, v any, problems *[]string) {
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		*problems = append(*problems, fmt.Sprintf("%s: want %s, got %s", p, strings.Join(s.Type, " or "), jsonType(v)))
+		return
+	}
+	if len(s.Enum) > 0 && !enumContains(s.Enum, v) {
+		*problems = append(*problems, fmt.Sprintf("%s: %v is not one of the allowed values", p, v))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: required", p, name))
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.check(p+"."+name, v[name], problems)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: unknown field", p, name))
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, el := range v {
+				s.Items.check(fmt.Sprintf("%s[%d]", p, i), el, problems)
+			}
+		}
+	}
+}
+
+// matches reports whether v, decoded from JSON, has one of the types.
+func (t schemaTypes) matches(v any) bool {
+	got := jsonType(v)
+	for _, want := range t {
+		if want == got || (want == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON Schema type of a decoded JSON value.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func enumContains(enum []any, v any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	types := SchemaTypes()
+	if len(types) == 0 {
+		t.Fatal("no schemas registered")
+	}
+	for _, typ := range types {
+		s, ok := EventSchema(typ, 0)
+		if !ok {
+			t.Errorf("%s: no latest schema", typ)
+			continue
+		}
+		if s.ID != SchemaID(typ, LatestSchemaVersion(typ)) {
+			t.Errorf("%s: $id = %q", typ, s.ID)
+		}
+		if s.Title != typ {
+			t.Errorf("%s: title = %q", typ, s.Title)
+		}
+		if s.Description == "" {
+			t.Errorf("%s: missing description", typ)
+		}
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; !ok {
+				t.Errorf("%s: required field %q has no property", typ, name)
+			}
+		}
+		if !json.Valid(s.JSON()) {
+			t.Errorf("%s: embedded document is not valid JSON", typ)
+		}
+	}
+
+	if v := LatestSchemaVersion("github.issue.opened"); v != 1 {
+		t.Errorf("LatestSchemaVersion(github.issue.opened) = %d, want 1", v)
+	}
+	if v := LatestSchemaVersion("custom.event"); v != 0 {
+		t.Errorf("LatestSchemaVersion(custom.event) = %d, want 0", v)
+	}
+	if _, ok := EventSchema("github.issue.opened", 9); ok {
+		t.Error("EventSchema returned an unregistered version")
+	}
+}
+
+func TestNewEvent_SchemaVersion(t *testing.T) {
+	if ev := NewEvent("github.push", "github", nil); ev.SchemaVersion != 1 {
+		t.Errorf("github.push schema_version = %d, want 1", ev.SchemaVersion)
+	}
+	ev := NewEvent("digest.ready", "workflow:digest", nil)
+	if ev.SchemaVersion != 0 {
+		t.Errorf("digest.ready schema_version = %d, want 0", ev.SchemaVersion)
+	}
+	data, _ := json.Marshal(ev)
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	if _, ok := m["schema_version"]; ok {
+		t.Error("schema_version should be omitted for types without a schema")
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	valid := map[string]any{
+		"owner":  "acme",
+		"repo":   "app",
+		"number": 42,
+		"title":  "Crash on start",
+		"body":   "",
+		"author": "alice",
+		"url":    "https://github.com/acme/app/issues/42",
+		"labels": []string{"bug"},
+	}
+	if err := ValidateEvent(NewEvent("github.issue.opened", "github", valid)); err != nil {
+		t.Errorf("valid payload: %v", err)
+	}
+
+	bad := map[string]any{
+		"owner":  "acme",
+		"repo":   "app",
+		"number": "42",
+		"title":  "Crash on start",
+		"body":   "",
+		"author": "alice",
+		"labels": []any{"bug", 7},
+	}
+	err := ValidateEvent(NewEvent("github.issue.opened", "github", bad))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("want *SchemaError, got %v", err)
+	}
+	want := []string{
+		"payload.url: required",
+		"payload.labels[1]: want string, got integer",
+		"payload.number: want integer, got string",
+	}
+	if schemaErr.Type != "github.issue.opened" || schemaErr.Version != 1 || !reflect.DeepEqual(schemaErr.Problems, want) {
+		t.Errorf("got %s %d %q, want %q", schemaErr.Type, schemaErr.Version, schemaErr.Problems, want)
+	}
+
+	// Types and versions without a schema are not checked.
+	if err := ValidateEvent(NewEvent("digest.ready", "workflow:digest", map[string]any{"x": 1})); err != nil {
+		t.Errorf("unregistered type: %v", err)
+	}
+	future := NewEvent("github.issue.opened", "github", bad)
+	future.SchemaVersion = 9
+	if err := ValidateEvent(future); err != nil {
+		t.Errorf("unregistered version: %v", err)
+	}
+}
+
+func TestValidatePayload(t *testing.T) {
+	gmail := map[string]any{
+		"id": "m1", "thread_id": "t1", "message_id": "<m1@mail>", "from": "a@example.com",
+		"to": "b@example.com", "subject": "hi", "body": "", "snippet": "", "date": "", "labels": nil,
+	}
+	if err := ValidatePayload("gmail.message.received", 1, gmail); err != nil {
+		t.Errorf("null labels: %v", err)
+	}
+	if err := ValidatePayload("linear.issue.created", 0, map[string]any{
+		"id": "1", "identifier": "ENG-1", "title": "t", "state": "Todo", "priority": 2.5,
+		"team": "ENG", "url": "u", "labels": []any{},
+	}); err != nil {
+		t.Errorf("number field: %v", err)
+	}
+	if err := ValidatePayload("sentinel.action.required", 0, nil); err == nil {
+		t.Error("missing payload should fail required fields")
+	}
+}
+
+func TestValidateValidationMode(t *testing.T) {
+	for _, m := range []string{"", ValidationOff, ValidationWarn, ValidationReject} {
+		if err := ValidateValidationMode(m); err != nil {
+			t.Errorf("ValidateValidationMode(%q): %v", m, err)
+		}
+	}
+	if err := ValidateValidationMode("strict"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestCommandSchemas(t *testing.T) {
+	for _, kind := range []string{"github", "slack", "linear", "google"} {
+		schemas := CommandSchemas(kind)
+		if len(schemas) == 0 {
+			t.Errorf("%s: no command schemas", kind)
+		}
+		for name, s := range schemas {
+			if s.Title != name || s.ID != "urn:sekia:command:"+kind+"."+name {
+				t.Errorf("%s.%s: title = %q, $id = %q", kind, name, s.Title, s.ID)
+			}
+			for _, field := range s.Required {
+				if _, ok := s.Properties[field]; !ok {
+					t.Errorf("%s.%s: required field %q has no property", kind, name, field)
+				}
+			}
+		}
+	}
+	if s := CommandSchemas("github")["add_label"]; s == nil || !reflect.DeepEqual(s.Required, []string{"owner", "repo", "number", "label"}) {
+		t.Errorf("github add_label schema = %+v", s)
+	}
+	if CommandSchemas("custom") != nil {
+		t.Error("CommandSchemas returned schemas for an unknown agent kind")
+	}
+}
@@ -2,40 +2,198 @@ package protocol
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
-// signingPayload is the subset of Command fields that are signed.
-// A dedicated struct ensures deterministic JSON marshal order.
+// Command signature versions. Version 1 signs the command's name, payload
+// and source. Version 2, the signed envelope, also signs when the
+// command was issued, when it expires and a random nonce, so that agents
+// can refuse stale and replayed commands. Versions 1 and 2 are HMACs with
+// the shared command secret. Version 3 signs the version 2 envelope and the
+// signing key's ID with an Ed25519 key instead (see SignCommandEd25519), so
+// agents need only the public keys.
+const (
+	SignatureV1 = 1
+	SignatureV2 = 2
+	SignatureV3 = 3
+)
+
+// DefaultCommandTTL is how long a version 2 command stays valid. It covers
+// time spent in the durable work queue and retries.
+const DefaultCommandTTL = time.Hour
+
+// MaxClockSkew is how far in the future a command's issued_at may be
+// before agents reject it.
+const MaxClockSkew = time.Minute
+
+// Errors returned by CheckCommandFreshness and NonceCache.Check.
+var (
+	ErrStaleCommand    = errors.New("stale command")
+	ErrReplayedCommand = errors.New("replayed command")
+)
+
+// signingPayload is the subset of Command fields that are signed by
+// version 1. A dedicated struct ensures deterministic JSON marshal order.
+// Its bytes must not change: agents that predate signature versions verify
+// exactly these fields, so the command ID is only signed from version 2.
 type signingPayload struct {
 	Command string         `json:"command"`
 	Payload map[string]any `json:"payload"`
 	Source  string         `json:"source"`
 }
 
+// signingPayloadV2 is the signed envelope: the version 1 fields plus the
+// version itself and the replay protection fields. Version 3 adds the key ID.
+type signingPayloadV2 struct {
+	Version   int            `json:"v"`
+	KeyID     string         `json:"key_id,omitempty"`
+	ID        string         `json:"id,omitempty"`
+	Command   string         `json:"command"`
+	Payload   map[string]any `json:"payload"`
+	Source    string         `json:"source"`
+	IssuedAt  time.Time      `json:"issued_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Nonce     string         `json:"nonce"`
+}
+
+// canonical returns the bytes signed for cmd, according to its SigVersion.
+func canonical(cmd *Command) ([]byte, error) {
+	switch cmd.SigVersion {
+	case 0, SignatureV1:
+		return json.Marshal(signingPayload{
+			Command: cmd.Command,
+			Payload: cmd.Payload,
+			Source:  cmd.Source,
+		})
+	case SignatureV2, SignatureV3:
+		p := signingPayloadV2{
+			Version:   cmd.SigVersion,
+			ID:        cmd.ID,
+			Command:   cmd.Command,
+			Payload:   cmd.Payload,
+			Source:    cmd.Source,
+			IssuedAt:  cmd.IssuedAt,
+			ExpiresAt: cmd.ExpiresAt,
+			Nonce:     cmd.Nonce,
+		}
+		if cmd.SigVersion == SignatureV3 {
+			p.KeyID = cmd.KeyID
+		}
+		return json.Marshal(p)
+	}
+	return nil, fmt.Errorf("unsupported signature version %d", cmd.SigVersion)
+}
+
+func sign(canonical []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // SignCommand computes an HMAC-SHA256 signature for the command and sets cmd.Signature.
 // If secret is empty, the command is left unsigned.
 func SignCommand(cmd *Command, secret string) error {
 	if secret == "" {
 		return nil
 	}
-	canonical, err := json.Marshal(signingPayload{
-		Command: cmd.Command,
-		Payload: cmd.Payload,
-		Source:  cmd.Source,
-	})
+	cmd.SigVersion, cmd.IssuedAt, cmd.ExpiresAt, cmd.Nonce, cmd.KeyID = 0, time.Time{}, time.Time{}, "", ""
+	data, err := canonical(cmd)
 	if err != nil {
 		return err
 	}
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write(canonical)
-	cmd.Signature = hex.EncodeToString(mac.Sum(nil))
+	cmd.Signature = sign(data, secret)
+	return nil
+}
+
+// SignCommandV2 signs the command with the version 2 envelope: it is
+// stamped with the current time, an expiry ttl from now (DefaultCommandTTL
+// if ttl <= 0) and a random nonce. Only send it to agents that advertise
+// SignatureV2 (see NegotiateSignatureVersion); version 1 agents reject it.
+// If secret is empty, the command is left unsigned.
+func SignCommandV2(cmd *Command, secret string, ttl time.Duration) error {
+	if secret == "" {
+		return nil
+	}
+	if err := stampEnvelope(cmd, SignatureV2, "", ttl); err != nil {
+		return err
+	}
+	data, err := canonical(cmd)
+	if err != nil {
+		return err
+	}
+	cmd.Signature = sign(data, secret)
+	return nil
+}
+
+// stampEnvelope fills in the envelope of a version 2 or 3 command: the
+// current time, an expiry ttl from now (DefaultCommandTTL if ttl <= 0), a
+// random nonce and the signing key's ID.
+func stampEnvelope(cmd *Command, version int, keyID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = DefaultCommandTTL
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	now := time.Now().UTC()
+	cmd.SigVersion = version
+	cmd.IssuedAt = now
+	cmd.ExpiresAt = now.Add(ttl)
+	cmd.Nonce = hex.EncodeToString(nonce)
+	cmd.KeyID = keyID
+	return nil
+}
+
+// SignCommandFor signs the command with the given signature version: with
+// key for version 3, falling back to secret if key is nil, and with secret
+// for versions 1 and 2.
+func SignCommandFor(cmd *Command, key *SigningKey, secret string, version int, ttl time.Duration) error {
+	switch {
+	case version >= SignatureV3 && key != nil:
+		return SignCommandEd25519(cmd, key, ttl)
+	case version >= SignatureV2:
+		return SignCommandV2(cmd, secret, ttl)
+	}
+	return SignCommand(cmd, secret)
+}
+
+// ValidateMinSignatureVersion checks a security.min_signature_version
+// setting. Zero means SignatureV1.
+func ValidateMinSignatureVersion(v int) error {
+	if v < 0 || v > SignatureV3 {
+		return fmt.Errorf("security.min_signature_version must be %d, %d or %d, got %d", SignatureV1, SignatureV2, SignatureV3, v)
+	}
 	return nil
 }
 
-// VerifyCommand checks the HMAC-SHA256 signature on a command.
+// NegotiateSignatureVersion returns the signature version to use for
+// commands to the named agent: the newest version the agent advertised
+// when registering, or SignatureV1 for agents that advertised none
+// (including unknown agents). Version 1 signatures are byte-for-byte those
+// of agents that predate signature versions, so such agents keep verifying
+// commands from an upgraded sekiad; upgraded agents accept every version
+// older senders produce. Upgrade agents first, then sekiad.
+func NegotiateSignatureVersion(agents []AgentInfo, agent string) int {
+	for _, a := range agents {
+		if a.Name == agent {
+			return min(max(a.SignatureVersion, SignatureV1), SignatureV3)
+		}
+	}
+	return SignatureV1
+}
+
+// VerifyCommand checks the HMAC-SHA256 signature on a command, of the
+// version given by its SigVersion (1 or 2; see KeySet.Verify for version
+// 3). It does not check that a version 2
+// command is fresh; see CheckCommandFreshness and NonceCache.
 // If secret is empty, verification is skipped (returns true).
 // If the command has no signature but a secret is configured, returns false.
 func VerifyCommand(cmd *Command, secret string) bool {
@@ -45,16 +203,91 @@ func VerifyCommand(cmd *Command, secret string) bool {
 	if cmd.Signature == "" {
 		return false
 	}
-	canonical, err := json.Marshal(signingPayload{
-		Command: cmd.Command,
-		Payload: cmd.Payload,
-		Source:  cmd.Source,
-	})
+	data, err := canonical(cmd)
 	if err != nil {
 		return false
 	}
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write(canonical)
-	expected := hex.EncodeToString(mac.Sum(nil))
-	return hmac.Equal([]byte(expected), []byte(cmd.Signature))
+	return hmac.Equal([]byte(sign(data, secret)), []byte(cmd.Signature))
+}
+
+// CheckCommandFreshness checks a version 2 command's envelope: it must
+// carry a nonce, must not have expired and must not have been issued more
+// than MaxClockSkew in the future. Version 1 commands have no envelope and
+// are always fresh.
+func CheckCommandFreshness(cmd *Command, now time.Time) error {
+	if cmd.SigVersion < SignatureV2 {
+		return nil
+	}
+	switch {
+	case cmd.Nonce == "" || cmd.IssuedAt.IsZero() || cmd.ExpiresAt.IsZero():
+		return fmt.Errorf("%w: incomplete signed envelope", ErrStaleCommand)
+	case now.After(cmd.ExpiresAt):
+		return fmt.Errorf("%w: expired at %s", ErrStaleCommand, cmd.ExpiresAt.Format(time.RFC3339))
+	case cmd.IssuedAt.After(now.Add(MaxClockSkew)):
+		return fmt.Errorf("%w: issued in the future (%s)", ErrStaleCommand, cmd.IssuedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// NonceCache remembers the nonces of version 2 commands until they expire,
+// so that each is accepted only once. It holds at most size nonces; when
+// full, the oldest is evicted and commands issued no later than it are
+// rejected from then on, since their nonces can no longer be checked.
+type NonceCache struct {
+	mu    sync.Mutex
+	size  int
+	seen  map[string]struct{}
+	order []nonceEntry // in insertion order
+	floor time.Time    // latest IssuedAt evicted before it expired
+}
+
+type nonceEntry struct {
+	nonce     string
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+// DefaultNonceCacheSize bounds the nonces an agent remembers.
+const DefaultNonceCacheSize = 100_000
+
+// NewNonceCache returns a cache holding up to size nonces
+// (DefaultNonceCacheSize if size <= 0).
+func NewNonceCache(size int) *NonceCache {
+	if size <= 0 {
+		size = DefaultNonceCacheSize
+	}
+	return &NonceCache{size: size, seen: make(map[string]struct{})}
+}
+
+// Check records a version 2 command's nonce, returning ErrReplayedCommand
+// if it has been seen before and ErrStaleCommand if it is too old to tell.
+// Version 1 commands are ignored.
+func (c *NonceCache) Check(cmd *Command, now time.Time) error {
+	if cmd.SigVersion < SignatureV2 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.order) > 0 && now.After(c.order[0].expiresAt) {
+		delete(c.seen, c.order[0].nonce)
+		c.order = c.order[1:]
+	}
+	if !cmd.IssuedAt.After(c.floor) {
+		return fmt.Errorf("%w: issued before the oldest remembered nonce", ErrStaleCommand)
+	}
+	if _, dup := c.seen[cmd.Nonce]; dup {
+		return fmt.Errorf("%w: nonce %s already used", ErrReplayedCommand, cmd.Nonce)
+	}
+	if len(c.order) >= c.size {
+		oldest := c.order[0]
+		delete(c.seen, oldest.nonce)
+		c.order = c.order[1:]
+		if oldest.issuedAt.After(c.floor) {
+			c.floor = oldest.issuedAt
+		}
+	}
+	c.seen[cmd.Nonce] = struct{}{}
+	c.order = append(c.order, nonceEntry{nonce: cmd.Nonce, issuedAt: cmd.IssuedAt, expiresAt: cmd.ExpiresAt})
+	return nil
 }
@@ -1,7 +1,11 @@
 package protocol
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSignAndVerify(t *testing.T) {
@@ -80,6 +84,41 @@ func TestVerifyTamperedSource(t *testing.T) {
 	}
 }
 
+func TestVerifyTamperedID(t *testing.T) {
+	cmd := NewCommand("add_label", map[string]any{"label": "bug"}, "workflow:legit")
+	secret := "my-secret"
+
+	if err := SignCommandV2(&cmd, secret, 0); err != nil {
+		t.Fatalf("SignCommandV2: %v", err)
+	}
+
+	cmd.ID = "cmd_replayed"
+
+	if VerifyCommand(&cmd, secret) {
+		t.Fatal("VerifyCommand returned true for tampered ID")
+	}
+}
+
+// TestSignCommand_V1WireFormat pins the version 1 signature to the bytes
+// agents that predate signature versions verify: {command, payload, source},
+// without the command ID.
+func TestSignCommand_V1WireFormat(t *testing.T) {
+	cmd := NewCommand("add_label", map[string]any{"label": "bug", "number": 42}, "workflow:test")
+	if err := SignCommand(&cmd, "my-secret"); err != nil {
+		t.Fatalf("SignCommand: %v", err)
+	}
+
+	// HMAC-SHA256("my-secret",
+	//   `{"command":"add_label","payload":{"label":"bug","number":42},"source":"workflow:test"}`)
+	const want = "f370c9f95a0bfa6ffc8b14bc3f91c9d771f8678579f89f8a4ffdefd18d55eb90"
+	if cmd.Signature != want {
+		t.Errorf("signature = %s, want %s", cmd.Signature, want)
+	}
+	if data, _ := json.Marshal(cmd); strings.Contains(string(data), `"sig_version"`) {
+		t.Errorf("v1 command carries a signature version: %s", data)
+	}
+}
+
 func TestVerifyWrongSecret(t *testing.T) {
 	cmd := &Command{
 		Command: "send_message",
@@ -160,3 +199,112 @@ func TestDeterministicSignature(t *testing.T) {
 		t.Fatalf("signatures differ: %s vs %s", cmd1.Signature, cmd2.Signature)
 	}
 }
+
+func TestSignV2_RoundTrip(t *testing.T) {
+	secret := "v2-secret"
+	cmd := NewCommand("close_issue", map[string]any{"number": float64(7)}, "workflow:closer")
+	if err := SignCommandV2(&cmd, secret, time.Minute); err != nil {
+		t.Fatalf("SignCommandV2: %v", err)
+	}
+	if cmd.SigVersion != SignatureV2 || cmd.Nonce == "" || cmd.ExpiresAt.Sub(cmd.IssuedAt) != time.Minute {
+		t.Fatalf("envelope = %+v", cmd)
+	}
+
+	// Agents verify the command as decoded from the wire.
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Command
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyCommand(&got, secret) {
+		t.Fatal("VerifyCommand returned false for a valid v2 signature")
+	}
+
+	tamper := map[string]func(c *Command){
+		"nonce":      func(c *Command) { c.Nonce = "0000" },
+		"expires_at": func(c *Command) { c.ExpiresAt = c.ExpiresAt.Add(24 * time.Hour) },
+		"issued_at":  func(c *Command) { c.IssuedAt = c.IssuedAt.Add(time.Hour) },
+		"downgrade":  func(c *Command) { c.SigVersion, c.Nonce, c.IssuedAt, c.ExpiresAt = 0, "", time.Time{}, time.Time{} },
+	}
+	for name, f := range tamper {
+		c := got
+		f(&c)
+		if VerifyCommand(&c, secret) {
+			t.Errorf("%s: VerifyCommand returned true for a tampered envelope", name)
+		}
+	}
+}
+
+func TestSignCommandFor(t *testing.T) {
+	agents := []AgentInfo{{Name: "new-agent", SignatureVersion: SignatureV2}, {Name: "old-agent"}}
+	for agent, want := range map[string]int{"new-agent": SignatureV2, "old-agent": 0, "unknown-agent": 0} {
+		cmd := NewCommand("noop", map[string]any{}, "mcp")
+		if err := SignCommandFor(&cmd, nil, "s", NegotiateSignatureVersion(agents, agent), 0); err != nil {
+			t.Fatal(err)
+		}
+		if cmd.SigVersion != want || !VerifyCommand(&cmd, "s") {
+			t.Errorf("%s: sig_version = %d, want %d", agent, cmd.SigVersion, want)
+		}
+	}
+}
+
+func TestCheckCommandFreshness(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		cmd  Command
+		want error
+	}{
+		{"v1", Command{}, nil},
+		{"fresh", Command{SigVersion: 2, Nonce: "n", IssuedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Minute)}, nil},
+		{"expired", Command{SigVersion: 2, Nonce: "n", IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Second)}, ErrStaleCommand},
+		{"future", Command{SigVersion: 2, Nonce: "n", IssuedAt: now.Add(time.Hour), ExpiresAt: now.Add(2 * time.Hour)}, ErrStaleCommand},
+		{"no nonce", Command{SigVersion: 2, IssuedAt: now, ExpiresAt: now.Add(time.Minute)}, ErrStaleCommand},
+	}
+	for _, tt := range tests {
+		if err := CheckCommandFreshness(&tt.cmd, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	now := time.Now()
+	cmd := func(nonce string, issued time.Duration) *Command {
+		return &Command{SigVersion: SignatureV2, Nonce: nonce, IssuedAt: now.Add(issued), ExpiresAt: now.Add(issued + time.Minute)}
+	}
+
+	c := NewNonceCache(2)
+	if err := c.Check(cmd("a", -3*time.Second), now); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := c.Check(cmd("a", -3*time.Second), now); !errors.Is(err, ErrReplayedCommand) {
+		t.Errorf("replay: err = %v, want ErrReplayedCommand", err)
+	}
+	if err := c.Check(&Command{}, now); err != nil {
+		t.Errorf("v1 command: %v", err)
+	}
+
+	// Filling the cache evicts "a"; commands issued no later than it can no
+	// longer be checked and are refused.
+	c.Check(cmd("b", -2*time.Second), now)
+	c.Check(cmd("c", -time.Second), now)
+	if err := c.Check(cmd("a", -3*time.Second), now); !errors.Is(err, ErrStaleCommand) {
+		t.Errorf("evicted replay: err = %v, want ErrStaleCommand", err)
+	}
+	if err := c.Check(cmd("d", 0), now); err != nil {
+		t.Errorf("newer command after eviction: %v", err)
+	}
+
+	// Expired nonces are forgotten without raising the floor.
+	later := now.Add(2 * time.Minute)
+	if err := c.Check(&Command{SigVersion: SignatureV2, Nonce: "e", IssuedAt: now.Add(-time.Second), ExpiresAt: later.Add(time.Minute)}, later); err != nil {
+		t.Errorf("after expiry: %v", err)
+	}
+	if len(c.seen) != 1 {
+		t.Errorf("cache holds %d nonces after expiry, want 1", len(c.seen))
+	}
+}
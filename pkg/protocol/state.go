@@ -0,0 +1,20 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StateEntry is one key of a workflow's persistent state (sekia.state).
+type StateEntry struct {
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	Revision uint64          `json:"revision"`
+	Updated  time.Time       `json:"updated"`
+}
+
+// StateResponse is returned by GET /api/v1/state/<workflow>.
+type StateResponse struct {
+	Workflow string       `json:"workflow"`
+	Entries  []StateEntry `json:"entries"`
+}
@@ -1,6 +1,9 @@
 package protocol
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // NATS subject constants and helpers.
 const (
@@ -8,6 +11,24 @@ const (
 	SubjectConfigReload = "sekia.config.reload"
 )
 
+// JetStream stream names.
+const (
+	// StreamEvents is the durable event log covering sekia.events.> and sekia.results.>.
+	StreamEvents = "SEKIA_EVENTS"
+	// StreamCommands is the work queue holding undelivered commands for every agent.
+	StreamCommands = "SEKIA_COMMANDS"
+	// StreamDLQ holds dead letters: failed workflow events and agent commands.
+	StreamDLQ = "SEKIA_DLQ"
+)
+
+// BucketState is the JetStream KeyValue bucket holding persistent workflow
+// state (sekia.state in Lua).
+const BucketState = "SEKIA_STATE"
+
+// BucketTimers is the JetStream KeyValue bucket holding pending delayed
+// events (sekia.after and sekia.at in Lua).
+const BucketTimers = "SEKIA_TIMERS"
+
 // SubjectConfigReloadAgent returns the subject for a specific agent's config reload.
 func SubjectConfigReloadAgent(agentName string) string {
 	return fmt.Sprintf("sekia.config.reload.%s", agentName)
@@ -17,10 +38,121 @@ func SubjectEvents(source string) string {
 	return fmt.Sprintf("sekia.events.%s", source)
 }
 
+// Event subject schemes, selected with events.subjects in the daemon's and
+// each agent's config.
+const (
+	// SubjectsFlat publishes every event of a source on sekia.events.<source>.
+	SubjectsFlat = "flat"
+	// SubjectsHierarchical appends the event type to the subject, e.g.
+	// sekia.events.github.issue.opened, so NATS does the type routing.
+	SubjectsHierarchical = "hierarchical"
+	// SubjectsCompat publishes hierarchical subjects, and the workflow engine
+	// lets flat handler patterns such as sekia.events.github keep matching.
+	SubjectsCompat = "compat"
+)
+
+// ValidateSubjectScheme checks an events.subjects setting. Empty means flat.
+func ValidateSubjectScheme(scheme string) error {
+	switch scheme {
+	case "", SubjectsFlat, SubjectsHierarchical, SubjectsCompat:
+		return nil
+	}
+	return fmt.Errorf("events.subjects must be %q, %q or %q, got %q", SubjectsFlat, SubjectsHierarchical, SubjectsCompat, scheme)
+}
+
+// SubjectEventsTyped returns the hierarchical subject for an event:
+// sekia.events.<source>.<type tokens>. A type starting with the source is
+// not repeated, so ("github", "github.issue.opened") gives
+// sekia.events.github.issue.opened. Characters NATS does not allow in a
+// token are replaced with "_".
+func SubjectEventsTyped(source, eventType string) string {
+	eventType = strings.TrimPrefix(eventType, source+".")
+	if eventType == "" || eventType == source {
+		return SubjectEvents(source)
+	}
+	tokens := strings.Split(eventType, ".")
+	for i, t := range tokens {
+		tokens[i] = subjectToken(t)
+	}
+	return SubjectEvents(source) + "." + strings.Join(tokens, ".")
+}
+
+// SubjectEventsFor returns the subject an event from source is published
+// on under the given scheme.
+func SubjectEventsFor(scheme, source, eventType string) string {
+	if scheme == "" || scheme == SubjectsFlat {
+		return SubjectEvents(source)
+	}
+	return SubjectEventsTyped(source, eventType)
+}
+
+// ExpandEventSubject applies the scheme to an explicit publish subject: a
+// flat sekia.events.<source> subject gets the event type appended under the
+// hierarchical and compat schemes. Any other subject is returned unchanged.
+func ExpandEventSubject(scheme, subject, eventType string) string {
+	source, ok := strings.CutPrefix(subject, "sekia.events.")
+	if !ok || source == "" || strings.ContainsAny(source, ".*> ") {
+		return subject
+	}
+	return SubjectEventsFor(scheme, source, eventType)
+}
+
+// SourceToken turns an arbitrary event source, such as a CloudEvents source
+// URI, into the <source> token of sekia.events.<source>: the URI scheme is
+// dropped and every character other than letters, digits, '-' and '_'
+// becomes '_'. "https://ci.example.com/builds" gives "ci_example_com_builds".
+func SourceToken(source string) string {
+	if i := strings.Index(source, "://"); i >= 0 {
+		source = source[i+3:]
+	}
+	token := strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, source), "_")
+	if token == "" {
+		return "unknown"
+	}
+	return token
+}
+
+// subjectToken makes s usable as a single NATS subject token.
+func subjectToken(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '*' || r == '>' || r <= ' ' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
 func SubjectCommands(agentName string) string {
 	return fmt.Sprintf("sekia.commands.%s", agentName)
 }
 
+// SubjectCommandsSync returns the request/reply subject for synchronous
+// commands. It sits outside the command work queue so the agent, not
+// JetStream, answers the request.
+func SubjectCommandsSync(agentName string) string {
+	return fmt.Sprintf("sekia.commands.%s.sync", agentName)
+}
+
+// SubjectResults returns the subject on which an agent reports command outcomes.
+func SubjectResults(agentName string) string {
+	return fmt.Sprintf("sekia.results.%s", agentName)
+}
+
 func SubjectHeartbeat(agentName string) string {
 	return fmt.Sprintf("sekia.heartbeat.%s", agentName)
 }
+
+// SubjectInbox returns the reply inbox prefix of an agent's NATS
+// connection, so that agent credentials can allow it to receive replies on
+// its own inboxes only.
+func SubjectInbox(agentName string) string {
+	return "_INBOX." + agentName
+}
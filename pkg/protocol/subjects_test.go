@@ -0,0 +1,80 @@
+package protocol
+
+import "testing"
+
+func TestSubjectEventsTyped(t *testing.T) {
+	tests := []struct {
+		source, eventType, want string
+	}{
+		{"github", "github.issue.opened", "sekia.events.github.issue.opened"},
+		{"github", "issue.opened", "sekia.events.github.issue.opened"},
+		{"google", "gmail.message.received", "sekia.events.google.gmail.message.received"},
+		{"sentinel", "sentinel", "sekia.events.sentinel"},
+		{"slack", "", "sekia.events.slack"},
+		{"mcp", "deploy finished", "sekia.events.mcp.deploy_finished"},
+		{"mcp", "a..b*", "sekia.events.mcp.a._.b_"},
+		{"mcp", "x.>", "sekia.events.mcp.x._"},
+	}
+	for _, tt := range tests {
+		if got := SubjectEventsTyped(tt.source, tt.eventType); got != tt.want {
+			t.Errorf("SubjectEventsTyped(%q, %q) = %q, want %q", tt.source, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestSubjectEventsFor(t *testing.T) {
+	for _, scheme := range []string{"", SubjectsFlat} {
+		if got := SubjectEventsFor(scheme, "github", "github.pr.opened"); got != "sekia.events.github" {
+			t.Errorf("scheme %q: got %q, want sekia.events.github", scheme, got)
+		}
+	}
+	for _, scheme := range []string{SubjectsHierarchical, SubjectsCompat} {
+		if got := SubjectEventsFor(scheme, "github", "github.pr.opened"); got != "sekia.events.github.pr.opened" {
+			t.Errorf("scheme %q: got %q, want sekia.events.github.pr.opened", scheme, got)
+		}
+	}
+}
+
+func TestExpandEventSubject(t *testing.T) {
+	tests := []struct {
+		scheme, subject, want string
+	}{
+		{SubjectsFlat, "sekia.events.digest", "sekia.events.digest"},
+		{SubjectsHierarchical, "sekia.events.digest", "sekia.events.digest.daily.ready"},
+		{SubjectsCompat, "sekia.events.digest", "sekia.events.digest.daily.ready"},
+		{SubjectsHierarchical, "sekia.events.digest.custom", "sekia.events.digest.custom"},
+		{SubjectsHierarchical, "sekia.events.*", "sekia.events.*"},
+		{SubjectsHierarchical, "alerts.ops", "alerts.ops"},
+	}
+	for _, tt := range tests {
+		if got := ExpandEventSubject(tt.scheme, tt.subject, "daily.ready"); got != tt.want {
+			t.Errorf("ExpandEventSubject(%q, %q) = %q, want %q", tt.scheme, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSubjectScheme(t *testing.T) {
+	for _, s := range []string{"", SubjectsFlat, SubjectsHierarchical, SubjectsCompat} {
+		if err := ValidateSubjectScheme(s); err != nil {
+			t.Errorf("ValidateSubjectScheme(%q): %v", s, err)
+		}
+	}
+	if err := ValidateSubjectScheme("nested"); err == nil {
+		t.Error("expected error for unknown scheme")
+	}
+}
+
+func TestSourceToken(t *testing.T) {
+	tests := []struct{ source, want string }{
+		{"github", "github"},
+		{"https://ci.example.com/builds", "ci_example_com_builds"},
+		{"/sensors/tn-1234567", "sensors_tn-1234567"},
+		{"urn:event:from:myapi", "urn_event_from_myapi"},
+		{"//", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := SourceToken(tt.source); got != tt.want {
+			t.Errorf("SourceToken(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package protocol
+
+import "time"
+
+// Timer is a delayed event publish scheduled by sekia.after or sekia.at.
+type Timer struct {
+	ID        string         `json:"id"`
+	Workflow  string         `json:"workflow"`
+	Subject   string         `json:"subject"`
+	EventType string         `json:"event_type"`
+	Payload   map[string]any `json:"payload"`
+	FireAt    time.Time      `json:"fire_at"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// TimersResponse is returned by GET /api/v1/timers.
+type TimersResponse struct {
+	Timers []Timer `json:"timers"`
+}